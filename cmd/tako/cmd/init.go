@@ -70,6 +70,18 @@ func init() {
 
 	flags.BoolP("skaffold", "s", false, "prepare the project for Skaffold")
 
+	flags.Bool(
+		"fail-on-secrets",
+		false,
+		"Exit with code 2 when secrets are detected in compose sources. Default: false",
+	)
+
+	flags.String(
+		"secrets-report",
+		"",
+		"Write detected secrets to a report file. SARIF 2.1.0 for .sarif files, JSON otherwise",
+	)
+
 	rootCmd.AddCommand(initCmd)
 }
 
@@ -78,6 +90,8 @@ func runInitCmd(cmd *cobra.Command, _ []string) error {
 	envs, _ := cmd.Flags().GetStringSlice("environment")
 	skaffold, _ := cmd.Flags().GetBool("skaffold")
 	verbose, _ := cmd.Root().Flags().GetBool("verbose")
+	failOnSecrets, _ := cmd.Flags().GetBool("fail-on-secrets")
+	secretsReport, _ := cmd.Flags().GetString("secrets-report")
 
 	// The working directory is always the current directory.
 	// This ensures created manifest yaml entries are portable between users and require no path fixing.
@@ -88,5 +102,7 @@ func runInitCmd(cmd *cobra.Command, _ []string) error {
 		tako.WithEnvs(envs),
 		tako.WithSkaffold(skaffold),
 		tako.WithLogVerbose(verbose),
+		tako.WithFailOnSecrets(failOnSecrets),
+		tako.WithSecretsReport(secretsReport),
 	)
 }
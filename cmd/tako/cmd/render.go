@@ -29,7 +29,22 @@ Examples:
   $ tako render
 
   ### Render an app Kubernetes manifests (default) for a specific environment(s)
-  $ tako render -e staging [-e production ...]`
+  $ tako render -e staging [-e production ...]
+
+  ### Render an app Helm chart with values file for each environment
+  $ tako render -f helm
+
+  ### Render an app Kustomize base with overlay for each environment
+  $ tako render -f kustomize
+
+  ### Render an app Knative Serving manifests, with HTTP services as Knative Services
+  $ tako render -f knative
+
+  ### Render an app Kubernetes manifests as JSON files
+  $ tako render -o json
+
+  ### Print a specific environment Kubernetes manifests to stdout and apply them
+  $ tako render -e staging --stdout | kubectl apply -f -`
 
 var renderCmd = &cobra.Command{
 	Use:   "render",
@@ -46,7 +61,7 @@ func init() {
 		"format",
 		"f",
 		"kubernetes", // default: native kubernetes manifests
-		"Deployment files format. Default: Kubernetes manifests.",
+		"Deployment files format: kubernetes, helm, kustomize or knative, used by all rendered environments. Default: Kubernetes manifests.",
 	)
 
 	flags.BoolP(
@@ -56,6 +71,19 @@ func init() {
 		"Controls whether to produce individual manifests or a single file output. Default: false",
 	)
 
+	flags.StringP(
+		"output",
+		"o",
+		"yaml", // default: yaml
+		"Manifests serialisation format: yaml or json. JSON is only supported by Kubernetes and Knative manifests. Default: yaml",
+	)
+
+	flags.Bool(
+		"stdout",
+		false,
+		"Print Kubernetes or Knative manifests of a single environment to stdout instead of writing them to disk, as a multi-document YAML stream or a JSON List. Nothing is printed when secrets detection fails the render. Default: false",
+	)
+
 	flags.StringP(
 		"dir",
 		"d",
@@ -77,16 +105,32 @@ func init() {
 		"Additional Kubernetes manifests to be included in the output",
 	)
 
+	flags.Bool(
+		"fail-on-secrets",
+		false,
+		"Exit with code 2 when secrets are detected in compose sources or rendered manifests. Default: false",
+	)
+
+	flags.String(
+		"secrets-report",
+		"",
+		"Write detected secrets to a report file. SARIF 2.1.0 for .sarif files, JSON otherwise",
+	)
+
 	rootCmd.AddCommand(renderCmd)
 }
 
 func runRenderCmd(cmd *cobra.Command, _ []string) error {
 	format, _ := cmd.Flags().GetString("format")
 	singleFile, _ := cmd.Flags().GetBool("single")
+	output, _ := cmd.Flags().GetString("output")
+	stdout, _ := cmd.Flags().GetBool("stdout")
 	dir, _ := cmd.Flags().GetString("dir")
 	envs, _ := cmd.Flags().GetStringSlice("environment")
 	verbose, _ := cmd.Root().Flags().GetBool("verbose")
 	additionalManifests, _ := cmd.Flags().GetStringSlice("additional-manifests")
+	failOnSecrets, _ := cmd.Flags().GetBool("fail-on-secrets")
+	secretsReport, _ := cmd.Flags().GetString("secrets-report")
 
 	// The working directory is always the current directory.
 	// This ensures created manifest yaml entries are portable between users and require no path fixing.
@@ -96,9 +140,13 @@ func runRenderCmd(cmd *cobra.Command, _ []string) error {
 		tako.WithAppName(rootCmd.Use),
 		tako.WithManifestFormat(format),
 		tako.WithManifestsAsSingleFile(singleFile),
+		tako.WithOutputFormat(output),
+		tako.WithManifestsToStdout(stdout),
 		tako.WithAdditionalManifests(additionalManifests),
 		tako.WithOutputDir(dir),
 		tako.WithEnvs(envs),
 		tako.WithLogVerbose(verbose),
+		tako.WithFailOnSecrets(failOnSecrets),
+		tako.WithSecretsReport(secretsReport),
 	)
 }
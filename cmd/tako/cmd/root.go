@@ -20,6 +20,7 @@ import (
 	"errors"
 	"os"
 
+	"github.com/appvia/tako/pkg/tako"
 	"github.com/appvia/tako/pkg/tako/config"
 	"github.com/spf13/cobra"
 )
@@ -60,6 +61,9 @@ func init() {
 // Execute command
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
+		if errors.Is(err, tako.ErrSecretsDetected) {
+			os.Exit(2)
+		}
 		os.Exit(1)
 	}
 }
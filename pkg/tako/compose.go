@@ -22,6 +22,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/appvia/tako/pkg/tako/config"
 	"github.com/appvia/tako/pkg/tako/log"
 	"github.com/compose-spec/compose-go/cli"
 	composego "github.com/compose-spec/compose-go/types"
@@ -49,7 +50,13 @@ type ComposeOpts func(project *ComposeProject) (*ComposeProject, error)
 
 // NewComposeProject loads and parses a set of input compose files and returns a ComposeProject object
 func NewComposeProject(paths []string, opts ...ComposeOpts) (*ComposeProject, error) {
-	raw, err := rawProjectFromSources(paths)
+	return newComposeProject(paths, false, opts...)
+}
+
+// newComposeProject loads and parses a set of input compose files and returns a ComposeProject object.
+// Service env_file references are only kept when keepEnvFiles is set.
+func newComposeProject(paths []string, keepEnvFiles bool, opts ...ComposeOpts) (*ComposeProject, error) {
+	raw, err := rawProjectFromSources(paths, keepEnvFiles)
 	if err != nil {
 		return nil, err
 	}
@@ -92,8 +99,14 @@ func (p *ComposeProject) GetVersion() string {
 }
 
 // rawProjectFromSources loads and parses a compose-go project from multiple docker-compose source files.
-func rawProjectFromSources(paths []string) (*composego.Project, error) {
-	projectOptions, err := cli.NewProjectOptions(paths, cli.WithOsEnv, cli.WithDotEnv, cli.WithDiscardEnvFile)
+// Service env_file references are discarded once resolved into the service environment, unless keepEnvFiles is set.
+func rawProjectFromSources(paths []string, keepEnvFiles bool) (*composego.Project, error) {
+	opts := []cli.ProjectOptionsFn{cli.WithOsEnv, cli.WithDotEnv}
+	if !keepEnvFiles {
+		opts = append(opts, cli.WithDiscardEnvFile)
+	}
+
+	projectOptions, err := cli.NewProjectOptions(paths, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -101,6 +114,30 @@ func rawProjectFromSources(paths []string) (*composego.Project, error) {
 	return cli.ProjectFromOptions(projectOptions)
 }
 
+// discardEnvFiles discards env_file references of services not loading env vars via envFrom.
+// Env file contents are already resolved into the service environment.
+func (p *ComposeProject) discardEnvFiles() error {
+	for i, svc := range p.Services {
+		if len(svc.EnvFile) == 0 {
+			continue
+		}
+
+		if _, ok := svc.Extensions[config.K8SExtensionKey]; ok {
+			cfg, err := config.ParseSvcK8sConfigFromMap(svc.Extensions, config.SkipValidation())
+			if err != nil {
+				return err
+			}
+			if cfg.Workload.EnvFrom {
+				continue
+			}
+		}
+
+		p.Services[i].EnvFile = nil
+	}
+
+	return nil
+}
+
 // getComposeVersion extracts version from compose file and returns a string
 func getComposeVersion(file string) (string, error) {
 	version := struct {
@@ -16,6 +16,8 @@
 
 package config
 
+import "time"
+
 const (
 	// DefaultVolumeSize default value PV class
 	DefaultVolumeSize = "1Gi"
@@ -96,6 +98,18 @@ const (
 
 	// DefaultProbeDisable default false. Enabled by default
 	DefaultProbeDisable = false
+
+	// DefaultClusterDomain default k8s cluster DNS domain
+	DefaultClusterDomain = "cluster.local"
+
+	// DefaultSecretStoreKind default External Secrets Operator secret store kind
+	DefaultSecretStoreKind = "SecretStore"
+
+	// DefaultExternalSecretRefreshInterval default 1h. Defines how often External Secrets Operator syncs a secret
+	DefaultExternalSecretRefreshInterval = time.Hour
+
+	// DefaultDownwardAPIPath default mount path of the downward API volume exposing pod labels and annotations
+	DefaultDownwardAPIPath = "/etc/podinfo"
 )
 
 var (
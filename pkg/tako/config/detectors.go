@@ -0,0 +1,148 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// SecretDetector detects a potential secret in an env var
+type SecretDetector interface {
+	// Detect returns true when env var name or value looks like a secret
+	Detect(name, value string) bool
+	// Describe returns a description of the detected secret
+	Describe() string
+}
+
+// regexDetector detects secrets by matching a regular expression against env var name or value
+type regexDetector struct {
+	part        string
+	match       *regexp.Regexp
+	description string
+}
+
+func (d regexDetector) Detect(name, value string) bool {
+	if d.part == PartIdentifier {
+		return d.match.MatchString(name)
+	}
+	return value != "" && d.match.MatchString(value)
+}
+
+func (d regexDetector) Describe() string {
+	return d.description
+}
+
+// entropyDetector detects secrets by measuring Shannon entropy of env var value
+type entropyDetector struct {
+	threshold   float64
+	minLength   int
+	description string
+}
+
+func (d entropyDetector) Detect(_, value string) bool {
+	return len(value) >= d.minLength && shannonEntropy(value) >= d.threshold
+}
+
+func (d entropyDetector) Describe() string {
+	return d.description
+}
+
+// shannonEntropy calculates Shannon entropy of a string in bits per character
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	freq := map[rune]float64{}
+	var total float64
+	for _, r := range s {
+		freq[r]++
+		total++
+	}
+
+	var entropy float64
+	for _, count := range freq {
+		p := count / total
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
+
+// NewSecretDetector creates a secret detector from a matcher definition
+func NewSecretDetector(matcher map[string]string) (SecretDetector, error) {
+	description := matcher["description"]
+
+	switch matcher["type"] {
+	case "", MatcherTypeRegex, MatcherTypeToken:
+		part := matcher["part"]
+		if matcher["type"] == MatcherTypeToken {
+			part = PartValue
+		}
+		if part != PartIdentifier && part != PartValue {
+			return nil, errors.Errorf("secret matcher %q has unsupported part %q, use %s or %s",
+				description, part, PartIdentifier, PartValue)
+		}
+
+		re, err := regexp.Compile(matcher["match"])
+		if err != nil {
+			return nil, errors.Wrapf(err, "secret matcher %q has invalid match expression", description)
+		}
+
+		return regexDetector{part: part, match: re, description: description}, nil
+
+	case MatcherTypeEntropy:
+		threshold, minLength := DefaultSecretEntropyThreshold, DefaultSecretEntropyMinLength
+		if v, ok := matcher["threshold"]; ok {
+			threshold = v
+		}
+		if v, ok := matcher["minLength"]; ok {
+			minLength = v
+		}
+
+		t, err := strconv.ParseFloat(threshold, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "secret matcher %q has invalid entropy threshold", description)
+		}
+		l, err := strconv.Atoi(minLength)
+		if err != nil {
+			return nil, errors.Wrapf(err, "secret matcher %q has invalid minimum length", description)
+		}
+
+		return entropyDetector{threshold: t, minLength: l, description: description}, nil
+
+	default:
+		return nil, errors.Errorf("secret matcher %q has unsupported type %q", description, matcher["type"])
+	}
+}
+
+// NewSecretDetectors creates secret detectors from matcher definitions preserving their order
+func NewSecretDetectors(matchers []map[string]string) ([]SecretDetector, error) {
+	var detectors []SecretDetector
+	for _, m := range matchers {
+		d, err := NewSecretDetector(m)
+		if err != nil {
+			return nil, err
+		}
+		detectors = append(detectors, d)
+	}
+	return detectors, nil
+}
@@ -0,0 +1,39 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import "time"
+
+// Knative holds the Knative Serving configuration of a service.
+// It only takes effect when manifests are rendered in the knative format.
+type Knative struct {
+	Enabled     *bool         `yaml:"enabled,omitempty"`
+	Concurrency int           `yaml:"concurrency,omitempty" validate:"min=0"`
+	MinScale    int           `yaml:"minScale,omitempty" validate:"min=0"`
+	MaxScale    int           `yaml:"maxScale,omitempty" validate:"omitempty,gtefield=MinScale"`
+	Timeout     time.Duration `yaml:"timeout,omitempty" validate:"min=0"`
+}
+
+// Explicit tells whether rendering the service as a Knative Service has been explicitly enabled or disabled
+func (kn Knative) Explicit() bool {
+	return kn.Enabled != nil
+}
+
+// Disabled tells whether rendering the service as a Knative Service has been explicitly disabled
+func (kn Knative) Disabled() bool {
+	return kn.Enabled != nil && !*kn.Enabled
+}
@@ -0,0 +1,104 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type MeshType string
+
+const (
+	// NoMesh default value
+	NoMesh MeshType = ""
+
+	// IstioMesh mesh type
+	IstioMesh MeshType = "istio"
+
+	// LinkerdMesh mesh type
+	LinkerdMesh MeshType = "linkerd"
+)
+
+// String converts a mesh type to a string value
+func (m MeshType) String() string {
+	return string(m)
+}
+
+// meshTypes are the only mesh type settings
+var meshTypes = map[MeshType]bool{
+	NoMesh:      true,
+	IstioMesh:   true,
+	LinkerdMesh: true,
+}
+
+// MeshTypeFromValue returns a Mesh Type for a given case insensitive value.
+// Returns a blank string and false for unknown values.
+func MeshTypeFromValue(s string) (MeshType, bool) {
+	for k, v := range meshTypes {
+		if strings.ToLower(k.String()) == strings.ToLower(s) {
+			return k, v
+		}
+	}
+	return "", false
+}
+
+// MeshTypesEqual checks if the supplied MeshTypes are equal
+func MeshTypesEqual(s, t MeshType) bool {
+	return strings.ToLower(s.String()) == strings.ToLower(t.String())
+}
+
+// validateMeshType validator to validate a mesh type
+func validateMeshType(fl validator.FieldLevel) bool {
+	_, valid := MeshTypeFromValue(fl.Field().String())
+	return valid
+}
+
+// ServiceMesh holds the service mesh traffic policy configuration for a service.
+// It only takes effect when the environment has a mesh enabled.
+type ServiceMesh struct {
+	Inject  *bool         `yaml:"inject,omitempty"`
+	Retries MeshRetries   `yaml:"retries,omitempty"`
+	Timeout time.Duration `yaml:"timeout,omitempty" validate:"min=0"`
+	MTLS    string        `yaml:"mtls,omitempty" validate:"oneof='' STRICT PERMISSIVE DISABLE"`
+	Canary  MeshCanary    `yaml:"canary,omitempty"`
+}
+
+// MeshRetries holds the mesh retry policy for requests to a service.
+type MeshRetries struct {
+	Attempts      int           `yaml:"attempts,omitempty" validate:"min=0"`
+	PerTryTimeout time.Duration `yaml:"perTryTimeout,omitempty" validate:"min=0"`
+	RetryOn       string        `yaml:"retryOn,omitempty"`
+}
+
+// MeshCanary holds a weighted traffic split between a service and its canary service.
+type MeshCanary struct {
+	Service string `yaml:"service,omitempty" validate:"required_with=Weight,subdomainIfAny"`
+	Weight  int    `yaml:"weight,omitempty" validate:"min=0,max=100"`
+}
+
+// InjectSidecar tells whether the mesh sidecar should be injected into the service pods
+func (sm ServiceMesh) InjectSidecar() bool {
+	return sm.Inject == nil || *sm.Inject
+}
+
+// HasTrafficPolicy tells whether any traffic policy has been configured for the service
+func (sm ServiceMesh) HasTrafficPolicy() bool {
+	return sm.Retries.Attempts > 0 || sm.Timeout > 0 || sm.MTLS != "" || sm.Canary.Weight > 0
+}
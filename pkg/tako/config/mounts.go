@@ -0,0 +1,125 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// BindMountStrategy defines how compose bind mounts, e.g. `./src:/app`, get converted
+type BindMountStrategy string
+
+const (
+	// NoBindMountStrategy default value. Bind mounts are claimed as PVCs
+	NoBindMountStrategy BindMountStrategy = ""
+
+	// PVCBindMount claims bind mounts as PVCs. Host content isn't copied
+	PVCBindMount BindMountStrategy = "pvc"
+
+	// HostPathBindMount mounts the host path from the cluster node
+	HostPathBindMount BindMountStrategy = "hostPath"
+
+	// EmptyDirBindMount mounts an empty directory
+	EmptyDirBindMount BindMountStrategy = "emptyDir"
+
+	// ConfigMapBindMount mounts host file or directory content from a ConfigMap
+	ConfigMapBindMount BindMountStrategy = "configMap"
+
+	// NoneBindMount drops bind mounts, e.g. when content is already baked into the image
+	NoneBindMount BindMountStrategy = "none"
+)
+
+// String converts a bind mount strategy to a string value
+func (b BindMountStrategy) String() string {
+	return string(b)
+}
+
+// bindMountStrategies are the only bind mount strategy settings
+var bindMountStrategies = map[BindMountStrategy]bool{
+	NoBindMountStrategy: true,
+	PVCBindMount:        true,
+	HostPathBindMount:   true,
+	EmptyDirBindMount:   true,
+	ConfigMapBindMount:  true,
+	NoneBindMount:       true,
+}
+
+// BindMountStrategyFromValue returns a Bind Mount Strategy for a given case insensitive value.
+// Returns a blank string and false for unknown values.
+func BindMountStrategyFromValue(s string) (BindMountStrategy, bool) {
+	for k, v := range bindMountStrategies {
+		if strings.ToLower(k.String()) == strings.ToLower(s) {
+			return k, v
+		}
+	}
+	return "", false
+}
+
+// validateBindMountStrategy validator to validate a bind mount strategy
+func validateBindMountStrategy(fl validator.FieldLevel) bool {
+	_, valid := BindMountStrategyFromValue(fl.Field().String())
+	return valid
+}
+
+// SharedVolumesCheck defines how ReadWriteOnce volume claims shared by multiple pods are reported
+type SharedVolumesCheck string
+
+const (
+	// NoSharedVolumesCheck default value. Shared ReadWriteOnce claims are reported as warnings
+	NoSharedVolumesCheck SharedVolumesCheck = ""
+
+	// WarnSharedVolumes reports shared ReadWriteOnce claims as warnings
+	WarnSharedVolumes SharedVolumesCheck = "warn"
+
+	// FailSharedVolumes fails the render when ReadWriteOnce claims are shared
+	FailSharedVolumes SharedVolumesCheck = "fail"
+
+	// IgnoreSharedVolumes doesn't check shared ReadWriteOnce claims, e.g. on single node clusters
+	IgnoreSharedVolumes SharedVolumesCheck = "ignore"
+)
+
+// String converts a shared volumes check to a string value
+func (s SharedVolumesCheck) String() string {
+	return string(s)
+}
+
+// sharedVolumesChecks are the only shared volumes check settings
+var sharedVolumesChecks = map[SharedVolumesCheck]bool{
+	NoSharedVolumesCheck: true,
+	WarnSharedVolumes:    true,
+	FailSharedVolumes:    true,
+	IgnoreSharedVolumes:  true,
+}
+
+// SharedVolumesCheckFromValue returns a Shared Volumes Check for a given case insensitive value.
+// Returns a blank string and false for unknown values.
+func SharedVolumesCheckFromValue(s string) (SharedVolumesCheck, bool) {
+	for k, v := range sharedVolumesChecks {
+		if strings.ToLower(k.String()) == strings.ToLower(s) {
+			return k, v
+		}
+	}
+	return "", false
+}
+
+// validateSharedVolumesCheck validator to validate a shared volumes check
+func validateSharedVolumesCheck(fl validator.FieldLevel) bool {
+	_, valid := SharedVolumesCheckFromValue(fl.Field().String())
+	return valid
+}
@@ -0,0 +1,149 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	composego "github.com/compose-spec/compose-go/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/imdario/mergo"
+	"gopkg.in/yaml.v3"
+)
+
+// FileObjectExtension represents the root of the docker-compose extensions for a secret or config
+type FileObjectExtension struct {
+	K8S FileObjK8sConfig `yaml:"x-k8s"`
+}
+
+// FileObjK8sConfig represents the root of the k8s specific fields supported by tako for secrets and configs.
+type FileObjK8sConfig struct {
+	Name string `yaml:"name,omitempty" validate:"omitempty,dns_rfc1035_label"`
+	// Environment is the name of an env var holding the secret or config content
+	Environment string `yaml:"environment,omitempty"`
+	// Content is the inline secret or config content
+	Content        string         `yaml:"content,omitempty"`
+	ExternalSecret ExternalSecret `yaml:"externalSecret,omitempty"`
+}
+
+// ExternalSecret holds External Secrets Operator settings of an external compose secret.
+type ExternalSecret struct {
+	// Store overrides the environment default secret store
+	Store SecretStoreRef `yaml:"store,omitempty"`
+	// Key is the secret key in the remote store. Defaults to the external secret name.
+	Key string `yaml:"key,omitempty"`
+	// Property is the property of the remote secret to fetch, e.g. a JSON field
+	Property        string        `yaml:"property,omitempty"`
+	RefreshInterval time.Duration `yaml:"refreshInterval,omitempty" validate:"min=0"`
+}
+
+// SecretStoreRef references an External Secrets Operator SecretStore or ClusterSecretStore.
+type SecretStoreRef struct {
+	Name string `yaml:"name,omitempty" validate:"omitempty,dns_rfc1035_label"`
+	Kind string `yaml:"kind,omitempty" validate:"oneof='' SecretStore ClusterSecretStore"`
+}
+
+// StoreKind returns the kind of the referenced secret store, SecretStore by default
+func (s SecretStoreRef) StoreKind() string {
+	if s.Kind == "" {
+		return DefaultSecretStoreKind
+	}
+	return s.Kind
+}
+
+// Map converts a FileObjK8sConfig config into a map
+func (fkc FileObjK8sConfig) Map() (map[string]interface{}, error) {
+	bs, err := yaml.Marshal(fkc)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	return m, yaml.Unmarshal(bs, &m)
+}
+
+// Merge merges in a src secret's or config's K8s config
+func (fkc FileObjK8sConfig) Merge(src FileObjK8sConfig) (FileObjK8sConfig, error) {
+	if err := mergo.Merge(&fkc, src, mergo.WithOverride); err != nil {
+		return FileObjK8sConfig{}, err
+	}
+	return fkc, nil
+}
+
+// Validate validates a secret's or config's K8s config
+func (fkc FileObjK8sConfig) Validate() error {
+	validate := validator.New()
+
+	if err := validate.Struct(fkc); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		for _, e := range validationErrors {
+			if e.Tag() == "required" {
+				return fmt.Errorf("%s is required", e.StructNamespace())
+			}
+		}
+		return errors.New(validationErrors[0].Error())
+	}
+
+	if fkc.Environment != "" && fkc.Content != "" {
+		return errors.New("FileObjK8sConfig.Environment and FileObjK8sConfig.Content can't be used together")
+	}
+
+	return nil
+}
+
+// FileObjK8sConfigFromCompose returns a FileObjK8sConfig from a compose-go secret or config object
+func FileObjK8sConfigFromCompose(obj *composego.FileObjectConfig) (FileObjK8sConfig, error) {
+	if _, ok := obj.Extensions[K8SExtensionKey]; !ok {
+		return FileObjK8sConfig{}, nil
+	}
+
+	return ParseFileObjK8sConfigFromMap(obj.Extensions)
+}
+
+// ParseFileObjK8sConfigFromMap parses a secret or config extension from the related map
+func ParseFileObjK8sConfigFromMap(m map[string]interface{}, opts ...K8sExtensionOption) (FileObjK8sConfig, error) {
+	var options extensionOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if _, ok := m[K8SExtensionKey]; !ok {
+		return FileObjK8sConfig{}, fmt.Errorf("missing %s extension", K8SExtensionKey)
+	}
+
+	var ext FileObjectExtension
+
+	var buf bytes.Buffer
+	if err := yaml.NewEncoder(&buf).Encode(m); err != nil {
+		return FileObjK8sConfig{}, err
+	}
+
+	if err := yaml.NewDecoder(&buf).Decode(&ext); err != nil {
+		return FileObjK8sConfig{}, err
+	}
+
+	if !options.skipValidation {
+		if err := ext.K8S.Validate(); err != nil {
+			return FileObjK8sConfig{}, err
+		}
+	}
+
+	return ext.K8S, nil
+}
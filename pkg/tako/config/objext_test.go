@@ -0,0 +1,127 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config_test
+
+import (
+	"time"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("File Object Extension", func() {
+	var obj composego.FileObjectConfig
+
+	BeforeEach(func() {
+		obj = composego.FileObjectConfig{}
+	})
+
+	Context("load", func() {
+		It("returns empty config when extension isn't present", func() {
+			cfg, err := config.FileObjK8sConfigFromCompose(&obj)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg).To(Equal(config.FileObjK8sConfig{}))
+		})
+
+		It("loads the extension from a compose-go secret or config", func() {
+			obj.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{"name": "api-key"},
+			}
+
+			cfg, err := config.FileObjK8sConfigFromCompose(&obj)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Name).To(Equal("api-key"))
+		})
+
+		It("validates name", func() {
+			obj.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{"name": "api.key"},
+			}
+
+			_, err := config.FileObjK8sConfigFromCompose(&obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("FileObjK8sConfig.Name"))
+		})
+
+		It("loads external secret settings", func() {
+			obj.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"externalSecret": map[string]interface{}{
+						"store":           map[string]interface{}{"name": "vault", "kind": "ClusterSecretStore"},
+						"key":             "prod/db",
+						"property":        "password",
+						"refreshInterval": "15m",
+					},
+				},
+			}
+
+			cfg, err := config.FileObjK8sConfigFromCompose(&obj)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.ExternalSecret).To(Equal(config.ExternalSecret{
+				Store:           config.SecretStoreRef{Name: "vault", Kind: "ClusterSecretStore"},
+				Key:             "prod/db",
+				Property:        "password",
+				RefreshInterval: 15 * time.Minute,
+			}))
+		})
+
+		It("validates external secret store kind", func() {
+			obj.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"externalSecret": map[string]interface{}{
+						"store": map[string]interface{}{"name": "vault", "kind": "Vault"},
+					},
+				},
+			}
+
+			_, err := config.FileObjK8sConfigFromCompose(&obj)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("FileObjK8sConfig.ExternalSecret.Store.Kind"))
+		})
+
+		It("loads inline content", func() {
+			obj.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{"content": "key: value"},
+			}
+
+			cfg, err := config.FileObjK8sConfigFromCompose(&obj)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Content).To(Equal("key: value"))
+		})
+
+		It("rejects environment and content used together", func() {
+			obj.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"environment": "API_KEY",
+					"content":     "key",
+				},
+			}
+
+			_, err := config.FileObjK8sConfigFromCompose(&obj)
+			Expect(err).To(MatchError("FileObjK8sConfig.Environment and FileObjK8sConfig.Content can't be used together"))
+		})
+	})
+
+	Context("secret store", func() {
+		It("defaults kind to SecretStore", func() {
+			Expect(config.SecretStoreRef{Name: "vault"}.StoreKind()).To(Equal("SecretStore"))
+			Expect(config.SecretStoreRef{Name: "vault", Kind: "ClusterSecretStore"}.StoreKind()).To(Equal("ClusterSecretStore"))
+		})
+	})
+})
@@ -0,0 +1,221 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	composego "github.com/compose-spec/compose-go/types"
+	"github.com/go-playground/validator/v10"
+	"github.com/imdario/mergo"
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectExtension represents the root of the docker-compose top level extensions for a project
+type ProjectExtension struct {
+	K8S ProjK8sConfig `yaml:"x-k8s"`
+}
+
+// ProjK8sConfig represents the root of the project (environment) wide k8s specific fields supported by tako.
+type ProjK8sConfig struct {
+	Mesh      MeshType  `yaml:"mesh,omitempty" validate:"meshType"`
+	Namespace string    `yaml:"namespace,omitempty" validate:"omitempty,dns_rfc1035_label"`
+	Hostnames Hostnames `yaml:"hostnames,omitempty"`
+	Secrets   Secrets   `yaml:"secrets,omitempty"`
+	// ConfigRollout defines how workloads get rolled out on ConfigMap and Secret content changes
+	ConfigRollout ConfigRollout `yaml:"configRollout,omitempty" validate:"configRollout"`
+	// BindMounts defines how compose bind mounts get converted, unless overridden per service mount
+	BindMounts BindMountStrategy `yaml:"bindMounts,omitempty" validate:"bindMountStrategy"`
+	// SharedVolumes defines how ReadWriteOnce claims shared by multiple pods are reported
+	SharedVolumes SharedVolumesCheck `yaml:"sharedVolumes,omitempty" validate:"sharedVolumesCheck"`
+	// StorageClasses created together with the environment, keyed by name
+	StorageClasses map[string]StorageClass `yaml:"storageClasses,omitempty" validate:"dive"`
+}
+
+// Hostnames holds the configuration of cross-service hostname rewriting in environment variable values.
+// Only URLs and `host:port` values are rewritten, unless bare service names rewrite is explicitly enabled.
+type Hostnames struct {
+	Rewrite       bool   `yaml:"rewrite,omitempty"`
+	BareNames     bool   `yaml:"bareNames,omitempty"`
+	ClusterDomain string `yaml:"clusterDomain,omitempty" validate:"subdomainIfAny"`
+}
+
+// Map converts a ProjK8sConfig config into a map
+func (pkc ProjK8sConfig) Map() (map[string]interface{}, error) {
+	bs, err := yaml.Marshal(pkc)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	return m, yaml.Unmarshal(bs, &m)
+}
+
+// Merge merges in a src project's K8s config
+func (pkc ProjK8sConfig) Merge(src ProjK8sConfig) (ProjK8sConfig, error) {
+	if err := mergo.Merge(&pkc, src, mergo.WithOverride); err != nil {
+		return ProjK8sConfig{}, err
+	}
+	return pkc, nil
+}
+
+// Validate validates a project's K8s config
+func (pkc ProjK8sConfig) Validate() error {
+	validate := validator.New()
+
+	if err := validate.RegisterValidation("meshType", validateMeshType); err != nil {
+		return err
+	}
+
+	if err := validate.RegisterValidation("subdomainIfAny", validateDNSSubdomainNameIfAny); err != nil {
+		return err
+	}
+
+	if err := validate.RegisterValidation("secretsOutput", validateSecretsOutput); err != nil {
+		return err
+	}
+
+	if err := validate.RegisterValidation("configRollout", validateConfigRollout); err != nil {
+		return err
+	}
+
+	if err := validate.RegisterValidation("bindMountStrategy", validateBindMountStrategy); err != nil {
+		return err
+	}
+
+	if err := validate.RegisterValidation("sharedVolumesCheck", validateSharedVolumesCheck); err != nil {
+		return err
+	}
+
+	if err := validate.Struct(pkc); err != nil {
+		validationErrors := err.(validator.ValidationErrors)
+		for _, e := range validationErrors {
+			if e.Tag() == "required" {
+				return fmt.Errorf("%s is required", e.StructNamespace())
+			}
+
+			if e.Tag() == "oneof" {
+				return fmt.Errorf("%s is invalid, use one of: %s", e.StructNamespace(), e.Param())
+			}
+		}
+		return errors.New(validationErrors[0].Error())
+	}
+
+	if pkc.Hostnames.Rewrite && pkc.Namespace == "" {
+		return errors.New("ProjK8sConfig.Namespace is required when hostnames rewrite is enabled")
+	}
+
+	for name := range pkc.StorageClasses {
+		if !dnsSubdomainNameRegex.MatchString(name) || len(name) > 253 {
+			return fmt.Errorf("ProjK8sConfig.StorageClasses[%s] name is invalid, use a valid DNS subdomain name", name)
+		}
+	}
+
+	return pkc.validateSecrets()
+}
+
+// validateSecrets validates settings required by the configured secrets output
+func (pkc ProjK8sConfig) validateSecrets() error {
+	output, _ := SecretsOutputFromValue(pkc.Secrets.Output.String())
+
+	switch output {
+	case SealedSecretsOutput:
+		if pkc.Secrets.Sealed.Certificate == "" {
+			return errors.New("ProjK8sConfig.Secrets.Sealed.Certificate is required when secrets output is sealed")
+		}
+		if pkc.Secrets.SealedScope() != SealedSecretsClusterWideScope && pkc.Namespace == "" {
+			return fmt.Errorf("ProjK8sConfig.Namespace is required for %s sealed secrets scope", pkc.Secrets.SealedScope())
+		}
+	case SOPSSecretsOutput:
+		if pkc.Secrets.SOPS.AgeRecipients == "" {
+			return errors.New("ProjK8sConfig.Secrets.SOPS.AgeRecipients is required when secrets output is sops")
+		}
+	}
+
+	return nil
+}
+
+// ServiceDomain returns the cluster domain suffix for services in the configured namespace,
+// e.g. `my-namespace.svc.cluster.local`. Returns an empty string when namespace isn't configured.
+func (pkc ProjK8sConfig) ServiceDomain() string {
+	if pkc.Namespace == "" {
+		return ""
+	}
+
+	clusterDomain := pkc.Hostnames.ClusterDomain
+	if clusterDomain == "" {
+		clusterDomain = DefaultClusterDomain
+	}
+
+	return fmt.Sprintf("%s.svc.%s", pkc.Namespace, clusterDomain)
+}
+
+// ProjK8sConfigFromCompose returns a ProjK8sConfig from a compose-go Project top level extensions
+func ProjK8sConfigFromCompose(p *composego.Project) (ProjK8sConfig, error) {
+	var cfg ProjK8sConfig
+
+	if _, ok := p.Extensions[K8SExtensionKey]; !ok {
+		return cfg, nil
+	}
+
+	cfg, err := ParseProjK8sConfigFromMap(p.Extensions)
+	if err != nil {
+		return ProjK8sConfig{}, err
+	}
+
+	// normalise case insensitive values
+	cfg.Mesh, _ = MeshTypeFromValue(cfg.Mesh.String())
+	cfg.Secrets.Output, _ = SecretsOutputFromValue(cfg.Secrets.Output.String())
+	cfg.ConfigRollout, _ = ConfigRolloutFromValue(cfg.ConfigRollout.String())
+	cfg.BindMounts, _ = BindMountStrategyFromValue(cfg.BindMounts.String())
+	cfg.SharedVolumes, _ = SharedVolumesCheckFromValue(cfg.SharedVolumes.String())
+
+	return cfg, nil
+}
+
+// ParseProjK8sConfigFromMap parses a project extension from the related map
+func ParseProjK8sConfigFromMap(m map[string]interface{}, opts ...K8sExtensionOption) (ProjK8sConfig, error) {
+	var options extensionOptions
+	for _, o := range opts {
+		o(&options)
+	}
+
+	if _, ok := m[K8SExtensionKey]; !ok {
+		return ProjK8sConfig{}, fmt.Errorf("missing %s project extension", K8SExtensionKey)
+	}
+
+	var ext ProjectExtension
+
+	var buf bytes.Buffer
+	if err := yaml.NewEncoder(&buf).Encode(m); err != nil {
+		return ProjK8sConfig{}, err
+	}
+
+	if err := yaml.NewDecoder(&buf).Decode(&ext); err != nil {
+		return ProjK8sConfig{}, err
+	}
+
+	if !options.skipValidation {
+		if err := ext.K8S.Validate(); err != nil {
+			return ProjK8sConfig{}, err
+		}
+	}
+
+	return ext.K8S, nil
+}
@@ -0,0 +1,285 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config_test
+
+import (
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Project Extension", func() {
+	var project composego.Project
+
+	BeforeEach(func() {
+		project = composego.Project{}
+	})
+
+	Context("load", func() {
+		It("returns empty config when the extension isn't present", func() {
+			cfg, err := config.ProjK8sConfigFromCompose(&project)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg).To(Equal(config.ProjK8sConfig{}))
+		})
+
+		It("loads the extension from compose-go project top level extensions", func() {
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"mesh": "Istio",
+				},
+			}
+
+			cfg, err := config.ProjK8sConfigFromCompose(&project)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Mesh).To(Equal(config.IstioMesh))
+		})
+
+		It("validates values", func() {
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"mesh": "consul",
+				},
+			}
+
+			_, err := config.ProjK8sConfigFromCompose(&project)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("ProjK8sConfig.Mesh"))
+		})
+	})
+
+	Context("hostnames", func() {
+		It("requires namespace when rewrite is enabled", func() {
+			cfg := config.ProjK8sConfig{Hostnames: config.Hostnames{Rewrite: true}}
+			Expect(cfg.Validate()).To(MatchError("ProjK8sConfig.Namespace is required when hostnames rewrite is enabled"))
+		})
+
+		It("validates namespace name", func() {
+			cfg := config.ProjK8sConfig{Namespace: "Not_Valid"}
+			err := cfg.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("ProjK8sConfig.Namespace"))
+		})
+
+		It("returns service domain for the namespace", func() {
+			Expect(config.ProjK8sConfig{}.ServiceDomain()).To(BeEmpty())
+			Expect(config.ProjK8sConfig{Namespace: "prod"}.ServiceDomain()).To(Equal("prod.svc.cluster.local"))
+			Expect(config.ProjK8sConfig{
+				Namespace: "prod",
+				Hostnames: config.Hostnames{ClusterDomain: "example.local"},
+			}.ServiceDomain()).To(Equal("prod.svc.example.local"))
+		})
+	})
+
+	Context("config rollout", func() {
+		It("defaults to checksum", func() {
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{},
+			}
+
+			cfg, err := config.ProjK8sConfigFromCompose(&project)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.ConfigRollout).To(Equal(config.ChecksumConfigRollout))
+		})
+
+		It("loads the config rollout case insensitively", func() {
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"configRollout": "HashedName",
+				},
+			}
+
+			cfg, err := config.ProjK8sConfigFromCompose(&project)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.ConfigRollout).To(Equal(config.HashedNameConfigRollout))
+		})
+
+		It("validates config rollout", func() {
+			cfg := config.ProjK8sConfig{ConfigRollout: "restart"}
+			err := cfg.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("ProjK8sConfig.ConfigRollout"))
+		})
+	})
+
+	Context("bind mounts", func() {
+		It("loads the bind mounts strategy case insensitively", func() {
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"bindMounts": "HostPath",
+				},
+			}
+
+			cfg, err := config.ProjK8sConfigFromCompose(&project)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.BindMounts).To(Equal(config.HostPathBindMount))
+		})
+
+		It("validates bind mounts strategy", func() {
+			cfg := config.ProjK8sConfig{BindMounts: "nfs"}
+			err := cfg.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("ProjK8sConfig.BindMounts"))
+		})
+	})
+
+	Context("storage classes", func() {
+		It("loads storage classes", func() {
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"storageClasses": map[string]interface{}{
+						"preview-ssd": map[string]interface{}{
+							"provisioner":       "ebs.csi.aws.com",
+							"parameters":        map[string]interface{}{"type": "gp3"},
+							"reclaimPolicy":     "Delete",
+							"volumeBindingMode": "WaitForFirstConsumer",
+						},
+					},
+				},
+			}
+
+			cfg, err := config.ProjK8sConfigFromCompose(&project)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.StorageClasses).To(Equal(map[string]config.StorageClass{
+				"preview-ssd": {
+					Provisioner:       "ebs.csi.aws.com",
+					Parameters:        map[string]string{"type": "gp3"},
+					ReclaimPolicy:     "Delete",
+					VolumeBindingMode: "WaitForFirstConsumer",
+				},
+			}))
+		})
+
+		It("requires storage class provisioner", func() {
+			cfg := config.ProjK8sConfig{StorageClasses: map[string]config.StorageClass{"ssd": {}}}
+			Expect(cfg.Validate()).To(MatchError("ProjK8sConfig.StorageClasses[ssd].Provisioner is required"))
+		})
+
+		It("validates storage class reclaim policy", func() {
+			cfg := config.ProjK8sConfig{StorageClasses: map[string]config.StorageClass{
+				"ssd": {Provisioner: "ebs.csi.aws.com", ReclaimPolicy: "Recycle"},
+			}}
+			Expect(cfg.Validate()).To(MatchError("ProjK8sConfig.StorageClasses[ssd].ReclaimPolicy is invalid, use one of: Retain Delete"))
+		})
+
+		It("validates storage class name", func() {
+			cfg := config.ProjK8sConfig{StorageClasses: map[string]config.StorageClass{
+				"fast_ssd": {Provisioner: "ebs.csi.aws.com"},
+			}}
+			err := cfg.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("ProjK8sConfig.StorageClasses[fast_ssd]"))
+		})
+	})
+
+	Context("shared volumes", func() {
+		It("loads the shared volumes check case insensitively", func() {
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"sharedVolumes": "Fail",
+				},
+			}
+
+			cfg, err := config.ProjK8sConfigFromCompose(&project)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.SharedVolumes).To(Equal(config.FailSharedVolumes))
+		})
+
+		It("validates shared volumes check", func() {
+			cfg := config.ProjK8sConfig{SharedVolumes: "error"}
+			err := cfg.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("ProjK8sConfig.SharedVolumes"))
+		})
+	})
+
+	Context("secrets", func() {
+		It("loads the secrets output case insensitively", func() {
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"secrets": map[string]interface{}{
+						"output": "SOPS",
+						"sops": map[string]interface{}{
+							"ageRecipients": "recipients.txt",
+						},
+					},
+				},
+			}
+
+			cfg, err := config.ProjK8sConfigFromCompose(&project)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Secrets.Output).To(Equal(config.SOPSSecretsOutput))
+			Expect(cfg.Secrets.SOPS.AgeRecipients).To(Equal("recipients.txt"))
+		})
+
+		It("validates secrets output", func() {
+			cfg := config.ProjK8sConfig{Secrets: config.Secrets{Output: "vault"}}
+			err := cfg.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("ProjK8sConfig.Secrets.Output"))
+		})
+
+		It("requires certificate for sealed output", func() {
+			cfg := config.ProjK8sConfig{Secrets: config.Secrets{Output: config.SealedSecretsOutput}}
+			Expect(cfg.Validate()).To(MatchError("ProjK8sConfig.Secrets.Sealed.Certificate is required when secrets output is sealed"))
+		})
+
+		It("requires namespace for strict sealed secrets scope", func() {
+			cfg := config.ProjK8sConfig{Secrets: config.Secrets{
+				Output: config.SealedSecretsOutput,
+				Sealed: config.SealedSecrets{Certificate: "cert.pem"},
+			}}
+			Expect(cfg.Validate()).To(MatchError("ProjK8sConfig.Namespace is required for strict sealed secrets scope"))
+		})
+
+		It("doesn't require namespace for cluster-wide sealed secrets scope", func() {
+			cfg := config.ProjK8sConfig{Secrets: config.Secrets{
+				Output: config.SealedSecretsOutput,
+				Sealed: config.SealedSecrets{Certificate: "cert.pem", Scope: config.SealedSecretsClusterWideScope},
+			}}
+			Expect(cfg.Validate()).To(Succeed())
+		})
+
+		It("validates sealed secrets scope", func() {
+			cfg := config.ProjK8sConfig{Secrets: config.Secrets{Sealed: config.SealedSecrets{Scope: "global"}}}
+			err := cfg.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("ProjK8sConfig.Secrets.Sealed.Scope"))
+		})
+
+		It("validates default secret store", func() {
+			cfg := config.ProjK8sConfig{Secrets: config.Secrets{Store: config.SecretStoreRef{Name: "vault", Kind: "Vault"}}}
+			err := cfg.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("ProjK8sConfig.Secrets.Store.Kind"))
+		})
+
+		It("requires age recipients for sops output", func() {
+			cfg := config.ProjK8sConfig{Secrets: config.Secrets{Output: config.SOPSSecretsOutput}}
+			Expect(cfg.Validate()).To(MatchError("ProjK8sConfig.Secrets.SOPS.AgeRecipients is required when secrets output is sops"))
+		})
+	})
+
+	Context("merge", func() {
+		It("overrides base values with the supplied config", func() {
+			base := config.ProjK8sConfig{Mesh: config.IstioMesh}
+			merged, err := base.Merge(config.ProjK8sConfig{Mesh: config.LinkerdMesh})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(merged.Mesh).To(Equal(config.LinkerdMesh))
+		})
+	})
+})
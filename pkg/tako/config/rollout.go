@@ -0,0 +1,70 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ConfigRollout defines how workloads get rolled out when content of ConfigMaps and Secrets they use changes
+type ConfigRollout string
+
+const (
+	// ChecksumConfigRollout annotates workload pod templates with content checksums of ConfigMaps and Secrets (default)
+	ChecksumConfigRollout ConfigRollout = "checksum"
+
+	// HashedNameConfigRollout suffixes ConfigMap names with their content hash, and annotates workload
+	// pod templates with content checksum of Secrets
+	HashedNameConfigRollout ConfigRollout = "hashedName"
+
+	// NoConfigRollout doesn't roll out workloads on ConfigMap and Secret content changes
+	NoConfigRollout ConfigRollout = "none"
+)
+
+// String converts a config rollout to a string value
+func (c ConfigRollout) String() string {
+	return string(c)
+}
+
+// configRollouts are the only config rollout settings
+var configRollouts = map[ConfigRollout]bool{
+	ChecksumConfigRollout:   true,
+	HashedNameConfigRollout: true,
+	NoConfigRollout:         true,
+}
+
+// ConfigRolloutFromValue returns a Config Rollout for a given case insensitive value.
+// Blank value defaults to checksum. Returns a blank string and false for unknown values.
+func ConfigRolloutFromValue(s string) (ConfigRollout, bool) {
+	if s == "" {
+		return ChecksumConfigRollout, true
+	}
+	for k, v := range configRollouts {
+		if strings.ToLower(k.String()) == strings.ToLower(s) {
+			return k, v
+		}
+	}
+	return "", false
+}
+
+// validateConfigRollout validator to validate a config rollout
+func validateConfigRollout(fl validator.FieldLevel) bool {
+	_, valid := ConfigRolloutFromValue(fl.Field().String())
+	return valid
+}
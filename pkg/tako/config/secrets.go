@@ -19,260 +19,322 @@ package config
 const (
 	PartIdentifier = "id"
 	PartValue      = "value"
+
+	// MatcherTypeRegex matches env var name or value against the `match` regular expression (default)
+	MatcherTypeRegex = "regex"
+	// MatcherTypeToken matches env var value against the `match` regular expression of a known token format
+	MatcherTypeToken = "token"
+	// MatcherTypeEntropy matches env var value with Shannon entropy (bits per character) of at least `threshold`
+	// and at least `minLength` characters long
+	MatcherTypeEntropy = "entropy"
+
+	// DefaultSecretEntropyThreshold is a default Shannon entropy threshold of secret values
+	DefaultSecretEntropyThreshold = "4.5"
+	// DefaultSecretEntropyMinLength is a default minimum length of secret values detected by entropy
+	DefaultSecretEntropyMinLength = "20"
 )
 
 // Sources,
 // gitrob: https://github.com/michenriksen/gitrob/blob/master/core/signatures.go
 // shhgit: https://github.com/eth0izzle/shhgit/blob/046cf0b704291aafba97d1feff19fe57e5e09152/config.yaml
+// gitleaks: https://github.com/zricethezav/gitleaks/blob/master/config/default.go
 
 var SecretMatchers = []map[string]string{
 	{
+		"type":        MatcherTypeToken,
+		"part":        PartValue,
+		"match":       `-----BEGIN ((RSA|DSA|EC|OPENSSH|PGP|ENCRYPTED) )?PRIVATE KEY( BLOCK)?-----`,
+		"description": "a PEM encoded private key",
+	},
+	{
+		"type":        MatcherTypeToken,
+		"part":        PartValue,
+		"match":       `\b(A3T[A-Z0-9]|AKIA|AGPA|AIDA|AROA|AIPA|ANPA|ANVA|ASIA)[A-Z0-9]{16}\b`,
+		"description": "an AWS Access Key ID",
+	},
+	{
+		"type":        MatcherTypeToken,
+		"part":        PartValue,
+		"match":       `\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]*`,
+		"description": "a JSON Web Token",
+	},
+	{
+		"type":        MatcherTypeToken,
 		"part":        PartValue,
-		"match":       ".pem",
+		"match":       `\bgh[pousr]_[A-Za-z0-9]{36,255}\b`,
+		"description": "a Github Token",
+	},
+	{
+		"type":        MatcherTypeToken,
+		"part":        PartValue,
+		"match":       `\bxox[baprs]-[0-9A-Za-z-]{10,72}\b`,
+		"description": "a Slack Token",
+	},
+	{
+		"type":        MatcherTypeToken,
+		"part":        PartValue,
+		"match":       `\b(sk|rk)_live_[0-9a-zA-Z]{24,99}\b`,
+		"description": "a Stripe API key",
+	},
+	{
+		"type":        MatcherTypeToken,
+		"part":        PartValue,
+		"match":       `\bAIza[0-9A-Za-z_-]{35}\b`,
+		"description": "a Google API key",
+	},
+	{
+		"type":        MatcherTypeToken,
+		"part":        PartValue,
+		"match":       `[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:[^/\s:@]+@`,
+		"description": "a URL with embedded credentials",
+	},
+	{
+		"part":        PartValue,
+		"match":       `\.pem$`,
 		"description": "a Potential cryptographic private key",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".log",
+		"match":       `\.log$`,
 		"description": "a Log file",
 		"comment":     "Log files can contain secret HTTP endpoints, session IDs, API keys and other goodies",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".pkcs12",
+		"match":       `\.pkcs12$`,
 		"description": "a Potential cryptographic key bundle",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".p12",
+		"match":       `\.p12$`,
 		"description": "a Potential cryptographic key bundle",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".pfx",
+		"match":       `\.pfx$`,
 		"description": "a Potential cryptographic key bundle",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".asc",
+		"match":       `\.asc$`,
 		"description": "a Potential cryptographic key bundle",
 	},
 	{
 		"part":        PartValue,
-		"match":       "otr.private_key",
+		"match":       `otr\.private_key$`,
 		"description": "a Pidgin OTR private key",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".ovpn",
+		"match":       `\.ovpn$`,
 		"description": "an OpenVPN client configuration file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".cscfg",
+		"match":       `\.cscfg$`,
 		"description": "an Azure service configuration schema file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".rdp",
+		"match":       `\.rdp$`,
 		"description": "a Remote Desktop connection file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".mdf",
+		"match":       `\.mdf$`,
 		"description": "a Microsoft SQL database file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".sdf",
+		"match":       `\.sdf$`,
 		"description": "a Microsoft SQL server compact database file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".sqlite",
+		"match":       `\.sqlite$`,
 		"description": "a SQLite database file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".bek",
+		"match":       `\.bek$`,
 		"description": "a Microsoft BitLocker recovery key file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".tpm",
+		"match":       `\.tpm$`,
 		"description": "a Microsoft BitLocker Trusted Platform Module password file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".fve",
+		"match":       `\.fve$`,
 		"description": "a Windows BitLocker full volume encrypted data file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".jks",
+		"match":       `\.jks$`,
 		"description": "a Java keystore file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".psafe3",
+		"match":       `\.psafe3$`,
 		"description": "a Password Safe database file",
 	},
 	{
 		"part":        PartValue,
-		"match":       "secret_token.rb",
+		"match":       `secret_token\.rb$`,
 		"description": "a Ruby On Rails secret token configuration file",
 		"comment":     "If the Rails secret token is known, it can allow for remote code execution (http://www.exploit-db.com/exploits/27527/)",
 	},
 	{
 		"part":        PartValue,
-		"match":       "carrierwave.rb",
+		"match":       `carrierwave\.rb$`,
 		"description": "a Carrierwave configuration file",
 		"comment":     "Can contain credentials for cloud storage systems such as Amazon S3 and Google Storage",
 	},
 	{
 		"part":        PartValue,
-		"match":       "database.yml",
+		"match":       `database\.yml$`,
 		"description": "a Potential Ruby On Rails database configuration file",
 		"comment":     "Can contain database credentials",
 	},
 	{
 		"part":        PartValue,
-		"match":       "omniauth.rb",
+		"match":       `omniauth\.rb$`,
 		"description": "an OmniAuth configuration file",
 		"comment":     "The OmniAuth configuration file can contain client application secrets",
 	},
 	{
 		"part":        PartValue,
-		"match":       "settings.py",
+		"match":       `settings\.py$`,
 		"description": "a Django configuration file",
 		"comment":     "Can contain database credentials, cloud storage system credentials, and other secrets",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".agilekeychain",
+		"match":       `\.agilekeychain$`,
 		"description": "a 1Password password manager database file",
 		"comment":     "Feed it to Hashcat and see if you're lucky",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".keychain",
+		"match":       `\.keychain$`,
 		"description": "a Apple Keychain database file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".pcap",
+		"match":       `\.pcap$`,
 		"description": "a Network traffic capture file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".gnucash",
+		"match":       `\.gnucash$`,
 		"description": "a GnuCash database file",
 	},
 	{
 		"part":        PartValue,
-		"match":       "jenkins.plugins.publish_over_ssh.BapSshPublisherPlugin.xml",
+		"match":       `jenkins\.plugins\.publish_over_ssh\.BapSshPublisherPlugin\.xml$`,
 		"description": "a Jenkins publish over SSH plugin file",
 	},
 	{
 		"part":        PartValue,
-		"match":       "credentials.xml",
+		"match":       `credentials\.xml$`,
 		"description": "a Potential Jenkins credentials file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".kwallet",
+		"match":       `\.kwallet$`,
 		"description": "a KDE Wallet Manager database file",
 	},
 	{
 		"part":        PartValue,
-		"match":       "LocalSettings.php",
+		"match":       `LocalSettings\.php$`,
 		"description": "a Potential MediaWiki configuration file",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".tblk",
+		"match":       `\.tblk$`,
 		"description": "a Tunnelblick VPN configuration file",
 	},
 	{
 		"part":        PartValue,
-		"match":       "a Favorites.plist",
+		"match":       `a Favorites\.plist$`,
 		"description": "Sequel Pro MySQL database manager bookmark file",
 	},
 	{
 		"part":        PartValue,
-		"match":       "configuration.user.xpl",
+		"match":       `configuration\.user\.xpl$`,
 		"description": "a Little Snitch firewall configuration file",
 		"comment":     "Contains traffic rules for applications",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".dayone",
+		"match":       `\.dayone$`,
 		"description": "a Day One journal file",
 		"comment":     "Now it's getting creepy...",
 	},
 	{
 		"part":        PartValue,
-		"match":       "journal.txt",
+		"match":       `journal\.txt$`,
 		"description": "a Potential jrnl journal file",
 		"comment":     "Now it's getting creepy...",
 	},
 	{
 		"part":        PartValue,
-		"match":       "knife.rb",
+		"match":       `knife\.rb$`,
 		"description": "Chef Knife configuration file",
 		"comment":     "Can contain references to Chef servers",
 	},
 	{
 		"part":        PartValue,
-		"match":       "proftpdpasswd",
+		"match":       `proftpdpasswd$`,
 		"description": "cPanel backup ProFTPd credentials file",
 		"comment":     "Contains usernames and password hashes for FTP accounts",
 	},
 	{
 		"part":        PartValue,
-		"match":       "robomongo.json",
+		"match":       `robomongo\.json$`,
 		"description": "Robomongo MongoDB manager configuration file",
 		"comment":     "Can contain credentials for MongoDB databases",
 	},
 	{
 		"part":        PartValue,
-		"match":       "filezilla.xml",
+		"match":       `filezilla\.xml$`,
 		"description": "FileZilla FTP configuration file",
 		"comment":     "Can contain credentials for FTP servers",
 	},
 	{
 		"part":        PartValue,
-		"match":       "recentservers.xml",
+		"match":       `recentservers\.xml$`,
 		"description": "FileZilla FTP recent servers file",
 		"comment":     "Can contain credentials for FTP servers",
 	},
 	{
 		"part":        PartValue,
-		"match":       "ventrilo_srv.ini",
+		"match":       `ventrilo_srv\.ini$`,
 		"description": "Ventrilo server configuration file",
 		"comment":     "Can contain passwords",
 	},
 	{
 		"part":        PartValue,
-		"match":       "terraform.tfvars",
+		"match":       `terraform\.tfvars$`,
 		"description": "Terraform variable config file",
 		"comment":     "Can contain credentials for terraform providers",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".exports",
+		"match":       `\.exports$`,
 		"description": "Shell configuration file",
 		"comment":     "a Shell configuration files can contain passwords, API keys, hostnames and other goodies",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".functions",
+		"match":       `\.functions$`,
 		"description": "Shell configuration file",
 		"comment":     "a Shell configuration files can contain passwords, API keys, hostnames and other goodies",
 	},
 	{
 		"part":        PartValue,
-		"match":       ".extra",
+		"match":       `\.extra$`,
 		"description": "Shell configuration file",
 		"comment":     "a Shell configuration files can contain passwords, API keys, hostnames and other goodies",
 	},
@@ -746,4 +808,11 @@ var SecretMatchers = []map[string]string{
 		"match":       `(?i)(secret)?.*_?.*(access)?.*_?.*key`,
 		"description": "Contains words: (secret or access) and key",
 	},
+	{
+		"type":        MatcherTypeEntropy,
+		"part":        PartValue,
+		"threshold":   DefaultSecretEntropyThreshold,
+		"minLength":   DefaultSecretEntropyMinLength,
+		"description": "a High entropy string",
+	},
 }
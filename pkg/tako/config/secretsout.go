@@ -0,0 +1,111 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// SecretsOutput is the format K8s Secrets generated from compose file based secrets are rendered in
+type SecretsOutput string
+
+const (
+	// PlainSecretsOutput renders base64 encoded v1.Secret objects (default)
+	PlainSecretsOutput SecretsOutput = "plain"
+
+	// SealedSecretsOutput renders Bitnami SealedSecret objects encrypted with a Sealed Secrets controller certificate
+	SealedSecretsOutput SecretsOutput = "sealed"
+
+	// SOPSSecretsOutput renders v1.Secret objects with data encrypted by SOPS using age recipients
+	SOPSSecretsOutput SecretsOutput = "sops"
+)
+
+const (
+	// SealedSecretsStrictScope binds a sealed secret to its name and namespace (default)
+	SealedSecretsStrictScope = "strict"
+
+	// SealedSecretsNamespaceWideScope allows a sealed secret to be renamed within its namespace
+	SealedSecretsNamespaceWideScope = "namespace-wide"
+
+	// SealedSecretsClusterWideScope allows a sealed secret to be unsealed in any namespace under any name
+	SealedSecretsClusterWideScope = "cluster-wide"
+)
+
+// String converts a secrets output to a string value
+func (o SecretsOutput) String() string {
+	return string(o)
+}
+
+// secretsOutputs are the only secrets output settings
+var secretsOutputs = map[SecretsOutput]bool{
+	"":                  true,
+	PlainSecretsOutput:  true,
+	SealedSecretsOutput: true,
+	SOPSSecretsOutput:   true,
+}
+
+// SecretsOutputFromValue returns a Secrets Output for a given case insensitive value.
+// Returns a blank string and false for unknown values.
+func SecretsOutputFromValue(s string) (SecretsOutput, bool) {
+	for k, v := range secretsOutputs {
+		if strings.EqualFold(k.String(), s) {
+			return k, v
+		}
+	}
+	return "", false
+}
+
+// validateSecretsOutput validator to validate a secrets output
+func validateSecretsOutput(fl validator.FieldLevel) bool {
+	_, valid := SecretsOutputFromValue(fl.Field().String())
+	return valid
+}
+
+// Secrets holds the configuration of K8s Secrets generated from compose file based secrets.
+type Secrets struct {
+	Output SecretsOutput `yaml:"output,omitempty" validate:"secretsOutput"`
+	Sealed SealedSecrets `yaml:"sealed,omitempty"`
+	SOPS   SOPSSecrets   `yaml:"sops,omitempty"`
+	// Store is the default External Secrets Operator secret store for external compose secrets
+	Store SecretStoreRef `yaml:"store,omitempty"`
+	// ClassifyEnv moves literal service env vars detected as secrets to a per-service Secret,
+	// and the remaining ones to a per-service ConfigMap
+	ClassifyEnv bool `yaml:"classifyEnv,omitempty"`
+}
+
+// SealedSecrets holds the configuration of Bitnami Sealed Secrets output.
+type SealedSecrets struct {
+	// Certificate is a path to the Sealed Secrets controller public certificate, e.g. fetched with `kubeseal --fetch-cert`
+	Certificate string `yaml:"certificate,omitempty"`
+	Scope       string `yaml:"scope,omitempty" validate:"oneof='' strict namespace-wide cluster-wide"`
+}
+
+// SOPSSecrets holds the configuration of SOPS encrypted secrets output.
+type SOPSSecrets struct {
+	// AgeRecipients is a path to a file with age public keys, one per line
+	AgeRecipients string `yaml:"ageRecipients,omitempty"`
+}
+
+// SealedScope returns the configured sealed secrets scope, strict by default
+func (s Secrets) SealedScope() string {
+	if s.Sealed.Scope == "" {
+		return SealedSecretsStrictScope
+	}
+	return s.Sealed.Scope
+}
@@ -0,0 +1,44 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+// StorageClass holds the configuration of a StorageClass created together with the environment,
+// e.g. for ephemeral preview environments provisioning their own storage
+type StorageClass struct {
+	Provisioner          string            `yaml:"provisioner,omitempty" validate:"required"`
+	Parameters           map[string]string `yaml:"parameters,omitempty"`
+	ReclaimPolicy        string            `yaml:"reclaimPolicy,omitempty" validate:"omitempty,oneof=Retain Delete"`
+	VolumeBindingMode    string            `yaml:"volumeBindingMode,omitempty" validate:"omitempty,oneof=Immediate WaitForFirstConsumer"`
+	AllowVolumeExpansion bool              `yaml:"allowVolumeExpansion,omitempty"`
+	MountOptions         []string          `yaml:"mountOptions,omitempty"`
+}
+
+// VolumeSnapshot holds the configuration of a one-off VolumeSnapshot of a volume claim,
+// or a snapshot schedule when schedule is set
+type VolumeSnapshot struct {
+	Name  string `yaml:"name,omitempty" validate:"subdomainIfAny"`
+	Class string `yaml:"class,omitempty" validate:"subdomainIfAny"`
+	// Schedule in cron format, e.g. `0 2 * * *`
+	Schedule string `yaml:"schedule,omitempty" validate:"omitempty,cron"`
+	// Retention is the maximum number of scheduled snapshots kept
+	Retention int `yaml:"retention,omitempty" validate:"omitempty,min=1"`
+}
+
+// Scheduled returns true when snapshots are taken on a schedule
+func (s VolumeSnapshot) Scheduled() bool {
+	return s.Schedule != ""
+}
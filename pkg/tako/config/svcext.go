@@ -46,6 +46,7 @@ type ServiceExtension struct {
 
 // SvcK8sConfig represents the root of the k8s specific fields supported by Tako.
 type SvcK8sConfig struct {
+	Name     string   `yaml:"name,omitempty" validate:"omitempty,dns_rfc1035_label"`
 	Disabled bool     `yaml:"disabled,omitempty"`
 	Workload Workload `yaml:"workload" validate:"required,dive"`
 	Service  Service  `yaml:"service,omitempty"`
@@ -95,6 +96,10 @@ func (skc SvcK8sConfig) Validate() error {
 		return err
 	}
 
+	if err := validate.RegisterValidation("bindMountStrategy", validateBindMountStrategy); err != nil {
+		return err
+	}
+
 	err := validate.Struct(skc)
 	if err != nil {
 		validationErrors := err.(validator.ValidationErrors)
@@ -270,7 +275,7 @@ func PodSecurityWithDefaults() PodSecurity {
 func ServiceTypeFromCompose(svc *composego.ServiceConfig) (ServiceType, error) {
 	var candidate = "none"
 
-	if len(svc.Ports) > 0 {
+	if len(svc.Ports) > 0 || len(svc.Expose) > 0 {
 		candidate = "clusterip"
 	}
 
@@ -429,6 +434,12 @@ type Workload struct {
 	PodSecurity           PodSecurity       `yaml:"podSecurity,omitempty"`
 	Command               []string          `yaml:"command,omitempty"`
 	CommandArgs           []string          `yaml:"commandArgs,omitempty"`
+	EnvFrom               bool              `yaml:"envFrom,omitempty"`
+	DownwardAPI           DownwardAPI       `yaml:"downwardAPI,omitempty"`
+	// BindMounts defines how compose bind mounts get converted, keyed by the container mount path
+	BindMounts map[string]BindMountStrategy `yaml:"bindMounts,omitempty" validate:"dive,bindMountStrategy"`
+	// Knative configures the Knative Service rendered for the service by the knative converter
+	Knative Knative `yaml:"knative,omitempty"`
 }
 
 type Resource struct {
@@ -451,17 +462,56 @@ type Autoscale struct {
 	MemoryThreshold int `yaml:"memThreshold,omitempty"`
 }
 
+// DownwardAPI holds the downward API volume configuration exposing pod labels and annotations as files.
+type DownwardAPI struct {
+	Path        string `yaml:"path,omitempty" validate:"omitempty,startswith=/"`
+	Labels      bool   `yaml:"labels,omitempty"`
+	Annotations bool   `yaml:"annotations,omitempty"`
+}
+
+// Enabled returns true when any pod metadata is exposed via the downward API volume
+func (d DownwardAPI) Enabled() bool {
+	return d.Labels || d.Annotations
+}
+
+// MountPath returns the downward API volume mount path
+func (d DownwardAPI) MountPath() string {
+	if d.Path == "" {
+		return DefaultDownwardAPIPath
+	}
+	return d.Path
+}
+
 type PodSecurity struct {
 	RunAsUser  *int64 `yaml:"runAsUser,omitempty"`
 	RunAsGroup *int64 `yaml:"runAsGroup,omitempty"`
 	FsGroup    *int64 `yaml:"fsGroup,omitempty"`
 }
 
-// Service will hold the service specific extensions in the future.
+// Service holds the k8s service specific configuration.
 type Service struct {
-	Type     ServiceType `yaml:"type" validate:"serviceType"`
-	NodePort int         `yaml:"nodeport,omitempty"`
-	Expose   Expose      `yaml:"expose,omitempty"`
+	Type                     ServiceType       `yaml:"type" validate:"serviceType"`
+	NodePort                 int               `yaml:"nodeport,omitempty"`
+	SessionAffinity          string            `yaml:"sessionAffinity,omitempty" validate:"oneof='' None ClientIP"`
+	ExternalTrafficPolicy    string            `yaml:"externalTrafficPolicy,omitempty" validate:"oneof='' Cluster Local"`
+	InternalTrafficPolicy    string            `yaml:"internalTrafficPolicy,omitempty" validate:"oneof='' Cluster Local"`
+	LoadBalancerSourceRanges []string          `yaml:"loadBalancerSourceRanges,omitempty" validate:"dive,cidr"`
+	LoadBalancerClass        string            `yaml:"loadBalancerClass,omitempty"`
+	Annotations              map[string]string `yaml:"annotations,omitempty"`
+	Ports                    []ServicePort     `yaml:"ports,omitempty" validate:"dive"`
+	ExternalName             string            `yaml:"externalName,omitempty" validate:"subdomainIfAny"`
+	ExternalLinks            map[string]string `yaml:"externalLinks,omitempty" validate:"dive,subdomainIfAny"`
+	Mesh                     ServiceMesh       `yaml:"mesh,omitempty"`
+	Expose                   Expose            `yaml:"expose,omitempty"`
+}
+
+// ServicePort holds per port service configuration.
+// A port is matched against compose service ports by its published port number and protocol.
+type ServicePort struct {
+	Port        int    `yaml:"port" validate:"required,min=1,max=65535"`
+	Protocol    string `yaml:"protocol,omitempty" validate:"oneof='' TCP UDP SCTP tcp udp sctp"`
+	AppProtocol string `yaml:"appProtocol,omitempty"`
+	NodePort    int    `yaml:"nodeport,omitempty" validate:"omitempty,min=1,max=65535"`
 }
 
 type Expose struct {
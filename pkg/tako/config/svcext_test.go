@@ -237,6 +237,183 @@ var _ = Describe("Service Extension", func() {
 						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Workload.Type"))
 					})
 				})
+
+				Context("with an invalid session affinity", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Service.SessionAffinity = "Sticky"
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Service.SessionAffinity"))
+					})
+				})
+
+				Context("with an invalid external traffic policy", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Service.ExternalTrafficPolicy = "Anywhere"
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Service.ExternalTrafficPolicy"))
+					})
+				})
+
+				Context("with an invalid load balancer source range", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Service.LoadBalancerSourceRanges = []string{"10.0.0.0/8", "not-a-cidr"}
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Service.LoadBalancerSourceRanges[1]"))
+					})
+				})
+
+				Context("with an invalid name", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Name = "my_api"
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Name"))
+					})
+				})
+
+				Context("with an invalid external name", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Service.ExternalName = "not a dns name"
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Service.ExternalName"))
+					})
+				})
+
+				Context("with an invalid external link hostname", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Service.ExternalLinks = map[string]string{"cache": "not a dns name"}
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Service.ExternalLinks[cache]"))
+					})
+				})
+
+				Context("with an invalid mesh mTLS mode", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Service.Mesh.MTLS = "MUTUAL"
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Service.Mesh.MTLS"))
+					})
+				})
+
+				Context("with a mesh canary weight but no canary service", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Service.Mesh.Canary.Weight = 10
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Service.Mesh.Canary.Service"))
+					})
+				})
+
+				Context("with a mesh canary weight out of range", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Service.Mesh.Canary.Service = "web-canary"
+						svcK8sConfig.Service.Mesh.Canary.Weight = 110
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Service.Mesh.Canary.Weight"))
+					})
+				})
+
+				Context("with a Knative max scale lower than min scale", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Workload.Knative.MinScale = 3
+						svcK8sConfig.Workload.Knative.MaxScale = 2
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Workload.Knative.MaxScale"))
+					})
+				})
+
+				Context("with a service port missing port number", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Service.Ports = []config.ServicePort{
+							{AppProtocol: "http"},
+						}
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Service.Ports[0].Port is required"))
+					})
+				})
+
+				Context("with a service port using an unsupported protocol", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Service.Ports = []config.ServicePort{
+							{Port: 8080, Protocol: "HTTP"},
+						}
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Service.Ports[0].Protocol"))
+					})
+				})
+
+				Context("with valid service options", func() {
+					It("doesn't return an error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Service.Type = config.LoadBalancerService
+						svcK8sConfig.Service.SessionAffinity = "ClientIP"
+						svcK8sConfig.Service.ExternalTrafficPolicy = "Local"
+						svcK8sConfig.Service.InternalTrafficPolicy = "Cluster"
+						svcK8sConfig.Service.LoadBalancerSourceRanges = []string{"10.0.0.0/8"}
+						svcK8sConfig.Service.Ports = []config.ServicePort{
+							{Port: 53, Protocol: "udp", NodePort: 30053},
+							{Port: 8080, AppProtocol: "http"},
+						}
+
+						Expect(svcK8sConfig.Validate()).To(Succeed())
+					})
+				})
+
+				Context("with an unknown bind mount strategy", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Workload.BindMounts = map[string]config.BindMountStrategy{"/app": "copy"}
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Workload.BindMounts[/app]"))
+					})
+				})
+
+				Context("with a relative downward API volume path", func() {
+					It("returns error", func() {
+						svcK8sConfig := config.DefaultSvcK8sConfig()
+						svcK8sConfig.Workload.DownwardAPI = config.DownwardAPI{Path: "etc/podinfo", Labels: true}
+
+						err = svcK8sConfig.Validate()
+						Expect(err).To(HaveOccurred())
+						Expect(err.Error()).To(ContainSubstring("SvcK8sConfig.Workload.DownwardAPI.Path"))
+					})
+				})
 			})
 		})
 	})
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
+	"strings"
 
 	composego "github.com/compose-spec/compose-go/types"
 	"github.com/go-playground/validator/v10"
@@ -39,9 +40,65 @@ type VolumeExtension struct {
 
 // VolK8sConfig represents the root of the k8s specific fields supported by tako.
 type VolK8sConfig struct {
-	Size         string `yaml:"size" validate:"required,quantity"`
-	StorageClass string `yaml:"storageClass,omitempty"`
-	Selector     string `yaml:"selector,omitempty"`
+	Name         string             `yaml:"name,omitempty" validate:"omitempty,dns_rfc1035_label"`
+	Size         string             `yaml:"size" validate:"required,quantity"`
+	StorageClass string             `yaml:"storageClass,omitempty"`
+	Selector     string             `yaml:"selector,omitempty"`
+	AccessModes  []string           `yaml:"accessModes,omitempty" validate:"dive,oneof=ReadWriteOnce ReadOnlyMany ReadWriteMany ReadWriteOncePod"`
+	VolumeMode   string             `yaml:"volumeMode,omitempty" validate:"omitempty,oneof=Filesystem Block"`
+	Annotations  map[string]string  `yaml:"annotations,omitempty"`
+	Labels       map[string]string  `yaml:"labels,omitempty"`
+	DataSource   VolumeDataSource   `yaml:"dataSource,omitempty"`
+	Retention    PVCRetentionPolicy `yaml:"retention,omitempty"`
+	NFS          VolumeNFS          `yaml:"nfs,omitempty"`
+	CSI          VolumeCSI          `yaml:"csi,omitempty"`
+	Snapshots    []VolumeSnapshot   `yaml:"snapshots,omitempty" validate:"dive"`
+}
+
+// VolumeNFS holds an NFS share mounted by the volume
+type VolumeNFS struct {
+	Server string `yaml:"server,omitempty" validate:"required_with=Path"`
+	Path   string `yaml:"path,omitempty" validate:"required_with=Server,omitempty,startswith=/"`
+	// PersistentVolume renders a static PersistentVolume and a claim bound to it instead of an inline NFS volume
+	PersistentVolume bool `yaml:"persistentVolume,omitempty"`
+}
+
+// Enabled returns true when the volume mounts an NFS share
+func (n VolumeNFS) Enabled() bool {
+	return n.Server != ""
+}
+
+// VolumeCSI holds a CSI inline volume, or a generic ephemeral volume provisioned via the volume storage class
+type VolumeCSI struct {
+	Driver            string            `yaml:"driver,omitempty"`
+	FSType            string            `yaml:"fsType,omitempty"`
+	ReadOnly          bool              `yaml:"readOnly,omitempty"`
+	VolumeAttributes  map[string]string `yaml:"volumeAttributes,omitempty"`
+	NodePublishSecret string            `yaml:"nodePublishSecret,omitempty" validate:"subdomainIfAny"`
+	Ephemeral         bool              `yaml:"ephemeral,omitempty"`
+}
+
+// Enabled returns true when the volume is a CSI inline or generic ephemeral volume
+func (c VolumeCSI) Enabled() bool {
+	return c.Driver != "" || c.Ephemeral
+}
+
+// VolumeDataSource references a VolumeSnapshot or another PersistentVolumeClaim to populate a volume from
+type VolumeDataSource struct {
+	Kind string `yaml:"kind,omitempty" validate:"required_with=Name,omitempty,oneof=VolumeSnapshot PersistentVolumeClaim"`
+	Name string `yaml:"name,omitempty" validate:"required_with=Kind,subdomainIfAny"`
+}
+
+// PVCRetentionPolicy controls whether StatefulSet volume claims are retained or deleted
+// when the StatefulSet is deleted or scaled down
+type PVCRetentionPolicy struct {
+	WhenDeleted string `yaml:"whenDeleted,omitempty" validate:"omitempty,oneof=Retain Delete"`
+	WhenScaled  string `yaml:"whenScaled,omitempty" validate:"omitempty,oneof=Retain Delete"`
+}
+
+// Enabled returns true when the retention policy has been configured
+func (p PVCRetentionPolicy) Enabled() bool {
+	return p.WhenDeleted != "" || p.WhenScaled != ""
 }
 
 // Merge merges in a src volume's K8s config
@@ -71,6 +128,10 @@ func (vkc VolK8sConfig) Validate() error {
 		return err
 	}
 
+	if err := validate.RegisterValidation("subdomainIfAny", validateDNSSubdomainNameIfAny); err != nil {
+		return err
+	}
+
 	if err := validate.Struct(vkc); err != nil {
 		validationErrors := err.(validator.ValidationErrors)
 		for _, e := range validationErrors {
@@ -84,10 +145,42 @@ func (vkc VolK8sConfig) Validate() error {
 					e.StructNamespace(),
 				)
 			}
+
+			if e.Tag() == "oneof" {
+				return fmt.Errorf("%s is invalid, use one of: %s", e.StructNamespace(), e.Param())
+			}
+
+			if e.Tag() == "required_with" {
+				return fmt.Errorf("%s is required with %s", e.StructNamespace(), e.Param())
+			}
+
+			if e.Tag() == "cron" {
+				return fmt.Errorf("%s is invalid, use a cron schedule format, e.g. 0 2 * * *", e.StructNamespace())
+			}
 		}
 		return errors.New(validationErrors[0].Error())
 	}
 
+	if vkc.NFS.Enabled() && vkc.CSI.Enabled() {
+		return errors.New("VolK8sConfig.NFS and VolK8sConfig.CSI can't be used together")
+	}
+
+	for i, snapshot := range vkc.Snapshots {
+		if snapshot.Retention > 0 && !snapshot.Scheduled() {
+			return fmt.Errorf("VolK8sConfig.Snapshots[%d].Retention is only supported with VolK8sConfig.Snapshots[%d].Schedule", i, i)
+		}
+	}
+
+	if (vkc.NFS.Enabled() && !vkc.NFS.PersistentVolume) || vkc.CSI.Enabled() {
+		if len(vkc.Snapshots) > 0 {
+			return errors.New("VolK8sConfig.Snapshots are only supported for volumes claimed via PVC")
+		}
+	}
+
+	if vkc.CSI.Ephemeral && vkc.CSI.Driver != "" {
+		return errors.New("VolK8sConfig.CSI.Driver can't be used with VolK8sConfig.CSI.Ephemeral, set VolK8sConfig.StorageClass instead")
+	}
+
 	return nil
 }
 
@@ -117,6 +210,14 @@ func VolK8sConfigFromCompose(vol *composego.VolumeConfig) (VolK8sConfig, error)
 		return VolK8sConfig{}, err
 	}
 
+	// @step infer NFS share from the local driver options, unless configured explicitly
+	if !cfg.NFS.Enabled() && !cfg.CSI.Enabled() {
+		if server, path, ok := nfsFromDriverOpts(vol.DriverOpts); ok {
+			cfg.NFS.Server = server
+			cfg.NFS.Path = path
+		}
+	}
+
 	if err := cfg.Validate(); err != nil {
 		return VolK8sConfig{}, err
 	}
@@ -124,6 +225,35 @@ func VolK8sConfigFromCompose(vol *composego.VolumeConfig) (VolK8sConfig, error)
 	return cfg, nil
 }
 
+// nfsFromDriverOpts returns NFS server and path from compose local driver options, e.g.
+// `type: nfs`, `o: addr=10.0.0.1,rw,nfsvers=4`, `device: ":/exports/data"`
+func nfsFromDriverOpts(opts map[string]string) (string, string, bool) {
+	if t := opts["type"]; t != "nfs" && t != "nfs4" {
+		return "", "", false
+	}
+
+	var server string
+	for _, o := range strings.Split(opts["o"], ",") {
+		if strings.HasPrefix(o, "addr=") {
+			server = strings.TrimPrefix(o, "addr=")
+		}
+	}
+
+	device := opts["device"]
+	if i := strings.Index(device, ":"); i >= 0 {
+		if server == "" {
+			server = device[:i]
+		}
+		device = device[i+1:]
+	}
+
+	if server == "" || device == "" {
+		return "", "", false
+	}
+
+	return server, device, true
+}
+
 // ParseVolK8sConfigFromMap parses a volume extension from the related map
 func ParseVolK8sConfigFromMap(m map[string]interface{}, opts ...K8sExtensionOption) (VolK8sConfig, error) {
 	var options extensionOptions
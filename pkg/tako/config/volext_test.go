@@ -67,5 +67,175 @@ var _ = Describe("Volume Extension", func() {
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("invalid, use a resource quantity format"))
 		})
+
+		It("validates name", func() {
+			composeVolExt["name"] = "db_data"
+			_, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("VolK8sConfig.Name"))
+		})
+
+		It("loads access modes, volume mode, metadata, data source and retention", func() {
+			composeVolExt["accessModes"] = []interface{}{"ReadWriteMany"}
+			composeVolExt["volumeMode"] = "Block"
+			composeVolExt["annotations"] = map[string]interface{}{"backup.example.com/enabled": "true"}
+			composeVolExt["labels"] = map[string]interface{}{"tier": "data"}
+			composeVolExt["dataSource"] = map[string]interface{}{"kind": "VolumeSnapshot", "name": "db-snapshot"}
+			composeVolExt["retention"] = map[string]interface{}{"whenDeleted": "Delete", "whenScaled": "Retain"}
+
+			cfg, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.AccessModes).To(Equal([]string{"ReadWriteMany"}))
+			Expect(cfg.VolumeMode).To(Equal("Block"))
+			Expect(cfg.Annotations).To(Equal(map[string]string{"backup.example.com/enabled": "true"}))
+			Expect(cfg.Labels).To(Equal(map[string]string{"tier": "data"}))
+			Expect(cfg.DataSource).To(Equal(config.VolumeDataSource{Kind: "VolumeSnapshot", Name: "db-snapshot"}))
+			Expect(cfg.Retention).To(Equal(config.PVCRetentionPolicy{WhenDeleted: "Delete", WhenScaled: "Retain"}))
+		})
+
+		It("validates access modes", func() {
+			composeVolExt["accessModes"] = []interface{}{"ReadWriteAll"}
+			_, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).To(MatchError("VolK8sConfig.AccessModes[0] is invalid, use one of: ReadWriteOnce ReadOnlyMany ReadWriteMany ReadWriteOncePod"))
+		})
+
+		It("validates volume mode", func() {
+			composeVolExt["volumeMode"] = "Raw"
+			_, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).To(MatchError("VolK8sConfig.VolumeMode is invalid, use one of: Filesystem Block"))
+		})
+
+		It("validates data source", func() {
+			composeVolExt["dataSource"] = map[string]interface{}{"kind": "VolumeSnapshot"}
+			_, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).To(MatchError("VolK8sConfig.DataSource.Name is required with Kind"))
+		})
+
+		It("validates retention policy", func() {
+			composeVolExt["retention"] = map[string]interface{}{"whenDeleted": "Keep"}
+			_, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).To(MatchError("VolK8sConfig.Retention.WhenDeleted is invalid, use one of: Retain Delete"))
+		})
+
+		Context("with NFS local driver options", func() {
+			BeforeEach(func() {
+				composeVol.DriverOpts = map[string]string{"type": "nfs", "o": "addr=10.0.0.1,rw", "device": ":/exports/data"}
+			})
+
+			AfterEach(func() {
+				composeVol.DriverOpts = nil
+			})
+
+			It("infers NFS server and path", func() {
+				cfg, err := config.VolK8sConfigFromCompose(&composeVol)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.NFS).To(Equal(config.VolumeNFS{Server: "10.0.0.1", Path: "/exports/data"}))
+			})
+
+			It("infers NFS server from the device when address isn't set", func() {
+				composeVol.DriverOpts = map[string]string{"type": "nfs4", "device": "nas.local:/exports/data"}
+				cfg, err := config.VolK8sConfigFromCompose(&composeVol)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.NFS).To(Equal(config.VolumeNFS{Server: "nas.local", Path: "/exports/data"}))
+			})
+
+			It("keeps explicitly configured NFS share", func() {
+				composeVolExt["nfs"] = map[string]interface{}{"server": "nas.local", "path": "/data", "persistentVolume": true}
+				cfg, err := config.VolK8sConfigFromCompose(&composeVol)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.NFS).To(Equal(config.VolumeNFS{Server: "nas.local", Path: "/data", PersistentVolume: true}))
+			})
+
+			It("ignores other driver types", func() {
+				composeVol.DriverOpts = map[string]string{"type": "tmpfs", "device": "tmpfs"}
+				cfg, err := config.VolK8sConfigFromCompose(&composeVol)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(cfg.NFS.Enabled()).To(BeFalse())
+			})
+		})
+
+		It("validates NFS share", func() {
+			composeVolExt["nfs"] = map[string]interface{}{"server": "nas.local", "path": "data"}
+			_, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("VolK8sConfig.NFS.Path"))
+		})
+
+		It("loads CSI volume", func() {
+			composeVolExt["csi"] = map[string]interface{}{
+				"driver":           "secrets-store.csi.k8s.io",
+				"volumeAttributes": map[string]interface{}{"secretProviderClass": "vault"},
+			}
+			cfg, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.CSI).To(Equal(config.VolumeCSI{
+				Driver:           "secrets-store.csi.k8s.io",
+				VolumeAttributes: map[string]string{"secretProviderClass": "vault"},
+			}))
+		})
+
+		It("rejects CSI driver for ephemeral volumes", func() {
+			composeVolExt["csi"] = map[string]interface{}{"driver": "ebs.csi.aws.com", "ephemeral": true}
+			_, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).To(MatchError("VolK8sConfig.CSI.Driver can't be used with VolK8sConfig.CSI.Ephemeral, set VolK8sConfig.StorageClass instead"))
+		})
+
+		It("loads snapshots", func() {
+			composeVolExt["snapshots"] = []interface{}{
+				map[string]interface{}{"name": "data-before-upgrade", "class": "csi-snapclass"},
+				map[string]interface{}{"schedule": "0 2 * * *", "retention": 7},
+			}
+			cfg, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(cfg.Snapshots).To(Equal([]config.VolumeSnapshot{
+				{Name: "data-before-upgrade", Class: "csi-snapclass"},
+				{Schedule: "0 2 * * *", Retention: 7},
+			}))
+		})
+
+		It("validates snapshot schedule", func() {
+			composeVolExt["snapshots"] = []interface{}{map[string]interface{}{"schedule": "daily"}}
+			_, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).To(MatchError("VolK8sConfig.Snapshots[0].Schedule is invalid, use a cron schedule format, e.g. 0 2 * * *"))
+		})
+
+		It("rejects snapshot retention without schedule", func() {
+			composeVolExt["snapshots"] = []interface{}{map[string]interface{}{"retention": 3}}
+			_, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).To(MatchError("VolK8sConfig.Snapshots[0].Retention is only supported with VolK8sConfig.Snapshots[0].Schedule"))
+		})
+
+		It("rejects snapshots of volumes not claimed via PVC", func() {
+			composeVolExt["csi"] = map[string]interface{}{"driver": "secrets-store.csi.k8s.io"}
+			composeVolExt["snapshots"] = []interface{}{map[string]interface{}{"name": "data"}}
+			_, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).To(MatchError("VolK8sConfig.Snapshots are only supported for volumes claimed via PVC"))
+		})
+
+		It("rejects NFS and CSI used together", func() {
+			composeVolExt["nfs"] = map[string]interface{}{"server": "nas.local", "path": "/data"}
+			composeVolExt["csi"] = map[string]interface{}{"driver": "ebs.csi.aws.com"}
+			_, err := config.VolK8sConfigFromCompose(&composeVol)
+			Expect(err).To(MatchError("VolK8sConfig.NFS and VolK8sConfig.CSI can't be used together"))
+		})
+	})
+
+	Context("merge", func() {
+		It("overrides access modes and retention with the supplied config", func() {
+			base := config.VolK8sConfig{
+				Size:        "1Gi",
+				AccessModes: []string{"ReadWriteOnce"},
+				Retention:   config.PVCRetentionPolicy{WhenDeleted: "Retain"},
+			}
+
+			merged, err := base.Merge(config.VolK8sConfig{
+				AccessModes: []string{"ReadWriteMany"},
+				Retention:   config.PVCRetentionPolicy{WhenDeleted: "Delete"},
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(merged.Size).To(Equal("1Gi"))
+			Expect(merged.AccessModes).To(Equal([]string{"ReadWriteMany"}))
+			Expect(merged.Retention.WhenDeleted).To(Equal("Delete"))
+		})
 	})
 })
@@ -19,7 +19,9 @@ package converter
 import (
 	kmd "github.com/appvia/komando"
 	"github.com/appvia/tako/pkg/tako/converter/dummy"
+	"github.com/appvia/tako/pkg/tako/converter/helm"
 	"github.com/appvia/tako/pkg/tako/converter/kubernetes"
+	"github.com/appvia/tako/pkg/tako/converter/kustomize"
 	composego "github.com/compose-spec/compose-go/types"
 )
 
@@ -35,17 +37,50 @@ type Converter interface {
 		excluded map[string][]string) (map[string]string, error)
 }
 
-// Factory returns a converter
-func Factory(name string, ui kmd.UI) Converter {
+// Output holds options controlling how rendered manifests are output
+type Output struct {
+	// ToStdout prints manifests to stdout instead of writing them to disk
+	ToStdout bool
+	// JSON renders manifests as JSON instead of YAML
+	JSON bool
+}
+
+// Factory returns a converter. Secret matchers and allowlist are used by converters classifying sensitive data.
+// Output options are only supported by the kubernetes and knative converters.
+func Factory(name string, ui kmd.UI, secretMatchers []map[string]string, secretAllowlist map[string][]string, output Output) Converter {
 	switch name {
 	case "dummy":
 		// Dummy converter example
 		return dummy.New()
+	case helm.Name:
+		// Helm chart converter
+		h := helm.NewWithUI(ui)
+		if ui == nil {
+			h = helm.New()
+		}
+		h.SecretMatchers = secretMatchers
+		h.SecretAllowlist = secretAllowlist
+		return h
+	case kustomize.Name:
+		// Kustomize base and overlays converter
+		k := kustomize.NewWithUI(ui)
+		if ui == nil {
+			k = kustomize.New()
+		}
+		k.SecretMatchers = secretMatchers
+		k.SecretAllowlist = secretAllowlist
+		return k
 	default:
-		// Kubernetes manifests converter by default
+		// Kubernetes manifests converter by default, rendering HTTP services as Knative Services for knative
+		k := kubernetes.NewWithUI(ui)
 		if ui == nil {
-			return kubernetes.New()
+			k = kubernetes.New()
 		}
-		return kubernetes.NewWithUI(ui)
+		k.SecretMatchers = secretMatchers
+		k.SecretAllowlist = secretAllowlist
+		k.ToStdout = output.ToStdout
+		k.GenerateJSON = output.JSON
+		k.Knative = name == kubernetes.KnativeName
+		return k
 	}
 }
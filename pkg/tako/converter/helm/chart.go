@@ -0,0 +1,529 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	chartAPIVersion = "v2"
+	chartVersion    = "0.1.0"
+	templatesDir    = "templates"
+	chartFile       = "Chart.yaml"
+	valuesFile      = "values.yaml"
+
+	// enabledKey is the values key toggling objects which aren't rendered in all environments
+	enabledKey = "enabled"
+
+	// placeholderPrefix marks object fields which are replaced by values references
+	placeholderPrefix = "TAKO_VALUE_"
+)
+
+var (
+	// identifierRe matches object keys usable as template values references
+	identifierRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	// placeholderRe matches placeholders in the YAML encoded object templates
+	placeholderRe = regexp.MustCompile(`(?m)^( *)(- )?([A-Za-z_][A-Za-z0-9_]*): (` + placeholderPrefix + `\d+)$`)
+
+	// templatedContainerFields are container fields always taken from values
+	templatedContainerFields = map[string]bool{
+		"image":     true,
+		"resources": true,
+		"env":       true,
+	}
+)
+
+// chart holds kubernetes objects of all environments which are turned into chart templates and values
+type chart struct {
+	name    string
+	envs    []string
+	objects []*chartObject
+	index   map[string]*chartObject
+}
+
+// chartObject holds an object as rendered in each environment
+type chartObject struct {
+	file string
+	kind string
+	root []string
+	envs map[string]map[string]interface{}
+}
+
+// placeholder is an object field replaced by a values reference
+type placeholder struct {
+	path     []string
+	values   map[string]interface{}
+	optional bool
+}
+
+// missing marks object fields absent in an environment
+type missing struct{}
+
+// newChart returns a chart for the given environments
+func newChart(name string, envs []string) *chart {
+	return &chart{
+		name:  name,
+		envs:  envs,
+		index: map[string]*chartObject{},
+	}
+}
+
+// add registers an object rendered in the given environment
+func (c *chart) add(env string, obj map[string]interface{}) error {
+	kind, _ := obj["kind"].(string)
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	if kind == "" || name == "" {
+		return errors.New("kubernetes object is missing kind or metadata name")
+	}
+
+	file := fmt.Sprintf("%s-%s", name, strings.ToLower(kind))
+	o, ok := c.index[file]
+	if !ok {
+		o = &chartObject{
+			file: file,
+			kind: kind,
+			root: []string{valuesKey(name), valuesKey(kind)},
+			envs: map[string]map[string]interface{}{},
+		}
+		c.objects = append(c.objects, o)
+		c.index[file] = o
+	}
+	o.envs[env] = obj
+
+	return nil
+}
+
+// files returns the chart files contents keyed by their path relative to the chart directory
+func (c *chart) files() (map[string][]byte, error) {
+	out := map[string][]byte{}
+
+	common := map[string]interface{}{}
+	envValues := map[string]map[string]interface{}{}
+	for _, env := range c.envs {
+		envValues[env] = map[string]interface{}{}
+	}
+
+	for _, o := range c.objects {
+		tpl, placeholders, err := o.template(c.envs)
+		if err != nil {
+			return nil, errors.Wrapf(err, "%s template", o.file)
+		}
+		out[fmt.Sprintf("%s/%s.yaml", templatesDir, o.file)] = tpl
+
+		// @step values shared by all environments rendering the object go to the default values file
+		for _, p := range placeholders {
+			path := append(append([]string{}, o.root...), p.path...)
+			envs := o.renderedIn(c.envs)
+			value, shared := sharedValue(envs, p.values)
+			if shared {
+				setValue(common, path, value)
+				continue
+			}
+
+			ensureMaps(common, path[:len(path)-1])
+			for _, env := range envs {
+				value, ok := p.values[env]
+				if !ok {
+					// field is missing in the environment, so its optional placeholder isn't rendered
+					continue
+				}
+				setValue(envValues[env], path, value)
+			}
+		}
+
+		// @step objects missing in some environments are toggled per environment
+		if o.partial(c.envs) {
+			setValue(common, append(o.root, enabledKey), false)
+			for _, env := range o.renderedIn(c.envs) {
+				setValue(envValues[env], append(o.root, enabledKey), true)
+			}
+		}
+	}
+
+	meta, err := encode(map[string]interface{}{
+		"apiVersion":  chartAPIVersion,
+		"name":        c.name,
+		"description": fmt.Sprintf("A Helm chart for %s", c.name),
+		"type":        "application",
+		"version":     chartVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	out[chartFile] = meta
+
+	if out[valuesFile], err = encode(common); err != nil {
+		return nil, err
+	}
+
+	for _, env := range c.envs {
+		if out[valuesFileName(env)], err = encode(envValues[env]); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// renderedIn returns the environments rendering the object, in the given order
+func (o *chartObject) renderedIn(envs []string) []string {
+	var out []string
+	for _, env := range envs {
+		if _, ok := o.envs[env]; ok {
+			out = append(out, env)
+		}
+	}
+	return out
+}
+
+// partial tells whether the object isn't rendered in some of the environments
+func (o *chartObject) partial(envs []string) bool {
+	return len(o.envs) < len(envs)
+}
+
+// template returns the object template and its placeholders. Fields with equal values in all
+// environments are kept as is, while templated and differing fields reference the chart values.
+func (o *chartObject) template(envs []string) ([]byte, []*placeholder, error) {
+	t := &templater{kind: o.kind, envs: o.renderedIn(envs)}
+
+	nodes := make([]interface{}, len(t.envs))
+	for i, env := range t.envs {
+		nodes[i] = o.envs[env]
+	}
+	obj := t.walk(nil, nodes)
+
+	data, err := encode(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// @step escape template delimiters of literal values
+	tpl := strings.ReplaceAll(string(data), "{{", "{{`{{`}}")
+
+	// @step replace placeholders with values references
+	tpl = placeholderRe.ReplaceAllStringFunc(tpl, func(match string) string {
+		m := placeholderRe.FindStringSubmatch(match)
+		n, _ := strconv.Atoi(strings.TrimPrefix(m[4], placeholderPrefix))
+		p := t.placeholders[n]
+		path := append(append([]string{}, o.root...), p.path...)
+		ref := ".Values." + strings.Join(path, ".")
+
+		line := fmt.Sprintf("%s%s%s: {{ %s | toJson }}", m[1], m[2], m[3], ref)
+		if p.structured() {
+			indent := len(m[1]) + len(m[2]) + 2
+			line = fmt.Sprintf("%s%s%s: {{- toYaml %s | nindent %d }}", m[1], m[2], m[3], ref, indent)
+		}
+
+		if !p.optional {
+			return line
+		}
+
+		// @step fields absent in some environments are only rendered when set in values
+		cond := fmt.Sprintf("{{- if hasKey .Values.%s %q }}", strings.Join(path[:len(path)-1], "."), path[len(path)-1])
+		if m[2] != "" {
+			// keep the list item when its first field is absent
+			return fmt.Sprintf("%s\n%s\n{{- else }}\n%s-\n{{- end }}", cond, line, m[1])
+		}
+		return fmt.Sprintf("%s\n%s\n{{- end }}", cond, line)
+	})
+
+	if o.partial(envs) {
+		ref := ".Values." + strings.Join(append(append([]string{}, o.root...), enabledKey), ".")
+		tpl = fmt.Sprintf("{{- if %s }}\n%s{{- end }}\n", ref, tpl)
+	}
+
+	return []byte(tpl), t.placeholders, nil
+}
+
+// structured tells whether any of the placeholder values is a map or a list
+func (p *placeholder) structured() bool {
+	for _, v := range p.values {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			return true
+		}
+	}
+	return false
+}
+
+// templater walks an object as rendered in each environment and builds its template
+type templater struct {
+	kind         string
+	envs         []string
+	placeholders []*placeholder
+}
+
+// walk returns the template node for object nodes found at the given field path in each environment
+func (t *templater) walk(fields []string, nodes []interface{}) interface{} {
+	path := valuesPath(fields)
+
+	if !t.templated(path) {
+		if keys, ok := sameKeys(nodes); ok {
+			out := map[string]interface{}{}
+			for _, key := range keys {
+				children := make([]interface{}, len(nodes))
+				for i, n := range nodes {
+					child, ok := n.(map[string]interface{})[key]
+					if !ok {
+						child = missing{}
+					}
+					children[i] = child
+				}
+				out[key] = t.walk(append(append([]string{}, fields...), key), children)
+			}
+			return out
+		}
+
+		if labels, ok := sameLists(fields, nodes); ok {
+			out := make([]interface{}, len(labels))
+			for i, label := range labels {
+				children := make([]interface{}, len(nodes))
+				for j, n := range nodes {
+					children[j] = n.([]interface{})[i]
+				}
+				out[i] = t.walk(label, children)
+			}
+			return out
+		}
+
+		if equal(nodes) {
+			return nodes[0]
+		}
+	}
+
+	p := &placeholder{path: path, values: map[string]interface{}{}}
+	for i, env := range t.envs {
+		if _, ok := nodes[i].(missing); ok {
+			p.optional = true
+			continue
+		}
+		p.values[env] = nodes[i]
+	}
+	t.placeholders = append(t.placeholders, p)
+
+	return fmt.Sprintf("%s%d", placeholderPrefix, len(t.placeholders)-1)
+}
+
+// templated tells whether a field at the given values path is always taken from values
+func (t *templater) templated(path []string) bool {
+	switch {
+	case len(path) == 1 && path[0] == "replicas":
+		return true
+	case len(path) == 3 && (path[0] == "containers" || path[0] == "initContainers"):
+		return templatedContainerFields[path[2]]
+	case t.kind == "Ingress" && len(path) == 2:
+		return (strings.HasPrefix(path[0], "rules") && path[1] == "host") ||
+			(strings.HasPrefix(path[0], "tls") && path[1] == "hosts")
+	}
+	return false
+}
+
+// sameKeys returns sorted keys of maps found in all nodes, as long as the keys can be used
+// in values references. Keys absent in some of the maps are included too.
+func sameKeys(nodes []interface{}) ([]string, bool) {
+	seen := map[string]bool{}
+	var keys []string
+	for _, n := range nodes {
+		m, ok := n.(map[string]interface{})
+		if !ok || len(m) == 0 {
+			return nil, false
+		}
+
+		for k := range m {
+			if !identifierRe.MatchString(k) {
+				return nil, false
+			}
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, true
+}
+
+// sameLists returns field paths of list items for lists of maps of the same length found in all nodes.
+// Items are identified by their name when unique, by their position otherwise.
+func sameLists(fields []string, nodes []interface{}) ([][]string, bool) {
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	var items []interface{}
+	for i, n := range nodes {
+		l, ok := n.([]interface{})
+		if !ok || len(l) == 0 || (i > 0 && len(l) != len(items)) {
+			return nil, false
+		}
+		for _, item := range l {
+			if _, ok := item.(map[string]interface{}); !ok {
+				return nil, false
+			}
+		}
+		items = l
+	}
+	items = nodes[0].([]interface{})
+
+	parent, key := fields[:len(fields)-1], fields[len(fields)-1]
+	out := make([][]string, len(items))
+	seen := map[string]bool{}
+	named := true
+	for i, item := range items {
+		name, _ := item.(map[string]interface{})["name"].(string)
+		label := valuesKey(name)
+		if name == "" || seen[label] {
+			named = false
+			break
+		}
+		seen[label] = true
+		out[i] = append(append([]string{}, fields...), label)
+	}
+
+	if !named {
+		for i := range items {
+			out[i] = append(append([]string{}, parent...), fmt.Sprintf("%s%d", key, i))
+		}
+	}
+
+	return out, true
+}
+
+// valuesPath returns a compact values path of an object field path. Nested pod
+// template fields are shortened, e.g. spec.template.spec.containers becomes containers.
+func valuesPath(fields []string) []string {
+	path := fields
+	if len(path) > 0 && path[0] == "spec" {
+		path = path[1:]
+	}
+	if len(path) > 1 && path[0] == "jobTemplate" && path[1] == "spec" {
+		path = path[2:]
+	}
+	if len(path) > 1 && path[0] == "template" {
+		switch path[1] {
+		case "metadata":
+			path = append([]string{"podMetadata"}, path[2:]...)
+		case "spec":
+			if len(path) > 2 && (path[2] == "containers" || path[2] == "initContainers") {
+				path = path[2:]
+			} else {
+				path = append([]string{"pod"}, path[2:]...)
+			}
+		}
+	}
+	if len(path) == 0 {
+		path = fields
+	}
+	return append([]string{}, path...)
+}
+
+// valuesKey returns a camel cased values key for the given name
+func valuesKey(name string) string {
+	parts := regexp.MustCompile("[^A-Za-z0-9]+").Split(name, -1)
+
+	var b strings.Builder
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteString(strings.ToLower(p[:1]) + p[1:])
+			continue
+		}
+		b.WriteString(strings.ToUpper(p[:1]) + p[1:])
+	}
+
+	key := b.String()
+	if key == "" || !identifierRe.MatchString(key) {
+		key = "_" + key
+	}
+	return key
+}
+
+// valuesFileName returns the values file name of an environment
+func valuesFileName(env string) string {
+	return fmt.Sprintf("values-%s.yaml", env)
+}
+
+// sharedValue returns the value when set and equal in all environments
+func sharedValue(envs []string, values map[string]interface{}) (interface{}, bool) {
+	nodes := make([]interface{}, len(envs))
+	for i, env := range envs {
+		value, ok := values[env]
+		if !ok {
+			return nil, false
+		}
+		nodes[i] = value
+	}
+	if !equal(nodes) {
+		return nil, false
+	}
+	return nodes[0], true
+}
+
+// equal tells whether all nodes are deeply equal
+func equal(nodes []interface{}) bool {
+	for _, n := range nodes[1:] {
+		if !reflect.DeepEqual(nodes[0], n) {
+			return false
+		}
+	}
+	return true
+}
+
+// setValue sets the value at the given path, creating intermediate maps
+func setValue(values map[string]interface{}, path []string, value interface{}) {
+	parent := ensureMaps(values, path[:len(path)-1])
+	parent[path[len(path)-1]] = value
+}
+
+// ensureMaps creates maps along the given path and returns the last one
+func ensureMaps(values map[string]interface{}, path []string) map[string]interface{} {
+	current := values
+	for _, key := range path {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			current[key] = next
+		}
+		current = next
+	}
+	return current
+}
+
+// encode returns YAML encoded data
+func encode(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
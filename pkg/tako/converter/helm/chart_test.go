@@ -0,0 +1,210 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package helm
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Chart", func() {
+
+	var ch *chart
+	var envs []string
+
+	BeforeEach(func() {
+		envs = []string{"dev", "prod"}
+		ch = newChart("my-app", envs)
+	})
+
+	expectRenderedAsAdded := func(objects map[string][]map[string]interface{}) {
+		files, err := ch.files()
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, env := range envs {
+			out, err := renderChart(files, env)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out).To(Equal(expectedObjects(objects[env])), env)
+		}
+	}
+
+	service := func(ports ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata": map[string]interface{}{
+				"name":   "web",
+				"labels": map[string]interface{}{"io.tako/service": "web"},
+			},
+			"spec": map[string]interface{}{
+				"ports": ports,
+			},
+		}
+	}
+
+	When("object fields are equal in all environments", func() {
+
+		It("keeps them in the template", func() {
+			svc := service(map[string]interface{}{"name": "http", "port": 80})
+			Expect(ch.add("dev", svc)).To(Succeed())
+			Expect(ch.add("prod", svc)).To(Succeed())
+
+			files, err := ch.files()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(files["templates/web-service.yaml"])).NotTo(ContainSubstring(".Values"))
+			Expect(string(files[valuesFile])).To(Equal("{}\n"))
+			Expect(string(files["values-dev.yaml"])).To(Equal("{}\n"))
+
+			expectRenderedAsAdded(map[string][]map[string]interface{}{
+				"dev":  {svc},
+				"prod": {svc},
+			})
+		})
+	})
+
+	When("object fields differ between environments", func() {
+
+		It("references environment values", func() {
+			dev := service(map[string]interface{}{"name": "http", "port": 80})
+			prod := service(map[string]interface{}{"name": "http", "port": 8080})
+			Expect(ch.add("dev", dev)).To(Succeed())
+			Expect(ch.add("prod", prod)).To(Succeed())
+
+			files, err := ch.files()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(files["templates/web-service.yaml"])).To(ContainSubstring("port: {{ .Values.web.service.ports.http.port | toJson }}"))
+			Expect(string(files["values-prod.yaml"])).To(ContainSubstring("port: 8080"))
+
+			expectRenderedAsAdded(map[string][]map[string]interface{}{
+				"dev":  {dev},
+				"prod": {prod},
+			})
+		})
+	})
+
+	When("object fields are absent in some environments", func() {
+
+		It("only renders them when set in values", func() {
+			dev := service(
+				map[string]interface{}{"appProtocol": "http", "port": 80},
+				map[string]interface{}{"port": 443},
+			)
+			prod := service(
+				map[string]interface{}{"port": 80},
+				map[string]interface{}{"port": 443, "targetPort": 8443},
+			)
+			Expect(ch.add("dev", dev)).To(Succeed())
+			Expect(ch.add("prod", prod)).To(Succeed())
+
+			files, err := ch.files()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(files["templates/web-service.yaml"])).To(ContainSubstring(`{{- if hasKey .Values.web.service.ports0 "appProtocol" }}`))
+
+			expectRenderedAsAdded(map[string][]map[string]interface{}{
+				"dev":  {dev},
+				"prod": {prod},
+			})
+		})
+	})
+
+	When("optional object field is only set in one environment", func() {
+
+		It("leaves it out of values and rendered object of other environments", func() {
+			dev := service(map[string]interface{}{"name": "http", "port": 80})
+			dev["metadata"].(map[string]interface{})["annotations"] = map[string]interface{}{"example.com/team": "web"}
+			prod := service(map[string]interface{}{"name": "http", "port": 80})
+			Expect(ch.add("dev", dev)).To(Succeed())
+			Expect(ch.add("prod", prod)).To(Succeed())
+
+			files, err := ch.files()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(files["values-dev.yaml"])).To(ContainSubstring("annotations:"))
+			Expect(string(files["values-prod.yaml"])).NotTo(ContainSubstring("annotations"))
+			Expect(string(files[valuesFile])).NotTo(ContainSubstring("annotations"))
+
+			out, err := renderChart(files, "prod")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out["templates/web-service.yaml"]).NotTo(HaveKey("annotations"))
+			Expect(out["templates/web-service.yaml"].(map[string]interface{})["metadata"]).NotTo(HaveKey("annotations"))
+
+			expectRenderedAsAdded(map[string][]map[string]interface{}{
+				"dev":  {dev},
+				"prod": {prod},
+			})
+		})
+	})
+
+	When("object isn't rendered in all environments", func() {
+
+		It("is toggled by environment values", func() {
+			svc := service(map[string]interface{}{"name": "http", "port": 80})
+			Expect(ch.add("prod", svc)).To(Succeed())
+
+			files, err := ch.files()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(files[valuesFile])).To(ContainSubstring("enabled: false"))
+			Expect(string(files["values-prod.yaml"])).To(ContainSubstring("enabled: true"))
+
+			expectRenderedAsAdded(map[string][]map[string]interface{}{
+				"prod": {svc},
+			})
+		})
+	})
+
+	When("object values contain template delimiters", func() {
+
+		It("escapes them", func() {
+			svc := service(map[string]interface{}{"name": "{{ http }}", "port": 80})
+			Expect(ch.add("dev", svc)).To(Succeed())
+			Expect(ch.add("prod", svc)).To(Succeed())
+
+			expectRenderedAsAdded(map[string][]map[string]interface{}{
+				"dev":  {svc},
+				"prod": {svc},
+			})
+		})
+	})
+
+	When("object is missing kind or name", func() {
+
+		It("returns an error", func() {
+			Expect(ch.add("dev", map[string]interface{}{"kind": "Service"})).NotTo(Succeed())
+		})
+	})
+
+	Describe("valuesPath", func() {
+
+		It("shortens pod template paths", func() {
+			Expect(valuesPath([]string{"spec", "template", "spec", "containers", "web", "image"})).To(Equal([]string{"containers", "web", "image"}))
+			Expect(valuesPath([]string{"spec", "template", "spec", "volumes"})).To(Equal([]string{"pod", "volumes"}))
+			Expect(valuesPath([]string{"spec", "template", "metadata", "annotations"})).To(Equal([]string{"podMetadata", "annotations"}))
+			Expect(valuesPath([]string{"spec", "jobTemplate", "spec", "template", "spec", "containers"})).To(Equal([]string{"containers"}))
+			Expect(valuesPath([]string{"spec", "replicas"})).To(Equal([]string{"replicas"}))
+			Expect(valuesPath([]string{"spec"})).To(Equal([]string{"spec"}))
+		})
+	})
+
+	Describe("valuesKey", func() {
+
+		It("camel cases names", func() {
+			Expect(valuesKey("my-api")).To(Equal("myApi"))
+			Expect(valuesKey("Deployment")).To(Equal("deployment"))
+			Expect(valuesKey("HorizontalPodAutoscaler")).To(Equal("horizontalPodAutoscaler"))
+			Expect(valuesKey("1-app")).To(Equal("_1App"))
+		})
+	})
+})
@@ -0,0 +1,198 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/converter/kubernetes"
+	"github.com/appvia/tako/pkg/tako/log"
+	composego "github.com/compose-spec/compose-go/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Name of the converter
+	Name = "helm"
+
+	// MultiFileSubDir is default output directory name for helm charts
+	MultiFileSubDir = "helm"
+
+	// defaultChartName is used when a chart name can't be derived from the working directory
+	defaultChartName = "app"
+)
+
+// Helm is a helm chart converter. It renders a single chart from the compose sources
+// with a values file per environment.
+type Helm struct {
+	UI kmd.UI
+
+	// SecretMatchers are used to classify env vars and env_file contents as secret
+	SecretMatchers []map[string]string
+	// SecretAllowlist holds env var names of compose services which aren't secrets
+	SecretAllowlist map[string][]string
+}
+
+// New return a helm chart converter
+func New() *Helm {
+	return &Helm{}
+}
+
+// NewWithUI returns a helm chart converter using the given UI
+func NewWithUI(ui kmd.UI) *Helm {
+	return &Helm{UI: ui}
+}
+
+// Render generates outcome
+func (c *Helm) Render(singleFile bool,
+	dir, workDir string,
+	projects map[string]*composego.Project,
+	files map[string][]string,
+	additionalFiles []string,
+	rendered map[string][]byte,
+	excluded map[string][]string) (map[string]string, error) {
+
+	if singleFile {
+		log.Warnf("Single file output isn't supported by %s format, rendering a chart directory instead", Name)
+	}
+
+	envs := kubernetes.SortedEnvs(projects)
+	name := chartName(workDir)
+
+	// @step override output directory if specified
+	outDirPath := filepath.Join(workDir, MultiFileSubDir)
+	if dir != "" {
+		outDirPath = dir
+	}
+	chartDir := filepath.Join(outDirPath, name)
+
+	// @step collect kubernetes objects of each environment
+	ch := newChart(name, envs)
+	envManifests := map[string][]byte{}
+	for _, env := range envs {
+		log.Debugf("Rendering environment [%s]", env)
+
+		envFile := files[env][len(files[env])-1]
+		c.UI.Output(fmt.Sprintf("%s: %s", env, envFile))
+
+		objects, err := c.envObjects(projects[env], files[env], excluded[env], additionalFiles)
+		if err != nil {
+			return nil, err
+		}
+
+		// chart templates aren't valid manifests, so objects of each environment are recorded before templating
+		if envManifests[env], err = manifestStream(objects); err != nil {
+			return nil, err
+		}
+
+		for _, o := range objects {
+			if err := ch.add(env, o); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// @step generate chart files
+	chartFiles, err := ch.files()
+	if err != nil {
+		return nil, errors.Wrapf(err, "Could not generate %s chart, details:\n", Name)
+	}
+
+	// @step write chart to disk
+	if err := os.RemoveAll(chartDir); err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Join(chartDir, templatesDir), os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	for _, f := range sortedFileNames(chartFiles) {
+		file := filepath.Join(chartDir, f)
+		if err := os.WriteFile(file, chartFiles[f], 0644); err != nil {
+			return nil, errors.Wrapf(err, "Could not render %s chart to disk, details:\n", Name)
+		}
+	}
+
+	// @step environment manifests are reported against their values file
+	renderOutputPaths := map[string]string{}
+	for _, env := range envs {
+		renderOutputPaths[env] = filepath.Join(chartDir, valuesFileName(env))
+		rendered[renderOutputPaths[env]] = envManifests[env]
+	}
+
+	return renderOutputPaths, nil
+}
+
+// envObjects returns the kubernetes objects of an environment, as maps.
+func (c *Helm) envObjects(project *composego.Project, files, excluded, additionalFiles []string) ([]map[string]interface{}, error) {
+	// @step Get Kubernetes transformer that maps compose project to Kubernetes primitives
+	k := &kubernetes.Kubernetes{
+		Opt:             kubernetes.ConvertOptions{InputFiles: files},
+		Project:         project,
+		Excluded:        excluded,
+		UI:              c.UI,
+		SecretMatchers:  c.SecretMatchers,
+		SecretAllowlist: c.SecretAllowlist,
+	}
+
+	// @step Do the transformation
+	return k.ObjectMaps(additionalFiles)
+}
+
+// manifestStream returns objects encoded as a multi-document YAML stream
+func manifestStream(objects []map[string]interface{}) ([]byte, error) {
+	var docs [][]byte
+	for _, o := range objects {
+		data, err := encode(o)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, data)
+	}
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// chartName returns a chart name derived from the working directory
+func chartName(workDir string) string {
+	if abs, err := filepath.Abs(workDir); err == nil {
+		workDir = abs
+	}
+
+	re := regexp.MustCompile("[^a-z0-9]+")
+	name := strings.Trim(re.ReplaceAllString(strings.ToLower(filepath.Base(workDir)), "-"), "-")
+	if name == "" {
+		return defaultChartName
+	}
+	return name
+}
+
+func sortedFileNames(files map[string][]byte) []string {
+	var out []string
+	for f := range files {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,239 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package helm
+
+import (
+	"os"
+	"path/filepath"
+
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Helm", func() {
+
+	var (
+		h        *Helm
+		workDir  string
+		outDir   string
+		projects map[string]*composego.Project
+		files    map[string][]string
+		excluded map[string][]string
+		rendered map[string][]byte
+	)
+
+	service := func(image, logLevel string, ext map[string]interface{}) composego.ServiceConfig {
+		return composego.ServiceConfig{
+			Name:  "web",
+			Image: image,
+			Ports: []composego.ServicePortConfig{
+				{Target: 80, Published: 8080, Protocol: "tcp"},
+			},
+			Environment: composego.MappingWithEquals{
+				"LOG_LEVEL": &logLevel,
+			},
+			Extensions: map[string]interface{}{config.K8SExtensionKey: ext},
+		}
+	}
+
+	BeforeEach(func() {
+		h = NewWithUI(kmd.NoOpUI())
+
+		var err error
+		workDir, err = os.MkdirTemp("", "my_app")
+		Expect(err).NotTo(HaveOccurred())
+		outDir = ""
+
+		projects = map[string]*composego.Project{
+			"dev": {
+				Services: composego.Services{
+					service("nginx:1.21", "info", map[string]interface{}{
+						"service": map[string]interface{}{
+							"expose": map[string]interface{}{"domain": "dev.example.com"},
+						},
+					}),
+				},
+			},
+			"prod": {
+				Services: composego.Services{
+					service("nginx:1.23", "warn", map[string]interface{}{
+						"workload": map[string]interface{}{
+							"replicas": 3,
+							"resource": map[string]interface{}{"memory": "512Mi"},
+							"autoscale": map[string]interface{}{
+								"maxReplicas": 6,
+							},
+						},
+						"service": map[string]interface{}{
+							"expose": map[string]interface{}{"domain": "www.example.com"},
+						},
+					}),
+				},
+			},
+		}
+
+		files = map[string][]string{
+			"dev":  {filepath.Join(workDir, "docker-compose.yaml"), filepath.Join(workDir, "docker-compose.env.dev.yaml")},
+			"prod": {filepath.Join(workDir, "docker-compose.yaml"), filepath.Join(workDir, "docker-compose.env.prod.yaml")},
+		}
+		excluded = map[string][]string{}
+		rendered = map[string][]byte{}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(workDir)).To(Succeed())
+	})
+
+	Describe("Render", func() {
+
+		var chartDir string
+		var outputPaths map[string]string
+		var renderErr error
+
+		JustBeforeEach(func() {
+			outputPaths, renderErr = h.Render(false, outDir, workDir, projects, files, nil, rendered, excluded)
+			chartDir = filepath.Join(workDir, MultiFileSubDir, chartName(workDir))
+			if outDir != "" {
+				chartDir = filepath.Join(outDir, chartName(workDir))
+			}
+		})
+
+		It("writes a chart with values file for each environment", func() {
+			Expect(renderErr).NotTo(HaveOccurred())
+
+			for _, f := range []string{chartFile, valuesFile, "values-dev.yaml", "values-prod.yaml", "templates/web-deployment.yaml"} {
+				Expect(filepath.Join(chartDir, f)).To(BeAnExistingFile())
+			}
+
+			Expect(outputPaths).To(Equal(map[string]string{
+				"dev":  filepath.Join(chartDir, "values-dev.yaml"),
+				"prod": filepath.Join(chartDir, "values-prod.yaml"),
+			}))
+		})
+
+		It("records the manifests of each environment against its values file, rather than chart templates", func() {
+			Expect(renderErr).NotTo(HaveOccurred())
+			Expect(rendered).To(HaveLen(2))
+
+			for _, env := range []string{"dev", "prod"} {
+				data := rendered[filepath.Join(chartDir, "values-"+env+".yaml")]
+				Expect(string(data)).To(ContainSubstring("kind: Deployment"))
+				Expect(string(data)).NotTo(ContainSubstring("{{"))
+			}
+		})
+
+		It("renders the same objects as kubernetes manifests for each environment", func() {
+			Expect(renderErr).NotTo(HaveOccurred())
+
+			chartFiles := map[string][]byte{}
+			err := filepath.Walk(chartDir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(chartDir, path)
+				if err != nil {
+					return err
+				}
+				chartFiles[rel], err = os.ReadFile(path)
+				return err
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, env := range []string{"dev", "prod"} {
+				objects, err := h.envObjects(projects[env], files[env], excluded[env], nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				out, err := renderChart(chartFiles, env)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(out).To(Equal(expectedObjects(objects)), env)
+			}
+		})
+
+		It("only renders objects of some environments when enabled in their values", func() {
+			Expect(renderErr).NotTo(HaveOccurred())
+
+			tpl, err := os.ReadFile(filepath.Join(chartDir, "templates/web-horizontalpodautoscaler.yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(tpl)).To(HavePrefix("{{- if .Values.web.horizontalPodAutoscaler.enabled }}\n"))
+		})
+
+		It("templates images, replicas, resources, env and ingress hosts", func() {
+			Expect(renderErr).NotTo(HaveOccurred())
+
+			deployment, err := os.ReadFile(filepath.Join(chartDir, "templates/web-deployment.yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(deployment)).To(ContainSubstring("replicas: {{ .Values.web.deployment.replicas | toJson }}"))
+			Expect(string(deployment)).To(ContainSubstring("image: {{ .Values.web.deployment.containers.web.image | toJson }}"))
+			Expect(string(deployment)).To(ContainSubstring("env: {{- toYaml .Values.web.deployment.containers.web.env | nindent 12 }}"))
+			Expect(string(deployment)).To(ContainSubstring("resources: {{- toYaml .Values.web.deployment.containers.web.resources | nindent 12 }}"))
+
+			ingress, err := os.ReadFile(filepath.Join(chartDir, "templates/web-ingress.yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(ingress)).To(ContainSubstring("host: {{ .Values.web.ingress.rules0.host | toJson }}"))
+
+			values, err := os.ReadFile(filepath.Join(chartDir, "values-prod.yaml"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(values)).To(ContainSubstring("image: nginx:1.23"))
+			Expect(string(values)).To(ContainSubstring("host: www.example.com"))
+		})
+
+		When("output directory is specified", func() {
+
+			BeforeEach(func() {
+				outDir = filepath.Join(workDir, "charts")
+			})
+
+			It("writes the chart to that directory", func() {
+				Expect(renderErr).NotTo(HaveOccurred())
+				Expect(filepath.Join(outDir, chartName(workDir), chartFile)).To(BeAnExistingFile())
+			})
+		})
+
+		When("a service is excluded in an environment", func() {
+
+			BeforeEach(func() {
+				excluded["dev"] = []string{"web"}
+			})
+
+			It("toggles the service objects in that environment", func() {
+				Expect(renderErr).NotTo(HaveOccurred())
+
+				values, err := os.ReadFile(filepath.Join(chartDir, "values-dev.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(values)).NotTo(ContainSubstring("enabled: true"))
+
+				values, err = os.ReadFile(filepath.Join(chartDir, "values-prod.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(values)).To(ContainSubstring("enabled: true"))
+			})
+		})
+	})
+
+	Describe("chartName", func() {
+
+		It("derives a chart name from the working directory", func() {
+			Expect(chartName("/path/to/My_App")).To(Equal("my-app"))
+		})
+
+		It("falls back to default name", func() {
+			Expect(chartName("/__")).To(Equal(defaultChartName))
+		})
+	})
+})
@@ -0,0 +1,119 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package helm
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"gopkg.in/yaml.v3"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// renderChart renders chart templates for an environment the way helm does, i.e. merging the environment
+// values file into default values, and returns the rendered objects keyed by their template file
+func renderChart(files map[string][]byte, env string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if err := sigsyaml.Unmarshal(files[valuesFile], &values); err != nil {
+		return nil, err
+	}
+
+	overrides := map[string]interface{}{}
+	if err := sigsyaml.Unmarshal(files[valuesFileName(env)], &overrides); err != nil {
+		return nil, err
+	}
+	mergeValues(values, overrides)
+
+	funcs := sprig.TxtFuncMap()
+	funcs["toYaml"] = func(v interface{}) string {
+		data, err := sigsyaml.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSuffix(string(data), "\n")
+	}
+
+	out := map[string]interface{}{}
+	for name, data := range files {
+		if !strings.HasPrefix(name, templatesDir+"/") {
+			continue
+		}
+
+		tpl, err := template.New(name).Option("missingkey=zero").Funcs(funcs).Parse(string(data))
+		if err != nil {
+			return nil, err
+		}
+
+		var b bytes.Buffer
+		if err := tpl.Execute(&b, map[string]interface{}{"Values": values}); err != nil {
+			return nil, err
+		}
+
+		if strings.TrimSpace(b.String()) == "" {
+			continue
+		}
+
+		var obj map[string]interface{}
+		if err := yaml.Unmarshal(b.Bytes(), &obj); err != nil {
+			return nil, err
+		}
+		out[name] = normalise(obj)
+	}
+
+	return out, nil
+}
+
+// mergeValues merges overrides into values the way helm coalesces them, i.e. null overrides remove default values,
+// while null overrides of keys absent in default values are kept as null
+func mergeValues(values, overrides map[string]interface{}) {
+	for k, v := range overrides {
+		if _, ok := values[k]; ok && v == nil {
+			delete(values, k)
+			continue
+		}
+
+		dst, dstOk := values[k].(map[string]interface{})
+		src, srcOk := v.(map[string]interface{})
+		if dstOk && srcOk {
+			mergeValues(dst, src)
+			continue
+		}
+		values[k] = v
+	}
+}
+
+// expectedObjects returns objects keyed by their template file
+func expectedObjects(objects []map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, o := range objects {
+		name := o["metadata"].(map[string]interface{})["name"].(string)
+		kind := o["kind"].(string)
+		out[templatesDir+"/"+name+"-"+strings.ToLower(kind)+".yaml"] = normalise(o)
+	}
+	return out
+}
+
+// normalise returns a JSON representation of an object, making numbers comparable
+func normalise(o interface{}) interface{} {
+	data, _ := json.Marshal(o)
+	var out interface{}
+	_ = json.Unmarshal(data, &out)
+	return out
+}
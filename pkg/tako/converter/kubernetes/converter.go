@@ -30,16 +30,40 @@ import (
 
 const (
 	// Name of the converter
-	Name                  = "kubernetes"
-	singleFileDefaultName = "k8s.yaml"
+	Name                      = "kubernetes"
+	singleFileDefaultName     = "k8s.yaml"
+	singleFileDefaultNameJSON = "k8s.json"
 
 	// MultiFileSubDir is default output directory name for kubernetes manifests
 	MultiFileSubDir = "k8s"
+
+	// StdoutOutput is reported as the output path of manifests printed to stdout
+	StdoutOutput = "stdout"
+
+	// KnativeName is the name of the converter rendering HTTP services as Knative Services
+	KnativeName                      = "knative"
+	knativeSingleFileDefaultName     = "knative.yaml"
+	knativeSingleFileDefaultNameJSON = "knative.json"
+
+	// KnativeMultiFileSubDir is default output directory name for knative manifests
+	KnativeMultiFileSubDir = "knative"
 )
 
 // K8s is a native kubernetes manifests converter
 type K8s struct {
 	UI kmd.UI
+
+	// SecretMatchers are used to classify env vars and env_file contents as secret
+	SecretMatchers []map[string]string
+	// SecretAllowlist holds env var names of compose services which aren't secrets
+	SecretAllowlist map[string][]string
+
+	// ToStdout prints manifests of a single environment to stdout instead of writing them to disk
+	ToStdout bool
+	// GenerateJSON renders manifests as JSON instead of YAML
+	GenerateJSON bool
+	// Knative renders HTTP services as Knative Services
+	Knative bool
 }
 
 // New return a native Kubernetes converter
@@ -61,7 +85,16 @@ func (c *K8s) Render(singleFile bool,
 	excluded map[string][]string) (map[string]string, error) {
 
 	renderOutputPaths := map[string]string{}
-	envs := getSortedEnvs(projects)
+	envs := SortedEnvs(projects)
+
+	name, subDir, singleFileName, singleFileNameJSON := Name, MultiFileSubDir, singleFileDefaultName, singleFileDefaultNameJSON
+	if c.Knative {
+		name, subDir, singleFileName, singleFileNameJSON = KnativeName, KnativeMultiFileSubDir, knativeSingleFileDefaultName, knativeSingleFileDefaultNameJSON
+	}
+
+	if c.ToStdout && len(envs) != 1 {
+		return nil, errors.Errorf("printing manifests to stdout requires a single environment, got %d", len(envs))
+	}
 
 	for _, env := range envs {
 		project := projects[env]
@@ -77,29 +110,38 @@ func (c *K8s) Render(singleFile bool,
 			// adding env name suffix to the custom directory to differentiate
 			outDirPath = filepath.Join(dir, env)
 		} else {
-			outDirPath = filepath.Join(workDir, MultiFileSubDir, env)
+			outDirPath = filepath.Join(workDir, subDir, env)
+		}
+
+		// @step generate multiple / single file
+		outFilePath := ""
+		switch {
+		case c.ToStdout:
+			outFilePath = StdoutOutput
+		case singleFile && c.GenerateJSON:
+			outFilePath = filepath.Join(outDirPath, singleFileNameJSON)
+		case singleFile:
+			outFilePath = filepath.Join(outDirPath, singleFileName)
+		default:
+			outFilePath = outDirPath
 		}
 
 		// @step create output directory
 		// To generate outcome as a set of separate manifests first must create out directory
 		// as Kompose logic checks for this and only will do that for existing directories,
 		// otherwise will treat OutFile as regular file and output all manifests to that single file.
-		if err := os.MkdirAll(outDirPath, os.ModePerm); err != nil {
-			return nil, err
-		}
-
-		// @step generate multiple / single file
-		outFilePath := ""
-		if singleFile {
-			outFilePath = filepath.Join(outDirPath, singleFileDefaultName)
-		} else {
-			outFilePath = outDirPath
+		if !c.ToStdout {
+			if err := os.MkdirAll(outDirPath, os.ModePerm); err != nil {
+				return nil, err
+			}
 		}
 
 		// @step kubernetes manifests output options
 		convertOpts := ConvertOptions{
-			InputFiles: files[env],
-			OutFile:    outFilePath,
+			InputFiles:   files[env],
+			OutFile:      outFilePath,
+			ToStdout:     c.ToStdout,
+			GenerateJSON: c.GenerateJSON,
 		}
 
 		renderOutputPaths[env] = outFilePath
@@ -113,7 +155,7 @@ func (c *K8s) Render(singleFile bool,
 		}
 
 		// @step Get Kubernetes transformer that maps compose project to Kubernetes primitives
-		k := &Kubernetes{Opt: convertOpts, Project: project, Excluded: exc, UI: c.UI}
+		k := &Kubernetes{Opt: convertOpts, Project: project, Excluded: exc, UI: c.UI, SecretMatchers: c.SecretMatchers, SecretAllowlist: c.SecretAllowlist, Knative: c.Knative}
 
 		// @step Do the transformation
 		objects, err := k.Transform()
@@ -124,14 +166,15 @@ func (c *K8s) Render(singleFile bool,
 		// @step Produce objects
 		err = PrintList(objects, convertOpts, additionalFiles, rendered)
 		if err != nil {
-			return nil, errors.Wrapf(err, "Could not render %s manifests to disk, details:\n", Name)
+			return nil, errors.Wrapf(err, "Could not render %s manifests to disk, details:\n", name)
 		}
 	}
 
 	return renderOutputPaths, nil
 }
 
-func getSortedEnvs(projects map[string]*composego.Project) []string {
+// SortedEnvs returns sorted names of environments to render
+func SortedEnvs(projects map[string]*composego.Project) []string {
 	var out []string
 	for env := range projects {
 		out = append(out, env)
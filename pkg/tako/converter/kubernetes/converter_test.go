@@ -0,0 +1,104 @@
+/**
+ * Copyright 2020 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	kmd "github.com/appvia/komando"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Converter", func() {
+
+	Describe("Render", func() {
+
+		Context("when printing manifests to stdout", func() {
+			c := &K8s{UI: kmd.NoOpUI(), ToStdout: true}
+
+			It("requires a single environment", func() {
+				projects := map[string]*composego.Project{
+					"dev":  {},
+					"prod": {},
+				}
+				files := map[string][]string{
+					"dev":  {"docker-compose.yaml", "docker-compose.env.dev.yaml"},
+					"prod": {"docker-compose.yaml", "docker-compose.env.prod.yaml"},
+				}
+
+				_, err := c.Render(false, "", ".", projects, files, nil, map[string][]byte{}, nil)
+				Expect(err).To(MatchError("printing manifests to stdout requires a single environment, got 2"))
+			})
+		})
+
+		Context("when rendering Knative manifests", func() {
+			var (
+				workDir     string
+				outputPaths map[string]string
+				err         error
+			)
+
+			BeforeEach(func() {
+				workDir, err = ioutil.TempDir("", "knative")
+				Expect(err).NotTo(HaveOccurred())
+
+				c := &K8s{UI: kmd.NoOpUI(), Knative: true}
+				projects := map[string]*composego.Project{
+					"dev": {
+						Services: composego.Services{
+							{
+								Name:  "web",
+								Image: "some-image",
+								Ports: []composego.ServicePortConfig{{Target: 8080, Published: 80, Protocol: "tcp"}},
+								Extensions: map[string]interface{}{
+									"x-k8s": map[string]interface{}{
+										"service": map[string]interface{}{
+											"expose": map[string]interface{}{"domain": "web.example.com"},
+										},
+									},
+								},
+							},
+						},
+					},
+				}
+				files := map[string][]string{"dev": {"docker-compose.yaml", "docker-compose.env.dev.yaml"}}
+
+				outputPaths, err = c.Render(false, "", workDir, projects, files, nil, map[string][]byte{}, nil)
+			})
+
+			AfterEach(func() {
+				_ = os.RemoveAll(workDir)
+			})
+
+			It("writes manifests with Knative Services to the knative directory", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				outDir := filepath.Join(workDir, KnativeMultiFileSubDir, "dev")
+				Expect(outputPaths).To(Equal(map[string]string{"dev": outDir}))
+
+				data, err := ioutil.ReadFile(filepath.Join(outDir, "web-service.yaml"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(data)).To(ContainSubstring("apiVersion: " + KnativeServingAPIVersion))
+				Expect(filepath.Join(outDir, "web-deployment.yaml")).NotTo(BeAnExistingFile())
+			})
+		})
+	})
+})
@@ -0,0 +1,159 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"strings"
+
+	"github.com/appvia/tako/pkg/tako/log"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// envConfigMapSuffix is a name suffix of a per-service ConfigMap holding classified env vars
+	envConfigMapSuffix = "-env-config"
+	// envSecretSuffix is a name suffix of a per-service Secret holding classified env vars
+	envSecretSuffix = "-env-secret"
+)
+
+// envClassificationEnabled returns true when service env vars should be classified into a ConfigMap and a Secret
+func (k *Kubernetes) envClassificationEnabled() bool {
+	cfg, err := k.projectK8sConfig()
+	return err == nil && cfg.Secrets.ClassifyEnv
+}
+
+// secretAllowed checks whether the env var of a project service has been allowlisted as not a secret
+func (k *Kubernetes) secretAllowed(projectService ProjectService, envVar string) bool {
+	for svc, envVars := range k.SecretAllowlist {
+		if k8sServiceNameByRef(k.Project.Services, svc) != projectService.Name {
+			continue
+		}
+		if contains(envVars, envVar) {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyEnvs moves literal env vars of a project service to a per-service Secret when detected as secrets,
+// or to a per-service ConfigMap otherwise. Env vars are then referenced via valueFrom.
+// Env vars referencing other env vars are kept inline, as these are only expanded for literal values.
+// Returns updated env vars and ConfigMap and Secret objects, if any.
+func (k *Kubernetes) classifyEnvs(projectService ProjectService, envs []v1.EnvVar) ([]v1.EnvVar, []runtime.Object, error) {
+	if !k.envClassificationEnabled() {
+		return envs, nil, nil
+	}
+
+	detectors, err := k.secretDetectors()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	configMapName := projectService.Name + envConfigMapSuffix
+	secretName := projectService.Name + envSecretSuffix
+
+	configData := map[string]string{}
+	secretData := map[string][]byte{}
+
+	out := make([]v1.EnvVar, 0, len(envs))
+	for _, env := range envs {
+		if env.ValueFrom != nil || strings.Contains(env.Value, "$(") {
+			out = append(out, env)
+			continue
+		}
+
+		secret := false
+		if !k.secretAllowed(projectService, env.Name) {
+			for _, detector := range detectors {
+				if detector.Detect(env.Name, env.Value) {
+					secret = true
+					break
+				}
+			}
+		}
+
+		if secret {
+			log.DebugfWithFields(log.Fields{
+				"project-service": projectService.Name,
+				"env-var":         env.Name,
+			}, "Env var detected as secret and will be referenced from Secret %s", secretName)
+
+			secretData[env.Name] = []byte(env.Value)
+			out = append(out, v1.EnvVar{
+				Name: env.Name,
+				ValueFrom: &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: secretName},
+						Key:                  env.Name,
+					},
+				},
+			})
+			continue
+		}
+
+		configData[env.Name] = env.Value
+		out = append(out, v1.EnvVar{
+			Name: env.Name,
+			ValueFrom: &v1.EnvVarSource{
+				ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+					LocalObjectReference: v1.LocalObjectReference{Name: configMapName},
+					Key:                  env.Name,
+				},
+			},
+		})
+	}
+
+	var objects []runtime.Object
+	if len(configData) > 0 {
+		objects = append(objects, &v1.ConfigMap{
+			TypeMeta: meta.TypeMeta{
+				Kind:       "ConfigMap",
+				APIVersion: "v1",
+			},
+			ObjectMeta: meta.ObjectMeta{
+				Name:   configMapName,
+				Labels: configLabels(projectService.Name),
+			},
+			Data: configData,
+		})
+	}
+
+	if len(secretData) > 0 {
+		secrets, err := k.secretObjects([]*v1.Secret{
+			{
+				TypeMeta: meta.TypeMeta{
+					Kind:       "Secret",
+					APIVersion: "v1",
+				},
+				ObjectMeta: meta.ObjectMeta{
+					Name:   secretName,
+					Labels: configLabels(projectService.Name),
+				},
+				Type: v1.SecretTypeOpaque,
+				Data: secretData,
+			},
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		objects = append(objects, secrets...)
+	}
+
+	return out, objects, nil
+}
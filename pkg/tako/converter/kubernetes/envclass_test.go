@@ -0,0 +1,138 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var _ = Describe("Env classification", func() {
+
+	var (
+		k              Kubernetes
+		project        composego.Project
+		projectService ProjectService
+		allowlist      map[string][]string
+		envs           []v1.EnvVar
+		out            []v1.EnvVar
+		objects        []runtime.Object
+		err            error
+	)
+
+	BeforeEach(func() {
+		project = composego.Project{
+			Services: composego.Services{{Name: "my_api", Image: "some-image"}},
+			Extensions: map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"secrets": map[string]interface{}{"classifyEnv": true},
+				},
+			},
+		}
+		projectService = ProjectService{ServiceConfig: composego.ServiceConfig{Name: "my-api"}}
+		allowlist = nil
+		envs = []v1.EnvVar{
+			{Name: "DB_URL", Value: "postgres://app:s3cr3t@db:5432/app"},
+			{Name: "LOG_LEVEL", Value: "info"},
+			{Name: "POD_NAME", ValueFrom: &v1.EnvVarSource{FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+			{Name: "URL", Value: "$(DB_URL)/extra"},
+		}
+	})
+
+	JustBeforeEach(func() {
+		k = Kubernetes{
+			Opt:             ConvertOptions{},
+			Project:         &project,
+			UI:              kmd.NoOpUI(),
+			SecretAllowlist: allowlist,
+		}
+		out, objects, err = k.classifyEnvs(projectService, envs)
+	})
+
+	It("references secret env vars from a Secret and the remaining literals from a ConfigMap", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal([]v1.EnvVar{
+			{
+				Name: "DB_URL",
+				ValueFrom: &v1.EnvVarSource{
+					SecretKeyRef: &v1.SecretKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "my-api-env-secret"},
+						Key:                  "DB_URL",
+					},
+				},
+			},
+			{
+				Name: "LOG_LEVEL",
+				ValueFrom: &v1.EnvVarSource{
+					ConfigMapKeyRef: &v1.ConfigMapKeySelector{
+						LocalObjectReference: v1.LocalObjectReference{Name: "my-api-env-config"},
+						Key:                  "LOG_LEVEL",
+					},
+				},
+			},
+			envs[2],
+			envs[3],
+		}))
+	})
+
+	It("creates a ConfigMap and a Secret holding env var values", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(objects).To(Equal([]runtime.Object{
+			&v1.ConfigMap{
+				TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "my-api-env-config", Labels: configLabels("my-api")},
+				Data:       map[string]string{"LOG_LEVEL": "info"},
+			},
+			&v1.Secret{
+				TypeMeta:   meta.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+				ObjectMeta: meta.ObjectMeta{Name: "my-api-env-secret", Labels: configLabels("my-api")},
+				Type:       v1.SecretTypeOpaque,
+				Data:       map[string][]byte{"DB_URL": []byte("postgres://app:s3cr3t@db:5432/app")},
+			},
+		}))
+	})
+
+	Context("with allowlisted env var", func() {
+		BeforeEach(func() {
+			allowlist = map[string][]string{"my_api": {"DB_URL"}}
+		})
+
+		It("keeps it in the ConfigMap", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects).To(HaveLen(1))
+			Expect(objects[0].(*v1.ConfigMap).Data).To(HaveKeyWithValue("DB_URL", "postgres://app:s3cr3t@db:5432/app"))
+		})
+	})
+
+	Context("when classification isn't enabled", func() {
+		BeforeEach(func() {
+			project.Extensions = nil
+		})
+
+		It("keeps env vars inline", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(out).To(Equal(envs))
+			Expect(objects).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,343 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	"github.com/appvia/tako/pkg/tako/log"
+	"github.com/compose-spec/compose-go/envfile"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// envVarK8sReference matches env var values referencing K8s secret, config map, pod field or container resource
+var envVarK8sReference = regexp.MustCompile(`^(config|pod|secret|container)\.[^\.]*\.[^\.]*`)
+
+// envVarK8sObjectReference matches env var values referencing a whole K8s secret or config map, e.g. `secret.my-secret`
+var envVarK8sObjectReference = regexp.MustCompile(`^(config|secret)\.([^\.]+)$`)
+
+// envFile holds env vars loaded from a compose service env_file, shared by workloads via envFrom
+type envFile struct {
+	// name is the K8s ConfigMap or Secret name
+	name string
+	// file is the env_file path as referenced by compose service
+	file string
+	// path is the env_file path resolved against project working directory
+	path string
+	// vars holds env vars as defined in the file
+	vars map[string]string
+	// data holds env var values with service hostnames resolved
+	data map[string]string
+	// secret is true when any of the env vars looks like a secret
+	secret bool
+}
+
+// envFileObjectName returns K8s name of ConfigMap or Secret holding env vars of an env_file
+func envFileObjectName(file string) string {
+	return rfc1123dns(strings.TrimPrefix(file, "./"))
+}
+
+// secretDetectors returns secret detectors used to classify env vars and env_file contents.
+// Defaults to the built-in secret matchers.
+func (k *Kubernetes) secretDetectors() ([]config.SecretDetector, error) {
+	matchers := k.SecretMatchers
+	if matchers == nil {
+		matchers = config.SecretMatchers
+	}
+	return config.NewSecretDetectors(matchers)
+}
+
+// envFiles returns env files of a project service in the order of precedence.
+// Returns nothing unless env vars are configured to be loaded via envFrom for the project service.
+func (k *Kubernetes) envFiles(projectService ProjectService) ([]envFile, error) {
+	if !projectService.SvcK8sConfig.Workload.EnvFrom || len(projectService.EnvFile) == 0 {
+		return nil, nil
+	}
+
+	detectors, err := k.secretDetectors()
+	if err != nil {
+		return nil, err
+	}
+
+	var out []envFile
+	for _, file := range projectService.EnvFile {
+		ef, err := k.loadEnvFile(file, detectors)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, ef)
+	}
+
+	return out, nil
+}
+
+// loadEnvFile loads env vars from an env_file and classifies them as secret when any of them is detected as such,
+// unless allowlisted as not a secret.
+// Env vars referencing K8s objects or other env vars are left out, as these get resolved per container.
+func (k *Kubernetes) loadEnvFile(file string, detectors []config.SecretDetector) (envFile, error) {
+	path := filepath.Clean(k.projectPath(file))
+	vars, err := envfile.Parse(path)
+	if err != nil {
+		return envFile{}, errors.Wrapf(err, "cannot read env file %s", file)
+	}
+
+	ef := envFile{
+		name: envFileObjectName(file),
+		file: file,
+		path: path,
+		vars: map[string]string{},
+		data: map[string]string{},
+	}
+
+	for key, value := range vars.Resolve(os.LookupEnv).RemoveEmpty() {
+		if envVarK8sReference.MatchString(*value) || envVarK8sObjectReference.MatchString(*value) {
+			continue
+		}
+
+		data, err := k.configServiceHostnames(*value)
+		if err != nil {
+			return envFile{}, fmt.Errorf("environment variable %s in env file %s is invalid: %s", key, file, err)
+		}
+		if strings.Contains(data, "{{") && strings.Contains(data, "}}") {
+			continue
+		}
+
+		ef.vars[key] = *value
+		ef.data[key] = data
+
+		if k.envFileSecretAllowed(path, key) {
+			continue
+		}
+		for _, detector := range detectors {
+			if detector.Detect(key, *value) {
+				ef.secret = true
+				break
+			}
+		}
+	}
+
+	return ef, nil
+}
+
+// envFileSecretAllowed checks whether the env var of an env_file has been allowlisted as not a secret
+// for all project services loading the env_file via envFrom, as they share its ConfigMap or Secret
+func (k *Kubernetes) envFileSecretAllowed(path, envVar string) bool {
+	var allowed bool
+	for _, svc := range k.Project.Services {
+		if contains(k.Excluded, svc.Name) {
+			continue
+		}
+		projectService, err := NewProjectService(svc)
+		if err != nil || !projectService.enabled() || !projectService.SvcK8sConfig.Workload.EnvFrom {
+			continue
+		}
+		projectService.Name = k8sServiceName(svc)
+
+		for _, file := range svc.EnvFile {
+			if filepath.Clean(k.projectPath(file)) != path {
+				continue
+			}
+			if !k.secretAllowed(projectService, envVar) {
+				return false
+			}
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// envFromVars returns env vars provided to project service workload via envFrom, with the last env file taking precedence
+func (k *Kubernetes) envFromVars(projectService ProjectService) (map[string]string, error) {
+	files, err := k.envFiles(projectService)
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[string]string{}
+	for _, ef := range files {
+		for key, value := range ef.vars {
+			out[key] = value
+		}
+	}
+	return out, nil
+}
+
+// configEnvFrom returns project service workload envFrom sources referencing env file ConfigMaps and Secrets,
+// followed by K8s secrets and config maps imported as a whole via `secret.{name}` and `config.{name}` env var values.
+func (k *Kubernetes) configEnvFrom(projectService ProjectService) ([]v1.EnvFromSource, error) {
+	files, err := k.envFiles(projectService)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []v1.EnvFromSource
+	for _, ef := range files {
+		ref := v1.LocalObjectReference{Name: ef.name}
+		if ef.secret {
+			out = append(out, v1.EnvFromSource{SecretRef: &v1.SecretEnvSource{LocalObjectReference: ref}})
+		} else {
+			out = append(out, v1.EnvFromSource{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: ref}})
+		}
+	}
+
+	return append(out, envObjectRefs(projectService)...), nil
+}
+
+// envObjectRefs returns envFrom sources for env vars referencing a whole K8s secret or config map, in env var name order.
+// Env var name is used as a prefix of imported keys when it ends with `_`, e.g. `DB_: secret.db-credentials`.
+func envObjectRefs(projectService ProjectService) []v1.EnvFromSource {
+	env := projectService.environment()
+
+	var names []string
+	for name, value := range env {
+		if value != nil && envVarK8sObjectReference.MatchString(*value) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var out []v1.EnvFromSource
+	for _, name := range names {
+		m := envVarK8sObjectReference.FindStringSubmatch(*env[name])
+
+		prefix := ""
+		if strings.HasSuffix(name, "_") {
+			prefix = name
+		}
+
+		ref := v1.LocalObjectReference{Name: m[2]}
+		if m[1] == "secret" {
+			out = append(out, v1.EnvFromSource{Prefix: prefix, SecretRef: &v1.SecretEnvSource{LocalObjectReference: ref}})
+		} else {
+			out = append(out, v1.EnvFromSource{Prefix: prefix, ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: ref}})
+		}
+	}
+
+	return out
+}
+
+// createEnvFileObjects creates a ConfigMap, or a Secret when secrets have been detected, for each env_file
+// loaded via envFrom. Objects are shared by all project services referencing the same env_file.
+func (k *Kubernetes) createEnvFileObjects() ([]*v1.ConfigMap, []*v1.Secret, error) {
+	files := map[string]envFile{}
+	for _, svc := range k.Project.Services {
+		if contains(k.Excluded, svc.Name) {
+			continue
+		}
+
+		projectService, err := NewProjectService(svc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !projectService.enabled() {
+			continue
+		}
+
+		efs, err := k.envFiles(projectService)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, ef := range efs {
+			if existing, ok := files[ef.name]; ok && existing.path != ef.path {
+				return nil, nil, fmt.Errorf("env files %s and %s resolve to the same K8s name %s", existing.file, ef.file, ef.name)
+			}
+			files[ef.name] = ef
+		}
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var configMaps []*v1.ConfigMap
+	var secrets []*v1.Secret
+	for _, name := range names {
+		ef := files[name]
+
+		if !ef.secret {
+			configMaps = append(configMaps, &v1.ConfigMap{
+				TypeMeta: meta.TypeMeta{
+					Kind:       "ConfigMap",
+					APIVersion: "v1",
+				},
+				ObjectMeta: meta.ObjectMeta{
+					Name:   name,
+					Labels: configLabels(name),
+				},
+				Data: ef.data,
+			})
+			continue
+		}
+
+		log.DebugfWithFields(log.Fields{
+			"env-file": ef.file,
+		}, "Env file contains secrets and will be converted to a Secret")
+
+		data := map[string][]byte{}
+		for key, value := range ef.data {
+			data[key] = []byte(value)
+		}
+		secrets = append(secrets, &v1.Secret{
+			TypeMeta: meta.TypeMeta{
+				Kind:       "Secret",
+				APIVersion: "v1",
+			},
+			ObjectMeta: meta.ObjectMeta{
+				Name:   name,
+				Labels: configLabels(name),
+			},
+			Type: v1.SecretTypeOpaque,
+			Data: data,
+		})
+	}
+
+	return configMaps, secrets, nil
+}
+
+// envFileObjects returns env file ConfigMaps and Secrets, with Secrets rendered as configured for the project
+func (k *Kubernetes) envFileObjects() ([]runtime.Object, error) {
+	configMaps, secrets, err := k.createEnvFileObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []runtime.Object
+	for _, cm := range configMaps {
+		objects = append(objects, cm)
+	}
+
+	if len(secrets) == 0 {
+		return objects, nil
+	}
+
+	secretObjects, err := k.secretObjects(secrets)
+	if err != nil {
+		return nil, err
+	}
+	return append(objects, secretObjects...), nil
+}
@@ -0,0 +1,312 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Env files", func() {
+
+	var (
+		k       Kubernetes
+		project composego.Project
+		tmpDir  string
+		envFrom map[string]interface{}
+		err     error
+	)
+
+	newService := func(name string, envFiles ...string) composego.ServiceConfig {
+		return composego.ServiceConfig{
+			Name:    name,
+			Image:   "some-image",
+			EnvFile: envFiles,
+			Extensions: map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"workload": envFrom,
+				},
+			},
+		}
+	}
+
+	writeEnvFile := func(name, content string) {
+		Expect(os.MkdirAll(filepath.Dir(filepath.Join(tmpDir, name)), os.ModePerm)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0600)).To(Succeed())
+	}
+
+	value := func(s string) *string {
+		return &s
+	}
+
+	BeforeEach(func() {
+		tmpDir, err = ioutil.TempDir("", "env-files")
+		Expect(err).NotTo(HaveOccurred())
+
+		writeEnvFile("common.env", "LOG_LEVEL=info\nAPI_URL=http://api:8080\n")
+		writeEnvFile("config/db.env", "DB_HOST=db\nDB_PASSWORD=postgres://app:s3cr3t@db:5432/app\n")
+
+		envFrom = map[string]interface{}{"envFrom": true}
+		project = composego.Project{WorkingDir: tmpDir}
+	})
+
+	JustBeforeEach(func() {
+		k = Kubernetes{
+			Opt:     ConvertOptions{},
+			Project: &project,
+			UI:      kmd.NoOpUI(),
+		}
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	Describe("createEnvFileObjects", func() {
+		var (
+			configMaps []*v1.ConfigMap
+			secrets    []*v1.Secret
+		)
+
+		BeforeEach(func() {
+			project.Services = composego.Services{
+				newService("api", "common.env", "./config/db.env"),
+				newService("worker", "./common.env"),
+			}
+		})
+
+		JustBeforeEach(func() {
+			configMaps, secrets, err = k.createEnvFileObjects()
+		})
+
+		It("creates a single ConfigMap for env file shared by services", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configMaps).To(Equal([]*v1.ConfigMap{
+				{
+					TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+					ObjectMeta: meta.ObjectMeta{Name: "common-env", Labels: configLabels("common-env")},
+					Data: map[string]string{
+						"LOG_LEVEL": "info",
+						"API_URL":   "http://api:8080",
+					},
+				},
+			}))
+		})
+
+		It("creates a Secret for env file containing secrets", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secrets).To(Equal([]*v1.Secret{
+				{
+					TypeMeta:   meta.TypeMeta{Kind: "Secret", APIVersion: "v1"},
+					ObjectMeta: meta.ObjectMeta{Name: "config-db-env", Labels: configLabels("config-db-env")},
+					Type:       v1.SecretTypeOpaque,
+					Data: map[string][]byte{
+						"DB_HOST":     []byte("db"),
+						"DB_PASSWORD": []byte("postgres://app:s3cr3t@db:5432/app"),
+					},
+				},
+			}))
+		})
+
+		Context("with custom secret matchers", func() {
+			JustBeforeEach(func() {
+				k.SecretMatchers = []map[string]string{
+					{"part": config.PartIdentifier, "match": "(?i)level", "description": "a log level"},
+				}
+				configMaps, secrets, err = k.createEnvFileObjects()
+			})
+
+			It("classifies env files with the supplied matchers", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configMaps).To(HaveLen(1))
+				Expect(configMaps[0].Name).To(Equal("config-db-env"))
+				Expect(secrets).To(HaveLen(1))
+				Expect(secrets[0].Name).To(Equal("common-env"))
+			})
+		})
+
+		Context("with allowlisted env vars", func() {
+			JustBeforeEach(func() {
+				k.SecretAllowlist = map[string][]string{"api": {"DB_HOST", "DB_PASSWORD"}}
+				configMaps, secrets, err = k.createEnvFileObjects()
+			})
+
+			It("doesn't classify env files with only allowlisted secrets as secret", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(secrets).To(BeEmpty())
+				Expect(configMaps).To(HaveLen(2))
+				Expect(configMaps[1].Name).To(Equal("config-db-env"))
+			})
+		})
+
+		Context("with env vars allowlisted for some of the services sharing an env file", func() {
+			JustBeforeEach(func() {
+				k.SecretMatchers = []map[string]string{
+					{"part": config.PartIdentifier, "match": "(?i)level", "description": "a log level"},
+				}
+				k.SecretAllowlist = map[string][]string{"api": {"LOG_LEVEL"}}
+				configMaps, secrets, err = k.createEnvFileObjects()
+			})
+
+			It("classifies the env file as secret", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(secrets).To(HaveLen(1))
+				Expect(secrets[0].Name).To(Equal("common-env"))
+			})
+
+			It("doesn't classify the env file as secret when allowlisted for all of them", func() {
+				k.SecretAllowlist["worker"] = []string{"LOG_LEVEL"}
+				configMaps, secrets, err = k.createEnvFileObjects()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(secrets).To(BeEmpty())
+				Expect(configMaps).To(HaveLen(2))
+			})
+		})
+
+		Context("with hostnames rewrite enabled", func() {
+			BeforeEach(func() {
+				project.Services = composego.Services{
+					newService("worker", "common.env"),
+					{Name: "api", Image: "some-image"},
+				}
+				project.Extensions = map[string]interface{}{
+					config.K8SExtensionKey: map[string]interface{}{
+						"namespace": "prod",
+						"hostnames": map[string]interface{}{"rewrite": true},
+					},
+				}
+			})
+
+			It("rewrites service hostnames in env file values", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configMaps[0].Data).To(HaveKeyWithValue("API_URL", "http://api.prod.svc.cluster.local:8080"))
+			})
+		})
+
+		Context("when envFrom isn't enabled", func() {
+			BeforeEach(func() {
+				envFrom = map[string]interface{}{}
+				project.Services = composego.Services{newService("api", "common.env")}
+			})
+
+			It("doesn't create any objects", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(configMaps).To(BeEmpty())
+				Expect(secrets).To(BeEmpty())
+			})
+		})
+
+		Context("when env file doesn't exist", func() {
+			BeforeEach(func() {
+				project.Services = composego.Services{newService("api", "common.env")}
+				project.Services[0].EnvFile = []string{"missing.env"}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(ContainSubstring("cannot read env file missing.env")))
+			})
+		})
+	})
+
+	Describe("workload env", func() {
+		var (
+			projectService ProjectService
+			envs           []v1.EnvVar
+			sources        []v1.EnvFromSource
+		)
+
+		BeforeEach(func() {
+			svc := newService("api", "common.env", "config/db.env")
+			svc.Environment = composego.MappingWithEquals{
+				"LOG_LEVEL":   value("info"),
+				"API_URL":     value("http://api:8080"),
+				"DB_HOST":     value("db-replica"),
+				"DB_PASSWORD": value("postgres://app:s3cr3t@db:5432/app"),
+				"DEBUG":       value("true"),
+			}
+			project.Services = composego.Services{svc}
+		})
+
+		JustBeforeEach(func() {
+			projectService, err = NewProjectService(project.Services[0])
+			Expect(err).NotTo(HaveOccurred())
+
+			envs, err = k.configEnvs(projectService)
+			Expect(err).NotTo(HaveOccurred())
+
+			sources, err = k.configEnvFrom(projectService)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("references env file ConfigMaps and Secrets in order", func() {
+			Expect(sources).To(Equal([]v1.EnvFromSource{
+				{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "common-env"}}},
+				{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "config-db-env"}}},
+			}))
+		})
+
+		It("keeps only env vars not provided by env files, or overriding their values", func() {
+			Expect(envs).To(Equal([]v1.EnvVar{
+				{Name: "DB_HOST", Value: "db-replica"},
+				{Name: "DEBUG", Value: "true"},
+			}))
+		})
+
+		Context("when envFrom isn't enabled", func() {
+			BeforeEach(func() {
+				project.Services[0].Extensions = nil
+			})
+
+			It("keeps all env vars inline", func() {
+				Expect(sources).To(BeEmpty())
+				Expect(envs).To(HaveLen(5))
+			})
+		})
+
+		Context("when env vars reference whole K8s secrets or config maps", func() {
+			BeforeEach(func() {
+				project.Services[0].Environment["DB_"] = value("secret.db-credentials")
+				project.Services[0].Environment["SETTINGS"] = value("config.app-settings")
+			})
+
+			It("imports them via envFrom after env files, using env var name ending with _ as prefix", func() {
+				Expect(sources).To(Equal([]v1.EnvFromSource{
+					{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "common-env"}}},
+					{SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "config-db-env"}}},
+					{Prefix: "DB_", SecretRef: &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "db-credentials"}}},
+					{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "app-settings"}}},
+				}))
+			})
+
+			It("doesn't add them as env vars", func() {
+				Expect(envs).To(Equal([]v1.EnvVar{
+					{Name: "DB_HOST", Value: "db-replica"},
+					{Name: "DEBUG", Value: "true"},
+				}))
+			})
+		})
+	})
+})
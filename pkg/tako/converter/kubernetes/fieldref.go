@@ -0,0 +1,124 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"regexp"
+
+	"github.com/appvia/tako/pkg/tako/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// DownwardAPIVolumeName is the name of the downward API volume exposing pod labels and annotations
+const DownwardAPIVolumeName = "podinfo"
+
+// podFieldPath describes a pod field that can be referenced by an env var
+type podFieldPath struct {
+	// path is the pod field path
+	path string
+	// keyed is true when the field path requires a key, e.g. `metadata.labels['app']`
+	keyed bool
+	// since is the minimum K8s version supporting the field path, empty when supported by all maintained versions
+	since string
+}
+
+// podFieldPaths lists pod field paths supported by env var field references.
+// https://kubernetes.io/docs/concepts/workloads/pods/downward-api/#downwardapi-fieldRef
+var podFieldPaths = []podFieldPath{
+	{path: "metadata.name"},
+	{path: "metadata.namespace"},
+	{path: "metadata.uid"},
+	{path: "metadata.labels", keyed: true},
+	{path: "metadata.annotations", keyed: true},
+	{path: "spec.nodeName"},
+	{path: "spec.serviceAccountName"},
+	{path: "status.hostIP"},
+	{path: "status.hostIPs", since: "1.29"},
+	{path: "status.podIP"},
+	{path: "status.podIPs", since: "1.21"},
+}
+
+// podFieldKeyedPath matches keyed pod field paths, e.g. `metadata.labels['app.kubernetes.io/name']`
+var podFieldKeyedPath = regexp.MustCompile(`^([^\[]+)\['[^']+'\]$`)
+
+// lookupPodFieldPath returns the supported pod field matching the referenced path
+func lookupPodFieldPath(path string) (podFieldPath, bool) {
+	keyed := false
+	if m := podFieldKeyedPath.FindStringSubmatch(path); m != nil {
+		path = m[1]
+		keyed = true
+	}
+
+	for _, f := range podFieldPaths {
+		if f.path == path && f.keyed == keyed {
+			return f, true
+		}
+	}
+
+	return podFieldPath{}, false
+}
+
+// warnPodFieldPathVersion warns when the referenced pod field path requires a recent K8s version
+func warnPodFieldPathVersion(projectService ProjectService, envVar string, field podFieldPath) {
+	if field.since == "" {
+		return
+	}
+
+	log.WarnfWithFields(log.Fields{
+		"project-service": projectService.Name,
+		"env-var":         envVar,
+		"path":            field.path,
+	}, "Pod field %s is only supported by K8s %s or later", field.path, field.since)
+}
+
+// configDownwardAPIVolume returns the downward API volume exposing pod labels and/or annotations as files,
+// along with its container mount. Returns nil when not enabled for the project service.
+func (k *Kubernetes) configDownwardAPIVolume(projectService ProjectService) (*v1.VolumeMount, *v1.Volume) {
+	cfg := projectService.SvcK8sConfig.Workload.DownwardAPI
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	var items []v1.DownwardAPIVolumeFile
+	if cfg.Labels {
+		items = append(items, v1.DownwardAPIVolumeFile{
+			Path:     "labels",
+			FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.labels"},
+		})
+	}
+	if cfg.Annotations {
+		items = append(items, v1.DownwardAPIVolumeFile{
+			Path:     "annotations",
+			FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.annotations"},
+		})
+	}
+
+	volumeMount := v1.VolumeMount{
+		Name:      DownwardAPIVolumeName,
+		MountPath: cfg.MountPath(),
+		ReadOnly:  true,
+	}
+
+	volume := v1.Volume{
+		Name: DownwardAPIVolumeName,
+		VolumeSource: v1.VolumeSource{
+			DownwardAPI: &v1.DownwardAPIVolumeSource{Items: items},
+		},
+	}
+
+	return &volumeMount, &volume
+}
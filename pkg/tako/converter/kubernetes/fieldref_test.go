@@ -0,0 +1,138 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Pod field references", func() {
+
+	Describe("lookupPodFieldPath", func() {
+		It("finds supported pod field paths", func() {
+			field, ok := lookupPodFieldPath("metadata.uid")
+			Expect(ok).To(BeTrue())
+			Expect(field.path).To(Equal("metadata.uid"))
+		})
+
+		It("finds keyed pod field paths", func() {
+			field, ok := lookupPodFieldPath("metadata.annotations['example.com/team']")
+			Expect(ok).To(BeTrue())
+			Expect(field.path).To(Equal("metadata.annotations"))
+		})
+
+		It("rejects keyed pod field paths without a key", func() {
+			_, ok := lookupPodFieldPath("metadata.annotations")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("rejects a key for pod field paths which don't support it", func() {
+			_, ok := lookupPodFieldPath("metadata.name['foo']")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns minimum K8s version for recently added pod field paths", func() {
+			field, ok := lookupPodFieldPath("status.hostIPs")
+			Expect(ok).To(BeTrue())
+			Expect(field.since).To(Equal("1.29"))
+		})
+	})
+
+	Describe("configDownwardAPIVolume", func() {
+		var (
+			k           Kubernetes
+			downwardAPI map[string]interface{}
+			volumeMount *v1.VolumeMount
+			volume      *v1.Volume
+		)
+
+		BeforeEach(func() {
+			downwardAPI = map[string]interface{}{}
+		})
+
+		JustBeforeEach(func() {
+			projectService, err := NewProjectService(composego.ServiceConfig{
+				Name:  "api",
+				Image: "some-image",
+				Extensions: map[string]interface{}{
+					config.K8SExtensionKey: map[string]interface{}{
+						"workload": map[string]interface{}{
+							"downwardAPI": downwardAPI,
+						},
+					},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			k = Kubernetes{
+				Opt:     ConvertOptions{},
+				Project: &composego.Project{Services: composego.Services{projectService.ServiceConfig}},
+				UI:      kmd.NoOpUI(),
+			}
+
+			volumeMount, volume = k.configDownwardAPIVolume(projectService)
+		})
+
+		Context("when not enabled", func() {
+			It("returns nothing", func() {
+				Expect(volumeMount).To(BeNil())
+				Expect(volume).To(BeNil())
+			})
+		})
+
+		Context("when labels and annotations are exposed", func() {
+			BeforeEach(func() {
+				downwardAPI = map[string]interface{}{
+					"labels":      true,
+					"annotations": true,
+				}
+			})
+
+			It("mounts a downward API volume at the default path", func() {
+				Expect(volumeMount).To(Equal(&v1.VolumeMount{
+					Name:      DownwardAPIVolumeName,
+					MountPath: config.DefaultDownwardAPIPath,
+					ReadOnly:  true,
+				}))
+
+				Expect(volume.DownwardAPI.Items).To(Equal([]v1.DownwardAPIVolumeFile{
+					{Path: "labels", FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.labels"}},
+					{Path: "annotations", FieldRef: &v1.ObjectFieldSelector{FieldPath: "metadata.annotations"}},
+				}))
+			})
+		})
+
+		Context("when custom path is specified", func() {
+			BeforeEach(func() {
+				downwardAPI = map[string]interface{}{
+					"path":   "/etc/meta",
+					"labels": true,
+				}
+			})
+
+			It("mounts the downward API volume at the specified path", func() {
+				Expect(volumeMount.MountPath).To(Equal("/etc/meta"))
+				Expect(volume.DownwardAPI.Items).To(HaveLen(1))
+			})
+		})
+	})
+})
@@ -0,0 +1,420 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	"github.com/appvia/tako/pkg/tako/log"
+	v1apps "k8s.io/api/apps/v1"
+	autoscalingv2beta2 "k8s.io/api/autoscaling/v2beta2"
+	v1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// KnativeServingAPIVersion is the API version of Knative Services
+	KnativeServingAPIVersion = "serving.knative.dev/v1"
+
+	// KnativeDomainMappingAPIVersion is the API version of Knative DomainMappings
+	KnativeDomainMappingAPIVersion = "serving.knative.dev/v1beta1"
+
+	// KnativeVisibilityLabel controls whether a Knative Service is reachable from outside of the cluster
+	KnativeVisibilityLabel = "networking.knative.dev/visibility"
+
+	// KnativeMinScaleAnnotation is a revision annotation holding the minimum number of replicas
+	KnativeMinScaleAnnotation = "autoscaling.knative.dev/min-scale"
+
+	// KnativeMaxScaleAnnotation is a revision annotation holding the maximum number of replicas
+	KnativeMaxScaleAnnotation = "autoscaling.knative.dev/max-scale"
+
+	// knativeClusterLocal is the visibility of Knative Services which aren't exposed
+	knativeClusterLocal = "cluster-local"
+
+	// knativeServicePort is the port Knative Services are reachable on within the cluster
+	knativeServicePort = 80
+)
+
+// knativeService tells whether the project service gets rendered as a Knative Service.
+// HTTP services are rendered as Knative Services unless they rely on features Knative Serving doesn't support,
+// in which case plain Kubernetes objects get rendered instead. Services explicitly enabled must be supported.
+func (k *Kubernetes) knativeService(projectService ProjectService, objects []runtime.Object) (bool, error) {
+	kn := projectService.SvcK8sConfig.Workload.Knative
+
+	if !k.Knative || kn.Disabled() {
+		return false, nil
+	}
+
+	if !kn.Explicit() && !knativeHTTPService(projectService) {
+		return false, nil
+	}
+
+	if reason := k.knativeUnsupported(projectService, objects); reason != "" {
+		if kn.Explicit() {
+			return false, fmt.Errorf("service %s can't be rendered as a Knative Service: %s", projectService.Name, reason)
+		}
+
+		log.WarnfWithFields(log.Fields{
+			"project-service": projectService.Name,
+		}, "Rendering plain Kubernetes objects instead of a Knative Service: %s", reason)
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// knativeHTTPService tells whether the project service serves HTTP traffic, i.e. it's exposed via ingress,
+// one of its service ports uses HTTP application protocol, or it's checked with an HTTP probe
+func knativeHTTPService(projectService ProjectService) bool {
+	if domain, _ := projectService.exposeService(); domain != "" {
+		return true
+	}
+
+	for _, port := range projectService.SvcK8sConfig.Service.Ports {
+		if knativeHTTPProtocol(port.AppProtocol) {
+			return true
+		}
+	}
+
+	workload := projectService.SvcK8sConfig.Workload
+	return workload.LivenessProbe.Type == config.ProbeTypeHTTP.String() ||
+		workload.ReadinessProbe.Type == config.ProbeTypeHTTP.String()
+}
+
+// knativeHTTPProtocol tells whether application protocol is served over HTTP
+func knativeHTTPProtocol(appProtocol string) bool {
+	switch strings.ToLower(appProtocol) {
+	case "http", "http2", "h2c", "kubernetes.io/h2c":
+		return true
+	}
+	return false
+}
+
+// knativeH2C tells whether the project service serves HTTP/2 over cleartext
+func knativeH2C(projectService ProjectService) bool {
+	for _, port := range projectService.SvcK8sConfig.Service.Ports {
+		switch strings.ToLower(port.AppProtocol) {
+		case "http2", "h2c", "kubernetes.io/h2c":
+			return true
+		}
+	}
+	return false
+}
+
+// knativeUnsupported returns the reason why the project service can't be rendered as a Knative Service,
+// or an empty string when Knative Serving supports it
+func (k *Kubernetes) knativeUnsupported(projectService ProjectService, objects []runtime.Object) string {
+	if workloadType := projectService.workloadType(); !config.WorkloadTypesEqual(workloadType, config.DeploymentWorkload) {
+		return fmt.Sprintf("%s workload isn't supported", workloadType)
+	}
+
+	serviceType, _ := projectService.serviceType()
+	if !config.ServiceTypesEqual(serviceType, config.ClusterIPService) && !config.ServiceTypesEqual(serviceType, config.NoService) {
+		return fmt.Sprintf("%s service type isn't supported", serviceType)
+	}
+
+	if len(k.serviceAliases(projectService)) > 0 {
+		return "network and link aliases aren't supported"
+	}
+
+	if k.meshType() != config.NoMesh && projectService.SvcK8sConfig.Service.Mesh.HasTrafficPolicy() {
+		return "mesh traffic policy isn't supported"
+	}
+
+	if domain, _ := projectService.prefixedDomain(); domain != "" {
+		hosts := regexp.MustCompile("[ ,]*,[ ,]*").Split(domain, -1)
+		if hasDefaultIngressBackendKeyword(hosts) {
+			return "default ingress backend isn't supported"
+		}
+		for _, host := range hosts {
+			if _, path := parseIngressPath(host); path != "" {
+				return "ingress paths aren't supported"
+			}
+		}
+	}
+
+	deployment := findDeployment(objects, projectService.Name)
+	if deployment == nil {
+		return "workload hasn't been rendered"
+	}
+
+	podSpec := deployment.Spec.Template.Spec
+	if len(podSpec.Containers) != 1 || len(podSpec.InitContainers) > 0 {
+		return "multiple containers aren't supported"
+	}
+
+	if podSpec.Hostname != "" || podSpec.Subdomain != "" {
+		return "pod hostname and domain name aren't supported"
+	}
+
+	for _, volume := range podSpec.Volumes {
+		source := volume.VolumeSource
+		if source.ConfigMap == nil && source.Secret == nil && source.Projected == nil && source.EmptyDir == nil && source.DownwardAPI == nil {
+			return fmt.Sprintf("volume %s isn't supported, only ConfigMap, Secret, projected and emptyDir volumes are", volume.Name)
+		}
+	}
+
+	container := podSpec.Containers[0]
+	if container.Stdin || container.TTY {
+		return "interactive containers aren't supported"
+	}
+
+	if len(container.Ports) != 1 {
+		return fmt.Sprintf("a single container port is required, found %d", len(container.Ports))
+	}
+
+	port := container.Ports[0]
+	if port.Protocol != "" && port.Protocol != v1.ProtocolTCP {
+		return fmt.Sprintf("%s port isn't supported", port.Protocol)
+	}
+
+	for _, probe := range []*v1.Probe{container.LivenessProbe, container.ReadinessProbe} {
+		if p := probePort(probe); p != 0 && p != port.ContainerPort {
+			return fmt.Sprintf("probe port %d differs from the served port %d", p, port.ContainerPort)
+		}
+	}
+
+	return ""
+}
+
+// createKnativeObjects replaces the Deployment, Service, HorizontalPodAutoscaler and Ingress
+// of each project service with a Knative Service and DomainMappings for its exposed domains.
+// Knative Serving manages routing and autoscaling of the service revisions itself.
+func (k *Kubernetes) createKnativeObjects(objects []runtime.Object, projectServices []ProjectService) ([]runtime.Object, error) {
+	services := map[string]ProjectService{}
+	for _, projectService := range projectServices {
+		services[projectService.Name] = projectService
+		services[rfc1123label(projectService.Name)] = projectService
+	}
+
+	var out []runtime.Object
+	for _, obj := range objects {
+		switch o := obj.(type) {
+		case *v1apps.Deployment:
+			if projectService, ok := services[o.Name]; ok {
+				ksvc, err := k.initKnativeService(projectService, o)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, ksvc)
+				continue
+			}
+		case *v1.Service:
+			if projectService, ok := services[o.Name]; ok {
+				warnKnativeServicePorts(projectService, o)
+				continue
+			}
+		case *autoscalingv2beta2.HorizontalPodAutoscaler:
+			if _, ok := services[o.Name]; ok {
+				continue
+			}
+		case *networkingv1.Ingress:
+			if projectService, ok := services[o.Name]; ok {
+				out = append(out, k.createDomainMappings(projectService)...)
+				continue
+			}
+		}
+		out = append(out, obj)
+	}
+
+	return out, nil
+}
+
+// warnKnativeServicePorts warns about ports of the replaced Service other than the port Knative Service is reachable on,
+// as references to the project service on these ports no longer resolve within the cluster
+func warnKnativeServicePorts(projectService ProjectService, svc *v1.Service) {
+	for _, port := range svc.Spec.Ports {
+		if port.Port == knativeServicePort {
+			continue
+		}
+		log.WarnfWithFields(log.Fields{
+			"project-service": projectService.Name,
+			"port":            port.Port,
+		}, "Knative Service is only reachable on port %d within the cluster. Update references to %s:%d accordingly",
+			knativeServicePort, svc.Name, port.Port)
+	}
+}
+
+// initKnativeService initialises Knative Service running the project service Deployment pod template
+func (k *Kubernetes) initKnativeService(projectService ProjectService, deployment *v1apps.Deployment) (*unstructured.Unstructured, error) {
+	kn := projectService.SvcK8sConfig.Workload.Knative
+	template := deployment.Spec.Template.DeepCopy()
+
+	// @step drop pod settings managed by Knative Serving
+	template.Spec.RestartPolicy = ""
+	template.Spec.TerminationGracePeriodSeconds = nil
+
+	// @step serve the only container port, Knative Serving only accepts http1 and h2c port names
+	container := &template.Spec.Containers[0]
+	port := v1.ContainerPort{ContainerPort: container.Ports[0].ContainerPort}
+	if knativeH2C(projectService) {
+		port.Name = "h2c"
+	}
+	container.Ports = []v1.ContainerPort{port}
+
+	// @step probes check the served port by default
+	for _, probe := range []*v1.Probe{container.LivenessProbe, container.ReadinessProbe} {
+		clearProbePort(probe)
+	}
+
+	// @step downward API volumes are mounted as projected volumes supported by Knative Serving
+	for i, volume := range template.Spec.Volumes {
+		if volume.DownwardAPI == nil {
+			continue
+		}
+		template.Spec.Volumes[i].VolumeSource = v1.VolumeSource{
+			Projected: &v1.ProjectedVolumeSource{
+				Sources: []v1.VolumeProjection{
+					{DownwardAPI: &v1.DownwardAPIProjection{Items: volume.DownwardAPI.Items}},
+				},
+				DefaultMode: volume.DownwardAPI.DefaultMode,
+			},
+		}
+	}
+
+	// @step configure revision autoscaling bounds
+	annotations := map[string]string{}
+	if kn.MinScale > 0 {
+		annotations[KnativeMinScaleAnnotation] = strconv.Itoa(kn.MinScale)
+	}
+	if kn.MaxScale > 0 {
+		annotations[KnativeMaxScaleAnnotation] = strconv.Itoa(kn.MaxScale)
+	}
+	template.Annotations = configAnnotations(template.Annotations, annotations)
+
+	podSpec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&template.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// @step configure revision request concurrency and timeout
+	if kn.Concurrency > 0 {
+		podSpec["containerConcurrency"] = int64(kn.Concurrency)
+	}
+	if kn.Timeout > 0 {
+		podSpec["timeoutSeconds"] = int64(math.Ceil(kn.Timeout.Seconds()))
+	}
+
+	templateMeta := map[string]interface{}{
+		"labels": unstructuredMap(template.Labels),
+	}
+	if len(template.Annotations) > 0 {
+		templateMeta["annotations"] = unstructuredMap(template.Annotations)
+	}
+
+	// @step services which aren't exposed are only reachable from within the cluster
+	labels := unstructuredMap(deployment.Labels)
+	if domain, _ := projectService.exposeService(); domain == "" {
+		labels[KnativeVisibilityLabel] = knativeClusterLocal
+	}
+
+	metadata := map[string]interface{}{
+		"name":   rfc1123label(projectService.Name),
+		"labels": labels,
+	}
+	if len(deployment.Annotations) > 0 {
+		metadata["annotations"] = unstructuredMap(deployment.Annotations)
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": KnativeServingAPIVersion,
+			"kind":       "Service",
+			"metadata":   metadata,
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"metadata": templateMeta,
+					"spec":     podSpec,
+				},
+			},
+		},
+	}, nil
+}
+
+// createDomainMappings creates a Knative DomainMapping for each domain the project service is exposed on
+func (k *Kubernetes) createDomainMappings(projectService ProjectService) []runtime.Object {
+	var objects []runtime.Object
+
+	domain, _ := projectService.prefixedDomain()
+	if domain == "" {
+		return objects
+	}
+
+	for _, host := range regexp.MustCompile("[ ,]*,[ ,]*").Split(domain, -1) {
+		spec := map[string]interface{}{
+			"ref": map[string]interface{}{
+				"apiVersion": KnativeServingAPIVersion,
+				"kind":       "Service",
+				"name":       rfc1123label(projectService.Name),
+			},
+		}
+
+		if tlsSecretName := projectService.tlsSecretName(); tlsSecretName != "" {
+			spec["tls"] = map[string]interface{}{
+				"secretName": tlsSecretName,
+			}
+		}
+
+		objects = append(objects, newCustomObject(KnativeDomainMappingAPIVersion, "DomainMapping", host, projectService, spec))
+	}
+
+	return objects
+}
+
+// findDeployment finds Deployment by name
+func findDeployment(objects []runtime.Object, name string) *v1apps.Deployment {
+	for _, obj := range objects {
+		if d, ok := obj.(*v1apps.Deployment); ok && d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// probePort returns port number checked by HTTP or TCP probe, or 0 when the probe doesn't check a port
+func probePort(probe *v1.Probe) int32 {
+	switch {
+	case probe == nil:
+		return 0
+	case probe.HTTPGet != nil:
+		return probe.HTTPGet.Port.IntVal
+	case probe.TCPSocket != nil:
+		return probe.TCPSocket.Port.IntVal
+	}
+	return 0
+}
+
+// clearProbePort clears port number checked by HTTP or TCP probe
+func clearProbePort(probe *v1.Probe) {
+	switch {
+	case probe == nil:
+		return
+	case probe.HTTPGet != nil:
+		probe.HTTPGet.Port = intstr.IntOrString{}
+	case probe.TCPSocket != nil:
+		probe.TCPSocket.Port = intstr.IntOrString{}
+	}
+}
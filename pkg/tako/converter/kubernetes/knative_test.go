@@ -0,0 +1,254 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	v1apps "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var _ = Describe("Knative", func() {
+
+	var (
+		k       Kubernetes
+		project composego.Project
+		knative bool
+		webExt  map[string]interface{}
+		ports   []composego.ServicePortConfig
+		objs    []runtime.Object
+		err     error
+	)
+
+	findObject := func(kind, name string) runtime.Object {
+		for _, o := range objs {
+			if o.GetObjectKind().GroupVersionKind().Kind != kind {
+				continue
+			}
+			if u, ok := o.(*unstructured.Unstructured); ok && u.GetName() == name {
+				return o
+			}
+			if d, ok := o.(*v1apps.Deployment); ok && d.Name == name {
+				return o
+			}
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		knative = true
+		ports = []composego.ServicePortConfig{{Target: 8080, Published: 80, Protocol: "tcp"}}
+		webExt = map[string]interface{}{
+			"service": map[string]interface{}{
+				"expose": map[string]interface{}{"domain": "web.example.com"},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		hook.Reset()
+
+		project = composego.Project{
+			Services: composego.Services{
+				{
+					Name:       "web",
+					Image:      "some-image",
+					Ports:      ports,
+					Extensions: map[string]interface{}{config.K8SExtensionKey: webExt},
+				},
+				{
+					Name:  "worker",
+					Image: "some-image",
+				},
+			},
+		}
+
+		k = Kubernetes{
+			Opt:     ConvertOptions{},
+			Project: &project,
+			UI:      kmd.NoOpUI(),
+			Knative: knative,
+		}
+
+		objs, err = k.Transform()
+	})
+
+	Context("with exposed HTTP service", func() {
+		It("renders a Knative Service instead of the Deployment, Service and Ingress", func() {
+			Expect(err).NotTo(HaveOccurred())
+
+			ksvc := findObject("Service", "web").(*unstructured.Unstructured)
+			Expect(ksvc.GetAPIVersion()).To(Equal(KnativeServingAPIVersion))
+			Expect(ksvc.GetLabels()).NotTo(HaveKey(KnativeVisibilityLabel))
+
+			containers, _, _ := unstructured.NestedSlice(ksvc.Object, "spec", "template", "spec", "containers")
+			Expect(containers).To(HaveLen(1))
+			Expect(containers[0]).To(HaveKeyWithValue("ports", []interface{}{
+				map[string]interface{}{"containerPort": int64(8080)},
+			}))
+
+			Expect(findObject("Deployment", "web")).To(BeNil())
+			for _, o := range objs {
+				Expect(o.GetObjectKind().GroupVersionKind().Kind).NotTo(Equal("Ingress"))
+			}
+		})
+
+		It("maps the exposed domain to the Knative Service", func() {
+			mapping := findObject("DomainMapping", "web.example.com").(*unstructured.Unstructured)
+			Expect(mapping.GetAPIVersion()).To(Equal(KnativeDomainMappingAPIVersion))
+
+			ref, _, _ := unstructured.NestedStringMap(mapping.Object, "spec", "ref")
+			Expect(ref).To(Equal(map[string]string{
+				"apiVersion": KnativeServingAPIVersion,
+				"kind":       "Service",
+				"name":       "web",
+			}))
+		})
+
+		It("keeps plain Kubernetes objects for non HTTP services", func() {
+			Expect(findObject("Deployment", "worker")).NotTo(BeNil())
+		})
+
+		It("doesn't warn about the service port", func() {
+			Expect(hook.Entries).NotTo(ContainElement(WithTransform(func(e logrus.Entry) string {
+				return e.Message
+			}, HavePrefix("Knative Service is only reachable on port 80"))))
+		})
+
+		Context("and service port other than 80", func() {
+			BeforeEach(func() {
+				ports = []composego.ServicePortConfig{{Target: 8080, Protocol: "tcp"}}
+			})
+
+			It("warns that references to the service port no longer resolve", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hook.Entries).To(ContainElement(WithTransform(func(e logrus.Entry) string {
+					return e.Message
+				}, Equal("Knative Service is only reachable on port 80 within the cluster. Update references to web:8080 accordingly"))))
+			})
+		})
+
+		Context("and Knative configuration", func() {
+			BeforeEach(func() {
+				webExt["workload"] = map[string]interface{}{
+					"knative": map[string]interface{}{
+						"concurrency": 50,
+						"minScale":    1,
+						"maxScale":    10,
+						"timeout":     "90s",
+					},
+				}
+			})
+
+			It("configures revision concurrency, scale bounds and timeout", func() {
+				ksvc := findObject("Service", "web").(*unstructured.Unstructured)
+
+				annotations, _, _ := unstructured.NestedStringMap(ksvc.Object, "spec", "template", "metadata", "annotations")
+				Expect(annotations).To(HaveKeyWithValue(KnativeMinScaleAnnotation, "1"))
+				Expect(annotations).To(HaveKeyWithValue(KnativeMaxScaleAnnotation, "10"))
+
+				concurrency, _, _ := unstructured.NestedInt64(ksvc.Object, "spec", "template", "spec", "containerConcurrency")
+				Expect(concurrency).To(Equal(int64(50)))
+
+				timeout, _, _ := unstructured.NestedInt64(ksvc.Object, "spec", "template", "spec", "timeoutSeconds")
+				Expect(timeout).To(Equal(int64(90)))
+			})
+		})
+	})
+
+	Context("with HTTP service which isn't exposed", func() {
+		BeforeEach(func() {
+			webExt = map[string]interface{}{
+				"service": map[string]interface{}{
+					"ports": []interface{}{
+						map[string]interface{}{"port": 80, "appProtocol": "http"},
+					},
+				},
+			}
+		})
+
+		It("renders a cluster local Knative Service", func() {
+			Expect(err).NotTo(HaveOccurred())
+
+			ksvc := findObject("Service", "web").(*unstructured.Unstructured)
+			Expect(ksvc.GetLabels()).To(HaveKeyWithValue(KnativeVisibilityLabel, "cluster-local"))
+			Expect(findObject("DomainMapping", "web.example.com")).To(BeNil())
+		})
+	})
+
+	Context("with HTTP service Knative Serving doesn't support", func() {
+		BeforeEach(func() {
+			webExt["workload"] = map[string]interface{}{"type": "StatefulSet"}
+		})
+
+		It("falls back to plain Kubernetes objects and warns", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(findObject("Service", "web")).To(BeNil())
+
+			assertLog(logrus.WarnLevel,
+				"Rendering plain Kubernetes objects instead of a Knative Service: StatefulSet workload isn't supported",
+				map[string]string{
+					"project-service": "web",
+				},
+			)
+		})
+
+		Context("and Knative explicitly enabled", func() {
+			BeforeEach(func() {
+				webExt["workload"] = map[string]interface{}{
+					"type":    "StatefulSet",
+					"knative": map[string]interface{}{"enabled": true},
+				}
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError("service web can't be rendered as a Knative Service: StatefulSet workload isn't supported"))
+			})
+		})
+	})
+
+	Context("with Knative disabled for the service", func() {
+		BeforeEach(func() {
+			webExt["workload"] = map[string]interface{}{
+				"knative": map[string]interface{}{"enabled": false},
+			}
+		})
+
+		It("renders plain Kubernetes objects", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(findObject("Deployment", "web")).NotTo(BeNil())
+		})
+	})
+
+	Context("when not rendering Knative manifests", func() {
+		BeforeEach(func() {
+			knative = false
+		})
+
+		It("renders plain Kubernetes objects", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(findObject("Deployment", "web")).NotTo(BeNil())
+			Expect(findObject("DomainMapping", "web.example.com")).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,281 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	"github.com/appvia/tako/pkg/tako/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// IstioNetworkingAPIVersion is the API version of Istio traffic management objects
+	IstioNetworkingAPIVersion = "networking.istio.io/v1beta1"
+
+	// IstioSecurityAPIVersion is the API version of Istio security objects
+	IstioSecurityAPIVersion = "security.istio.io/v1beta1"
+
+	// GatewayAPIVersion is the API version of Gateway API objects used by Linkerd for traffic splitting
+	GatewayAPIVersion = "gateway.networking.k8s.io/v1"
+)
+
+// meshPodAnnotations returns pod template annotations controlling mesh sidecar injection
+func (k *Kubernetes) meshPodAnnotations(projectService ProjectService) map[string]string {
+	mesh := projectService.SvcK8sConfig.Service.Mesh
+	annotations := map[string]string{}
+
+	switch k.meshType() {
+	case config.IstioMesh:
+		annotations["sidecar.istio.io/inject"] = strconv.FormatBool(mesh.InjectSidecar())
+
+	case config.LinkerdMesh:
+		annotations["linkerd.io/inject"] = "enabled"
+		if !mesh.InjectSidecar() {
+			annotations["linkerd.io/inject"] = "disabled"
+		}
+
+		switch mesh.MTLS {
+		case "STRICT":
+			annotations["config.linkerd.io/default-inbound-policy"] = "all-authenticated"
+		case "PERMISSIVE":
+			annotations["config.linkerd.io/default-inbound-policy"] = "all-unauthenticated"
+		case "DISABLE":
+			log.WarnWithFields(log.Fields{
+				"project-service": projectService.Name,
+			}, "Linkerd mTLS can't be disabled for meshed pods. Setting will be ignored")
+		}
+	}
+
+	return annotations
+}
+
+// meshServiceAnnotations returns service annotations configuring mesh retries and timeouts.
+// Linkerd reads these from the Service, whereas Istio uses a VirtualService instead.
+func (k *Kubernetes) meshServiceAnnotations(projectService ProjectService) map[string]string {
+	mesh := projectService.SvcK8sConfig.Service.Mesh
+	annotations := map[string]string{}
+
+	if !config.MeshTypesEqual(k.meshType(), config.LinkerdMesh) {
+		return annotations
+	}
+
+	if mesh.Retries.Attempts > 0 {
+		retryOn := mesh.Retries.RetryOn
+		if retryOn == "" {
+			retryOn = "5xx"
+		}
+		annotations["retry.linkerd.io/http"] = retryOn
+		annotations["retry.linkerd.io/limit"] = strconv.Itoa(mesh.Retries.Attempts)
+		if mesh.Retries.PerTryTimeout > 0 {
+			annotations["retry.linkerd.io/timeout"] = meshDuration(mesh.Retries.PerTryTimeout)
+		}
+	}
+
+	if mesh.Timeout > 0 {
+		annotations["timeout.linkerd.io/request"] = meshDuration(mesh.Timeout)
+	}
+
+	return annotations
+}
+
+// createMeshObjects creates mesh traffic policy objects for the project service k8s service
+func (k *Kubernetes) createMeshObjects(projectService ProjectService, svc *v1.Service) []runtime.Object {
+	var objects []runtime.Object
+	mesh := projectService.SvcK8sConfig.Service.Mesh
+
+	if !mesh.HasTrafficPolicy() {
+		return objects
+	}
+
+	canary := k8sServiceNameByRef(k.Project.Services, mesh.Canary.Service)
+	if mesh.Canary.Weight > 0 && findByName(k.Project.Services, canary) == nil {
+		log.WarnfWithFields(log.Fields{
+			"project-service": projectService.Name,
+			"canary":          mesh.Canary.Service,
+		}, "Canary service isn't defined in the project. Make sure %q service exists in the cluster", canary)
+	}
+
+	switch k.meshType() {
+	case config.IstioMesh:
+		if mesh.Retries.Attempts > 0 || mesh.Timeout > 0 || mesh.Canary.Weight > 0 {
+			objects = append(objects, k.initVirtualService(projectService, svc))
+		}
+		if mesh.MTLS != "" {
+			objects = append(objects, k.initDestinationRule(projectService, svc), k.initPeerAuthentication(projectService, svc))
+		}
+
+	case config.LinkerdMesh:
+		if mesh.Canary.Weight > 0 {
+			objects = append(objects, k.initHTTPRoute(projectService, svc))
+		}
+
+	default:
+		log.WarnWithFields(log.Fields{
+			"project-service": projectService.Name,
+		}, "Service mesh traffic policy will be ignored as no mesh has been enabled for the environment")
+	}
+
+	return objects
+}
+
+// initVirtualService initialises Istio VirtualService with retries, timeout and canary traffic split
+func (k *Kubernetes) initVirtualService(projectService ProjectService, svc *v1.Service) *unstructured.Unstructured {
+	mesh := projectService.SvcK8sConfig.Service.Mesh
+
+	route := []interface{}{
+		map[string]interface{}{
+			"destination": map[string]interface{}{"host": svc.Name},
+		},
+	}
+
+	if mesh.Canary.Weight > 0 {
+		route = []interface{}{
+			map[string]interface{}{
+				"destination": map[string]interface{}{"host": svc.Name},
+				"weight":      int64(100 - mesh.Canary.Weight),
+			},
+			map[string]interface{}{
+				"destination": map[string]interface{}{"host": k8sServiceNameByRef(k.Project.Services, mesh.Canary.Service)},
+				"weight":      int64(mesh.Canary.Weight),
+			},
+		}
+	}
+
+	httpRoute := map[string]interface{}{
+		"route": route,
+	}
+
+	if mesh.Retries.Attempts > 0 {
+		retries := map[string]interface{}{
+			"attempts": int64(mesh.Retries.Attempts),
+		}
+		if mesh.Retries.PerTryTimeout > 0 {
+			retries["perTryTimeout"] = meshDuration(mesh.Retries.PerTryTimeout)
+		}
+		if mesh.Retries.RetryOn != "" {
+			retries["retryOn"] = mesh.Retries.RetryOn
+		}
+		httpRoute["retries"] = retries
+	}
+
+	if mesh.Timeout > 0 {
+		httpRoute["timeout"] = meshDuration(mesh.Timeout)
+	}
+
+	return newCustomObject(IstioNetworkingAPIVersion, "VirtualService", svc.Name, projectService, map[string]interface{}{
+		"hosts": []interface{}{svc.Name},
+		"http":  []interface{}{httpRoute},
+	})
+}
+
+// initDestinationRule initialises Istio DestinationRule with client side mTLS mode
+func (k *Kubernetes) initDestinationRule(projectService ProjectService, svc *v1.Service) *unstructured.Unstructured {
+	tlsMode := "ISTIO_MUTUAL"
+	if projectService.SvcK8sConfig.Service.Mesh.MTLS == "DISABLE" {
+		tlsMode = "DISABLE"
+	}
+
+	return newCustomObject(IstioNetworkingAPIVersion, "DestinationRule", svc.Name, projectService, map[string]interface{}{
+		"host": svc.Name,
+		"trafficPolicy": map[string]interface{}{
+			"tls": map[string]interface{}{
+				"mode": tlsMode,
+			},
+		},
+	})
+}
+
+// initPeerAuthentication initialises Istio PeerAuthentication with server side mTLS mode
+func (k *Kubernetes) initPeerAuthentication(projectService ProjectService, svc *v1.Service) *unstructured.Unstructured {
+	matchLabels := map[string]interface{}{}
+	for key, val := range svc.Spec.Selector {
+		matchLabels[key] = val
+	}
+
+	return newCustomObject(IstioSecurityAPIVersion, "PeerAuthentication", svc.Name, projectService, map[string]interface{}{
+		"selector": map[string]interface{}{
+			"matchLabels": matchLabels,
+		},
+		"mtls": map[string]interface{}{
+			"mode": projectService.SvcK8sConfig.Service.Mesh.MTLS,
+		},
+	})
+}
+
+// initHTTPRoute initialises Gateway API HTTPRoute splitting traffic between the service and its canary.
+// Canary service is expected to serve the same port as the primary service.
+func (k *Kubernetes) initHTTPRoute(projectService ProjectService, svc *v1.Service) *unstructured.Unstructured {
+	mesh := projectService.SvcK8sConfig.Service.Mesh
+	port := int64(svc.Spec.Ports[0].Port)
+
+	return newCustomObject(GatewayAPIVersion, "HTTPRoute", svc.Name, projectService, map[string]interface{}{
+		"parentRefs": []interface{}{
+			map[string]interface{}{
+				"group": "core",
+				"kind":  "Service",
+				"name":  svc.Name,
+				"port":  port,
+			},
+		},
+		"rules": []interface{}{
+			map[string]interface{}{
+				"backendRefs": []interface{}{
+					map[string]interface{}{
+						"name":   svc.Name,
+						"port":   port,
+						"weight": int64(100 - mesh.Canary.Weight),
+					},
+					map[string]interface{}{
+						"name":   k8sServiceNameByRef(k.Project.Services, mesh.Canary.Service),
+						"port":   port,
+						"weight": int64(mesh.Canary.Weight),
+					},
+				},
+			},
+		},
+	})
+}
+
+// newCustomObject creates an unstructured object of a custom resource, as CRD types aren't part of the k8s API
+func newCustomObject(apiVersion, kind, name string, projectService ProjectService, spec map[string]interface{}) *unstructured.Unstructured {
+	labels := map[string]interface{}{}
+	for key, val := range configLabels(projectService.Name) {
+		labels[key] = val
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": apiVersion,
+			"kind":       kind,
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": labels,
+			},
+			"spec": spec,
+		},
+	}
+}
+
+// meshDuration formats duration in seconds as expected by mesh traffic policies, e.g. `1.5s`
+func meshDuration(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "s"
+}
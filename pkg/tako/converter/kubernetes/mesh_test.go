@@ -0,0 +1,293 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"time"
+
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("Mesh", func() {
+
+	var (
+		k              Kubernetes
+		project        composego.Project
+		projectService ProjectService
+		svc            *v1.Service
+		mesh           config.MeshType
+	)
+
+	BeforeEach(func() {
+		mesh = config.NoMesh
+
+		ps, err := NewProjectService(composego.ServiceConfig{
+			Name:  "web",
+			Image: "some-image",
+			Ports: []composego.ServicePortConfig{
+				{Target: 8080, Published: 80, Protocol: "tcp"},
+			},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		projectService = ps
+	})
+
+	JustBeforeEach(func() {
+		project = composego.Project{
+			Services: composego.Services{
+				projectService.ServiceConfig,
+				{Name: "web-canary", Image: "some-image"},
+			},
+			Extensions: map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"mesh": mesh.String(),
+				},
+			},
+		}
+
+		k = Kubernetes{
+			Opt:     ConvertOptions{},
+			Project: &project,
+			UI:      kmd.NoOpUI(),
+		}
+
+		var err error
+		svc, err = k.createService(config.ClusterIPService, projectService)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("meshPodAnnotations", func() {
+		Context("when no mesh is enabled", func() {
+			It("returns no annotations", func() {
+				Expect(k.meshPodAnnotations(projectService)).To(BeEmpty())
+			})
+		})
+
+		Context("with istio mesh", func() {
+			BeforeEach(func() {
+				mesh = config.IstioMesh
+			})
+
+			It("enables sidecar injection by default", func() {
+				Expect(k.meshPodAnnotations(projectService)).To(Equal(map[string]string{
+					"sidecar.istio.io/inject": "true",
+				}))
+			})
+
+			Context("and injection disabled for the service", func() {
+				BeforeEach(func() {
+					inject := false
+					projectService.SvcK8sConfig.Service.Mesh.Inject = &inject
+				})
+
+				It("disables sidecar injection", func() {
+					Expect(k.meshPodAnnotations(projectService)).To(Equal(map[string]string{
+						"sidecar.istio.io/inject": "false",
+					}))
+				})
+			})
+		})
+
+		Context("with linkerd mesh", func() {
+			BeforeEach(func() {
+				mesh = config.LinkerdMesh
+				projectService.SvcK8sConfig.Service.Mesh.MTLS = "STRICT"
+			})
+
+			It("enables proxy injection and sets default inbound policy for mTLS mode", func() {
+				Expect(k.meshPodAnnotations(projectService)).To(Equal(map[string]string{
+					"linkerd.io/inject":                        "enabled",
+					"config.linkerd.io/default-inbound-policy": "all-authenticated",
+				}))
+			})
+
+			Context("and mTLS disabled", func() {
+				BeforeEach(func() {
+					projectService.SvcK8sConfig.Service.Mesh.MTLS = "DISABLE"
+				})
+
+				It("warns that setting is ignored", func() {
+					Expect(k.meshPodAnnotations(projectService)).To(HaveLen(1))
+					assertLog(logrus.WarnLevel,
+						"Linkerd mTLS can't be disabled for meshed pods. Setting will be ignored",
+						map[string]string{
+							"project-service": projectService.Name,
+						},
+					)
+				})
+			})
+		})
+	})
+
+	Describe("meshServiceAnnotations", func() {
+		BeforeEach(func() {
+			projectService.SvcK8sConfig.Service.Mesh.Retries = config.MeshRetries{
+				Attempts:      3,
+				PerTryTimeout: 1500 * time.Millisecond,
+			}
+			projectService.SvcK8sConfig.Service.Mesh.Timeout = 10 * time.Second
+		})
+
+		Context("with istio mesh", func() {
+			BeforeEach(func() {
+				mesh = config.IstioMesh
+			})
+
+			It("returns no annotations", func() {
+				Expect(k.meshServiceAnnotations(projectService)).To(BeEmpty())
+			})
+		})
+
+		Context("with linkerd mesh", func() {
+			BeforeEach(func() {
+				mesh = config.LinkerdMesh
+			})
+
+			It("returns retry and timeout annotations", func() {
+				Expect(k.meshServiceAnnotations(projectService)).To(Equal(map[string]string{
+					"retry.linkerd.io/http":      "5xx",
+					"retry.linkerd.io/limit":     "3",
+					"retry.linkerd.io/timeout":   "1.5s",
+					"timeout.linkerd.io/request": "10s",
+				}))
+			})
+
+			It("adds them to the service", func() {
+				Expect(svc.Annotations).To(HaveKeyWithValue("retry.linkerd.io/limit", "3"))
+			})
+		})
+	})
+
+	Describe("createMeshObjects", func() {
+		Context("when no traffic policy is configured", func() {
+			BeforeEach(func() {
+				mesh = config.IstioMesh
+			})
+
+			It("returns no objects", func() {
+				Expect(k.createMeshObjects(projectService, svc)).To(BeEmpty())
+			})
+		})
+
+		Context("with istio mesh", func() {
+			BeforeEach(func() {
+				mesh = config.IstioMesh
+				projectService.SvcK8sConfig.Service.Mesh = config.ServiceMesh{
+					Retries: config.MeshRetries{Attempts: 2, RetryOn: "5xx,connect-failure"},
+					Timeout: 5 * time.Second,
+					MTLS:    "STRICT",
+					Canary:  config.MeshCanary{Service: "web-canary", Weight: 20},
+				}
+			})
+
+			It("creates VirtualService, DestinationRule and PeerAuthentication", func() {
+				objs := k.createMeshObjects(projectService, svc)
+				Expect(objs).To(HaveLen(3))
+
+				vs := objs[0].(*unstructured.Unstructured)
+				Expect(vs.GetKind()).To(Equal("VirtualService"))
+				Expect(vs.GetAPIVersion()).To(Equal(IstioNetworkingAPIVersion))
+				Expect(vs.GetName()).To(Equal("web"))
+
+				http, _, _ := unstructured.NestedSlice(vs.Object, "spec", "http")
+				Expect(http).To(HaveLen(1))
+				route := http[0].(map[string]interface{})
+				Expect(route["timeout"]).To(Equal("5s"))
+				Expect(route["retries"]).To(Equal(map[string]interface{}{
+					"attempts": int64(2),
+					"retryOn":  "5xx,connect-failure",
+				}))
+				Expect(route["route"]).To(Equal([]interface{}{
+					map[string]interface{}{
+						"destination": map[string]interface{}{"host": "web"},
+						"weight":      int64(80),
+					},
+					map[string]interface{}{
+						"destination": map[string]interface{}{"host": "web-canary"},
+						"weight":      int64(20),
+					},
+				}))
+
+				dr := objs[1].(*unstructured.Unstructured)
+				Expect(dr.GetKind()).To(Equal("DestinationRule"))
+				tlsMode, _, _ := unstructured.NestedString(dr.Object, "spec", "trafficPolicy", "tls", "mode")
+				Expect(tlsMode).To(Equal("ISTIO_MUTUAL"))
+
+				pa := objs[2].(*unstructured.Unstructured)
+				Expect(pa.GetKind()).To(Equal("PeerAuthentication"))
+				Expect(pa.GetAPIVersion()).To(Equal(IstioSecurityAPIVersion))
+				mtlsMode, _, _ := unstructured.NestedString(pa.Object, "spec", "mtls", "mode")
+				Expect(mtlsMode).To(Equal("STRICT"))
+			})
+		})
+
+		Context("with linkerd mesh", func() {
+			BeforeEach(func() {
+				mesh = config.LinkerdMesh
+				projectService.SvcK8sConfig.Service.Mesh = config.ServiceMesh{
+					Canary: config.MeshCanary{Service: "web-canary", Weight: 25},
+				}
+			})
+
+			It("creates HTTPRoute splitting traffic to the canary", func() {
+				objs := k.createMeshObjects(projectService, svc)
+				Expect(objs).To(HaveLen(1))
+
+				route := objs[0].(*unstructured.Unstructured)
+				Expect(route.GetKind()).To(Equal("HTTPRoute"))
+				Expect(route.GetAPIVersion()).To(Equal(GatewayAPIVersion))
+
+				rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+				backendRefs := rules[0].(map[string]interface{})["backendRefs"].([]interface{})
+				Expect(backendRefs).To(HaveLen(2))
+				Expect(backendRefs[0].(map[string]interface{})["weight"]).To(Equal(int64(75)))
+				Expect(backendRefs[1].(map[string]interface{})["name"]).To(Equal("web-canary"))
+				Expect(backendRefs[1].(map[string]interface{})["port"]).To(Equal(int64(80)))
+			})
+		})
+
+		Context("when no mesh is enabled", func() {
+			BeforeEach(func() {
+				projectService.SvcK8sConfig.Service.Mesh.Timeout = 5 * time.Second
+			})
+
+			It("warns that traffic policy is ignored", func() {
+				Expect(k.createMeshObjects(projectService, svc)).To(BeEmpty())
+				assertLog(logrus.WarnLevel,
+					"Service mesh traffic policy will be ignored as no mesh has been enabled for the environment",
+					map[string]string{
+						"project-service": projectService.Name,
+					},
+				)
+			})
+		})
+	})
+
+	Describe("meshDuration", func() {
+		It("formats duration in seconds", func() {
+			Expect(meshDuration(90 * time.Second)).To(Equal("90s"))
+			Expect(meshDuration(250 * time.Millisecond)).To(Equal("0.25s"))
+		})
+	})
+})
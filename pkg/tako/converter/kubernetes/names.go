@@ -0,0 +1,234 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+)
+
+// validateObjectNames validates that K8s names of project services, volumes, secrets, configs and env objects don't collide.
+// Compose names are normalised to valid DNS names, e.g. `my_api` and `my-api` would both become `my-api`,
+// in which case one of the objects would silently replace the other. Such collisions can be resolved
+// by explicitly setting a distinct K8s name via `x-k8s.name` extension.
+func (k *Kubernetes) validateObjectNames() error {
+	var collisions []string
+
+	services := map[string][]string{}
+	for _, svc := range k.Project.Services {
+		if contains(k.Excluded, svc.Name) {
+			continue
+		}
+		if ps, err := NewProjectService(svc); err == nil && !ps.enabled() {
+			continue
+		}
+		name := k8sServiceName(svc)
+		services[name] = append(services[name], svc.Name)
+	}
+	collisions = append(collisions, nameCollisions("services", services)...)
+
+	volumes := map[string][]string{}
+	for name, vol := range k.Project.Volumes {
+		k8sName := k8sVolumeName(name, vol)
+		volumes[k8sName] = append(volumes[k8sName], name)
+	}
+	collisions = append(collisions, nameCollisions("volumes", volumes)...)
+
+	secrets := map[string][]string{}
+	for name := range k.Project.Secrets {
+		k8sName := k.secretName(name)
+		secrets[k8sName] = append(secrets[k8sName], name)
+	}
+	collisions = append(collisions, nameCollisions("secrets", secrets)...)
+
+	configs := map[string][]string{}
+	for name := range k.Project.Configs {
+		k8sName := k.configName(name)
+		configs[k8sName] = append(configs[k8sName], name)
+	}
+	collisions = append(collisions, nameCollisions("configs", configs)...)
+	collisions = append(collisions, nameCollisions("env files and service env vars", k.envObjectNames())...)
+
+	if len(collisions) > 0 {
+		return fmt.Errorf("K8s object name collisions detected: %s. Set a unique `x-k8s.name` to resolve them",
+			strings.Join(collisions, "; "))
+	}
+
+	return nil
+}
+
+// validateServiceNames validates that K8s Services generated for project services don't collide.
+// Besides the main Service, each project service may generate an internal Service for its expose ports,
+// and Services for its network & link aliases and for its external links. When two project services generate a Service under the same name,
+// only one of them would be rendered and the other silently dropped.
+func (k *Kubernetes) validateServiceNames() error {
+	owners := map[string][]string{}
+	externalTargets := map[string]string{}
+
+	for _, svc := range k.Project.Services {
+		if contains(k.Excluded, svc.Name) {
+			continue
+		}
+		projectService, err := NewProjectService(svc)
+		if err != nil || !projectService.enabled() {
+			continue
+		}
+		projectService.Name = k8sServiceName(svc)
+
+		name := rfc1123label(projectService.Name)
+		owners[name] = append(owners[name], fmt.Sprintf("service %q", svc.Name))
+
+		serviceType, err := projectService.serviceType()
+		if err != nil {
+			continue
+		}
+
+		if projectService.externalName() == "" && k.portsExist(projectService) &&
+			!config.ServiceTypesEqual(serviceType, config.NoService) &&
+			len(projectService.internalPorts(serviceType)) > 0 {
+			name := internalServiceName(projectService)
+			owners[name] = append(owners[name], fmt.Sprintf("internal service of service %q", svc.Name))
+		}
+
+		if projectService.externalName() != "" || !config.ServiceTypesEqual(serviceType, config.NoService) {
+			aliases, _ := k.aliasNames(projectService)
+			for _, alias := range aliases {
+				owners[alias] = append(owners[alias], fmt.Sprintf("alias of service %q", svc.Name))
+			}
+		}
+
+		if projectService.externalName() != "" {
+			continue
+		}
+
+		links := projectService.externalLinks()
+		for alias, container := range links {
+			name := rfc1123label(alias)
+			if findByName(k.Project.Services, name) != nil {
+				continue
+			}
+
+			// @step the same external link shared by multiple project services resolves to the same Service
+			target := projectService.externalLinkHostname(alias)
+			if target == "" {
+				target = rfc1123dns(container)
+			}
+			if t, ok := externalTargets[name]; ok && t == target {
+				continue
+			}
+			externalTargets[name] = target
+
+			owners[name] = append(owners[name], fmt.Sprintf("external link of service %q", svc.Name))
+		}
+	}
+
+	var collisions []string
+	for name, generatedBy := range owners {
+		if len(generatedBy) < 2 {
+			continue
+		}
+		sort.Strings(generatedBy)
+		collisions = append(collisions, fmt.Sprintf("%q is generated by %s", name, strings.Join(generatedBy, ", ")))
+	}
+
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return fmt.Errorf("K8s Service name collisions detected: %s. Rename the aliases or links, "+
+			"or set a unique `x-k8s.name` to resolve them", strings.Join(collisions, "; "))
+	}
+
+	return nil
+}
+
+// envObjectNames returns K8s names of ConfigMaps and Secrets holding env vars, keyed by name. These are generated for
+// env files loaded via envFrom, keyed by the env file, and for service env vars when classified, keyed by the service.
+func (k *Kubernetes) envObjectNames() map[string][]string {
+	names := map[string][]string{}
+	paths := map[string]bool{}
+	classifyEnv := k.envClassificationEnabled()
+
+	for _, svc := range k.Project.Services {
+		if contains(k.Excluded, svc.Name) {
+			continue
+		}
+		projectService, err := NewProjectService(svc)
+		if err != nil || !projectService.enabled() {
+			continue
+		}
+
+		if classifyEnv {
+			name := k8sServiceName(svc)
+			names[name+envConfigMapSuffix] = append(names[name+envConfigMapSuffix], svc.Name)
+			names[name+envSecretSuffix] = append(names[name+envSecretSuffix], svc.Name)
+		}
+
+		if !projectService.SvcK8sConfig.Workload.EnvFrom {
+			continue
+		}
+		for _, file := range svc.EnvFile {
+			// env file shared by services is rendered once
+			path := filepath.Clean(k.projectPath(file))
+			if paths[path] {
+				continue
+			}
+			paths[path] = true
+
+			name := envFileObjectName(file)
+			names[name] = append(names[name], file)
+		}
+	}
+
+	return names
+}
+
+// nameCollisions returns sorted descriptions of K8s names shared by multiple compose objects of a given kind
+func nameCollisions(kind string, names map[string][]string) []string {
+	var collisions []string
+
+	for k8sName, composeNames := range names {
+		if len(composeNames) < 2 {
+			continue
+		}
+		sort.Strings(composeNames)
+
+		quoted := make([]string, len(composeNames))
+		for i, n := range composeNames {
+			quoted[i] = strconv.Quote(n)
+		}
+		collisions = append(collisions, fmt.Sprintf("%s %s all resolve to %q",
+			kind, strings.Join(quoted, ", "), k8sName))
+	}
+
+	sort.Strings(collisions)
+	return collisions
+}
+
+// secretName returns K8s name of the project secret
+func (k *Kubernetes) secretName(name string) string {
+	return k8sFileObjectName(name, composego.FileObjectConfig(k.Project.Secrets[name]))
+}
+
+// configName returns K8s name of the project config
+func (k *Kubernetes) configName(name string) string {
+	return k8sFileObjectName(name, composego.FileObjectConfig(k.Project.Configs[name]))
+}
@@ -0,0 +1,361 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Names", func() {
+
+	var (
+		k        Kubernetes
+		project  composego.Project
+		excluded []string
+	)
+
+	BeforeEach(func() {
+		excluded = nil
+		project = composego.Project{
+			Services: composego.Services{
+				{Name: "my_api", Image: "api"},
+				{Name: "my-api", Image: "api"},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		k = Kubernetes{
+			Opt:      ConvertOptions{},
+			Project:  &project,
+			Excluded: excluded,
+			UI:       kmd.NoOpUI(),
+		}
+	})
+
+	Describe("validateObjectNames", func() {
+
+		Context("for services colliding once normalised", func() {
+			It("returns an error listing colliding compose names", func() {
+				err := k.validateObjectNames()
+				Expect(err).To(MatchError("K8s object name collisions detected: " +
+					`services "my-api", "my_api" all resolve to "my-api". ` +
+					"Set a unique `x-k8s.name` to resolve them"))
+			})
+
+			It("fails the transformation", func() {
+				_, err := k.Transform()
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("when one of colliding services sets an explicit K8s name", func() {
+			BeforeEach(func() {
+				project.Services[0].Extensions = map[string]interface{}{
+					config.K8SExtensionKey: map[string]interface{}{
+						"name": "my-api-v2",
+					},
+				}
+			})
+
+			It("doesn't return an error", func() {
+				Expect(k.validateObjectNames()).To(Succeed())
+			})
+
+			It("renders the service objects under the explicit name", func() {
+				objs, err := k.Transform()
+				Expect(err).NotTo(HaveOccurred())
+
+				var names []string
+				for _, o := range objs {
+					names = append(names, o.(interface{ GetName() string }).GetName())
+				}
+				Expect(names).To(ConsistOf("my-api", "my-api-v2"))
+			})
+		})
+
+		Context("when one of colliding services is excluded", func() {
+			BeforeEach(func() {
+				excluded = []string{"my_api"}
+			})
+
+			It("doesn't return an error", func() {
+				Expect(k.validateObjectNames()).To(Succeed())
+			})
+		})
+
+		Context("when one of colliding services is disabled", func() {
+			BeforeEach(func() {
+				project.Services[1].Extensions = map[string]interface{}{
+					config.K8SExtensionKey: map[string]interface{}{
+						"disabled": true,
+						"workload": map[string]interface{}{
+							"livenessProbe": map[string]interface{}{
+								"type": "none",
+							},
+						},
+					},
+				}
+			})
+
+			It("doesn't return an error", func() {
+				Expect(k.validateObjectNames()).To(Succeed())
+			})
+		})
+
+		Context("for volumes, secrets and configs colliding once normalised", func() {
+			BeforeEach(func() {
+				project.Services = composego.Services{{Name: "web", Image: "web"}}
+				project.Volumes = composego.Volumes{
+					"db_data": composego.VolumeConfig{},
+					"db-data": composego.VolumeConfig{},
+				}
+				project.Secrets = composego.Secrets{
+					"api.key": composego.SecretConfig{},
+					"api_key": composego.SecretConfig{},
+				}
+				project.Configs = composego.Configs{
+					"app_conf": composego.ConfigObjConfig{},
+					"App-Conf": composego.ConfigObjConfig{},
+				}
+			})
+
+			It("returns an error listing all collisions", func() {
+				err := k.validateObjectNames()
+				Expect(err).To(MatchError("K8s object name collisions detected: " +
+					`volumes "db-data", "db_data" all resolve to "db-data"; ` +
+					`secrets "api.key", "api_key" all resolve to "api-key"; ` +
+					`configs "App-Conf", "app_conf" all resolve to "app-conf". ` +
+					"Set a unique `x-k8s.name` to resolve them"))
+			})
+
+			When("explicit K8s names are set", func() {
+				BeforeEach(func() {
+					withName := func(name string) map[string]interface{} {
+						return map[string]interface{}{
+							config.K8SExtensionKey: map[string]interface{}{
+								"name": name,
+							},
+						}
+					}
+					project.Volumes["db_data"] = composego.VolumeConfig{Extensions: withName("db-data-legacy")}
+					project.Secrets["api.key"] = composego.SecretConfig{Extensions: withName("api-key-legacy")}
+					project.Configs["App-Conf"] = composego.ConfigObjConfig{Extensions: withName("app-conf-legacy")}
+				})
+
+				It("doesn't return an error", func() {
+					Expect(k.validateObjectNames()).To(Succeed())
+				})
+
+				It("uses explicit names for secrets and configs", func() {
+					Expect(k.secretName("api.key")).To(Equal("api-key-legacy"))
+					Expect(k.secretName("api_key")).To(Equal("api-key"))
+					Expect(k.configName("App-Conf")).To(Equal("app-conf-legacy"))
+				})
+			})
+		})
+
+		Context("for env files and classified service env vars colliding", func() {
+			BeforeEach(func() {
+				project.Services = composego.Services{
+					{
+						Name:    "web",
+						Image:   "web",
+						EnvFile: []string{"./web-env-config", "common.env"},
+						Extensions: map[string]interface{}{
+							config.K8SExtensionKey: map[string]interface{}{
+								"workload": map[string]interface{}{"envFrom": true},
+							},
+						},
+					},
+					{
+						Name:    "worker",
+						Image:   "worker",
+						EnvFile: []string{"./common.env"},
+						Extensions: map[string]interface{}{
+							config.K8SExtensionKey: map[string]interface{}{
+								"workload": map[string]interface{}{"envFrom": true},
+							},
+						},
+					},
+				}
+				project.Extensions = map[string]interface{}{
+					config.K8SExtensionKey: map[string]interface{}{
+						"secrets": map[string]interface{}{"classifyEnv": true},
+					},
+				}
+			})
+
+			It("returns an error listing colliding env files and services", func() {
+				err := k.validateObjectNames()
+				Expect(err).To(MatchError("K8s object name collisions detected: " +
+					`env files and service env vars "./web-env-config", "web" all resolve to "web-env-config". ` +
+					"Set a unique `x-k8s.name` to resolve them"))
+			})
+
+			When("env vars aren't classified", func() {
+				BeforeEach(func() {
+					project.Extensions = nil
+				})
+
+				It("doesn't return an error", func() {
+					Expect(k.validateObjectNames()).To(Succeed())
+				})
+			})
+		})
+	})
+
+	Describe("validateServiceNames", func() {
+		BeforeEach(func() {
+			ports := []composego.ServicePortConfig{{Target: 8080, Protocol: "tcp"}}
+			project.Services = composego.Services{
+				{Name: "api", Image: "api", Ports: ports},
+				{Name: "web", Image: "web", Ports: ports},
+			}
+		})
+
+		It("doesn't return an error for distinct service names", func() {
+			Expect(k.validateServiceNames()).To(Succeed())
+		})
+
+		Context("when two services share a network alias", func() {
+			BeforeEach(func() {
+				for i := range project.Services {
+					project.Services[i].Networks = map[string]*composego.ServiceNetworkConfig{
+						"default": {Aliases: []string{"backend"}},
+					}
+				}
+			})
+
+			It("returns an error listing the services generating the alias", func() {
+				err := k.validateServiceNames()
+				Expect(err).To(MatchError("K8s Service name collisions detected: " +
+					`"backend" is generated by alias of service "api", alias of service "web". ` +
+					"Rename the aliases or links, or set a unique `x-k8s.name` to resolve them"))
+			})
+
+			It("fails the transformation", func() {
+				_, err := k.Transform()
+				Expect(err).To(MatchError(ContainSubstring("K8s Service name collisions detected")))
+			})
+		})
+
+		Context("when an alias collides with an external link of another service", func() {
+			BeforeEach(func() {
+				project.Services[0].Networks = map[string]*composego.ServiceNetworkConfig{
+					"default": {Aliases: []string{"cache"}},
+				}
+				project.Services[1].ExternalLinks = []string{"redis_1:cache"}
+			})
+
+			It("returns an error", func() {
+				err := k.validateServiceNames()
+				Expect(err).To(MatchError(ContainSubstring(
+					`"cache" is generated by alias of service "api", external link of service "web"`)))
+			})
+		})
+
+		Context("when internal service of a project service collides with another project service", func() {
+			BeforeEach(func() {
+				project.Services[0].Name = "web-internal"
+				project.Services[1].Extensions = map[string]interface{}{
+					config.K8SExtensionKey: map[string]interface{}{
+						"service": map[string]interface{}{
+							"type": "NodePort",
+						},
+					},
+				}
+				project.Services[1].Expose = composego.StringOrNumberList{"9000"}
+			})
+
+			It("returns an error", func() {
+				err := k.validateServiceNames()
+				Expect(err).To(MatchError(ContainSubstring(
+					`"web-internal" is generated by internal service of service "web", service "web-internal"`)))
+			})
+
+			It("fails the transformation", func() {
+				_, err := k.Transform()
+				Expect(err).To(MatchError(ContainSubstring("K8s Service name collisions detected")))
+			})
+		})
+
+		Context("when services share the same external link", func() {
+			BeforeEach(func() {
+				for i := range project.Services {
+					project.Services[i].ExternalLinks = []string{"redis_1:cache"}
+				}
+			})
+
+			It("doesn't return an error", func() {
+				Expect(k.validateServiceNames()).To(Succeed())
+			})
+		})
+
+		Context("when services link the same alias to different external containers", func() {
+			BeforeEach(func() {
+				project.Services[0].ExternalLinks = []string{"redis_1:cache"}
+				project.Services[1].ExternalLinks = []string{"memcached_1:cache"}
+			})
+
+			It("returns an error", func() {
+				err := k.validateServiceNames()
+				Expect(err).To(MatchError(ContainSubstring(
+					`"cache" is generated by external link of service "api", external link of service "web"`)))
+			})
+		})
+	})
+
+	Describe("createSecrets", func() {
+		BeforeEach(func() {
+			project.Secrets = composego.Secrets{
+				"api_key": composego.SecretConfig{
+					File: "../../testdata/converter/kubernetes/secrets/secret_file",
+				},
+			}
+		})
+
+		It("creates secret with a normalised name", func() {
+			secrets, err := k.createSecrets()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secrets).To(HaveLen(1))
+			Expect(secrets[0].Name).To(Equal("api-key"))
+			Expect(secrets[0].Data).To(HaveKey("api_key"))
+		})
+
+		It("references the normalised secret name in secret volumes", func() {
+			ps, err := NewProjectService(composego.ServiceConfig{
+				Name:    "web",
+				Secrets: []composego.ServiceSecretConfig{{Source: "api_key"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, volumes := k.configSecretVolumes(ps)
+			Expect(volumes).To(HaveLen(1))
+			Expect(volumes[0].Name).To(Equal("api-key"))
+			Expect(volumes[0].VolumeSource.Secret).To(Equal(&v1.SecretVolumeSource{
+				SecretName: "api-key",
+				Items:      []v1.KeyToPath{{Key: "api_key", Path: "api_key"}},
+			}))
+		})
+	})
+})
@@ -19,6 +19,8 @@ package kubernetes
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/appvia/tako/pkg/tako/config"
@@ -138,13 +140,37 @@ func (p *ProjectService) serviceType() (config.ServiceType, error) {
 		return "", fmt.Errorf("`%s` workload service type must be set as `NodePort` when assigning node port value", p.Name)
 	}
 
-	if len(p.ports()) > 1 && p.nodePort() != 0 {
+	if len(p.servicePorts(serviceType)) > 1 && p.nodePort() != 0 {
 		return "", fmt.Errorf("`%s` cannot set NodePort service port when project service has multiple ports defined", p.Name)
 	}
 
+	// @step validate per port service configuration
+	for _, sp := range p.SvcK8sConfig.Service.Ports {
+		if _, ok := p.findPort(uint32(sp.Port), sp.Protocol); !ok {
+			return "", fmt.Errorf("`%s` service port %d/%s isn't defined by the project service ports", p.Name, sp.Port, servicePortProtocol(sp.Protocol))
+		}
+
+		if sp.NodePort != 0 && !isExternalServiceType(serviceType) {
+			return "", fmt.Errorf("`%s` workload service type must be set as `NodePort` or `LoadBalancer` when assigning per port node port values", p.Name)
+		}
+	}
+
 	return serviceType, nil
 }
 
+// isExternalServiceType tells whether service type makes the service reachable from outside of the cluster
+func isExternalServiceType(st config.ServiceType) bool {
+	return config.ServiceTypesEqual(st, config.NodePortService) || config.ServiceTypesEqual(st, config.LoadBalancerService)
+}
+
+// servicePortProtocol returns upper cased protocol, defaults to TCP when protocol isn't specified
+func servicePortProtocol(protocol string) v1.Protocol {
+	if protocol == "" {
+		return v1.ProtocolTCP
+	}
+	return v1.Protocol(strings.ToUpper(protocol))
+}
+
 // toV1ServiceType maps to a case-sensitive v1 service type
 func toV1ServiceType(st config.ServiceType) (v1.ServiceType, error) {
 	caseSensitiveSvcType, ok := config.ServiceTypeFromValue(st.String())
@@ -159,6 +185,127 @@ func (p *ProjectService) nodePort() int32 {
 	return int32(p.SvcK8sConfig.Service.NodePort)
 }
 
+// sessionAffinity returns the service session affinity
+func (p *ProjectService) sessionAffinity() v1.ServiceAffinity {
+	return v1.ServiceAffinity(p.SvcK8sConfig.Service.SessionAffinity)
+}
+
+// externalTrafficPolicy returns the service external traffic policy
+func (p *ProjectService) externalTrafficPolicy() v1.ServiceExternalTrafficPolicy {
+	return v1.ServiceExternalTrafficPolicy(p.SvcK8sConfig.Service.ExternalTrafficPolicy)
+}
+
+// internalTrafficPolicy returns the service internal traffic policy
+func (p *ProjectService) internalTrafficPolicy() *v1.ServiceInternalTrafficPolicy {
+	if p.SvcK8sConfig.Service.InternalTrafficPolicy == "" {
+		return nil
+	}
+	policy := v1.ServiceInternalTrafficPolicy(p.SvcK8sConfig.Service.InternalTrafficPolicy)
+	return &policy
+}
+
+// loadBalancerSourceRanges returns the CIDRs allowed to access the LoadBalancer service
+func (p *ProjectService) loadBalancerSourceRanges() []string {
+	return p.SvcK8sConfig.Service.LoadBalancerSourceRanges
+}
+
+// loadBalancerClass returns the LoadBalancer service implementation class
+func (p *ProjectService) loadBalancerClass() *string {
+	if p.SvcK8sConfig.Service.LoadBalancerClass == "" {
+		return nil
+	}
+	class := p.SvcK8sConfig.Service.LoadBalancerClass
+	return &class
+}
+
+// serviceAnnotations returns the service annotations
+func (p *ProjectService) serviceAnnotations() map[string]string {
+	annotations := p.SvcK8sConfig.Service.Annotations
+	if len(annotations) == 0 {
+		annotations = map[string]string{}
+	}
+	return annotations
+}
+
+// servicePortConfig returns per port service configuration for the given port and protocol if any
+func (p *ProjectService) servicePortConfig(port uint32, protocol string) (config.ServicePort, bool) {
+	for _, sp := range p.SvcK8sConfig.Service.Ports {
+		if uint32(sp.Port) == port && servicePortProtocol(sp.Protocol) == servicePortProtocol(protocol) {
+			return sp, true
+		}
+	}
+	return config.ServicePort{}, false
+}
+
+// externalName returns the external DNS name the project service resolves to.
+// When set, the project service is provided outside of the cluster and only an ExternalName service gets created.
+func (p *ProjectService) externalName() string {
+	return strings.TrimSpace(p.SvcK8sConfig.Service.ExternalName)
+}
+
+// externalLinkHostname returns external hostname the external link alias is explicitly mapped to
+func (p *ProjectService) externalLinkHostname(alias string) string {
+	return strings.TrimSpace(p.SvcK8sConfig.Service.ExternalLinks[alias])
+}
+
+// networkAliases returns sorted unique aliases of the project service across all its networks
+func (p *ProjectService) networkAliases() []string {
+	exist := map[string]bool{}
+	var aliases []string
+
+	for _, network := range p.Networks {
+		if network == nil {
+			continue
+		}
+		for _, alias := range network.Aliases {
+			if !exist[alias] {
+				aliases = append(aliases, alias)
+				exist[alias] = true
+			}
+		}
+	}
+
+	sort.Strings(aliases)
+	return aliases
+}
+
+// linkAliases returns sorted unique aliases under which other project services link to the project service,
+// e.g. `links: ["db:database"]` defined by any other service gives `database` alias for the `db` project service.
+func (p *ProjectService) linkAliases(project *composego.Project) []string {
+	exist := map[string]bool{}
+	var aliases []string
+
+	for _, svc := range project.Services {
+		for _, link := range svc.Links {
+			target, alias := parseLink(link)
+			if alias == "" || k8sServiceNameByRef(project.Services, target) != rfc1123dns(p.Name) || exist[alias] {
+				continue
+			}
+			aliases = append(aliases, alias)
+			exist[alias] = true
+		}
+	}
+
+	sort.Strings(aliases)
+	return aliases
+}
+
+// externalLinks returns project service external links as a map of alias to external container name.
+// When no alias is specified the external container name is used as the alias.
+func (p *ProjectService) externalLinks() map[string]string {
+	links := map[string]string{}
+
+	for _, link := range p.ExternalLinks {
+		target, alias := parseLink(link)
+		if alias == "" {
+			alias = target
+		}
+		links[alias] = target
+	}
+
+	return links
+}
+
 // exposeService tells whether service for project component should be exposed
 func (p *ProjectService) exposeService() (string, error) {
 	val := strings.TrimSpace(p.SvcK8sConfig.Service.Expose.Domain)
@@ -274,7 +421,10 @@ func (p *ProjectService) volumes(project *composego.Project) ([]Volumes, error)
 	}
 
 	for i, vol := range vols {
-		composeVol := volumeByNameAndFormat(vol.VolumeName, rfc1123, project.Volumes)
+		composeVol, ok := project.Volumes[vol.ComposeName]
+		if !ok {
+			composeVol = volumeByNameAndFormat(vol.VolumeName, rfc1123, project.Volumes)
+		}
 		k8sVol, err := config.VolK8sConfigFromCompose(&composeVol)
 		if err != nil {
 			return nil, err
@@ -282,9 +432,22 @@ func (p *ProjectService) volumes(project *composego.Project) ([]Volumes, error)
 
 		// We can't assign value to struct field in map while iterating over it, so temporary variable `temp` is used here
 		var temp = vols[i]
+		if ok {
+			temp.VolumeName = k8sVolumeName(vol.ComposeName, composeVol)
+		}
 		temp.PVCSize = k8sVol.Size
 		temp.SelectorValue = k8sVol.Selector
 		temp.StorageClass = k8sVol.StorageClass
+		temp.AccessModes = k8sVol.AccessModes
+		temp.VolumeMode = k8sVol.VolumeMode
+		temp.Annotations = k8sVol.Annotations
+		temp.Labels = k8sVol.Labels
+		temp.DataSource = k8sVol.DataSource
+		temp.Retention = k8sVol.Retention
+		temp.External = composeVol.External.External
+		temp.NFS = k8sVol.NFS
+		temp.CSI = k8sVol.CSI
+		temp.Snapshots = k8sVol.Snapshots
 		vols[i] = temp
 	}
 
@@ -390,9 +553,117 @@ func (p *ProjectService) runAsGroup() *int64 {
 	return p.SvcK8sConfig.Workload.PodSecurity.RunAsGroup
 }
 
-// fsGroup returns pod security context fsGroup value
+// fsGroup returns pod security context fsGroup value.
+// When not configured, it defaults to the first numeric `gid` of secrets and configs mounted by the project service,
+// or the `gid` option of its tmpfs mounts, so mounted files are accessible by the group.
 func (p *ProjectService) fsGroup() *int64 {
-	return p.SvcK8sConfig.Workload.PodSecurity.FsGroup
+	if p.SvcK8sConfig.Workload.PodSecurity.FsGroup != nil {
+		return p.SvcK8sConfig.Workload.PodSecurity.FsGroup
+	}
+
+	var gids []string
+	for _, s := range p.Secrets {
+		gids = append(gids, s.GID)
+	}
+	for _, c := range p.Configs {
+		gids = append(gids, c.GID)
+	}
+
+	for _, g := range gids {
+		if gid, err := strconv.ParseInt(g, 10, 64); err == nil {
+			return &gid
+		}
+	}
+
+	if mounts, err := p.tmpfs(); err == nil {
+		for _, m := range mounts {
+			if m.GID != nil {
+				return m.GID
+			}
+		}
+	}
+
+	return nil
+}
+
+// tmpfsMount holds a tmpfs mount of the project service
+type tmpfsMount struct {
+	Target string
+	Size   *resource.Quantity
+	Mode   *int64
+	GID    *int64
+}
+
+// tmpfs returns tmpfs mounts of the project service, defined via short syntax `tmpfs` entries
+// e.g. `/run:size=64m,mode=1770`, followed by long syntax volumes of `tmpfs` type.
+func (p *ProjectService) tmpfs() ([]tmpfsMount, error) {
+	var mounts []tmpfsMount
+
+	for _, entry := range p.Tmpfs {
+		parts := strings.SplitN(entry, ":", 2)
+		mount := tmpfsMount{Target: parts[0]}
+
+		if len(parts) == 2 {
+			for _, opt := range strings.Split(parts[1], ",") {
+				kv := strings.SplitN(opt, "=", 2)
+				if len(kv) != 2 {
+					continue
+				}
+
+				switch kv[0] {
+				case "size":
+					size, err := tmpfsSize(kv[1])
+					if err != nil {
+						return nil, fmt.Errorf("tmpfs %s size %q is invalid", mount.Target, kv[1])
+					}
+					mount.Size = &size
+				case "mode":
+					mode, err := strconv.ParseInt(kv[1], 8, 64)
+					if err != nil {
+						return nil, fmt.Errorf("tmpfs %s mode %q is invalid, use octal notation, e.g. 1770", mount.Target, kv[1])
+					}
+					mount.Mode = &mode
+				case "gid":
+					gid, err := strconv.ParseInt(kv[1], 10, 64)
+					if err != nil {
+						return nil, fmt.Errorf("tmpfs %s gid %q is invalid, use a numeric group ID", mount.Target, kv[1])
+					}
+					mount.GID = &gid
+				}
+			}
+		}
+
+		mounts = append(mounts, mount)
+	}
+
+	for _, vol := range p.Volumes {
+		if vol.Type != "tmpfs" {
+			continue
+		}
+
+		mount := tmpfsMount{Target: vol.Target}
+		if vol.Tmpfs != nil && vol.Tmpfs.Size > 0 {
+			mount.Size = resource.NewQuantity(vol.Tmpfs.Size, resource.BinarySI)
+		}
+
+		mounts = append(mounts, mount)
+	}
+
+	return mounts, nil
+}
+
+// tmpfsSize parses tmpfs size option. Docker `k`, `m` and `g` suffixes are binary multiples,
+// which would otherwise be mistaken for K8s decimal or milli quantities, e.g. `64m` is 64Mi.
+func tmpfsSize(size string) (resource.Quantity, error) {
+	units := map[byte]string{'k': "Ki", 'm': "Mi", 'g': "Gi", 't': "Ti"}
+
+	if n := len(size); n > 0 {
+		if unit, ok := units[strings.ToLower(size)[n-1]]; ok {
+			size = size[:n-1] + unit
+		}
+	}
+
+	return resource.ParseQuantity(size)
 }
 
 // imagePullPolicy returns image PullPolicy for project service
@@ -451,47 +722,97 @@ func (p *ProjectService) environment() composego.MappingWithEquals {
 	return envs
 }
 
-// ports returns combined list of ports from both project service `Ports` and `Expose`. Docker Expose ports are treated as TCP ports.
+// ports returns combined list of ports from both project service `Ports` and `Expose`.
 // @orig: https://github.com/kubernetes/kompose/blob/e7f05588bf8bd645000612faa136b1b6aa0d5bb6/pkg/loader/compose/v3.go#L185
 func (p *ProjectService) ports() []composego.ServicePortConfig {
 	var prts []composego.ServicePortConfig
+
+	prts = append(prts, p.Ports...)
+	prts = append(prts, p.exposePorts()...)
+
+	return prts
+}
+
+// exposePorts returns ports listed by the compose `expose` attribute that aren't already specified in `ports`.
+// Compose Expose ports aren't published to the host - they are meant to be accessed only by linked services.
+// Each entry may be an individual port or a range of ports, with an optional protocol, e.g. `8000-8010/udp`.
+// https://docs.docker.com/compose/compose-file/#expose
+func (p *ProjectService) exposePorts() []composego.ServicePortConfig {
+	var prts []composego.ServicePortConfig
 	exist := map[string]bool{}
 
 	for _, port := range p.Ports {
-		prts = append(prts, port)
 		exist[cast.ToString(port.Target)+strings.ToUpper(port.Protocol)] = true
 	}
 
-	// Compose Expose ports aren't published to the host - they are meant to be accessed only by linked services.
-	// We simply map them onto the list of existing ports, see above.
-	// https://docs.docker.com/compose/compose-file/#expose
-	if p.Expose != nil {
-		for _, port := range p.Expose {
-			portValue := port
-			protocol := v1.ProtocolTCP
+	for _, entry := range p.Expose {
+		portValue := entry
+		protocol := v1.ProtocolTCP
 
-			// @todo - this seem invalid as expose can only specify individual ports
-			// if strings.Contains(portValue, "/") {
-			// 	splits := strings.Split(port, "/")
-			// 	portValue = splits[0]
-			// 	protocol = v1.Protocol(strings.ToUpper(splits[1]))
-			// }
+		if strings.Contains(portValue, "/") {
+			splits := strings.SplitN(portValue, "/", 2)
+			portValue = splits[0]
+			protocol = servicePortProtocol(splits[1])
+		}
 
-			if exist[portValue+string(protocol)] {
+		start, end, err := parsePortRange(portValue)
+		if err != nil {
+			log.WarnfWithFields(log.Fields{
+				"project-service": p.Name,
+				"expose":          entry,
+			}, "Invalid expose port will be ignored: %s", err)
+			continue
+		}
+
+		for port := start; port <= end; port++ {
+			if exist[cast.ToString(port)+string(protocol)] {
 				continue
 			}
 
 			prts = append(prts, composego.ServicePortConfig{
-				Target:    cast.ToUint32(portValue),
-				Published: cast.ToUint32(portValue),
+				Target:    port,
+				Published: port,
 				Protocol:  string(protocol),
 			})
+			exist[cast.ToString(port)+string(protocol)] = true
 		}
 	}
 
 	return prts
 }
 
+// servicePorts returns ports to be served by the project service k8s Service of a given type.
+// Expose ports are cluster-internal only, so they are left out of externally reachable
+// services (NodePort & LoadBalancer) whenever there are other ports to serve.
+func (p *ProjectService) servicePorts(serviceType config.ServiceType) []composego.ServicePortConfig {
+	if !isExternalServiceType(serviceType) || len(p.Ports) == 0 {
+		return p.ports()
+	}
+	return p.Ports
+}
+
+// internalPorts returns expose ports left out of the externally reachable service of a given type.
+// These get served by a separate cluster-internal ClusterIP service.
+func (p *ProjectService) internalPorts(serviceType config.ServiceType) []composego.ServicePortConfig {
+	if !isExternalServiceType(serviceType) || len(p.Ports) == 0 {
+		return nil
+	}
+	return p.exposePorts()
+}
+
+// findPort finds project service port by published port number and protocol
+func (p *ProjectService) findPort(published uint32, protocol string) (composego.ServicePortConfig, bool) {
+	for _, port := range p.ports() {
+		if port.Published == 0 {
+			port.Published = port.Target
+		}
+		if port.Published == published && servicePortProtocol(port.Protocol) == servicePortProtocol(protocol) {
+			return port, true
+		}
+	}
+	return composego.ServicePortConfig{}, false
+}
+
 func (p *ProjectService) LivenessProbe() (*v1.Probe, error) {
 	p1 := p.ServiceConfig
 	k8sconf, err := config.SvcK8sConfigFromCompose(&p1)
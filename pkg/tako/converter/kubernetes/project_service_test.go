@@ -50,6 +50,10 @@ var _ = Describe("ProjectService", func() {
 		command            composego.ShellCommand
 		args               composego.ShellCommand
 		svcK8sConfig       config.SvcK8sConfig
+
+		projectServiceSecrets []composego.ServiceSecretConfig
+		projectServiceConfigs []composego.ServiceConfigObjConfig
+		projectServiceTmpfs   composego.StringList
 	)
 
 	BeforeEach(func() {
@@ -64,6 +68,9 @@ var _ = Describe("ProjectService", func() {
 		projectVolumes = composego.Volumes{}
 		command = composego.ShellCommand{}
 		args = composego.ShellCommand{}
+		projectServiceSecrets = nil
+		projectServiceConfigs = nil
+		projectServiceTmpfs = nil
 
 		svcK8sConfig = config.SvcK8sConfig{}
 	})
@@ -86,6 +93,9 @@ var _ = Describe("ProjectService", func() {
 			Entrypoint:  command,
 			Command:     args,
 			Extensions:  extensions,
+			Secrets:     projectServiceSecrets,
+			Configs:     projectServiceConfigs,
+			Tmpfs:       projectServiceTmpfs,
 		})
 		Expect(err).NotTo(HaveOccurred())
 
@@ -516,6 +526,41 @@ var _ = Describe("ProjectService", func() {
 					Expect(err).To(MatchError(fmt.Sprintf("`%s` cannot set NodePort service port when project service has multiple ports defined", projectServiceName)))
 				})
 			})
+
+			Context("when service port configuration refers to a port not defined by the project service", func() {
+				BeforeEach(func() {
+					svcK8sConfig.Service.Type = config.ClusterIPService
+					svcK8sConfig.Service.Ports = []config.ServicePort{
+						{Port: 9090, AppProtocol: "http"},
+					}
+				})
+
+				It("returns an error", func() {
+					_, err := projectService.serviceType()
+					Expect(err).To(MatchError(fmt.Sprintf("`%s` service port 9090/TCP isn't defined by the project service ports", projectServiceName)))
+				})
+			})
+
+			Context("when per port node port is specified but service type isn't NodePort or LoadBalancer", func() {
+				BeforeEach(func() {
+					svcK8sConfig.Service.Type = config.ClusterIPService
+					svcK8sConfig.Service.Ports = []config.ServicePort{
+						{Port: 9090, NodePort: 30090},
+					}
+					ports = []composego.ServicePortConfig{
+						{
+							Target:    8080,
+							Published: 9090,
+							Protocol:  "tcp",
+						},
+					}
+				})
+
+				It("returns an error", func() {
+					_, err := projectService.serviceType()
+					Expect(err).To(MatchError(fmt.Sprintf("`%s` workload service type must be set as `NodePort` or `LoadBalancer` when assigning per port node port values", projectServiceName)))
+				})
+			})
 		})
 	})
 
@@ -732,6 +777,7 @@ var _ = Describe("ProjectService", func() {
 						SvcName:      projectServiceName,
 						MountPath:    ":" + targetPath,
 						VolumeName:   rfc1123(volumeName),
+						ComposeName:  volumeName,
 						Container:    targetPath,
 						PVCName:      projectServiceName + "-claim0",
 						PVCSize:      config.DefaultVolumeSize,
@@ -784,6 +830,25 @@ var _ = Describe("ProjectService", func() {
 				})
 			})
 
+			Context("when volume contains an explicit name in k8s extension", func() {
+				BeforeEach(func() {
+					projectVolumes = composego.Volumes{
+						volumeName: composego.VolumeConfig{
+							Extensions: map[string]interface{}{
+								config.K8SExtensionKey: map[string]interface{}{
+									"name": "legacy-data",
+								},
+							},
+						},
+					}
+				})
+
+				It("will set the volume name as expected", func() {
+					v, _ := projectService.volumes(&project)
+					Expect(v[0].VolumeName).To(Equal("legacy-data"))
+				})
+			})
+
 		})
 
 	})
@@ -1014,6 +1079,76 @@ var _ = Describe("ProjectService", func() {
 			It("returns default value", func() {
 				Expect(projectService.fsGroup()).To(Equal(config.DefaultSecurityContextFsGroup))
 			})
+
+			Context("and mounted secrets or configs specify gid", func() {
+				BeforeEach(func() {
+					projectServiceSecrets = []composego.ServiceSecretConfig{{Source: "my-secret", GID: "staff"}}
+					projectServiceConfigs = []composego.ServiceConfigObjConfig{{Source: "my-config", GID: "2000"}}
+				})
+
+				It("returns the first numeric gid", func() {
+					expected := int64(2000)
+					Expect(projectService.fsGroup()).To(Equal(&expected))
+				})
+			})
+
+			Context("and tmpfs mounts specify gid", func() {
+				BeforeEach(func() {
+					projectServiceTmpfs = composego.StringList{"/run:size=64m,gid=3000"}
+				})
+
+				It("returns the tmpfs gid", func() {
+					expected := int64(3000)
+					Expect(projectService.fsGroup()).To(Equal(&expected))
+				})
+			})
+		})
+	})
+
+	Describe("tmpfs", func() {
+		Context("with short syntax options", func() {
+			BeforeEach(func() {
+				projectServiceTmpfs = composego.StringList{"/run:rw,noexec,size=64m,mode=1770", "/tmp"}
+			})
+
+			It("parses size and mode", func() {
+				mounts, err := projectService.tmpfs()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mounts).To(HaveLen(2))
+
+				Expect(mounts[0].Target).To(Equal("/run"))
+				Expect(mounts[0].Size.String()).To(Equal("64Mi"))
+				Expect(*mounts[0].Mode).To(Equal(int64(0o1770)))
+
+				Expect(mounts[1]).To(Equal(tmpfsMount{Target: "/tmp"}))
+			})
+		})
+
+		Context("with long syntax volume", func() {
+			BeforeEach(func() {
+				volumes = []composego.ServiceVolumeConfig{
+					{Type: "tmpfs", Target: "/cache", Tmpfs: &composego.ServiceVolumeTmpfs{Size: 10485760}},
+				}
+			})
+
+			It("parses size", func() {
+				mounts, err := projectService.tmpfs()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mounts).To(HaveLen(1))
+				Expect(mounts[0].Target).To(Equal("/cache"))
+				Expect(mounts[0].Size.String()).To(Equal("10Mi"))
+			})
+		})
+
+		Context("with invalid size", func() {
+			BeforeEach(func() {
+				projectServiceTmpfs = composego.StringList{"/run:size=lots"}
+			})
+
+			It("returns an error", func() {
+				_, err := projectService.tmpfs()
+				Expect(err).To(MatchError(`tmpfs /run size "lots" is invalid`))
+			})
 		})
 	})
 
@@ -1273,6 +1408,161 @@ var _ = Describe("ProjectService", func() {
 				Expect(len(projectService.ports())).To(Equal(1))
 			})
 		})
+
+		Context("when Expose ports specify a protocol and a range of ports", func() {
+			BeforeEach(func() {
+				expose = composego.StringOrNumberList{
+					"7000-7002",
+					"5000/udp",
+				}
+			})
+
+			It("adds each port in the range with the specified protocol", func() {
+				Expect(projectService.ports()).To(Equal([]composego.ServicePortConfig{
+					{Target: 8080, Published: 9090, Protocol: string(v1.ProtocolTCP)},
+					{Target: 7000, Published: 7000, Protocol: string(v1.ProtocolTCP)},
+					{Target: 7001, Published: 7001, Protocol: string(v1.ProtocolTCP)},
+					{Target: 7002, Published: 7002, Protocol: string(v1.ProtocolTCP)},
+					{Target: 5000, Published: 5000, Protocol: string(v1.ProtocolUDP)},
+				}))
+			})
+		})
+
+		Context("when Expose ports are invalid", func() {
+			BeforeEach(func() {
+				expose = composego.StringOrNumberList{
+					"7002-7000",
+					"http",
+				}
+			})
+
+			It("ignores them", func() {
+				Expect(len(projectService.ports())).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("servicePorts & internalPorts", func() {
+
+		BeforeEach(func() {
+			ports = []composego.ServicePortConfig{
+				{
+					Target:    8080,
+					Published: 9090,
+					Protocol:  string(v1.ProtocolTCP),
+				},
+			}
+			expose = composego.StringOrNumberList{
+				"9999",
+			}
+		})
+
+		Context("for cluster-internal service type", func() {
+			It("serves all the ports", func() {
+				Expect(projectService.servicePorts(config.ClusterIPService)).To(HaveLen(2))
+				Expect(projectService.internalPorts(config.ClusterIPService)).To(BeEmpty())
+			})
+		})
+
+		Context("for externally reachable service type", func() {
+			It("serves expose ports via an internal service only", func() {
+				Expect(projectService.servicePorts(config.LoadBalancerService)).To(Equal(ports))
+				Expect(projectService.internalPorts(config.LoadBalancerService)).To(Equal([]composego.ServicePortConfig{
+					{Target: 9999, Published: 9999, Protocol: string(v1.ProtocolTCP)},
+				}))
+			})
+
+			Context("and there are only expose ports", func() {
+				BeforeEach(func() {
+					ports = []composego.ServicePortConfig{}
+				})
+
+				It("serves the expose ports", func() {
+					Expect(projectService.servicePorts(config.NodePortService)).To(HaveLen(1))
+					Expect(projectService.internalPorts(config.NodePortService)).To(BeEmpty())
+				})
+			})
+		})
+	})
+
+	Describe("aliases", func() {
+
+		var svc composego.ServiceConfig
+
+		BeforeEach(func() {
+			svc = composego.ServiceConfig{
+				Name: "db",
+				Networks: map[string]*composego.ServiceNetworkConfig{
+					"front": {Aliases: []string{"database", "postgres"}},
+					"back":  {Aliases: []string{"database"}},
+					"other": nil,
+				},
+				ExternalLinks: []string{"redis_1:cache", "mysql"},
+			}
+		})
+
+		It("returns sorted unique network aliases", func() {
+			ps := ProjectService{ServiceConfig: svc}
+			Expect(ps.networkAliases()).To(Equal([]string{"database", "postgres"}))
+		})
+
+		It("returns aliases used by other project services to link to the project service", func() {
+			ps := ProjectService{ServiceConfig: svc}
+			p := &composego.Project{
+				Services: composego.Services{
+					svc,
+					{Name: "web", Links: []string{"db:pg", "cache"}},
+					{Name: "worker", Links: []string{"db:pg", "db:primary", "db"}},
+				},
+			}
+			Expect(ps.linkAliases(p)).To(Equal([]string{"pg", "primary"}))
+		})
+
+		It("returns external links keyed by alias", func() {
+			ps := ProjectService{ServiceConfig: svc}
+			Expect(ps.externalLinks()).To(Equal(map[string]string{
+				"cache": "redis_1",
+				"mysql": "mysql",
+			}))
+		})
+	})
+
+	Describe("externalName", func() {
+		Context("when specified via an extension", func() {
+			BeforeEach(func() {
+				svcK8sConfig.Service.ExternalName = "db.example.com"
+			})
+
+			It("will use the extension value", func() {
+				Expect(projectService.externalName()).To(Equal("db.example.com"))
+			})
+		})
+
+		Context("when not specified via an extension", func() {
+			It("will return an empty string", func() {
+				Expect(projectService.externalName()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("servicePortConfig", func() {
+
+		BeforeEach(func() {
+			svcK8sConfig.Service.Ports = []config.ServicePort{
+				{Port: 53, Protocol: "udp", AppProtocol: "dns"},
+			}
+		})
+
+		It("matches the port by number and case insensitive protocol", func() {
+			sp, ok := projectService.servicePortConfig(53, "UDP")
+			Expect(ok).To(BeTrue())
+			Expect(sp.AppProtocol).To(Equal("dns"))
+		})
+
+		It("doesn't match the same port number with a different protocol", func() {
+			_, ok := projectService.servicePortConfig(53, "tcp")
+			Expect(ok).To(BeFalse())
+		})
 	})
 
 	Describe("liveness probe", func() {
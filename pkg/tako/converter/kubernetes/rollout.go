@@ -0,0 +1,306 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	v1apps "k8s.io/api/apps/v1"
+	v1batch "k8s.io/api/batch/v1"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// ConfigChecksumAnnotation is a pod template annotation holding content checksum of referenced ConfigMaps
+	ConfigChecksumAnnotation = "checksum/config"
+	// SecretChecksumAnnotation is a pod template annotation holding content checksum of referenced Secrets
+	SecretChecksumAnnotation = "checksum/secret"
+	// SecretChecksumKeyEnv is an environment variable holding a key of encrypted Secrets content checksums
+	SecretChecksumKeyEnv = "TAKO_SECRETS_CHECKSUM_KEY"
+
+	// hashedNameLength is a length of the content hash suffix of hashed ConfigMap names
+	hashedNameLength = 10
+)
+
+// podReferences holds names of ConfigMaps and Secrets referenced by a pod spec
+type podReferences struct {
+	configMaps map[string]bool
+	secrets    map[string]bool
+}
+
+// recordSecretChecksum records content checksum of a rendered Secret
+func (k *Kubernetes) recordSecretChecksum(name, checksum string) {
+	if k.secretChecksums == nil {
+		k.secretChecksums = map[string]string{}
+	}
+	k.secretChecksums[name] = checksum
+}
+
+// secretChecksum returns content checksum of a plain Secret. When key is set, the checksum is a keyed HMAC,
+// so that checksums kept next to encrypted content can't be used to guess the plain content offline.
+func secretChecksum(secret *v1.Secret, key []byte) string {
+	data, _ := json.Marshal(struct {
+		Data       map[string][]byte `json:"data,omitempty"`
+		StringData map[string]string `json:"stringData,omitempty"`
+		Type       v1.SecretType     `json:"type,omitempty"`
+	}{secret.Data, secret.StringData, secret.Type})
+
+	if len(key) == 0 {
+		return fmt.Sprintf("%x", sha256.Sum256(data))
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return fmt.Sprintf("%x", mac.Sum(nil))
+}
+
+// encryptedSecretChecksum returns content checksum of an encrypted Secret object
+func encryptedSecretChecksum(obj runtime.Object) string {
+	data, _ := json.Marshal(obj)
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// secretChecksumKey returns a key of encrypted Secrets content checksums, if set
+func secretChecksumKey() []byte {
+	return []byte(os.Getenv(SecretChecksumKeyEnv))
+}
+
+// configRollout ensures workloads get rolled out when content of ConfigMaps and Secrets they reference changes.
+// Depending on the project configuration, workload pod templates get annotated with content checksums,
+// or ConfigMaps get content hash suffixed names. Only ConfigMaps and Secrets rendered for the project are taken into account.
+func (k *Kubernetes) configRollout(objects []runtime.Object) error {
+	cfg, err := k.projectK8sConfig()
+	if err != nil {
+		return err
+	}
+
+	rollout, _ := config.ConfigRolloutFromValue(cfg.ConfigRollout.String())
+	if rollout == config.NoConfigRollout {
+		return nil
+	}
+
+	configChecksums := map[string]string{}
+	renamed := map[string]string{}
+	for _, obj := range objects {
+		cm, ok := obj.(*v1.ConfigMap)
+		if !ok {
+			continue
+		}
+
+		checksum := configMapChecksum(cm)
+		configChecksums[cm.Name] = checksum
+
+		if rollout == config.HashedNameConfigRollout {
+			if _, ok := renamed[cm.Name]; !ok {
+				renamed[cm.Name] = hashedName(cm.Name, checksum)
+			}
+			cm.Name = renamed[cm.Name]
+		}
+	}
+
+	for _, obj := range objects {
+		objectMeta, podSpec := podTemplate(obj)
+		if podSpec == nil {
+			continue
+		}
+
+		renameConfigMapReferences(podSpec, renamed)
+		refs := referencedConfigs(podSpec)
+
+		if rollout == config.ChecksumConfigRollout {
+			annotateChecksum(objectMeta, ConfigChecksumAnnotation, refs.configMaps, configChecksums)
+		}
+		annotateChecksum(objectMeta, SecretChecksumAnnotation, refs.secrets, k.secretChecksums)
+	}
+
+	return nil
+}
+
+// configMapChecksum returns content checksum of a ConfigMap
+func configMapChecksum(cm *v1.ConfigMap) string {
+	data, _ := json.Marshal(struct {
+		Kind       string            `json:"kind"`
+		Name       string            `json:"name"`
+		Data       map[string]string `json:"data,omitempty"`
+		BinaryData map[string][]byte `json:"binaryData,omitempty"`
+	}{"ConfigMap", cm.Name, cm.Data, cm.BinaryData})
+
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// hashedName returns a name suffixed with content hash, in the style of kustomize generated ConfigMap names.
+// Hash characters which could form unfortunate words are replaced the same way kustomize does.
+func hashedName(name, checksum string) string {
+	replacer := strings.NewReplacer("0", "g", "1", "h", "3", "k", "a", "m", "e", "t")
+	suffix := replacer.Replace(checksum[:hashedNameLength])
+
+	// keep the name within the DNS subdomain length limit
+	if max := 253 - hashedNameLength - 1; len(name) > max {
+		name = name[:max]
+	}
+	return fmt.Sprintf("%s-%s", name, suffix)
+}
+
+// podTemplate returns pod template metadata and spec of a workload object, or nils for other objects
+func podTemplate(obj runtime.Object) (*meta.ObjectMeta, *v1.PodSpec) {
+	switch o := obj.(type) {
+	case *v1apps.Deployment:
+		return &o.Spec.Template.ObjectMeta, &o.Spec.Template.Spec
+	case *v1apps.StatefulSet:
+		return &o.Spec.Template.ObjectMeta, &o.Spec.Template.Spec
+	case *v1apps.DaemonSet:
+		return &o.Spec.Template.ObjectMeta, &o.Spec.Template.Spec
+	case *v1batch.Job:
+		return &o.Spec.Template.ObjectMeta, &o.Spec.Template.Spec
+	case *v1.Pod:
+		return &o.ObjectMeta, &o.Spec
+	}
+	return nil, nil
+}
+
+// podContainers returns all containers of a pod spec
+func podContainers(podSpec *v1.PodSpec) []*v1.Container {
+	var out []*v1.Container
+	for i := range podSpec.InitContainers {
+		out = append(out, &podSpec.InitContainers[i])
+	}
+	for i := range podSpec.Containers {
+		out = append(out, &podSpec.Containers[i])
+	}
+	return out
+}
+
+// renameConfigMapReferences updates references to renamed ConfigMaps in a pod spec
+func renameConfigMapReferences(podSpec *v1.PodSpec, renamed map[string]string) {
+	if len(renamed) == 0 {
+		return
+	}
+
+	rename := func(ref *v1.LocalObjectReference) {
+		if name, ok := renamed[ref.Name]; ok {
+			ref.Name = name
+		}
+	}
+
+	for _, vol := range podSpec.Volumes {
+		if vol.ConfigMap != nil {
+			rename(&vol.ConfigMap.LocalObjectReference)
+		}
+		if vol.Projected != nil {
+			for _, src := range vol.Projected.Sources {
+				if src.ConfigMap != nil {
+					rename(&src.ConfigMap.LocalObjectReference)
+				}
+			}
+		}
+	}
+
+	for _, c := range podContainers(podSpec) {
+		for _, env := range c.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				rename(&env.ValueFrom.ConfigMapKeyRef.LocalObjectReference)
+			}
+		}
+		for _, envFrom := range c.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				rename(&envFrom.ConfigMapRef.LocalObjectReference)
+			}
+		}
+	}
+}
+
+// referencedConfigs returns names of ConfigMaps and Secrets mounted or referenced by a pod spec
+func referencedConfigs(podSpec *v1.PodSpec) podReferences {
+	refs := podReferences{configMaps: map[string]bool{}, secrets: map[string]bool{}}
+
+	for _, vol := range podSpec.Volumes {
+		if vol.ConfigMap != nil {
+			refs.configMaps[vol.ConfigMap.Name] = true
+		}
+		if vol.Secret != nil {
+			refs.secrets[vol.Secret.SecretName] = true
+		}
+		if vol.Projected != nil {
+			for _, src := range vol.Projected.Sources {
+				if src.ConfigMap != nil {
+					refs.configMaps[src.ConfigMap.Name] = true
+				}
+				if src.Secret != nil {
+					refs.secrets[src.Secret.Name] = true
+				}
+			}
+		}
+	}
+
+	for _, c := range podContainers(podSpec) {
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				refs.configMaps[env.ValueFrom.ConfigMapKeyRef.Name] = true
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				refs.secrets[env.ValueFrom.SecretKeyRef.Name] = true
+			}
+		}
+		for _, envFrom := range c.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				refs.configMaps[envFrom.ConfigMapRef.Name] = true
+			}
+			if envFrom.SecretRef != nil {
+				refs.secrets[envFrom.SecretRef.Name] = true
+			}
+		}
+	}
+
+	return refs
+}
+
+// annotateChecksum annotates pod template with a combined checksum of referenced objects with known content.
+// Objects not rendered for the project, e.g. Secrets expected to exist in the cluster, are skipped.
+func annotateChecksum(objectMeta *meta.ObjectMeta, annotation string, names map[string]bool, checksums map[string]string) {
+	var keys []string
+	for name := range names {
+		if _, ok := checksums[name]; ok {
+			keys = append(keys, name)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, name := range keys {
+		fmt.Fprintf(h, "%s=%s\n", name, checksums[name])
+	}
+
+	if objectMeta.Annotations == nil {
+		objectMeta.Annotations = map[string]string{}
+	}
+	objectMeta.Annotations[annotation] = fmt.Sprintf("%x", h.Sum(nil))
+}
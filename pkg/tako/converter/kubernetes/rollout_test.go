@@ -0,0 +1,200 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var _ = Describe("Config rollout", func() {
+
+	var (
+		k          Kubernetes
+		project    composego.Project
+		configMap  *v1.ConfigMap
+		secret     *v1.Secret
+		deployment *v1apps.Deployment
+		objects    []runtime.Object
+		err        error
+	)
+
+	newDeployment := func() *v1apps.Deployment {
+		return &v1apps.Deployment{
+			ObjectMeta: meta.ObjectMeta{Name: "api"},
+			Spec: v1apps.DeploymentSpec{
+				Template: v1.PodTemplateSpec{
+					Spec: v1.PodSpec{
+						Containers: []v1.Container{
+							{
+								Name: "api",
+								Env: []v1.EnvVar{
+									{
+										Name: "TOKEN",
+										ValueFrom: &v1.EnvVarSource{
+											SecretKeyRef: &v1.SecretKeySelector{
+												LocalObjectReference: v1.LocalObjectReference{Name: "api-token"},
+												Key:                  "token",
+											},
+										},
+									},
+									{
+										Name: "DB_PASSWORD",
+										ValueFrom: &v1.EnvVarSource{
+											SecretKeyRef: &v1.SecretKeySelector{
+												LocalObjectReference: v1.LocalObjectReference{Name: "existing-in-cluster"},
+												Key:                  "password",
+											},
+										},
+									},
+								},
+								EnvFrom: []v1.EnvFromSource{
+									{ConfigMapRef: &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: "app-config"}}},
+								},
+							},
+						},
+						Volumes: []v1.Volume{
+							{
+								Name: "config",
+								VolumeSource: v1.VolumeSource{
+									ConfigMap: &v1.ConfigMapVolumeSource{LocalObjectReference: v1.LocalObjectReference{Name: "app-config"}},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		project = composego.Project{}
+		configMap = &v1.ConfigMap{
+			ObjectMeta: meta.ObjectMeta{Name: "app-config"},
+			Data:       map[string]string{"LOG_LEVEL": "info"},
+		}
+		secret = &v1.Secret{
+			ObjectMeta: meta.ObjectMeta{Name: "api-token"},
+			Type:       v1.SecretTypeOpaque,
+			Data:       map[string][]byte{"token": []byte("s3cr3t")},
+		}
+	})
+
+	JustBeforeEach(func() {
+		k = Kubernetes{
+			Opt:     ConvertOptions{},
+			Project: &project,
+			UI:      kmd.NoOpUI(),
+		}
+
+		var secrets []runtime.Object
+		secrets, err = k.secretObjects([]*v1.Secret{secret})
+		Expect(err).NotTo(HaveOccurred())
+
+		deployment = newDeployment()
+		objects = append([]runtime.Object{configMap, deployment}, secrets...)
+		err = k.configRollout(objects)
+	})
+
+	Context("with default config rollout", func() {
+		It("annotates pod template with checksums of referenced ConfigMaps and Secrets", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deployment.Spec.Template.Annotations).To(HaveKey(ConfigChecksumAnnotation))
+			Expect(deployment.Spec.Template.Annotations).To(HaveKey(SecretChecksumAnnotation))
+			Expect(deployment.Spec.Template.Annotations[ConfigChecksumAnnotation]).To(HaveLen(64))
+			Expect(configMap.Name).To(Equal("app-config"))
+		})
+
+		It("produces stable checksums", func() {
+			annotations := deployment.Spec.Template.Annotations
+
+			other := newDeployment()
+			Expect(k.configRollout([]runtime.Object{configMap, other})).To(Succeed())
+			Expect(other.Spec.Template.Annotations).To(Equal(annotations))
+		})
+
+		It("changes the config checksum when ConfigMap content changes", func() {
+			annotations := deployment.Spec.Template.Annotations
+
+			configMap.Data["LOG_LEVEL"] = "debug"
+			other := newDeployment()
+			Expect(k.configRollout([]runtime.Object{configMap, other})).To(Succeed())
+			Expect(other.Spec.Template.Annotations[ConfigChecksumAnnotation]).NotTo(Equal(annotations[ConfigChecksumAnnotation]))
+			Expect(other.Spec.Template.Annotations[SecretChecksumAnnotation]).To(Equal(annotations[SecretChecksumAnnotation]))
+		})
+
+		It("changes the secret checksum when Secret content changes", func() {
+			annotations := deployment.Spec.Template.Annotations
+
+			secret.Data["token"] = []byte("n3w-s3cr3t")
+			k.recordSecretChecksum(secret.Name, secretChecksum(secret, nil))
+			other := newDeployment()
+			Expect(k.configRollout([]runtime.Object{configMap, other})).To(Succeed())
+			Expect(other.Spec.Template.Annotations[SecretChecksumAnnotation]).NotTo(Equal(annotations[SecretChecksumAnnotation]))
+		})
+	})
+
+	Context("with hashed name config rollout", func() {
+		BeforeEach(func() {
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{"configRollout": "hashedName"},
+			}
+		})
+
+		It("suffixes ConfigMap names with content hash and updates references", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(configMap.Name).To(MatchRegexp(`^app-config-[a-z0-9]{10}$`))
+
+			podSpec := deployment.Spec.Template.Spec
+			Expect(podSpec.Volumes[0].ConfigMap.Name).To(Equal(configMap.Name))
+			Expect(podSpec.Containers[0].EnvFrom[0].ConfigMapRef.Name).To(Equal(configMap.Name))
+		})
+
+		It("only annotates pod template with checksum of referenced Secrets", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deployment.Spec.Template.Annotations).NotTo(HaveKey(ConfigChecksumAnnotation))
+			Expect(deployment.Spec.Template.Annotations).To(HaveKey(SecretChecksumAnnotation))
+		})
+	})
+
+	Context("with config rollout disabled", func() {
+		BeforeEach(func() {
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{"configRollout": "none"},
+			}
+		})
+
+		It("leaves workloads and ConfigMaps untouched", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deployment.Spec.Template.Annotations).To(BeEmpty())
+			Expect(configMap.Name).To(Equal("app-config"))
+		})
+	})
+
+	Describe("hashedName", func() {
+		It("replaces hash characters the kustomize way", func() {
+			Expect(hashedName("cfg", "0123456789abcdef")).To(Equal("cfg-gh2k456789"))
+		})
+	})
+})
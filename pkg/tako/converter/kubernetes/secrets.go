@@ -0,0 +1,558 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	"github.com/appvia/tako/pkg/tako/log"
+	sealedcrypto "github.com/bitnami-labs/sealed-secrets/pkg/crypto"
+	composego "github.com/compose-spec/compose-go/types"
+	"github.com/getsops/sops/v3"
+	sopsaes "github.com/getsops/sops/v3/aes"
+	sopsage "github.com/getsops/sops/v3/age"
+	sopsstores "github.com/getsops/sops/v3/stores"
+	sopsversion "github.com/getsops/sops/v3/version"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// SealedSecretAPIVersion is the API version of Bitnami SealedSecret objects
+	SealedSecretAPIVersion = "bitnami.com/v1alpha1"
+
+	// ExternalSecretAPIVersion is the API version of External Secrets Operator ExternalSecret objects
+	ExternalSecretAPIVersion = "external-secrets.io/v1beta1"
+
+	sealedSecretsNamespaceWideAnnotation = "sealedsecrets.bitnami.com/namespace-wide"
+	sealedSecretsClusterWideAnnotation   = "sealedsecrets.bitnami.com/cluster-wide"
+
+	sopsEncryptedRegex = "^(data|stringData)$"
+	sopsMetadataKey    = "sops"
+)
+
+// secretObjects returns K8s objects for generated secrets in the environment's configured secrets output format.
+// Plain secrets are returned as is, otherwise secrets are encrypted offline so they can be safely committed.
+func (k *Kubernetes) secretObjects(secrets []*v1.Secret) ([]runtime.Object, error) {
+	var objects []runtime.Object
+
+	cfg, err := k.projectK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// @step checksums of encrypted secrets are keyed, otherwise the plain content could be guessed from them offline
+	checksumKey := secretChecksumKey()
+
+	switch cfg.Secrets.Output {
+	case config.SealedSecretsOutput:
+		key, err := loadSealingKey(k.projectPath(cfg.Secrets.Sealed.Certificate))
+		if err != nil {
+			return nil, err
+		}
+		for _, secret := range secrets {
+			sealed, err := sealSecret(secret, key, cfg.Namespace, cfg.Secrets.SealedScope())
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot seal secret %s", secret.Name)
+			}
+			objects = append(objects, sealed)
+		}
+
+	case config.SOPSSecretsOutput:
+		recipients, err := loadAgeRecipients(k.projectPath(cfg.Secrets.SOPS.AgeRecipients))
+		if err != nil {
+			return nil, err
+		}
+		previous := k.previousSOPSSecrets()
+		for _, secret := range secrets {
+			// @step keep previously encrypted secret when its content and recipients haven't changed,
+			// so that secrets aren't re-encrypted with a new data key and timestamp on each render
+			var checksum string
+			if len(checksumKey) > 0 {
+				checksum = secretChecksum(secret, checksumKey)
+			}
+			if prev, ok := previous[secret.Name]; ok && sopsSecretUnchanged(prev, checksum, recipients) {
+				objects = append(objects, prev)
+				continue
+			}
+
+			encrypted, err := sopsEncryptSecret(secret, recipients, checksum)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot encrypt secret %s with SOPS", secret.Name)
+			}
+			objects = append(objects, encrypted)
+		}
+
+	default:
+		for _, secret := range secrets {
+			k.recordSecretChecksum(secret.Name, secretChecksum(secret, nil))
+			objects = append(objects, secret)
+		}
+		return objects, nil
+	}
+
+	// @step record checksums of encrypted secrets, falling back to encrypted content checksum when no key is set
+	if len(checksumKey) == 0 && len(secrets) > 0 {
+		log.Warnf("%s isn't set, so encrypted secrets are encrypted again and workloads referencing them rolled out on each render", SecretChecksumKeyEnv)
+	}
+	for i, secret := range secrets {
+		checksum := encryptedSecretChecksum(objects[i])
+		if len(checksumKey) > 0 {
+			checksum = secretChecksum(secret, checksumKey)
+		}
+		k.recordSecretChecksum(secret.Name, checksum)
+	}
+
+	return objects, nil
+}
+
+// createExternalSecrets creates External Secrets Operator ExternalSecret objects for external compose secrets.
+// A secret store must be configured either on the secret itself or as the environment default,
+// otherwise the secret is expected to already exist in the target namespace.
+func (k *Kubernetes) createExternalSecrets() ([]runtime.Object, error) {
+	var objects []runtime.Object
+
+	cfg, err := k.projectK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for name, secretConfig := range k.Project.Secrets {
+		if secretConfig.External.External {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		es, ok, err := k.externalSecret(name, cfg.Secrets.Store)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		objects = append(objects, k.initExternalSecret(name, es))
+	}
+
+	return objects, nil
+}
+
+// externalSecret returns External Secrets settings of an external compose secret with defaults applied.
+// Returns false when there is no secret store configured for the secret.
+func (k *Kubernetes) externalSecret(name string, defaultStore config.SecretStoreRef) (config.ExternalSecret, bool, error) {
+	secretConfig := composego.FileObjectConfig(k.Project.Secrets[name])
+
+	objCfg, err := config.FileObjK8sConfigFromCompose(&secretConfig)
+	if err != nil {
+		return config.ExternalSecret{}, false, errors.Wrapf(err, "secret %s", name)
+	}
+
+	es := objCfg.ExternalSecret
+	if es.Store.Name == "" {
+		es.Store = defaultStore
+	}
+	if es.Store.Name == "" {
+		return es, false, nil
+	}
+
+	if es.Key == "" {
+		es.Key = secretConfig.Name
+	}
+	if es.Key == "" {
+		es.Key = name
+	}
+	if es.RefreshInterval == 0 {
+		es.RefreshInterval = config.DefaultExternalSecretRefreshInterval
+	}
+
+	return es, true, nil
+}
+
+// externalSecretManaged tells whether an external compose secret is synced by External Secrets Operator
+func (k *Kubernetes) externalSecretManaged(name string) bool {
+	cfg, err := k.projectK8sConfig()
+	if err != nil {
+		return false
+	}
+	_, ok, err := k.externalSecret(name, cfg.Secrets.Store)
+	return err == nil && ok
+}
+
+// initExternalSecret initialises an ExternalSecret syncing the remote secret into the K8s Secret mounted by workloads
+func (k *Kubernetes) initExternalSecret(name string, es config.ExternalSecret) *unstructured.Unstructured {
+	secretName := k.secretName(name)
+
+	remoteRef := map[string]interface{}{
+		"key": es.Key,
+	}
+	if es.Property != "" {
+		remoteRef["property"] = es.Property
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": ExternalSecretAPIVersion,
+			"kind":       "ExternalSecret",
+			"metadata": map[string]interface{}{
+				"name":   secretName,
+				"labels": unstructuredMap(configLabels(secretName)),
+			},
+			"spec": map[string]interface{}{
+				"refreshInterval": es.RefreshInterval.String(),
+				"secretStoreRef": map[string]interface{}{
+					"name": es.Store.Name,
+					"kind": es.Store.StoreKind(),
+				},
+				"target": map[string]interface{}{
+					"name":           secretName,
+					"creationPolicy": "Owner",
+				},
+				"data": []interface{}{
+					map[string]interface{}{
+						// workloads mount the secret key named after the compose secret
+						"secretKey": name,
+						"remoteRef": remoteRef,
+					},
+				},
+			},
+		},
+	}
+}
+
+// projectPath resolves a path relative to the compose project working directory
+func (k *Kubernetes) projectPath(path string) string {
+	if filepath.IsAbs(path) || k.Project.WorkingDir == "" {
+		return path
+	}
+	return filepath.Join(k.Project.WorkingDir, path)
+}
+
+// loadSealingKey loads RSA public key from a Sealed Secrets controller PEM encoded certificate or public key
+func loadSealingKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read sealed secrets certificate %s", path)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.Errorf("sealed secrets certificate %s isn't PEM encoded", path)
+	}
+
+	var pub interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot parse sealed secrets certificate %s", path)
+		}
+		pub = cert.PublicKey
+	case "PUBLIC KEY":
+		if pub, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+			return nil, errors.Wrapf(err, "cannot parse sealed secrets public key %s", path)
+		}
+	default:
+		return nil, errors.Errorf("sealed secrets certificate %s has unsupported PEM block type %q", path, block.Type)
+	}
+
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.Errorf("sealed secrets certificate %s must hold an RSA public key", path)
+	}
+
+	return key, nil
+}
+
+// sealSecret converts a secret into a SealedSecret with data encrypted for the given scope
+func sealSecret(secret *v1.Secret, key *rsa.PublicKey, namespace, scope string) (*unstructured.Unstructured, error) {
+	label := sealingLabel(namespace, secret.Name, scope)
+
+	encryptedData := map[string]interface{}{}
+	for _, name := range sortedDataKeys(secret.Data) {
+		ciphertext, err := sealedcrypto.HybridEncrypt(rand.Reader, key, secret.Data[name], label)
+		if err != nil {
+			return nil, err
+		}
+		encryptedData[name] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	metadata := map[string]interface{}{
+		"name":   secret.Name,
+		"labels": unstructuredMap(secret.Labels),
+	}
+	if scope != config.SealedSecretsClusterWideScope && namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	switch scope {
+	case config.SealedSecretsNamespaceWideScope:
+		metadata["annotations"] = map[string]interface{}{sealedSecretsNamespaceWideAnnotation: "true"}
+	case config.SealedSecretsClusterWideScope:
+		metadata["annotations"] = map[string]interface{}{sealedSecretsClusterWideAnnotation: "true"}
+	}
+
+	template := map[string]interface{}{
+		"metadata": runtime.DeepCopyJSONValue(metadata),
+		"type":     string(secret.Type),
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": SealedSecretAPIVersion,
+			"kind":       "SealedSecret",
+			"metadata":   metadata,
+			"spec": map[string]interface{}{
+				"encryptedData": encryptedData,
+				"template":      template,
+			},
+		},
+	}, nil
+}
+
+// sealingLabel returns the label binding encrypted data to the SealedSecret scope
+func sealingLabel(namespace, name, scope string) []byte {
+	switch scope {
+	case config.SealedSecretsClusterWideScope:
+		return []byte{}
+	case config.SealedSecretsNamespaceWideScope:
+		return []byte(namespace)
+	default:
+		return []byte(fmt.Sprintf("%s/%s", namespace, name))
+	}
+}
+
+// sopsEncryptSecret encrypts secret data with SOPS for the age recipients, so the secret can be decrypted
+// with `sops -d` or any SOPS aware deployment tooling. Keyed content checksum, if any, is kept in annotations.
+// See: https://github.com/getsops/sops
+func sopsEncryptSecret(secret *v1.Secret, recipients []string, checksum string) (*unstructured.Unstructured, error) {
+	data := map[string]interface{}{}
+	for name, value := range secret.Data {
+		data[name] = base64.StdEncoding.EncodeToString(value)
+	}
+
+	annotations := unstructuredMap(secret.Annotations)
+	if checksum != "" {
+		annotations[SecretChecksumAnnotation] = checksum
+	}
+
+	// keys are sorted, matching the order in which they are rendered, as SOPS MAC depends on the order of values
+	branch := sopsTreeBranch(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata": map[string]interface{}{
+			"name":        secret.Name,
+			"labels":      unstructuredMap(secret.Labels),
+			"annotations": annotations,
+		},
+		"type": string(secret.Type),
+		"data": data,
+	})
+
+	var group sops.KeyGroup
+	for _, r := range recipients {
+		key, err := sopsage.MasterKeyFromRecipient(r)
+		if err != nil {
+			return nil, err
+		}
+		group = append(group, key)
+	}
+
+	tree := sops.Tree{
+		Branches: sops.TreeBranches{branch},
+		Metadata: sops.Metadata{
+			EncryptedRegex: sopsEncryptedRegex,
+			Version:        sopsversion.Version,
+			KeyGroups:      []sops.KeyGroup{group},
+		},
+	}
+
+	// @step generate data key and encrypt it for each of the recipients
+	dataKey, errs := tree.GenerateDataKey()
+	if len(errs) > 0 {
+		return nil, errors.Errorf("cannot encrypt data key: %v", errs)
+	}
+
+	// @step encrypt values and MAC the way `sops -e` does
+	cipher := sopsaes.NewCipher()
+	mac, err := tree.Encrypt(dataKey, cipher)
+	if err != nil {
+		return nil, err
+	}
+	tree.Metadata.LastModified = time.Now().UTC()
+	tree.Metadata.MessageAuthenticationCode, err = cipher.Encrypt(mac, dataKey, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := sops.EmitAsMap(tree.Branches)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := jsonValue(sopsstores.MetadataFromInternal(tree.Metadata))
+	if err != nil {
+		return nil, err
+	}
+	obj[sopsMetadataKey] = metadata
+
+	return &unstructured.Unstructured{Object: obj}, nil
+}
+
+// sopsTreeBranch converts a map into SOPS tree branch with keys sorted
+func sopsTreeBranch(m map[string]interface{}) sops.TreeBranch {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var branch sops.TreeBranch
+	for _, key := range keys {
+		value := m[key]
+		if nested, ok := value.(map[string]interface{}); ok {
+			value = sopsTreeBranch(nested)
+		}
+		branch = append(branch, sops.TreeItem{Key: key, Value: value})
+	}
+	return branch
+}
+
+// sopsSecretUnchanged tells whether previously encrypted secret holds the same content for the same recipients
+func sopsSecretUnchanged(prev *unstructured.Unstructured, checksum string, recipients []string) bool {
+	if checksum == "" || prev.GetAnnotations()[SecretChecksumAnnotation] != checksum {
+		return false
+	}
+
+	ageKeys, _, _ := unstructured.NestedSlice(prev.Object, sopsMetadataKey, "age")
+	var prevRecipients []string
+	for _, k := range ageKeys {
+		if m, ok := k.(map[string]interface{}); ok {
+			prevRecipients = append(prevRecipients, fmt.Sprint(m["recipient"]))
+		}
+	}
+
+	current := append([]string{}, recipients...)
+	sort.Strings(current)
+	sort.Strings(prevRecipients)
+	return reflect.DeepEqual(current, prevRecipients)
+}
+
+// previousSOPSSecrets returns SOPS encrypted Secrets found in the previously rendered manifests, keyed by name.
+// Manifests which can't be read are ignored, as the secrets then simply get encrypted again.
+func (k *Kubernetes) previousSOPSSecrets() map[string]*unstructured.Unstructured {
+	out := map[string]*unstructured.Unstructured{}
+
+	if k.Opt.OutFile == "" || k.Opt.ToStdout {
+		return out
+	}
+
+	files := []string{k.Opt.OutFile}
+	if info, err := os.Stat(k.Opt.OutFile); err != nil {
+		return out
+	} else if info.IsDir() {
+		files, _ = filepath.Glob(filepath.Join(k.Opt.OutFile, "*"))
+	}
+
+	for _, file := range files {
+		objects, err := readManifestObjects(file)
+		if err != nil {
+			log.Debugf("Ignoring previously rendered manifest %s: %s", file, err)
+			continue
+		}
+		for _, o := range objects {
+			if _, ok := o.Object[sopsMetadataKey]; ok && o.GetKind() == "Secret" {
+				out[o.GetName()] = o
+			}
+		}
+	}
+
+	return out
+}
+
+// readManifestObjects reads all K8s objects of a YAML or JSON manifest, expanding List objects
+func readManifestObjects(file string) ([]*unstructured.Unstructured, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*unstructured.Unstructured
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		obj := map[string]interface{}{}
+		if err := decoder.Decode(&obj); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(obj) == 0 {
+			continue
+		}
+
+		u := &unstructured.Unstructured{Object: obj}
+		if !u.IsList() {
+			out = append(out, u)
+			continue
+		}
+		if err := u.EachListItem(func(item runtime.Object) error {
+			out = append(out, item.(*unstructured.Unstructured))
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, nil
+}
+
+// jsonValue converts a value to its JSON representation usable in unstructured objects
+func jsonValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	return out, json.Unmarshal(data, &out)
+}
+
+// sortedDataKeys returns sorted keys of secret data
+func sortedDataKeys(data map[string][]byte) []string {
+	var keys []string
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
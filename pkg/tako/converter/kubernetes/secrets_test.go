@@ -0,0 +1,499 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	sealedcrypto "github.com/bitnami-labs/sealed-secrets/pkg/crypto"
+	composego "github.com/compose-spec/compose-go/types"
+	sopsage "github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/decrypt"
+	sopsversion "github.com/getsops/sops/v3/version"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("Secrets output", func() {
+
+	var (
+		k       Kubernetes
+		project composego.Project
+		tmpDir  string
+		secrets []*v1.Secret
+		objects []runtime.Object
+		err     error
+	)
+
+	BeforeEach(func() {
+		tmpDir, err = ioutil.TempDir("", "secrets-output")
+		Expect(err).NotTo(HaveOccurred())
+
+		project = composego.Project{WorkingDir: tmpDir}
+		secrets = []*v1.Secret{
+			{
+				ObjectMeta: meta.ObjectMeta{Name: "db-password", Labels: configLabels("db-password")},
+				Type:       v1.SecretTypeOpaque,
+				Data:       map[string][]byte{"db_password": []byte("s3cr3t")},
+			},
+		}
+	})
+
+	JustBeforeEach(func() {
+		k = Kubernetes{
+			Opt:     ConvertOptions{},
+			Project: &project,
+			UI:      kmd.NoOpUI(),
+		}
+		objects, err = k.secretObjects(secrets)
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(tmpDir)
+	})
+
+	Context("with default output", func() {
+		It("returns plain secrets", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects).To(Equal([]runtime.Object{secrets[0]}))
+		})
+	})
+
+	Context("with sealed output", func() {
+		var (
+			privateKey *rsa.PrivateKey
+			scope      string
+		)
+
+		BeforeEach(func() {
+			privateKey = writeSealingCertificate(filepath.Join(tmpDir, "sealed-secrets.pem"))
+			scope = ""
+		})
+
+		JustBeforeEach(func() {
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"namespace": "prod",
+					"secrets": map[string]interface{}{
+						"output": "sealed",
+						"sealed": map[string]interface{}{
+							"certificate": "sealed-secrets.pem",
+							"scope":       scope,
+						},
+					},
+				},
+			}
+			objects, err = k.secretObjects(secrets)
+		})
+
+		It("returns SealedSecrets with data decryptable by the controller key", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects).To(HaveLen(1))
+
+			sealed := objects[0].(*unstructured.Unstructured)
+			Expect(sealed.GetAPIVersion()).To(Equal(SealedSecretAPIVersion))
+			Expect(sealed.GetKind()).To(Equal("SealedSecret"))
+			Expect(sealed.GetName()).To(Equal("db-password"))
+			Expect(sealed.GetNamespace()).To(Equal("prod"))
+			Expect(sealed.GetLabels()).To(Equal(map[string]string{Selector: "db-password"}))
+
+			templateType, _, _ := unstructured.NestedString(sealed.Object, "spec", "template", "type")
+			Expect(templateType).To(Equal("Opaque"))
+			templateName, _, _ := unstructured.NestedString(sealed.Object, "spec", "template", "metadata", "name")
+			Expect(templateName).To(Equal("db-password"))
+
+			encrypted, _, _ := unstructured.NestedString(sealed.Object, "spec", "encryptedData", "db_password")
+			Expect(unseal(privateKey, encrypted, []byte("prod/db-password"))).To(Equal("s3cr3t"))
+		})
+
+		Context("and cluster-wide scope", func() {
+			BeforeEach(func() {
+				scope = config.SealedSecretsClusterWideScope
+			})
+
+			It("binds encrypted data to no namespace and name", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				sealed := objects[0].(*unstructured.Unstructured)
+				Expect(sealed.GetNamespace()).To(BeEmpty())
+				Expect(sealed.GetAnnotations()).To(HaveKeyWithValue("sealedsecrets.bitnami.com/cluster-wide", "true"))
+
+				encrypted, _, _ := unstructured.NestedString(sealed.Object, "spec", "encryptedData", "db_password")
+				Expect(unseal(privateKey, encrypted, []byte{})).To(Equal("s3cr3t"))
+			})
+		})
+
+		Context("and namespace-wide scope", func() {
+			BeforeEach(func() {
+				scope = config.SealedSecretsNamespaceWideScope
+			})
+
+			It("binds encrypted data to the namespace only", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				sealed := objects[0].(*unstructured.Unstructured)
+				Expect(sealed.GetAnnotations()).To(HaveKeyWithValue("sealedsecrets.bitnami.com/namespace-wide", "true"))
+
+				encrypted, _, _ := unstructured.NestedString(sealed.Object, "spec", "encryptedData", "db_password")
+				Expect(unseal(privateKey, encrypted, []byte("prod"))).To(Equal("s3cr3t"))
+			})
+		})
+
+		Context("and missing certificate", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(tmpDir, "sealed-secrets.pem"))).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("cannot read sealed secrets certificate"))
+			})
+		})
+	})
+
+	Context("with sops output", func() {
+		var identity *age.X25519Identity
+
+		BeforeEach(func() {
+			identity, err = age.GenerateX25519Identity()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.WriteFile(filepath.Join(tmpDir, "recipients.txt"),
+				[]byte("# team keys\n"+identity.Recipient().String()+"\n"), 0600)).To(Succeed())
+			Expect(os.Setenv(sopsage.SopsAgeKeyEnv, identity.String())).To(Succeed())
+
+			project.Extensions = map[string]interface{}{
+				config.K8SExtensionKey: map[string]interface{}{
+					"secrets": map[string]interface{}{
+						"output": "sops",
+						"sops": map[string]interface{}{
+							"ageRecipients": "recipients.txt",
+						},
+					},
+				},
+			}
+		})
+
+		AfterEach(func() {
+			_ = os.Unsetenv(sopsage.SopsAgeKeyEnv)
+		})
+
+		It("returns rendered secrets which sops decrypts with the age identity", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects).To(HaveLen(1))
+
+			secret := objects[0].(*unstructured.Unstructured)
+			Expect(secret.GetKind()).To(Equal("Secret"))
+			Expect(secret.GetName()).To(Equal("db-password"))
+
+			metadata, _, _ := unstructured.NestedMap(secret.Object, "sops")
+			Expect(metadata).To(HaveKeyWithValue("encrypted_regex", "^(data|stringData)$"))
+			Expect(metadata).To(HaveKeyWithValue("version", sopsversion.Version))
+
+			encrypted, _, _ := unstructured.NestedString(secret.Object, "data", "db_password")
+			Expect(encrypted).To(HavePrefix("ENC[AES256_GCM,data:"))
+
+			// decrypt the manifest as rendered to disk, sops verifies the MAC over all values
+			rendered, err := marshal(secret, false, 2)
+			Expect(err).NotTo(HaveOccurred())
+			plain, err := decrypt.Data(rendered, "yaml")
+			Expect(err).NotTo(HaveOccurred())
+
+			var decrypted v1.Secret
+			Expect(yaml.Unmarshal(plain, &decrypted)).To(Succeed())
+			Expect(decrypted.Name).To(Equal("db-password"))
+			Expect(decrypted.Data).To(Equal(map[string][]byte{"db_password": []byte("s3cr3t")}))
+		})
+
+		It("encrypts the data key as an age file decryptable by the identity", func() {
+			ageKeys, _, _ := unstructured.NestedSlice(objects[0].(*unstructured.Unstructured).Object, "sops", "age")
+			Expect(ageKeys).To(HaveLen(1))
+			Expect(ageKeys[0]).To(HaveKeyWithValue("recipient", identity.Recipient().String()))
+
+			enc := ageKeys[0].(map[string]interface{})["enc"].(string)
+			r, err := age.Decrypt(armor.NewReader(strings.NewReader(enc)), identity)
+			Expect(err).NotTo(HaveOccurred())
+			dataKey, err := ioutil.ReadAll(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dataKey).To(HaveLen(32))
+		})
+
+		It("doesn't keep plain content checksum in annotations", func() {
+			Expect(objects[0].(*unstructured.Unstructured).GetAnnotations()).NotTo(HaveKey(SecretChecksumAnnotation))
+			Expect(k.secretChecksums["db-password"]).To(Equal(encryptedSecretChecksum(objects[0])))
+		})
+
+		Context("and previously rendered secrets", func() {
+			var previous *unstructured.Unstructured
+
+			BeforeEach(func() {
+				Expect(os.Setenv(SecretChecksumKeyEnv, "checksum-key")).To(Succeed())
+			})
+
+			AfterEach(func() {
+				_ = os.Unsetenv(SecretChecksumKeyEnv)
+			})
+
+			JustBeforeEach(func() {
+				Expect(err).NotTo(HaveOccurred())
+				previous = objects[0].(*unstructured.Unstructured)
+
+				data, err := marshal(previous, false, 2)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(ioutil.WriteFile(filepath.Join(tmpDir, "db-password-secret.yaml"), data, 0600)).To(Succeed())
+				k.Opt.OutFile = tmpDir
+			})
+
+			It("keeps encrypted secrets with unchanged content", func() {
+				again, err := k.secretObjects(secrets)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(again[0].(*unstructured.Unstructured).Object).To(Equal(previous.Object))
+			})
+
+			It("keeps keyed content checksum in annotations", func() {
+				checksum := previous.GetAnnotations()[SecretChecksumAnnotation]
+				Expect(checksum).To(Equal(secretChecksum(secrets[0], []byte("checksum-key"))))
+				Expect(checksum).NotTo(Equal(secretChecksum(secrets[0], nil)))
+				Expect(k.secretChecksums["db-password"]).To(Equal(checksum))
+			})
+
+			It("encrypts secrets again when checksum key is no longer set", func() {
+				Expect(os.Unsetenv(SecretChecksumKeyEnv)).To(Succeed())
+
+				again, err := k.secretObjects(secrets)
+				Expect(err).NotTo(HaveOccurred())
+
+				mac, _, _ := unstructured.NestedString(again[0].(*unstructured.Unstructured).Object, "sops", "mac")
+				prevMac, _, _ := unstructured.NestedString(previous.Object, "sops", "mac")
+				Expect(mac).NotTo(Equal(prevMac))
+			})
+
+			It("encrypts secrets with changed content again", func() {
+				secrets[0].Data["db_password"] = []byte("n3w-s3cr3t")
+
+				again, err := k.secretObjects(secrets)
+				Expect(err).NotTo(HaveOccurred())
+
+				mac, _, _ := unstructured.NestedString(again[0].(*unstructured.Unstructured).Object, "sops", "mac")
+				prevMac, _, _ := unstructured.NestedString(previous.Object, "sops", "mac")
+				Expect(mac).NotTo(Equal(prevMac))
+			})
+		})
+
+		Context("and invalid recipient", func() {
+			BeforeEach(func() {
+				Expect(ioutil.WriteFile(filepath.Join(tmpDir, "recipients.txt"),
+					[]byte("ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHp\n"), 0600)).To(Succeed())
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("malformed age recipient"))
+			})
+		})
+	})
+
+	Describe("createExternalSecrets", func() {
+		var externalSecrets []runtime.Object
+
+		BeforeEach(func() {
+			project.Secrets = composego.Secrets{
+				"db_password": composego.SecretConfig{
+					Name:     "prod-db-password",
+					External: composego.External{External: true},
+				},
+				"api_key": composego.SecretConfig{
+					Name:     "api_key",
+					External: composego.External{External: true},
+					Extensions: map[string]interface{}{
+						config.K8SExtensionKey: map[string]interface{}{
+							"name": "api-credentials",
+							"externalSecret": map[string]interface{}{
+								"store":           map[string]interface{}{"name": "aws", "kind": "ClusterSecretStore"},
+								"key":             "prod/api",
+								"property":        "key",
+								"refreshInterval": "15m",
+							},
+						},
+					},
+				},
+				"local": composego.SecretConfig{
+					File: "../../testdata/converter/kubernetes/secrets/secret_file",
+				},
+			}
+		})
+
+		JustBeforeEach(func() {
+			externalSecrets, err = k.createExternalSecrets()
+		})
+
+		Context("without environment default secret store", func() {
+			It("only creates ExternalSecrets for secrets with a store configured", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(externalSecrets).To(HaveLen(1))
+
+				es := externalSecrets[0].(*unstructured.Unstructured)
+				Expect(es.Object).To(Equal(map[string]interface{}{
+					"apiVersion": ExternalSecretAPIVersion,
+					"kind":       "ExternalSecret",
+					"metadata": map[string]interface{}{
+						"name":   "api-credentials",
+						"labels": map[string]interface{}{Selector: "api-credentials"},
+					},
+					"spec": map[string]interface{}{
+						"refreshInterval": "15m0s",
+						"secretStoreRef": map[string]interface{}{
+							"name": "aws",
+							"kind": "ClusterSecretStore",
+						},
+						"target": map[string]interface{}{
+							"name":           "api-credentials",
+							"creationPolicy": "Owner",
+						},
+						"data": []interface{}{
+							map[string]interface{}{
+								"secretKey": "api_key",
+								"remoteRef": map[string]interface{}{
+									"key":      "prod/api",
+									"property": "key",
+								},
+							},
+						},
+					},
+				}))
+			})
+		})
+
+		Context("with environment default secret store", func() {
+			BeforeEach(func() {
+				project.Extensions = map[string]interface{}{
+					config.K8SExtensionKey: map[string]interface{}{
+						"secrets": map[string]interface{}{
+							"store": map[string]interface{}{"name": "vault"},
+						},
+					},
+				}
+			})
+
+			It("creates ExternalSecrets for all external secrets using default store, key and refresh interval", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(externalSecrets).To(HaveLen(2))
+
+				es := externalSecrets[1].(*unstructured.Unstructured)
+				Expect(es.GetName()).To(Equal("db-password"))
+
+				spec := es.Object["spec"].(map[string]interface{})
+				Expect(spec["refreshInterval"]).To(Equal("1h0m0s"))
+				Expect(spec["secretStoreRef"]).To(Equal(map[string]interface{}{"name": "vault", "kind": "SecretStore"}))
+				Expect(spec["target"]).To(HaveKeyWithValue("name", "db-password"))
+				Expect(spec["data"]).To(Equal([]interface{}{
+					map[string]interface{}{
+						"secretKey": "db_password",
+						"remoteRef": map[string]interface{}{"key": "prod-db-password"},
+					},
+				}))
+			})
+
+			It("targets the secret mounted by workloads", func() {
+				mounts, volumes := k.configSecretVolumes(ProjectService{ServiceConfig: composego.ServiceConfig{
+					Secrets: []composego.ServiceSecretConfig{{Source: "db_password"}},
+				}})
+				Expect(mounts).To(HaveLen(1))
+				Expect(volumes[0].Secret.SecretName).To(Equal("db-password"))
+				Expect(volumes[0].Secret.Items[0].Key).To(Equal("db_password"))
+			})
+
+			It("doesn't warn about external secrets which need to exist in the cluster", func() {
+				hook.Reset()
+				_, err := k.createSecrets()
+				Expect(err).NotTo(HaveOccurred())
+				for _, entry := range hook.AllEntries() {
+					Expect(entry.Message).NotTo(ContainSubstring("expects secret to exist"))
+				}
+			})
+		})
+	})
+
+	Describe("loadAgeRecipients", func() {
+		It("rejects recipients with invalid checksum", func() {
+			identity, err := age.GenerateX25519Identity()
+			Expect(err).NotTo(HaveOccurred())
+			recipient := identity.Recipient().String()
+			last := recipient[len(recipient)-1:]
+			replacement := "q"
+			if last == "q" {
+				replacement = "p"
+			}
+
+			path := filepath.Join(tmpDir, "recipients.txt")
+			Expect(ioutil.WriteFile(path, []byte(recipient[:len(recipient)-1]+replacement), 0600)).To(Succeed())
+
+			_, err = loadAgeRecipients(path)
+			Expect(err).To(MatchError(ContainSubstring("malformed age recipient")))
+		})
+	})
+})
+
+// writeSealingCertificate writes a self-signed certificate to a file and returns its private key
+func writeSealingCertificate(path string) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	Expect(err).NotTo(HaveOccurred())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sealed-secret"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	Expect(err).NotTo(HaveOccurred())
+
+	Expect(ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)).To(Succeed())
+	return key
+}
+
+// unseal decrypts sealed secret value the way Sealed Secrets controller does
+func unseal(key *rsa.PrivateKey, encrypted string, label []byte) string {
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
+	Expect(err).NotTo(HaveOccurred())
+
+	fingerprint, err := sealedcrypto.PublicKeyFingerprint(&key.PublicKey)
+	Expect(err).NotTo(HaveOccurred())
+
+	plaintext, err := sealedcrypto.HybridDecrypt(rand.Reader, map[string]*rsa.PrivateKey{fingerprint: key}, ciphertext, label)
+	Expect(err).NotTo(HaveOccurred())
+	return string(plaintext)
+}
@@ -0,0 +1,206 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	"github.com/appvia/tako/pkg/tako/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+// volumeClaimMount represents a workload mounting a volume claim
+type volumeClaimMount struct {
+	workload string
+	replicas int32
+}
+
+// analyseSharedVolumes builds the graph of volume claims and workloads mounting them, and reports
+// ReadWriteOnce claims shared by multiple workloads, or by a workload running multiple pods.
+// Such claims work in compose, but can only be attached to a single cluster node at a time.
+func (k *Kubernetes) analyseSharedVolumes() error {
+	cfg, err := k.projectK8sConfig()
+	if err != nil {
+		return err
+	}
+
+	check, _ := config.SharedVolumesCheckFromValue(cfg.SharedVolumes.String())
+	if check == config.IgnoreSharedVolumes {
+		return nil
+	}
+
+	// @step volumes aren't claimed when converted globally to other volume types
+	if k.Opt.EmptyVols || k.Opt.Volumes != "" {
+		return nil
+	}
+
+	graph, err := k.volumeClaimsGraph()
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	claims := make([]string, 0, len(graph))
+	for claim := range graph {
+		claims = append(claims, claim)
+	}
+	sort.Strings(claims)
+
+	for _, claim := range claims {
+		mounts := graph[claim]
+		workloads := make([]string, 0, len(mounts))
+		for _, m := range mounts {
+			workloads = append(workloads, m.workload)
+		}
+
+		var msg string
+		if len(mounts) > 1 {
+			msg = fmt.Sprintf("ReadWriteOnce volume %q is shared by workloads %s. Use ReadWriteMany volume access mode, or merge the workloads into one with sidecar containers",
+				claim, strings.Join(workloads, ", "))
+		} else if mounts[0].replicas > 1 {
+			msg = fmt.Sprintf("ReadWriteOnce volume %q is shared by %d replicas of workload %s. Use ReadWriteMany volume access mode, or a StatefulSet with volume retention policy to claim a volume per replica",
+				claim, mounts[0].replicas, mounts[0].workload)
+		} else {
+			continue
+		}
+
+		if check == config.FailSharedVolumes {
+			problems = append(problems, msg)
+			continue
+		}
+
+		log.WarnfWithFields(log.Fields{
+			"volume":    claim,
+			"workloads": strings.Join(workloads, ","),
+		}, "%s", msg)
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("Shared volumes detected: %s. Set `sharedVolumes: warn` to render them regardless",
+			strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// volumeClaimsGraph returns project service workloads mounting each ReadWriteOnce volume claim
+func (k *Kubernetes) volumeClaimsGraph() (map[string][]volumeClaimMount, error) {
+	graph := map[string][]volumeClaimMount{}
+
+	for _, svc := range k.Project.Services {
+		if contains(k.Excluded, svc.Name) {
+			continue
+		}
+
+		projectService, err := NewProjectService(svc)
+		if err != nil {
+			return nil, err
+		}
+
+		if !projectService.enabled() || projectService.externalName() != "" {
+			continue
+		}
+
+		projectService.Name = k8sServiceName(svc)
+		volumes, err := projectService.volumes(k.Project)
+		if err != nil {
+			return nil, err
+		}
+
+		mount := volumeClaimMount{
+			workload: projectService.Name,
+			replicas: k.workloadPods(projectService),
+		}
+
+		seen := map[string]bool{}
+		for _, volume := range volumes {
+			claim, ok := k.readWriteOnceClaim(projectService, volume)
+			if !ok || seen[claim] {
+				continue
+			}
+			seen[claim] = true
+			graph[claim] = append(graph[claim], mount)
+		}
+	}
+
+	return graph, nil
+}
+
+// workloadPods returns the maximum number of pods of the project service workload.
+// DaemonSet runs a pod per node, so it's always considered to run multiple pods.
+func (k *Kubernetes) workloadPods(projectService ProjectService) int32 {
+	if config.WorkloadTypesEqual(projectService.SvcK8sConfig.Workload.Type, config.DaemonSetWorkload) ||
+		(projectService.Deploy != nil && projectService.Deploy.Mode == "global") {
+		return 2
+	}
+
+	replicas := projectService.replicas()
+	if maxReplicas := projectService.autoscaleMaxReplicas(); maxReplicas > replicas {
+		replicas = maxReplicas
+	}
+
+	return replicas
+}
+
+// readWriteOnceClaim returns the name of the claim mounted via the volume, and true when
+// the claim is only accessible from a single node. External claims aren't checked as
+// their access modes are unknown, and StatefulSet volume claim templates are per pod.
+func (k *Kubernetes) readWriteOnceClaim(projectService ProjectService, volume Volumes) (string, bool) {
+	if volume.External || volume.CSI.Enabled() || (volume.NFS.Enabled() && !volume.NFS.PersistentVolume) {
+		return "", false
+	}
+
+	if isBindMount(volume) {
+		strategy := k.bindMountStrategy(projectService, volume)
+		if strategy != config.NoBindMountStrategy && strategy != config.PVCBindMount {
+			return "", false
+		}
+	}
+
+	if volume.Retention.Enabled() &&
+		config.WorkloadTypesEqual(projectService.SvcK8sConfig.Workload.Type, config.StatefulSetWorkload) {
+		return "", false
+	}
+
+	modes := volume.AccessModes
+	if len(modes) == 0 {
+		switch {
+		case volume.Mode == "ro":
+			modes = []string{string(v1.ReadOnlyMany)}
+		case volume.NFS.Enabled():
+			modes = []string{string(v1.ReadWriteMany)}
+		default:
+			modes = []string{string(v1.ReadWriteOnce)}
+		}
+	}
+
+	for _, mode := range modes {
+		if mode != string(v1.ReadWriteOnce) && mode != string(v1.ReadWriteOncePod) {
+			return "", false
+		}
+	}
+
+	claim := volume.VolumeName
+	if claim == "" {
+		claim = volume.PVCName
+	}
+
+	return claim, true
+}
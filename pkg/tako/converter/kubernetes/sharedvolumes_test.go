@@ -0,0 +1,153 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Shared volumes", func() {
+
+	var (
+		k          Kubernetes
+		project    composego.Project
+		projectExt map[string]interface{}
+		volumeExt  map[string]interface{}
+		apiExt     map[string]interface{}
+		workerExt  map[string]interface{}
+		err        error
+	)
+
+	BeforeEach(func() {
+		projectExt = map[string]interface{}{}
+		volumeExt = map[string]interface{}{}
+		apiExt = map[string]interface{}{}
+		workerExt = map[string]interface{}{}
+	})
+
+	JustBeforeEach(func() {
+		hook.Reset()
+
+		project = composego.Project{
+			Services: composego.Services{
+				{
+					Name:       "api",
+					Image:      "api",
+					Volumes:    []composego.ServiceVolumeConfig{{Type: "volume", Source: "data", Target: "/data"}},
+					Extensions: map[string]interface{}{config.K8SExtensionKey: apiExt},
+				},
+				{
+					Name:       "worker",
+					Image:      "worker",
+					Volumes:    []composego.ServiceVolumeConfig{{Type: "volume", Source: "data", Target: "/data"}},
+					Extensions: map[string]interface{}{config.K8SExtensionKey: workerExt},
+				},
+			},
+			Volumes: composego.Volumes{
+				"data": composego.VolumeConfig{
+					Extensions: map[string]interface{}{config.K8SExtensionKey: volumeExt},
+				},
+			},
+			Extensions: map[string]interface{}{config.K8SExtensionKey: projectExt},
+		}
+
+		k = Kubernetes{
+			Opt:     ConvertOptions{},
+			Project: &project,
+			UI:      kmd.NoOpUI(),
+		}
+
+		err = k.analyseSharedVolumes()
+	})
+
+	Context("with ReadWriteOnce volume shared by workloads", func() {
+		It("warns about the shared volume", func() {
+			Expect(err).NotTo(HaveOccurred())
+			assertLog(logrus.WarnLevel,
+				`ReadWriteOnce volume "data" is shared by workloads api, worker. Use ReadWriteMany volume access mode, or merge the workloads into one with sidecar containers`,
+				map[string]string{
+					"volume":    "data",
+					"workloads": "api,worker",
+				},
+			)
+		})
+
+		Context("and shared volumes check set to fail", func() {
+			BeforeEach(func() {
+				projectExt["sharedVolumes"] = "fail"
+			})
+
+			It("returns an error", func() {
+				Expect(err).To(MatchError(`Shared volumes detected: ReadWriteOnce volume "data" is shared by workloads api, worker. ` +
+					"Use ReadWriteMany volume access mode, or merge the workloads into one with sidecar containers. " +
+					"Set `sharedVolumes: warn` to render them regardless"))
+			})
+		})
+
+		Context("and shared volumes check set to ignore", func() {
+			BeforeEach(func() {
+				projectExt["sharedVolumes"] = "ignore"
+			})
+
+			It("doesn't report the shared volume", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(hook.LastEntry()).To(BeNil())
+			})
+		})
+	})
+
+	Context("with ReadWriteMany volume shared by workloads", func() {
+		BeforeEach(func() {
+			volumeExt["accessModes"] = []interface{}{"ReadWriteMany"}
+		})
+
+		It("doesn't report the shared volume", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hook.LastEntry()).To(BeNil())
+		})
+	})
+
+	Context("with ReadWriteOnce volume mounted by a workload with multiple replicas", func() {
+		BeforeEach(func() {
+			projectExt["sharedVolumes"] = "fail"
+			apiExt["workload"] = map[string]interface{}{"replicas": 3}
+			workerExt["disabled"] = true
+		})
+
+		It("returns an error suggesting a volume per replica", func() {
+			Expect(err).To(MatchError(`Shared volumes detected: ReadWriteOnce volume "data" is shared by 3 replicas of workload api. ` +
+				"Use ReadWriteMany volume access mode, or a StatefulSet with volume retention policy to claim a volume per replica. " +
+				"Set `sharedVolumes: warn` to render them regardless"))
+		})
+	})
+
+	Context("with ReadWriteOnce volume mounted by a single pod", func() {
+		BeforeEach(func() {
+			workerExt["disabled"] = true
+		})
+
+		It("doesn't report the volume", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hook.LastEntry()).To(BeNil())
+		})
+	})
+})
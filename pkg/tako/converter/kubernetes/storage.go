@@ -0,0 +1,307 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	"github.com/appvia/tako/pkg/tako/log"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	// VolumeSnapshotAPIVersion is the API version of CSI VolumeSnapshot objects
+	VolumeSnapshotAPIVersion = "snapshot.storage.k8s.io/v1"
+
+	// SnapshotScheduleAPIVersion is the API version of SnapScheduler SnapshotSchedule objects
+	SnapshotScheduleAPIVersion = "snapscheduler.backube/v1"
+)
+
+// createStorageClasses returns StorageClasses declared via project `storageClasses`, sorted by name.
+// It also warns about volumes referencing storage classes not declared in the project, which then
+// must already exist in the cluster.
+func (k *Kubernetes) createStorageClasses() ([]runtime.Object, error) {
+	cfg, err := k.projectK8sConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.StorageClasses) == 0 {
+		return nil, nil
+	}
+
+	// @step validate storage classes referenced by project volumes
+	volumeNames := make([]string, 0, len(k.Project.Volumes))
+	for name := range k.Project.Volumes {
+		volumeNames = append(volumeNames, name)
+	}
+	sort.Strings(volumeNames)
+
+	for _, name := range volumeNames {
+		vol := k.Project.Volumes[name]
+		volCfg, err := config.VolK8sConfigFromCompose(&vol)
+		if err != nil {
+			return nil, err
+		}
+
+		if volCfg.StorageClass == "" {
+			continue
+		}
+
+		if _, ok := cfg.StorageClasses[volCfg.StorageClass]; !ok {
+			log.WarnfWithFields(log.Fields{
+				"volume":        name,
+				"storage-class": volCfg.StorageClass,
+			}, "Storage class %s isn't declared in project storageClasses. It must exist in the cluster", volCfg.StorageClass)
+		}
+	}
+
+	names := make([]string, 0, len(cfg.StorageClasses))
+	for name := range cfg.StorageClasses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var objects []runtime.Object
+	for _, name := range names {
+		objects = append(objects, k.initStorageClass(name, cfg.StorageClasses[name]))
+	}
+
+	return objects, nil
+}
+
+// initStorageClass initialises a StorageClass object
+func (k *Kubernetes) initStorageClass(name string, sc config.StorageClass) *storagev1.StorageClass {
+	storageClass := &storagev1.StorageClass{
+		TypeMeta: meta.TypeMeta{
+			Kind:       "StorageClass",
+			APIVersion: "storage.k8s.io/v1",
+		},
+		ObjectMeta: meta.ObjectMeta{
+			Name:   name,
+			Labels: configLabels(name),
+		},
+		Provisioner:  sc.Provisioner,
+		Parameters:   sc.Parameters,
+		MountOptions: sc.MountOptions,
+	}
+
+	if len(sc.ReclaimPolicy) > 0 {
+		reclaimPolicy := v1.PersistentVolumeReclaimPolicy(sc.ReclaimPolicy)
+		storageClass.ReclaimPolicy = &reclaimPolicy
+	}
+
+	if len(sc.VolumeBindingMode) > 0 {
+		bindingMode := storagev1.VolumeBindingMode(sc.VolumeBindingMode)
+		storageClass.VolumeBindingMode = &bindingMode
+	}
+
+	if sc.AllowVolumeExpansion {
+		storageClass.AllowVolumeExpansion = &sc.AllowVolumeExpansion
+	}
+
+	return storageClass
+}
+
+// createVolumeSnapshots returns VolumeSnapshots and SnapshotSchedules of the project service volume claims.
+// Snapshots of claims created from StatefulSet volume claim templates aren't supported, as the claims are per pod.
+func (k *Kubernetes) createVolumeSnapshots(projectService ProjectService) ([]runtime.Object, error) {
+	// @step volumes aren't claimed when converted globally to other volume types
+	if k.Opt.EmptyVols || k.Opt.Volumes != "" {
+		return nil, nil
+	}
+
+	projectServiceVolumes, err := projectService.volumes(k.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	// @step snapshots are named once across the whole project
+	if k.snapshotNames == nil {
+		if k.snapshotNames, err = k.volumeSnapshotNames(); err != nil {
+			return nil, err
+		}
+	}
+
+	var objects []runtime.Object
+	for _, volume := range projectServiceVolumes {
+		if len(volume.Snapshots) == 0 {
+			continue
+		}
+
+		if volume.Retention.Enabled() &&
+			config.WorkloadTypesEqual(projectService.SvcK8sConfig.Workload.Type, config.StatefulSetWorkload) {
+			log.WarnfWithFields(log.Fields{
+				"project-service": projectService.Name,
+				"volume":          volume.VolumeName,
+			}, "Snapshots of volume %s claimed via StatefulSet volume claim templates aren't supported. Snapshots will be ignored",
+				volume.VolumeName)
+
+			continue
+		}
+
+		names := k.snapshotNames[volume.VolumeName]
+		for i, snapshot := range volume.Snapshots {
+			name := names[i]
+
+			if snapshot.Scheduled() {
+				objects = append(objects, k.initSnapshotSchedule(name, volume.VolumeName, snapshot))
+			} else {
+				objects = append(objects, k.initVolumeSnapshot(name, volume.VolumeName, snapshot))
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+// volumeSnapshotNames returns names of VolumeSnapshots and SnapshotSchedules of all project volume claims, keyed by
+// claim name in the order snapshots are defined. Explicitly set names must be unique across the project.
+// Default names, i.e. `<volume>-snapshot` and `<volume>-schedule`, are suffixed with a sequence number when taken.
+func (k *Kubernetes) volumeSnapshotNames() (map[string][]string, error) {
+	type volumeSnapshots struct {
+		claim     string
+		snapshots []config.VolumeSnapshot
+	}
+
+	volumeNames := make([]string, 0, len(k.Project.Volumes))
+	for name := range k.Project.Volumes {
+		volumeNames = append(volumeNames, name)
+	}
+	sort.Strings(volumeNames)
+
+	var volumes []volumeSnapshots
+	for _, name := range volumeNames {
+		vol := k.Project.Volumes[name]
+		cfg, err := config.VolK8sConfigFromCompose(&vol)
+		if err != nil {
+			return nil, err
+		}
+		if len(cfg.Snapshots) > 0 {
+			volumes = append(volumes, volumeSnapshots{claim: k8sVolumeName(name, vol), snapshots: cfg.Snapshots})
+		}
+	}
+
+	kind := func(snapshot config.VolumeSnapshot) string {
+		if snapshot.Scheduled() {
+			return "SnapshotSchedule"
+		}
+		return "VolumeSnapshot"
+	}
+
+	// @step explicitly set names are reserved first
+	taken := map[string]string{}
+	for _, vol := range volumes {
+		for _, snapshot := range vol.snapshots {
+			if snapshot.Name == "" {
+				continue
+			}
+			key := kind(snapshot) + "/" + snapshot.Name
+			if claim, ok := taken[key]; ok {
+				return nil, fmt.Errorf("%s name %q of volume %s is already used by volume %s. Set a unique snapshot `name`",
+					kind(snapshot), snapshot.Name, vol.claim, claim)
+			}
+			taken[key] = vol.claim
+		}
+	}
+
+	// @step default names are suffixed with a sequence number when already taken
+	names := map[string][]string{}
+	for _, vol := range volumes {
+		for _, snapshot := range vol.snapshots {
+			name := snapshot.Name
+			if name == "" {
+				suffix := "snapshot"
+				if snapshot.Scheduled() {
+					suffix = "schedule"
+				}
+				base := fmt.Sprintf("%s-%s", vol.claim, suffix)
+
+				name = base
+				for n := 1; taken[kind(snapshot)+"/"+name] != ""; n++ {
+					name = fmt.Sprintf("%s-%d", base, n)
+				}
+				taken[kind(snapshot)+"/"+name] = vol.claim
+			}
+			names[vol.claim] = append(names[vol.claim], name)
+		}
+	}
+
+	return names, nil
+}
+
+// initVolumeSnapshot initialises a one-off VolumeSnapshot of the claim
+func (k *Kubernetes) initVolumeSnapshot(name, claim string, snapshot config.VolumeSnapshot) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": claim,
+		},
+	}
+	if snapshot.Class != "" {
+		spec["volumeSnapshotClassName"] = snapshot.Class
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": VolumeSnapshotAPIVersion,
+			"kind":       "VolumeSnapshot",
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": unstructuredMap(configLabels(claim)),
+			},
+			"spec": spec,
+		},
+	}
+}
+
+// initSnapshotSchedule initialises a SnapshotSchedule taking snapshots of the claim on a schedule
+func (k *Kubernetes) initSnapshotSchedule(name, claim string, snapshot config.VolumeSnapshot) *unstructured.Unstructured {
+	spec := map[string]interface{}{
+		"schedule": snapshot.Schedule,
+		"claimSelector": map[string]interface{}{
+			"matchLabels": unstructuredMap(configLabels(claim)),
+		},
+	}
+	if snapshot.Retention > 0 {
+		spec["retention"] = map[string]interface{}{
+			"maxCount": int64(snapshot.Retention),
+		}
+	}
+	if snapshot.Class != "" {
+		spec["snapshotTemplate"] = map[string]interface{}{
+			"snapshotClassName": snapshot.Class,
+		}
+	}
+
+	return &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": SnapshotScheduleAPIVersion,
+			"kind":       "SnapshotSchedule",
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": unstructuredMap(configLabels(claim)),
+			},
+			"spec": spec,
+		},
+	}
+}
@@ -0,0 +1,228 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var _ = Describe("Storage", func() {
+
+	var (
+		k          Kubernetes
+		project    composego.Project
+		projectExt map[string]interface{}
+		volumeExt  map[string]interface{}
+		objs       []runtime.Object
+		err        error
+	)
+
+	findObjects := func(kind string) []runtime.Object {
+		var out []runtime.Object
+		for _, o := range objs {
+			if o.GetObjectKind().GroupVersionKind().Kind == kind {
+				out = append(out, o)
+			}
+		}
+		return out
+	}
+
+	BeforeEach(func() {
+		projectExt = map[string]interface{}{}
+		volumeExt = map[string]interface{}{"size": "1Gi"}
+	})
+
+	JustBeforeEach(func() {
+		hook.Reset()
+
+		project = composego.Project{
+			Services: composego.Services{
+				{
+					Name:    "db",
+					Image:   "postgres",
+					Volumes: []composego.ServiceVolumeConfig{{Type: "volume", Source: "data", Target: "/var/lib/data"}},
+				},
+			},
+			Volumes: composego.Volumes{
+				"data": composego.VolumeConfig{
+					Extensions: map[string]interface{}{config.K8SExtensionKey: volumeExt},
+				},
+			},
+			Extensions: map[string]interface{}{config.K8SExtensionKey: projectExt},
+		}
+
+		k = Kubernetes{
+			Opt:     ConvertOptions{},
+			Project: &project,
+			UI:      kmd.NoOpUI(),
+		}
+
+		objs, err = k.Transform()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("storage classes", func() {
+		BeforeEach(func() {
+			projectExt["storageClasses"] = map[string]interface{}{
+				"preview-ssd": map[string]interface{}{
+					"provisioner":          "ebs.csi.aws.com",
+					"parameters":           map[string]interface{}{"type": "gp3"},
+					"reclaimPolicy":        "Delete",
+					"volumeBindingMode":    "WaitForFirstConsumer",
+					"allowVolumeExpansion": true,
+				},
+			}
+			volumeExt["storageClass"] = "preview-ssd"
+		})
+
+		It("creates the declared StorageClass", func() {
+			classes := findObjects("StorageClass")
+			Expect(classes).To(HaveLen(1))
+
+			sc := classes[0].(*storagev1.StorageClass)
+			Expect(sc.Name).To(Equal("preview-ssd"))
+			Expect(sc.Provisioner).To(Equal("ebs.csi.aws.com"))
+			Expect(sc.Parameters).To(Equal(map[string]string{"type": "gp3"}))
+			Expect(*sc.ReclaimPolicy).To(Equal(v1.PersistentVolumeReclaimDelete))
+			Expect(*sc.VolumeBindingMode).To(Equal(storagev1.VolumeBindingWaitForFirstConsumer))
+			Expect(*sc.AllowVolumeExpansion).To(BeTrue())
+		})
+
+		It("claims the volume from the declared StorageClass", func() {
+			pvc := findObjects("PersistentVolumeClaim")[0].(*v1.PersistentVolumeClaim)
+			Expect(*pvc.Spec.StorageClassName).To(Equal("preview-ssd"))
+		})
+
+		Context("when volume references undeclared storage class", func() {
+			BeforeEach(func() {
+				volumeExt["storageClass"] = "standard"
+			})
+
+			It("warns the storage class must exist in the cluster", func() {
+				Expect(hook.Entries).To(ContainElement(WithTransform(func(e logrus.Entry) string {
+					return e.Message
+				}, Equal("Storage class standard isn't declared in project storageClasses. It must exist in the cluster"))))
+			})
+		})
+	})
+
+	Describe("volume snapshots", func() {
+		BeforeEach(func() {
+			volumeExt["snapshots"] = []interface{}{
+				map[string]interface{}{"class": "csi-snapclass"},
+				map[string]interface{}{"schedule": "0 2 * * *", "retention": 7},
+			}
+		})
+
+		It("creates a one-off VolumeSnapshot of the claim", func() {
+			snapshots := findObjects("VolumeSnapshot")
+			Expect(snapshots).To(HaveLen(1))
+
+			s := snapshots[0].(*unstructured.Unstructured)
+			Expect(s.GetAPIVersion()).To(Equal(VolumeSnapshotAPIVersion))
+			Expect(s.GetName()).To(Equal("data-snapshot"))
+			Expect(s.Object["spec"]).To(Equal(map[string]interface{}{
+				"volumeSnapshotClassName": "csi-snapclass",
+				"source":                  map[string]interface{}{"persistentVolumeClaimName": "data"},
+			}))
+		})
+
+		Context("with multiple one-off snapshots", func() {
+			BeforeEach(func() {
+				volumeExt["snapshots"] = []interface{}{
+					map[string]interface{}{},
+					map[string]interface{}{},
+					map[string]interface{}{"name": "data-before-upgrade"},
+				}
+			})
+
+			It("suffixes default snapshot names", func() {
+				var names []string
+				for _, o := range findObjects("VolumeSnapshot") {
+					names = append(names, o.(*unstructured.Unstructured).GetName())
+				}
+				Expect(names).To(Equal([]string{"data-snapshot", "data-snapshot-1", "data-before-upgrade"}))
+			})
+		})
+
+		Context("with snapshots of multiple volumes", func() {
+			JustBeforeEach(func() {
+				project.Services[0].Volumes = append(project.Services[0].Volumes,
+					composego.ServiceVolumeConfig{Type: "volume", Source: "archive", Target: "/var/lib/archive"})
+				project.Volumes["archive"] = composego.VolumeConfig{
+					Extensions: map[string]interface{}{config.K8SExtensionKey: map[string]interface{}{
+						"size":      "1Gi",
+						"snapshots": []interface{}{map[string]interface{}{"name": "data-snapshot"}},
+					}},
+				}
+				k.snapshotNames = nil
+
+				objs, err = k.Transform()
+			})
+
+			It("suffixes default snapshot names taken by other volumes", func() {
+				Expect(err).NotTo(HaveOccurred())
+
+				names := map[string]string{}
+				for _, o := range findObjects("VolumeSnapshot") {
+					u := o.(*unstructured.Unstructured)
+					names[u.GetName()] = u.Object["spec"].(map[string]interface{})["source"].(map[string]interface{})["persistentVolumeClaimName"].(string)
+				}
+				Expect(names).To(Equal(map[string]string{
+					"data-snapshot":   "archive",
+					"data-snapshot-1": "data",
+				}))
+			})
+
+			Context("when explicit snapshot names clash", func() {
+				BeforeEach(func() {
+					volumeExt["snapshots"] = []interface{}{
+						map[string]interface{}{"name": "data-snapshot"},
+					}
+				})
+
+				It("returns an error", func() {
+					Expect(err).To(MatchError(ContainSubstring(
+						"VolumeSnapshot name \"data-snapshot\" of volume data is already used by volume archive. Set a unique snapshot `name`")))
+				})
+			})
+		})
+
+		It("creates a SnapshotSchedule of the claim", func() {
+			schedules := findObjects("SnapshotSchedule")
+			Expect(schedules).To(HaveLen(1))
+
+			s := schedules[0].(*unstructured.Unstructured)
+			Expect(s.GetAPIVersion()).To(Equal(SnapshotScheduleAPIVersion))
+			Expect(s.GetName()).To(Equal("data-schedule"))
+			Expect(s.Object["spec"]).To(Equal(map[string]interface{}{
+				"schedule":      "0 2 * * *",
+				"claimSelector": map[string]interface{}{"matchLabels": unstructuredMap(configLabels("data"))},
+				"retention":     map[string]interface{}{"maxCount": int64(7)},
+			}))
+		})
+	})
+})
@@ -53,12 +53,28 @@ import (
 
 const DefaultIngressBackendKeyword = "default"
 
+var (
+	// serviceHostnamePlaceholder matches explicit service hostname placeholder, e.g. `{{service:db}}`
+	serviceHostnamePlaceholder = regexp.MustCompile(`\{\{\s*service:([^}]+)\}\}`)
+
+	// serviceHostnameReference matches a bare host, `host:port` or URL, capturing the host with its prefix and suffix,
+	// as well as the URL scheme and port
+	serviceHostnameReference = regexp.MustCompile(`^(\s*(?:([a-zA-Z][a-zA-Z0-9+.\-]*)://)?(?:[^@/\s]*@)?)([a-zA-Z0-9_][a-zA-Z0-9_.\-]*)((:[0-9]+)?(?:[/?#].*)?\s*)$`)
+)
+
 // Kubernetes transformer
 type Kubernetes struct {
 	Opt      ConvertOptions     // user provided options from the command line
 	Project  *composego.Project // docker compose project
 	Excluded []string           // docker compose service names that should be excluded
 	UI       kmd.UI
+
+	SecretMatchers  []map[string]string // secret matchers used to classify env vars and env_file contents
+	SecretAllowlist map[string][]string // env var names of compose services which aren't secrets
+	Knative         bool                // render HTTP services as Knative Services
+
+	secretChecksums map[string]string   // content checksums of rendered Secrets keyed by name
+	snapshotNames   map[string][]string // names of volume snapshots and schedules keyed by claim name
 }
 
 // Transform converts compose project to set of k8s objects
@@ -68,10 +84,35 @@ func (k *Kubernetes) Transform() ([]runtime.Object, error) {
 	// holds all the converted objects
 	var allobjects []runtime.Object
 	var renderedNetworkPolicy runtime.Object
+	var knativeServices []ProjectService
 
 	sg := k.UI.StepGroup()
 	defer sg.Done()
 
+	// @step validate project wide configuration
+	if _, err := k.projectK8sConfig(); err != nil {
+		sg.Add("Validating project configuration").Error()
+		return nil, err
+	}
+
+	// @step validate K8s object names don't collide once normalised
+	if err := k.validateObjectNames(); err != nil {
+		sg.Add("Validating project object names").Error()
+		return nil, err
+	}
+
+	// @step make sure generated services don't collide
+	if err := k.validateServiceNames(); err != nil {
+		sg.Add("Validating project service names").Error()
+		return nil, err
+	}
+
+	// @step report ReadWriteOnce volume claims shared by multiple pods
+	if err := k.analyseSharedVolumes(); err != nil {
+		sg.Add("Validating project shared volumes").Error()
+		return nil, err
+	}
+
 	// @step iterate over defined secrets and build Secret objects accordingly
 	if k.Project.Secrets != nil && len(k.Project.Secrets) > 0 {
 		stepSecrets := sg.Add("Converting project secrets")
@@ -82,12 +123,50 @@ func (k *Kubernetes) Transform() ([]runtime.Object, error) {
 			stepSecrets.Error()
 			return nil, errors.Wrapf(err, "%s", msg)
 		}
-		for _, item := range secrets {
-			allobjects = append(allobjects, item)
+		objects, err := k.secretObjects(secrets)
+		if err != nil {
+			log.Error("Unable to output Secret resources")
+			stepSecrets.Error()
+			return nil, err
 		}
+		allobjects = append(allobjects, objects...)
+
+		externalSecrets, err := k.createExternalSecrets()
+		if err != nil {
+			log.Error("Unable to create ExternalSecret resources")
+			stepSecrets.Error()
+			return nil, err
+		}
+		allobjects = append(allobjects, externalSecrets...)
 		stepSecrets.Success("Converted project secrets")
 	}
 
+	// @step build StorageClasses declared for the environment
+	storageClasses, err := k.createStorageClasses()
+	if err != nil {
+		msg := "Unable to create StorageClass resources"
+		log.Error(msg)
+		sg.Add("Converting project storage classes").Error()
+		return nil, errors.Wrapf(err, "%s", msg)
+	}
+	if len(storageClasses) > 0 {
+		sg.Add("Converting project storage classes").Success("Converted project storage classes")
+		allobjects = append(allobjects, storageClasses...)
+	}
+
+	// @step build ConfigMaps and Secrets from env files shared via envFrom
+	envFileObjects, err := k.envFileObjects()
+	if err != nil {
+		msg := "Unable to create env file ConfigMap or Secret resources"
+		log.Error(msg)
+		sg.Add("Converting env files").Error()
+		return nil, errors.Wrapf(err, "%s", msg)
+	}
+	if len(envFileObjects) > 0 {
+		sg.Add("Converting env files").Success("Converted env files")
+		allobjects = append(allobjects, envFileObjects...)
+	}
+
 	// @step sort project services by name for consistency
 	sortServices(k.Project)
 
@@ -111,14 +190,13 @@ func (k *Kubernetes) Transform() ([]runtime.Object, error) {
 			continue
 		}
 
-		// @step normalise project service name
-		if rfc1123dns(projectService.Name) != projectService.Name {
+		// @step normalise project service name, or use the explicitly set one
+		if name := k8sServiceName(pSvc); name != projectService.Name {
 			log.DebugfWithFields(log.Fields{
 				"project-service": projectService.Name,
-			}, "Compose service name normalised to %q",
-				rfc1123dns(projectService.Name))
+			}, "Compose service name normalised to %q", name)
 
-			projectService.Name = rfc1123dns(projectService.Name)
+			projectService.Name = name
 		}
 
 		// @step we're not concerned about building & publishing images yet,
@@ -132,6 +210,17 @@ func (k *Kubernetes) Transform() ([]runtime.Object, error) {
 			return nil, fmt.Errorf("image key required within build parameters in order to build and push service '%s'", projectService.Name)
 		}
 
+		// @step project service provided outside of the cluster only resolves to its external name
+		if projectService.externalName() != "" {
+			objects = k.createExternalNameServices(projectService)
+
+			stepSvc.Success(fmt.Sprintf("Converted service: %s", pSvc.Name))
+			k.outputRenderedObjects(objects)
+
+			allobjects = append(allobjects, objects...)
+			continue
+		}
+
 		// @step create kubernetes object (never create a pod in isolation!)
 		// https://kubernetes.io/docs/concepts/workloads/pods/pod-lifecycle/#pod-lifetime
 		objects = k.createKubernetesObjects(projectService)
@@ -155,6 +244,12 @@ func (k *Kubernetes) Transform() ([]runtime.Object, error) {
 			}
 			objects = append(objects, svc)
 
+			// Expose ports are cluster-internal only and get a separate ClusterIP service
+			// when the project service is reachable from outside of the cluster
+			if len(projectService.internalPorts(serviceType)) > 0 {
+				objects = append(objects, k.createInternalService(serviceType, projectService))
+			}
+
 			// For exposed service also create an ingress (Note: only the first port is used for ingress!)
 			expose, err := projectService.exposeService()
 			if err != nil {
@@ -165,12 +260,25 @@ func (k *Kubernetes) Transform() ([]runtime.Object, error) {
 			if expose != "" {
 				objects = append(objects, k.initIngress(projectService, svc.Spec.Ports[0].Port))
 			}
+
+			// @step create service mesh traffic policy objects
+			objects = append(objects, k.createMeshObjects(projectService, svc)...)
 		} else if config.ServiceTypesEqual(serviceType, config.HeadlessService) {
 			// No ports defined - creating headless service instead
 			svc := k.createHeadlessService(projectService)
 			objects = append(objects, svc)
 		}
 
+		// @step create alias services, so that compose network & link aliases keep resolving
+		if !config.ServiceTypesEqual(serviceType, config.NoService) {
+			for _, alias := range k.serviceAliases(projectService) {
+				objects = append(objects, k.createAliasService(projectService, alias))
+			}
+		}
+
+		// @step create external name services for compose external links
+		objects = append(objects, k.createExternalLinkServices(projectService)...)
+
 		// @step updating all objects related to a current compose service
 		if err = k.updateKubernetesObjects(projectService, &objects); err != nil {
 			msg := "Error occurred while transforming Kubernetes objects"
@@ -178,16 +286,19 @@ func (k *Kubernetes) Transform() ([]runtime.Object, error) {
 			return nil, errors.Wrapf(err, "%s", msg)
 		}
 
-		stepSvc.Success(fmt.Sprintf("Converted service: %s", pSvc.Name))
-		for _, object := range objects {
-			k.UI.Output(
-				fmt.Sprintf("rendered %s", object.GetObjectKind().GroupVersionKind().Kind),
-				kmd.WithStyle(kmd.LogStyle),
-				kmd.WithIndent(3),
-				kmd.WithIndentChar(kmd.LogIndentChar),
-			)
+		// @step HTTP services are rendered as Knative Services once all workloads are configured
+		knative, err := k.knativeService(projectService, objects)
+		if err != nil {
+			stepSvc.Error()
+			return nil, err
+		}
+		if knative {
+			knativeServices = append(knativeServices, projectService)
 		}
 
+		stepSvc.Success(fmt.Sprintf("Converted service: %s", pSvc.Name))
+		k.outputRenderedObjects(objects)
+
 		// @step create network policies if networks defined
 		if len(projectService.Networks) > 0 {
 			for name := range projectService.Networks {
@@ -225,9 +336,56 @@ func (k *Kubernetes) Transform() ([]runtime.Object, error) {
 	k.sortServicesFirst(&allobjects)
 	k.removeDupObjects(&allobjects)
 
+	// @step roll out workloads on ConfigMap and Secret content changes
+	if err := k.configRollout(allobjects); err != nil {
+		sg.Add("Configuring workload rollouts").Error()
+		return nil, err
+	}
+
+	// @step replace workloads of HTTP services with Knative Services
+	if len(knativeServices) > 0 {
+		if allobjects, err = k.createKnativeObjects(allobjects, knativeServices); err != nil {
+			sg.Add("Knative").Error()
+			return nil, err
+		}
+
+		sg.Add("Knative").Success()
+		for _, projectService := range knativeServices {
+			k.UI.Output(
+				fmt.Sprintf("rendered Knative Service: %s", projectService.Name),
+				kmd.WithStyle(kmd.LogStyle),
+				kmd.WithIndent(3),
+				kmd.WithIndentChar(kmd.LogIndentChar),
+			)
+		}
+	}
+
 	return allobjects, nil
 }
 
+// projectK8sConfig returns project wide k8s configuration from the project top level extension
+func (k *Kubernetes) projectK8sConfig() (config.ProjK8sConfig, error) {
+	return config.ProjK8sConfigFromCompose(k.Project)
+}
+
+// meshType returns the service mesh enabled for the project
+func (k *Kubernetes) meshType() config.MeshType {
+	cfg, _ := k.projectK8sConfig()
+	return cfg.Mesh
+}
+
+// outputRenderedObjects outputs kinds of rendered objects
+func (k *Kubernetes) outputRenderedObjects(objects []runtime.Object) {
+	for _, object := range objects {
+		k.UI.Output(
+			fmt.Sprintf("rendered %s", object.GetObjectKind().GroupVersionKind().Kind),
+			kmd.WithStyle(kmd.LogStyle),
+			kmd.WithIndent(3),
+			kmd.WithIndentChar(kmd.LogIndentChar),
+		)
+	}
+}
+
 // initPodSpec creates the pod specification
 // @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/kubernetes/kubernetes.go#L129
 func (k *Kubernetes) initPodSpec(projectService ProjectService) v1.PodSpec {
@@ -278,7 +436,9 @@ func (k *Kubernetes) initPodSpec(projectService ProjectService) v1.PodSpec {
 	return pod
 }
 
-// getConfigMapKeyFromMeta gets configmap from project configs
+// getConfigMapKeyFromMeta gets configmap key from project configs.
+// Configs defined inline or sourced from an env var, and external configs, are keyed by config name.
+// Configs sourced from a file are keyed by file name.
 func (k *Kubernetes) getConfigMapKeyFromMeta(configName string) (string, error) {
 	if k.Project.Configs == nil {
 		return "", fmt.Errorf("config %s not found", configName)
@@ -291,7 +451,12 @@ func (k *Kubernetes) getConfigMapKeyFromMeta(configName string) (string, error)
 	cfg := k.Project.Configs[configName]
 
 	if cfg.External.External {
-		return "", fmt.Errorf("config %s is external", configName)
+		return configName, nil
+	}
+
+	if ext, err := config.FileObjK8sConfigFromCompose((*composego.FileObjectConfig)(&cfg)); err == nil &&
+		(ext.Content != "" || ext.Environment != "") {
+		return configName, nil
 	}
 
 	return filepath.Base(cfg.File), nil
@@ -304,7 +469,7 @@ func (k *Kubernetes) initPodSpecWithConfigMap(projectService ProjectService) v1.
 	var volumes []v1.Volume
 
 	for _, value := range projectService.Configs {
-		cmVolName := formatFileName(value.Source)
+		cmVolName := k.configName(value.Source)
 		target := value.Target
 		if target == "" {
 			// short syntax, = /<source>
@@ -317,7 +482,6 @@ func (k *Kubernetes) initPodSpecWithConfigMap(projectService ProjectService) v1.
 
 		key, err := k.getConfigMapKeyFromMeta(value.Source)
 		if err != nil {
-			// config is most likely defined as external
 			log.WarnfWithFields(log.Fields{
 				"project-service": projectService.Name,
 				"config":          value.Source,
@@ -326,6 +490,8 @@ func (k *Kubernetes) initPodSpecWithConfigMap(projectService ProjectService) v1.
 			continue
 		}
 
+		k.warnFileObjectOwnership(projectService, "config", composego.FileReferenceConfig(value))
+
 		volSource.Items = []v1.KeyToPath{{
 			Key:  key,
 			Path: subPath,
@@ -334,6 +500,7 @@ func (k *Kubernetes) initPodSpecWithConfigMap(projectService ProjectService) v1.
 		if value.Mode != nil {
 			tmpMode := int32(*value.Mode)
 			volSource.DefaultMode = &tmpMode
+			volSource.Items[0].Mode = &tmpMode
 		}
 
 		cmVol := v1.Volume{
@@ -475,7 +642,7 @@ func (k *Kubernetes) initConfigMapFromFile(projectService ProjectService, fileNa
 	configMapName := ""
 	for key, tmpConfig := range k.Project.Configs {
 		if tmpConfig.File == fileName {
-			configMapName = key
+			configMapName = k.configName(key)
 		}
 	}
 
@@ -819,14 +986,25 @@ func (k *Kubernetes) initServiceAccount(projectService ProjectService) *v1.Servi
 func (k *Kubernetes) createSecrets() ([]*v1.Secret, error) {
 	var objects []*v1.Secret
 	for name, secretConfig := range k.Project.Secrets {
-		if secretConfig.File != "" {
-			dataString, err := getContentFromFile(secretConfig.File)
-			if err != nil {
-				log.ErrorWithFields(log.Fields{
-					"file": secretConfig.File,
-				}, "Unable to read secret(s) from file")
+		secretName := k.secretName(name)
 
-				return nil, err
+		// @step secret content defined inline or sourced from an env var takes precedence over the file
+		content, inline, err := fileObjectContent("secret", name, composego.FileObjectConfig(secretConfig))
+		if err != nil {
+			return nil, err
+		}
+
+		if inline || secretConfig.File != "" {
+			dataString := content
+			if !inline {
+				dataString, err = getContentFromFile(secretConfig.File)
+				if err != nil {
+					log.ErrorWithFields(log.Fields{
+						"file": secretConfig.File,
+					}, "Unable to read secret(s) from file")
+
+					return nil, err
+				}
 			}
 			data := []byte(dataString)
 			secret := &v1.Secret{
@@ -835,16 +1013,20 @@ func (k *Kubernetes) createSecrets() ([]*v1.Secret, error) {
 					APIVersion: "v1",
 				},
 				ObjectMeta: meta.ObjectMeta{
-					Name:   name,
-					Labels: configLabels(name),
+					Name:   secretName,
+					Labels: configLabels(secretName),
 				},
 				Type: v1.SecretTypeOpaque,
 				Data: map[string][]byte{name: data},
 			}
 			objects = append(objects, secret)
+		} else if k.externalSecretManaged(name) {
+			log.DebugfWithFields(log.Fields{
+				"secret-name": secretName,
+			}, "Secret will be synced by External Secrets Operator")
 		} else {
 			log.WarnWithFields(log.Fields{
-				"secret-name": name,
+				"secret-name": secretName,
 			}, "Your deployment(s) expects secret to exist in the target K8s cluster namespace.")
 			log.Warn("Follow the official guidelines on how to create K8s secrets manually")
 			log.Warn("https://kubernetes.io/docs/tasks/inject-data-application/distribute-credentials-secure/")
@@ -870,8 +1052,9 @@ func (k *Kubernetes) createPVC(volume Volumes) (*v1.PersistentVolumeClaim, error
 			APIVersion: "v1",
 		},
 		ObjectMeta: meta.ObjectMeta{
-			Name:   volume.VolumeName,
-			Labels: configLabels(volume.VolumeName),
+			Name:        volume.VolumeName,
+			Labels:      configLabels(volume.VolumeName),
+			Annotations: volume.Annotations,
 		},
 		Spec: v1.PersistentVolumeClaimSpec{
 			Resources: v1.VolumeResourceRequirements{
@@ -882,6 +1065,13 @@ func (k *Kubernetes) createPVC(volume Volumes) (*v1.PersistentVolumeClaim, error
 		},
 	}
 
+	// @step add configured labels, keeping the default ones intact
+	for key, val := range volume.Labels {
+		if _, ok := pvc.ObjectMeta.Labels[key]; !ok {
+			pvc.ObjectMeta.Labels[key] = val
+		}
+	}
+
 	if len(volume.SelectorValue) > 0 {
 		pvc.Spec.Selector = &meta.LabelSelector{
 			MatchLabels: configLabels(volume.SelectorValue),
@@ -892,12 +1082,47 @@ func (k *Kubernetes) createPVC(volume Volumes) (*v1.PersistentVolumeClaim, error
 		pvc.Spec.StorageClassName = &volume.StorageClass
 	}
 
-	if volume.Mode == "ro" {
+	// @step explicitly configured access modes take precedence over the mount mode
+	if len(volume.AccessModes) > 0 {
+		for _, mode := range volume.AccessModes {
+			pvc.Spec.AccessModes = append(pvc.Spec.AccessModes, v1.PersistentVolumeAccessMode(mode))
+		}
+	} else if volume.Mode == "ro" {
 		pvc.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadOnlyMany}
+	} else if volume.NFS.Enabled() {
+		pvc.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteMany}
 	} else {
 		pvc.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
 	}
 
+	if len(volume.VolumeMode) > 0 {
+		volumeMode := v1.PersistentVolumeMode(volume.VolumeMode)
+		pvc.Spec.VolumeMode = &volumeMode
+	}
+
+	// @step populate the volume from a snapshot or clone another PVC
+	if len(volume.DataSource.Kind) > 0 {
+		dataSource := &v1.TypedLocalObjectReference{
+			Kind: volume.DataSource.Kind,
+			Name: volume.DataSource.Name,
+		}
+		if volume.DataSource.Kind == "VolumeSnapshot" {
+			apiGroup := "snapshot.storage.k8s.io"
+			dataSource.APIGroup = &apiGroup
+		}
+		pvc.Spec.DataSource = dataSource
+	}
+
+	// @step bind the claim to the static NFS PersistentVolume of the same name
+	if volume.NFS.Enabled() {
+		pvc.Spec.VolumeName = volume.VolumeName
+		pvc.Spec.Selector = nil
+		if pvc.Spec.StorageClassName == nil {
+			noStorageClass := ""
+			pvc.Spec.StorageClassName = &noStorageClass
+		}
+	}
+
 	return pvc, nil
 }
 
@@ -931,11 +1156,16 @@ func (k *Kubernetes) configPorts(projectService ProjectService) []v1.ContainerPo
 // configServicePorts configure the container service ports.
 // @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/kubernetes/kubernetes.go#L602
 func (k *Kubernetes) configServicePorts(serviceType config.ServiceType, projectService ProjectService) []v1.ServicePort {
+	return k.toServicePorts(serviceType, projectService, projectService.servicePorts(serviceType))
+}
+
+// toServicePorts converts project service ports to service ports for a given service type.
+func (k *Kubernetes) toServicePorts(serviceType config.ServiceType, projectService ProjectService, ports []composego.ServicePortConfig) []v1.ServicePort {
 	servicePorts := []v1.ServicePort{}
-	seenPorts := make(map[int]struct{}, len(projectService.ports()))
+	seenPorts := make(map[int]struct{}, len(ports))
 
 	var servicePort v1.ServicePort
-	for _, port := range projectService.ports() {
+	for _, port := range ports {
 		if port.Published == 0 {
 			port.Published = port.Target
 		}
@@ -970,6 +1200,18 @@ func (k *Kubernetes) configServicePorts(serviceType config.ServiceType, projectS
 			servicePort.NodePort = np
 		}
 
+		// @step apply per port configuration if specified
+		if sp, ok := projectService.servicePortConfig(port.Published, port.Protocol); ok {
+			if sp.AppProtocol != "" {
+				appProtocol := sp.AppProtocol
+				servicePort.AppProtocol = &appProtocol
+			}
+
+			if sp.NodePort != 0 && isExternalServiceType(serviceType) {
+				servicePort.NodePort = int32(sp.NodePort)
+			}
+		}
+
 		servicePorts = append(servicePorts, servicePort)
 		seenPorts[int(port.Published)] = struct{}{}
 	}
@@ -999,23 +1241,42 @@ func (k *Kubernetes) configCapabilities(projectService ProjectService) *v1.Capab
 
 // configTmpfs configure the tmpfs.
 // @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/kubernetes/kubernetes.go#L664
-func (k *Kubernetes) configTmpfs(projectService ProjectService) ([]v1.VolumeMount, []v1.Volume) {
+func (k *Kubernetes) configTmpfs(projectService ProjectService) ([]v1.VolumeMount, []v1.Volume, error) {
 	volumeMounts := []v1.VolumeMount{}
 	volumes := []v1.Volume{}
 
-	for index, volume := range projectService.Tmpfs {
+	mounts, err := projectService.tmpfs()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	total := resource.NewQuantity(0, resource.BinarySI)
+	for index, mount := range mounts {
 		// @step naming volumes if multiple tmpfs are provided
 		volumeName := fmt.Sprintf("%s-tmpfs%d", projectService.Name, index)
-		volume = strings.Split(volume, ":")[0]
+
 		// @step create a new volume mount object and append to list
 		volMount := v1.VolumeMount{
 			Name:      volumeName,
-			MountPath: volume,
+			MountPath: mount.Target,
 		}
 		volumeMounts = append(volumeMounts, volMount)
 
-		// @step create tmpfs specific empty volumes
+		// @step create tmpfs specific empty volumes, limited to the tmpfs size if set
 		volSource := k.configEmptyVolumeSource("tmpfs")
+		if mount.Size != nil {
+			volSource.EmptyDir.SizeLimit = mount.Size
+			total.Add(*mount.Size)
+		}
+
+		// @step emptyDir volumes are writable by all users, so more restrictive modes can't be enforced
+		if mount.Mode != nil && *mount.Mode&0o007 != 0o007 {
+			log.WarnfWithFields(log.Fields{
+				"project-service": projectService.Name,
+				"tmpfs":           mount.Target,
+			}, "Tmpfs mode %04o can't be enforced, as emptyDir volumes are writable by all users. "+
+				"Use tmpfs `gid` option or workload podSecurity fsGroup to control the volume group ownership", *mount.Mode)
+		}
 
 		// @step create a new volume object using the volsource and add to list
 		vol := v1.Volume{
@@ -1026,7 +1287,22 @@ func (k *Kubernetes) configTmpfs(projectService ProjectService) ([]v1.VolumeMoun
 		volumes = append(volumes, vol)
 	}
 
-	return volumeMounts, volumes
+	// @step memory backed volumes count towards the container memory
+	memLimit, _, _ := projectService.resourceLimits()
+	memRequest, _, _ := projectService.resourceRequests()
+	for _, mem := range []struct {
+		kind  string
+		value int64
+	}{{"limit", *memLimit}, {"request", *memRequest}} {
+		if mem.value > 0 && total.Value() > mem.value {
+			log.WarnfWithFields(log.Fields{
+				"project-service": projectService.Name,
+			}, "Tmpfs mounts size %s exceeds container memory %s %s. Tmpfs usage counts towards the container memory",
+				total.String(), mem.kind, resource.NewQuantity(mem.value, resource.BinarySI).String())
+		}
+	}
+
+	return volumeMounts, volumes, nil
 }
 
 // configSecretVolumes config volumes from secret.
@@ -1042,16 +1318,7 @@ func (k *Kubernetes) configSecretVolumes(projectService ProjectService) ([]v1.Vo
 
 	if len(projectService.Secrets) > 0 {
 		for _, secretConfig := range projectService.Secrets {
-			if secretConfig.UID != "" {
-				log.WarnWithFields(log.Fields{
-					"project-service": projectService.Name,
-				}, "Ignoring `uid` field on compose project service secret")
-			}
-			if secretConfig.GID != "" {
-				log.WarnWithFields(log.Fields{
-					"project-service": projectService.Name,
-				}, "Ignoring `gid` field on compose project service secret")
-			}
+			k.warnFileObjectOwnership(projectService, "secret", composego.FileReferenceConfig(secretConfig))
 
 			var itemPath string // should be the filename
 			var mountPath = ""  // should be the directory
@@ -1087,7 +1354,7 @@ func (k *Kubernetes) configSecretVolumes(projectService ProjectService) ([]v1.Vo
 
 			volSource := v1.VolumeSource{
 				Secret: &v1.SecretVolumeSource{
-					SecretName: secretConfig.Source,
+					SecretName: k.secretName(secretConfig.Source),
 					Items: []v1.KeyToPath{{
 						Key:  secretConfig.Source,
 						Path: itemPath,
@@ -1098,10 +1365,11 @@ func (k *Kubernetes) configSecretVolumes(projectService ProjectService) ([]v1.Vo
 			if secretConfig.Mode != nil {
 				mode := cast.ToInt32(*secretConfig.Mode)
 				volSource.Secret.DefaultMode = &mode
+				volSource.Secret.Items[0].Mode = &mode
 			}
 
 			vol := v1.Volume{
-				Name:         secretConfig.Source,
+				Name:         k.secretName(secretConfig.Source),
 				VolumeSource: volSource,
 			}
 			volumes = append(volumes, vol)
@@ -1117,6 +1385,41 @@ func (k *Kubernetes) configSecretVolumes(projectService ProjectService) ([]v1.Vo
 	return volumeMounts, volumes
 }
 
+// warnFileObjectOwnership warns about secret or config mount `uid` and `gid` settings which can't be honoured.
+// Files mounted from K8s Secrets and ConfigMaps are owned by root, and their group is only controlled by the pod fsGroup.
+func (k *Kubernetes) warnFileObjectOwnership(projectService ProjectService, kind string, ref composego.FileReferenceConfig) {
+	if ref.UID != "" {
+		log.WarnfWithFields(log.Fields{
+			"project-service": projectService.Name,
+			kind:              ref.Source,
+			"uid":             ref.UID,
+		}, "Ignoring `uid` field on compose project service %s as K8s mounts it as owned by root. Use `gid` and `mode` to control access", kind)
+	}
+
+	if ref.GID == "" {
+		return
+	}
+
+	gid, err := strconv.ParseInt(ref.GID, 10, 64)
+	if err != nil {
+		log.WarnfWithFields(log.Fields{
+			"project-service": projectService.Name,
+			kind:              ref.Source,
+			"gid":             ref.GID,
+		}, "Ignoring `gid` field on compose project service %s as it's not numeric", kind)
+		return
+	}
+
+	if fsGroup := projectService.fsGroup(); fsGroup != nil && *fsGroup != gid {
+		log.WarnfWithFields(log.Fields{
+			"project-service": projectService.Name,
+			kind:              ref.Source,
+			"gid":             ref.GID,
+			"fs-group":        *fsGroup,
+		}, "Ignoring `gid` field on compose project service %s as pod fsGroup is set to %d", kind, *fsGroup)
+	}
+}
+
 // configVolumes configure the container volumes.
 // @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/kubernetes/kubernetes.go#L774
 func (k *Kubernetes) configVolumes(projectService ProjectService) ([]v1.VolumeMount, []v1.Volume, []*v1.PersistentVolumeClaim, []*v1.ConfigMap, error) {
@@ -1151,6 +1454,27 @@ func (k *Kubernetes) configVolumes(projectService ProjectService) ([]v1.VolumeMo
 		// check if ro/rw mode is defined, default rw
 		readonly := len(volume.Mode) > 0 && volume.Mode == "ro"
 
+		// @step bind mounts are converted according to the configured strategy
+		useEmptyVolumes, useHostPath, useConfigMap := useEmptyVolumes, useHostPath, useConfigMap
+		strategy := config.NoBindMountStrategy
+		if isBindMount(volume) {
+			strategy = k.bindMountStrategy(projectService, volume)
+			switch strategy {
+			case config.NoneBindMount:
+				log.DebugfWithFields(log.Fields{
+					"project-service": projectService.Name,
+					"host":            volume.Host,
+					"container":       volume.Container,
+				}, "Dropping bind mount %s", volume.Container)
+
+				continue
+			case config.PVCBindMount, config.HostPathBindMount, config.EmptyDirBindMount, config.ConfigMapBindMount:
+				useEmptyVolumes = strategy == config.EmptyDirBindMount
+				useHostPath = strategy == config.HostPathBindMount
+				useConfigMap = strategy == config.ConfigMapBindMount
+			}
+		}
+
 		if volume.VolumeName == "" {
 			if useEmptyVolumes {
 				volumeName = strings.Replace(volume.PVCName, "claim", "empty", 1)
@@ -1160,6 +1484,8 @@ func (k *Kubernetes) configVolumes(projectService ProjectService) ([]v1.VolumeMo
 				volumeName = strings.Replace(volume.PVCName, "claim", "cm", 1)
 			} else {
 				volumeName = volume.PVCName
+				// @step claims of bind mounts are named after the mount
+				volume.VolumeName = volume.PVCName
 			}
 			count++
 		} else {
@@ -1211,6 +1537,23 @@ func (k *Kubernetes) configVolumes(projectService ProjectService) ([]v1.VolumeMo
 				volMount.SubPath = volsource.ConfigMap.Items[0].Path
 			}
 
+		} else if !volume.External && volume.NFS.Enabled() && !volume.NFS.PersistentVolume {
+			log.DebugWithFields(log.Fields{
+				"project-service": projectService.Name,
+			}, "Use NFS volume")
+
+			volsource = k.configNFSVolumeSource(volume, readonly)
+		} else if !volume.External && volume.CSI.Enabled() {
+			log.DebugWithFields(log.Fields{
+				"project-service": projectService.Name,
+			}, "Use CSI volume")
+
+			source, err := k.configCSIVolumeSource(volume, readonly)
+			if err != nil {
+				log.Error("Couldn't create CSI volume source")
+				return nil, nil, nil, nil, err
+			}
+			volsource = source
 		} else {
 			log.DebugWithFields(log.Fields{
 				"project-service": projectService.Name,
@@ -1218,7 +1561,8 @@ func (k *Kubernetes) configVolumes(projectService ProjectService) ([]v1.VolumeMo
 
 			volsource = k.configPVCVolumeSource(volumeName, readonly)
 
-			if volume.VFrom == "" {
+			// @step external volumes reference an existing PVC, so no claim is generated
+			if volume.VFrom == "" && !volume.External {
 				createdPVC, err := k.createPVC(volume)
 
 				if err != nil {
@@ -1239,11 +1583,11 @@ func (k *Kubernetes) configVolumes(projectService ProjectService) ([]v1.VolumeMo
 		}
 		volumes = append(volumes, vol)
 
-		if len(volume.Host) > 0 && (!useHostPath && !useConfigMap) {
+		if len(volume.Host) > 0 && (!useHostPath && !useConfigMap) && strategy == config.NoBindMountStrategy {
 			log.WarnWithFields(log.Fields{
 				"project-service": projectService.Name,
 				"host":            volume.Host,
-			}, "Volume mount on the host isn't supported. Ignoring path on the host")
+			}, "Volume mount on the host isn't supported. Ignoring path on the host. Set `bindMounts` strategy to control how it's converted")
 		}
 	}
 
@@ -1300,13 +1644,12 @@ func (k *Kubernetes) configConfigMapVolumeSource(cmName string, targetPath strin
 // configHostPathVolumeSource is a helper function to create a HostPath v1.VolumeSource
 // @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/kubernetes/kubernetes.go#L935
 func (k *Kubernetes) configHostPathVolumeSource(path string) (*v1.VolumeSource, error) {
-	dir, err := getComposeFileDir(k.Opt.InputFiles)
-	if err != nil {
-		return nil, err
-	}
-
 	absPath := path
 	if !filepath.IsAbs(path) {
+		dir, err := getComposeFileDir(k.Opt.InputFiles)
+		if err != nil {
+			return nil, err
+		}
 		absPath = filepath.Join(dir, path)
 	}
 
@@ -1334,7 +1677,15 @@ func (k *Kubernetes) configEnvs(projectService ProjectService) ([]v1.EnvVar, err
 	envs := EnvSort{}
 	envsWithDeps := []v1.EnvVar{}
 
-	refK8s := regexp.MustCompile(`^(config|pod|secret|container)\.[^\.]*\.[^\.]*`)
+	refK8s := envVarK8sReference
+
+	serviceHostnames := k.configServiceHostnames
+
+	// @step env vars loaded from env files via envFrom are skipped unless overridden
+	envFromVars, err := k.envFromVars(projectService)
+	if err != nil {
+		return nil, err
+	}
 
 	// @step load up the environment variables
 	for k, v := range projectService.environment() {
@@ -1344,10 +1695,20 @@ func (k *Kubernetes) configEnvs(projectService ProjectService) ([]v1.EnvVar, err
 			v = &temp
 		}
 
+		if value, ok := envFromVars[k]; ok && value == *v {
+			continue
+		}
+
+		// @step whole K8s secret or config map references are imported via envFrom
+		if envVarK8sObjectReference.MatchString(*v) {
+			continue
+		}
+
 		// @step generate EnvVar spec and handle special value reference cases for kubernetes `secret`, `configmap`, `pod` field or `container` resource
 		// e.g. `secret.my-secret-name.my-key`,
 		//      `config.my-config-name.config-key`,
 		//      `pod.metadata.namespace`,
+		//      `pod.metadata.labels['app']`,
 		//      `container.my-container-name.limits.cpu`,
 		// if none of the special cases has been referenced by the env var value then it's going to be treated as literal value
 
@@ -1395,28 +1756,11 @@ func (k *Kubernetes) configEnvs(projectService ProjectService) ([]v1.EnvVar, err
 				},
 			})
 		case "pod":
-			// Selects a field of the pod; Supported paths:
-			paths := []string{
-				"metadata.name", "metadata.namespace", "metadata.labels", "metadata.annotations",
-				"spec.nodeName", "spec.serviceAccountName", "status.hostIP", "status.podIP", "status.podIPs",
-			}
+			// Selects a field of the pod, see podFieldPaths for supported paths
+			thePath := strings.TrimPrefix(*v, "pod.")
 
-			if len(parts) != 3 {
-				return nil, fmt.Errorf("environment variable %s referencing kubernetes pod field is invalid: %s", k, *v)
-			}
-
-			thePath := strings.Join(parts[1:], ".")
-
-			if contains(paths, thePath) {
-				envs = append(envs, v1.EnvVar{
-					Name: k,
-					ValueFrom: &v1.EnvVarSource{
-						FieldRef: &v1.ObjectFieldSelector{
-							FieldPath: thePath,
-						},
-					},
-				})
-			} else {
+			field, ok := lookupPodFieldPath(thePath)
+			if !ok {
 				log.DebugfWithFields(log.Fields{
 					"project-service": projectService.Name,
 					"env-var":         k,
@@ -1425,6 +1769,17 @@ func (k *Kubernetes) configEnvs(projectService ProjectService) ([]v1.EnvVar, err
 
 				return nil, fmt.Errorf("environment variable %s references unsupported kubernetes pod field: %s", k, *v)
 			}
+
+			warnPodFieldPathVersion(projectService, k, field)
+
+			envs = append(envs, v1.EnvVar{
+				Name: k,
+				ValueFrom: &v1.EnvVarSource{
+					FieldRef: &v1.ObjectFieldSelector{
+						FieldPath: thePath,
+					},
+				},
+			})
 		case "container":
 			// Selects a resource of the container. Only resources limits and requests are currently supported:
 			resources := []string{
@@ -1459,6 +1814,13 @@ func (k *Kubernetes) configEnvs(projectService ProjectService) ([]v1.EnvVar, err
 				return nil, fmt.Errorf("environment variable %s references unsupported kubernetes container resource: %s", k, *v)
 			}
 		default:
+			// @step resolve service hostname placeholders and rewrite service hostnames if enabled
+			value, err := serviceHostnames(*v)
+			if err != nil {
+				return nil, fmt.Errorf("environment variable %s is invalid: %s", k, err)
+			}
+			*v = value
+
 			if strings.Contains(*v, "{{") && strings.Contains(*v, "}}") {
 				*v = strings.ReplaceAll(*v, "{{", "$(")
 				*v = strings.ReplaceAll(*v, "}}", ")")
@@ -1489,6 +1851,72 @@ func (k *Kubernetes) configEnvs(projectService ProjectService) ([]v1.EnvVar, err
 	return envs, nil
 }
 
+// configServiceHostnames resolves `{{service:name}}` placeholders in env var value to the referenced project
+// service hostname. When hostnames rewrite is enabled for the project it also rewrites values referencing
+// project services by name as `host:port` or URL, e.g. `db:5432`, `http://api:8080/v1`. Bare hosts, e.g. `db`,
+// are only rewritten when bare names rewrite is enabled, as they can't be told apart from any other word.
+// Hostnames resolve to `<service>.<namespace>.svc.<cluster-domain>` when namespace is configured,
+// otherwise to normalised service name.
+func (k *Kubernetes) configServiceHostnames(value string) (string, error) {
+	cfg, _ := k.projectK8sConfig()
+	domain := cfg.ServiceDomain()
+
+	hostname := func(name string) string {
+		name = k8sServiceNameByRef(k.Project.Services, name)
+		if domain == "" {
+			return name
+		}
+		return fmt.Sprintf("%s.%s", name, domain)
+	}
+
+	// @step resolve explicit placeholders
+	var unknown []string
+	value = serviceHostnamePlaceholder.ReplaceAllStringFunc(value, func(m string) string {
+		name := strings.TrimSpace(serviceHostnamePlaceholder.FindStringSubmatch(m)[1])
+		if findByName(k.Project.Services, k8sServiceNameByRef(k.Project.Services, name)) == nil {
+			unknown = append(unknown, name)
+			return m
+		}
+		return hostname(name)
+	})
+
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("referenced service %s isn't defined in the project", strings.Join(unknown, ", "))
+	}
+
+	if !cfg.Hostnames.Rewrite {
+		return value, nil
+	}
+
+	// @step rewrite each comma separated item referencing a project service hostname
+	items := strings.Split(value, ",")
+	for i, item := range items {
+		m := serviceHostnameReference.FindStringSubmatch(item)
+		if m == nil || !k.isProjectServiceName(m[3]) {
+			continue
+		}
+
+		// @step bare hosts are only rewritten when explicitly enabled
+		if m[2] == "" && m[5] == "" && !cfg.Hostnames.BareNames {
+			continue
+		}
+
+		items[i] = m[1] + hostname(m[3]) + m[4]
+	}
+
+	return strings.Join(items, ","), nil
+}
+
+// isProjectServiceName checks whether name exactly matches compose project service name or its K8s name
+func (k *Kubernetes) isProjectServiceName(name string) bool {
+	for _, svc := range k.Project.Services {
+		if svc.Name == name || k8sServiceName(svc) == name {
+			return true
+		}
+	}
+	return false
+}
+
 // createKubernetesObjects generates a Kubernetes object for each input compose project service
 // @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/kubernetes/kubernetes.go#L1020
 func (k *Kubernetes) createKubernetesObjects(projectService ProjectService) []runtime.Object {
@@ -1550,6 +1978,22 @@ func (k *Kubernetes) createConfigMapFromComposeConfig(projectService ProjectServ
 			continue
 		}
 
+		content, inline, err := fileObjectContent("config", currentConfigName, composego.FileObjectConfig(currentConfigObj))
+		if err != nil {
+			log.ErrorfWithFields(log.Fields{
+				"project-service": projectService.Name,
+				"config-name":     currentConfigName,
+			}, "Unable to initialise ConfigMap: %s", err.Error())
+
+			continue
+		}
+		if inline {
+			configMapName := k.configName(currentConfigName)
+			objects = append(objects, k.initConfigMap(projectService, configMapName, map[string]string{currentConfigName: content}))
+
+			continue
+		}
+
 		currentFileName := currentConfigObj.File
 		configMap, err := k.initConfigMapFromFile(projectService, currentFileName)
 		if err != nil {
@@ -1685,11 +2129,192 @@ func (k *Kubernetes) createService(serviceType config.ServiceType, projectServic
 		svc.Spec.Type = v1SvcType
 	}
 
-	svc.ObjectMeta.Annotations = configAnnotations(projectService.Labels)
+	// @step configure session affinity & traffic policies
+	svc.Spec.SessionAffinity = projectService.sessionAffinity()
+	svc.Spec.InternalTrafficPolicy = projectService.internalTrafficPolicy()
+
+	if policy := projectService.externalTrafficPolicy(); policy != "" {
+		if isExternalServiceType(serviceType) {
+			svc.Spec.ExternalTrafficPolicy = policy
+		} else {
+			log.WarnWithFields(log.Fields{
+				"project-service": projectService.Name,
+				"service-type":    serviceType.String(),
+			}, "External traffic policy is only applicable to NodePort and LoadBalancer services and will be ignored")
+		}
+	}
+
+	// @step configure load balancer specific settings
+	if config.ServiceTypesEqual(serviceType, config.LoadBalancerService) {
+		svc.Spec.LoadBalancerSourceRanges = projectService.loadBalancerSourceRanges()
+		svc.Spec.LoadBalancerClass = projectService.loadBalancerClass()
+	} else if len(projectService.loadBalancerSourceRanges()) > 0 || projectService.loadBalancerClass() != nil {
+		log.WarnWithFields(log.Fields{
+			"project-service": projectService.Name,
+			"service-type":    serviceType.String(),
+		}, "Load balancer source ranges and class are only applicable to LoadBalancer services and will be ignored")
+	}
+
+	svc.ObjectMeta.Annotations = configAnnotations(projectService.Labels, projectService.serviceAnnotations(), k.meshServiceAnnotations(projectService))
 
 	return svc, nil
 }
 
+// createInternalService creates a cluster-internal ClusterIP k8s service for compose `expose` ports,
+// which are left out of an externally reachable service of a given type.
+func (k *Kubernetes) createInternalService(serviceType config.ServiceType, projectService ProjectService) *v1.Service {
+	svc := k.initSvc(projectService)
+	svc.ObjectMeta.Name = internalServiceName(projectService)
+
+	svc.Spec.Type = v1.ServiceTypeClusterIP
+	svc.Spec.Ports = k.toServicePorts(config.ClusterIPService, projectService, projectService.internalPorts(serviceType))
+	svc.Spec.SessionAffinity = projectService.sessionAffinity()
+	svc.Spec.InternalTrafficPolicy = projectService.internalTrafficPolicy()
+
+	svc.ObjectMeta.Annotations = configAnnotations(projectService.Labels)
+
+	return svc
+}
+
+// internalServiceName returns name of the cluster-internal service for project service expose ports
+func internalServiceName(projectService ProjectService) string {
+	return rfc1123label(projectService.Name + "-internal")
+}
+
+// serviceAliases returns normalised alias names for the project service, derived from compose network aliases
+// and links defined by other project services. Aliases clashing with project service names are skipped.
+func (k *Kubernetes) serviceAliases(projectService ProjectService) []string {
+	aliases, clashing := k.aliasNames(projectService)
+
+	for _, alias := range clashing {
+		log.WarnWithFields(log.Fields{
+			"project-service": projectService.Name,
+			"alias":           alias,
+		}, "Service alias clashes with another project service name and will be ignored")
+	}
+
+	return aliases
+}
+
+// aliasNames returns unique normalised aliases of the project service,
+// along with the original aliases clashing with project service names.
+func (k *Kubernetes) aliasNames(projectService ProjectService) (aliases []string, clashing []string) {
+	exist := map[string]bool{
+		rfc1123label(projectService.Name): true,
+	}
+
+	candidates := append(projectService.networkAliases(), projectService.linkAliases(k.Project)...)
+	for _, candidate := range candidates {
+		alias := rfc1123label(candidate)
+		if alias == "" || exist[alias] {
+			continue
+		}
+		exist[alias] = true
+
+		if findByName(k.Project.Services, alias) != nil {
+			clashing = append(clashing, candidate)
+			continue
+		}
+
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, clashing
+}
+
+// createAliasService creates a k8s ClusterIP service under an alias name. It shares the project service selector
+// so the alias resolves to the same pods. Headless service is created for project service without ports.
+func (k *Kubernetes) createAliasService(projectService ProjectService, alias string) *v1.Service {
+	var svc *v1.Service
+
+	if k.portsExist(projectService) {
+		svc = k.initSvc(projectService)
+		svc.Spec.Type = v1.ServiceTypeClusterIP
+		svc.Spec.Ports = k.toServicePorts(config.ClusterIPService, projectService, projectService.ports())
+		svc.ObjectMeta.Annotations = configAnnotations(projectService.Labels)
+	} else {
+		svc = k.createHeadlessService(projectService)
+	}
+
+	svc.ObjectMeta.Name = alias
+
+	return svc
+}
+
+// createExternalNameService creates a k8s ExternalName service resolving to external DNS name
+func (k *Kubernetes) createExternalNameService(projectService ProjectService, name, externalName string) *v1.Service {
+	return &v1.Service{
+		TypeMeta: meta.TypeMeta{
+			Kind:       "Service",
+			APIVersion: "v1",
+		},
+		ObjectMeta: meta.ObjectMeta{
+			Name:        rfc1123label(name),
+			Labels:      configLabels(projectService.Name),
+			Annotations: configAnnotations(projectService.Labels),
+		},
+		Spec: v1.ServiceSpec{
+			Type:         v1.ServiceTypeExternalName,
+			ExternalName: externalName,
+		},
+	}
+}
+
+// createExternalNameServices creates ExternalName services for project service provided outside of the cluster,
+// both under project service name and all its aliases.
+func (k *Kubernetes) createExternalNameServices(projectService ProjectService) []runtime.Object {
+	externalName := projectService.externalName()
+
+	objects := []runtime.Object{
+		k.createExternalNameService(projectService, projectService.Name, externalName),
+	}
+
+	for _, alias := range k.serviceAliases(projectService) {
+		objects = append(objects, k.createExternalNameService(projectService, alias, externalName))
+	}
+
+	return objects
+}
+
+// createExternalLinkServices creates ExternalName services for project service external links.
+// Each link resolves to the hostname it's mapped to via `x-k8s.service.externalLinks`. Otherwise the external
+// container name is normalised, and expected to be resolvable from within the cluster.
+func (k *Kubernetes) createExternalLinkServices(projectService ProjectService) []runtime.Object {
+	var objects []runtime.Object
+
+	links := projectService.externalLinks()
+	aliases := make([]string, 0, len(links))
+	for alias := range links {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	for _, alias := range aliases {
+		if findByName(k.Project.Services, rfc1123label(alias)) != nil {
+			log.WarnWithFields(log.Fields{
+				"project-service": projectService.Name,
+				"external-link":   alias,
+			}, "External link alias clashes with a project service name and will be ignored")
+			continue
+		}
+
+		target := projectService.externalLinkHostname(alias)
+		if target == "" {
+			target = rfc1123dns(links[alias])
+
+			log.WarnfWithFields(log.Fields{
+				"project-service": projectService.Name,
+				"external-link":   alias,
+			}, "External link has no hostname set in `x-k8s.service.externalLinks` and will resolve to %q. "+
+				"Make sure it's resolvable from within the cluster", target)
+		}
+
+		objects = append(objects, k.createExternalNameService(projectService, alias, target))
+	}
+
+	return objects
+}
+
 // createHeadlessService creates a k8s headless service.
 // This is used for docker-compose services without ports. For such services we can't create regular Kubernetes Service.
 // and without Service Pods can't find each other using DNS names.
@@ -1723,6 +2348,19 @@ func (k *Kubernetes) updateKubernetesObjects(projectService ProjectService, obje
 		return errors.Wrap(err, "Unable to load env variables")
 	}
 
+	// @step move env vars to a ConfigMap and a Secret if enabled
+	envs, envObjects, err := k.classifyEnvs(projectService, envs)
+	if err != nil {
+		return errors.Wrap(err, "Unable to classify env variables")
+	}
+	*objects = append(*objects, envObjects...)
+
+	// @step configure env vars loaded from env files and whole K8s secrets or config maps
+	envFrom, err := k.configEnvFrom(projectService)
+	if err != nil {
+		return errors.Wrap(err, "Unable to load env files")
+	}
+
 	// @step configure the container volumes
 	volumesMounts, volumes, pvcs, cms, err := k.configVolumes(projectService)
 	if err != nil {
@@ -1730,10 +2368,23 @@ func (k *Kubernetes) updateKubernetesObjects(projectService ProjectService, obje
 	}
 
 	// @step configure Tmpfs
-	if len(projectService.Tmpfs) > 0 {
-		TmpVolumesMount, TmpVolumes := k.configTmpfs(projectService)
-		volumes = append(volumes, TmpVolumes...)
-		volumesMounts = append(volumesMounts, TmpVolumesMount...)
+	TmpVolumesMount, TmpVolumes, err := k.configTmpfs(projectService)
+	if err != nil {
+		return errors.Wrap(err, "Unable to configure tmpfs volumes")
+	}
+	volumes = append(volumes, TmpVolumes...)
+	volumesMounts = append(volumesMounts, TmpVolumesMount...)
+
+	// @step expose raw block volumes as container devices
+	volumesMounts, volumeDevices, err := k.configVolumeDevices(projectService, volumesMounts, volumes)
+	if err != nil {
+		return errors.Wrap(err, "Unable to configure container volume devices")
+	}
+
+	// @step configure downward API volume
+	if volumeMount, volume := k.configDownwardAPIVolume(projectService); volume != nil {
+		volumes = append(volumes, *volume)
+		volumesMounts = append(volumesMounts, *volumeMount)
 	}
 
 	// @step add PVCs to objects
@@ -1744,6 +2395,22 @@ func (k *Kubernetes) updateKubernetesObjects(projectService ProjectService, obje
 		*objects = append(*objects, p)
 	}
 
+	// @step add static PersistentVolumes the PVCs are bound to
+	pvs, err := k.createPersistentVolumes(projectService, pvcs)
+	if err != nil {
+		return errors.Wrap(err, "Unable to create persistent volumes")
+	}
+	for _, p := range pvs {
+		*objects = append(*objects, p)
+	}
+
+	// @step add snapshots of the PVCs
+	snapshots, err := k.createVolumeSnapshots(projectService)
+	if err != nil {
+		return errors.Wrap(err, "Unable to create volume snapshots")
+	}
+	*objects = append(*objects, snapshots...)
+
 	// @step add ConfigMaps to objects
 	for _, c := range cms {
 		*objects = append(*objects, c)
@@ -1758,16 +2425,21 @@ func (k *Kubernetes) updateKubernetesObjects(projectService ProjectService, obje
 	// @step configure annotations
 	annotations := configAnnotations(projectService.Labels)
 
+	// @step configure mesh sidecar injection annotations
+	meshAnnotations := k.meshPodAnnotations(projectService)
+
 	// @step fillTemplate function will fill the pod template with the values calculated from config
 	fillTemplate := func(template *v1.PodTemplateSpec) error {
 		if len(projectService.ContainerName) > 0 {
 			template.Spec.Containers[0].Name = rfc1123dns(projectService.ContainerName)
 		}
 		template.Spec.Containers[0].Env = envs
+		template.Spec.Containers[0].EnvFrom = envFrom
 		template.Spec.Containers[0].Command = projectService.command()
 		template.Spec.Containers[0].Args = projectService.commandArgs()
 		template.Spec.Containers[0].WorkingDir = projectService.WorkingDir
 		template.Spec.Containers[0].VolumeMounts = append(template.Spec.Containers[0].VolumeMounts, volumesMounts...)
+		template.Spec.Containers[0].VolumeDevices = append(template.Spec.Containers[0].VolumeDevices, volumeDevices...)
 		template.Spec.Containers[0].Stdin = projectService.StdinOpen
 		template.Spec.Containers[0].TTY = projectService.Tty
 		template.Spec.Volumes = append(template.Spec.Volumes, volumes...)
@@ -1842,6 +2514,11 @@ func (k *Kubernetes) updateKubernetesObjects(projectService ProjectService, obje
 		// @step update labels
 		template.ObjectMeta.Labels = configLabelsWithNetwork(projectService)
 
+		// @step update mesh annotations
+		if len(meshAnnotations) > 0 {
+			template.ObjectMeta.Annotations = configAnnotations(template.ObjectMeta.Annotations, meshAnnotations)
+		}
+
 		// @step configure the image pull policy
 		template.Spec.Containers[0].ImagePullPolicy = projectService.imagePullPolicy()
 
@@ -1888,6 +2565,11 @@ func (k *Kubernetes) updateKubernetesObjects(projectService ProjectService, obje
 		}
 	}
 
+	// @step create StatefulSet claims from templates for volumes with a retention policy
+	if err := k.configVolumeClaimTemplates(projectService, objects); err != nil {
+		return errors.Wrap(err, "Unable to configure volume claim templates")
+	}
+
 	return nil
 }
 
@@ -28,7 +28,6 @@ import (
 // ConvertOptions holds all options that controls transformation process
 type ConvertOptions struct {
 	ToStdout     bool     // Display output to STDOUT
-	CreateChart  bool     // Create K8s manifests as Chart
 	GenerateJSON bool     // Generate outcome as JSON. By defaults YAML gets generated.
 	EmptyVols    bool     // Treat all referenced volumes as Empty volumes
 	Volumes      string   // Volumes to be generated ("persistentVolumeClaim"|"emptyDir"|"hostPath"|"configMap") (default "persistentVolumeClaim")
@@ -39,17 +38,28 @@ type ConvertOptions struct {
 
 // Volumes holds the container volume struct
 type Volumes struct {
-	SvcName       string // Service name to which volume is linked
-	MountPath     string // Mountpath extracted from docker-compose file
-	VFrom         string // denotes service name from which volume is coming
-	VolumeName    string // name of volume if provided explicitly
-	Host          string // host machine address
-	Container     string // Mountpath
-	Mode          string // access mode for volume
-	PVCName       string // name of PVC
-	PVCSize       string // PVC size
-	StorageClass  string // PVC storage class
-	SelectorValue string // Value of the label selector
+	SvcName       string                    // Service name to which volume is linked
+	MountPath     string                    // Mountpath extracted from docker-compose file
+	VFrom         string                    // denotes service name from which volume is coming
+	VolumeName    string                    // name of volume if provided explicitly
+	ComposeName   string                    // name of volume as referenced in docker-compose file
+	Host          string                    // host machine address
+	Container     string                    // Mountpath
+	Mode          string                    // access mode for volume
+	PVCName       string                    // name of PVC
+	PVCSize       string                    // PVC size
+	StorageClass  string                    // PVC storage class
+	SelectorValue string                    // Value of the label selector
+	AccessModes   []string                  // PVC access modes
+	VolumeMode    string                    // PVC volume mode, Filesystem or Block
+	Annotations   map[string]string         // PVC annotations
+	Labels        map[string]string         // PVC labels
+	DataSource    config.VolumeDataSource   // PVC data source
+	Retention     config.PVCRetentionPolicy // StatefulSet PVC retention policy
+	External      bool                      // volume references an existing PVC
+	NFS           config.VolumeNFS          // NFS share mounted by the volume
+	CSI           config.VolumeCSI          // CSI inline or generic ephemeral volume
+	Snapshots     []config.VolumeSnapshot   // one-off or scheduled snapshots of the volume claim
 }
 
 // ProjectService is a wrapper type around composego.ServiceConfig
@@ -30,10 +30,11 @@ import (
 	"reflect"
 	"regexp"
 	"sort"
+	"strconv"
 	"strings"
-	"text/template"
 	"time"
 
+	"github.com/appvia/tako/pkg/tako/config"
 	"github.com/appvia/tako/pkg/tako/log"
 	composego "github.com/compose-spec/compose-go/types"
 	"github.com/pkg/errors"
@@ -77,6 +78,16 @@ func (env EnvSort) Swap(i, j int) {
 // @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/kubernetes/k8sutils.go#L153
 func PrintList(objects []runtime.Object, opt ConvertOptions, additionalManifests []string, rendered map[string][]byte) error {
 
+	indent := 2
+	if opt.YAMLIndent > 0 {
+		indent = opt.YAMLIndent
+	}
+
+	// @step render for stdout - nothing gets written to disk
+	if opt.ToStdout {
+		return renderForStdout(objects, opt, additionalManifests, rendered, indent)
+	}
+
 	var f *os.File
 	dirName := getDirName(opt)
 	log.Debugf("Target Dir: %s", dirName)
@@ -89,10 +100,6 @@ func PrintList(objects []runtime.Object, opt ConvertOptions, additionalManifests
 		return err
 	}
 
-	if opt.CreateChart {
-		isDirVal = true
-	}
-
 	if !isDirVal {
 		// cleanup target directory before creating a new file
 		if err := os.RemoveAll(filepath.Dir(dirName)); err != nil {
@@ -118,44 +125,10 @@ func PrintList(objects []runtime.Object, opt ConvertOptions, additionalManifests
 		}(f)
 	}
 
-	indent := 2
-	if opt.YAMLIndent > 0 {
-		indent = opt.YAMLIndent
-	}
-
-	// @step print to stdout, or to a single file - it will return a list object
-	if opt.ToStdout || f != nil {
-
-		list := &v1.List{}
-
-		// convert objects to versioned and add them to list
-		for _, object := range objects {
-			versionedObject, err := convertToVersion(object, schema.GroupVersion{})
-			if err != nil {
-				return err
-			}
-
-			list.Items = append(list.Items, runtime.RawExtension{Object: versionedObject})
-		}
+	// @step print to a single file - it will return a list object
+	if f != nil {
 
-		// if additional manifests files are specified, add them to the generated objects list
-		if len(additionalManifests) > 0 {
-			for _, extraManifest := range additionalManifests {
-
-				ro, err := fileToRuntimeObject(extraManifest)
-				if err != nil {
-					return err
-				}
-
-				list.Items = append(list.Items, runtime.RawExtension{Object: ro})
-			}
-		}
-
-		// version list itself
-		listVersion := schema.GroupVersion{Group: "", Version: "v1"}
-		list.Kind = "List"
-		list.APIVersion = "v1"
-		convertedList, err := convertToVersion(list, listVersion)
+		convertedList, err := listObject(objects, additionalManifests)
 		if err != nil {
 			return err
 		}
@@ -166,7 +139,7 @@ func PrintList(objects []runtime.Object, opt ConvertOptions, additionalManifests
 			return err
 		}
 
-		file, err := print(dirName, "", "", data, opt.ToStdout, opt.GenerateJSON, f)
+		file, err := print(dirName, "", "", data, opt.GenerateJSON, f)
 		if err != nil {
 			log.Error("Printing manifests failed")
 			return err
@@ -179,11 +152,6 @@ func PrintList(objects []runtime.Object, opt ConvertOptions, additionalManifests
 
 		finalDirName := dirName
 
-		// if that's a chart it'll spit things out to "templates" subdir
-		if opt.CreateChart {
-			finalDirName = filepath.Join(dirName, "templates")
-		}
-
 		if err := os.RemoveAll(finalDirName); err != nil {
 			return err
 		}
@@ -240,7 +208,7 @@ func PrintList(objects []runtime.Object, opt ConvertOptions, additionalManifests
 				objectMeta = val.FieldByName("ObjectMeta").Interface().(meta.ObjectMeta)
 			}
 
-			file, err := print(finalDirName, objectMeta.Name, strings.ToLower(typeMeta.Kind), data, opt.ToStdout, opt.GenerateJSON, f)
+			file, err := print(finalDirName, objectMeta.Name, strings.ToLower(typeMeta.Kind), data, opt.GenerateJSON, f)
 			if err != nil {
 				log.Error("Printing manifests failed")
 				return err
@@ -249,17 +217,88 @@ func PrintList(objects []runtime.Object, opt ConvertOptions, additionalManifests
 			rendered[file] = data
 		}
 	}
-	// @step for helm output generate chart directory structure
-	if opt.CreateChart {
-		if err = generateHelm(dirName); err != nil {
-			log.Error("Couldn't generate HELM chart")
+	return nil
+}
+
+// renderForStdout renders objects as a multi-document YAML stream, or as a list object in JSON format.
+// The output is only recorded in rendered, and it's up to the caller to print it once rendered manifests are validated.
+func renderForStdout(objects []runtime.Object, opt ConvertOptions, additionalManifests []string, rendered map[string][]byte, indent int) error {
+	var data []byte
+
+	if opt.GenerateJSON {
+		convertedList, err := listObject(objects, additionalManifests)
+		if err != nil {
+			return err
+		}
+
+		data, err = marshal(convertedList, true, indent)
+		if err != nil {
+			log.Error("Error in marshalling the List")
 			return err
 		}
+		data = append(data, '\n')
+	} else {
+		// if additional manifests files are specified, add them to the generated objects
+		for _, extraManifest := range additionalManifests {
+			ro, err := fileToRuntimeObject(extraManifest)
+			if err != nil {
+				return err
+			}
+
+			objects = append(objects, ro)
+		}
+
+		docs := [][]byte{}
+		for _, object := range objects {
+			versionedObject, err := convertToVersion(object, schema.GroupVersion{})
+			if err != nil {
+				return err
+			}
+
+			doc, err := marshal(versionedObject, false, indent)
+			if err != nil {
+				return err
+			}
+			docs = append(docs, doc)
+		}
+		data = bytes.Join(docs, []byte("---\n"))
 	}
 
+	rendered[StdoutOutput] = data
 	return nil
 }
 
+// listObject returns a versioned list of objects and additional manifests
+func listObject(objects []runtime.Object, additionalManifests []string) (runtime.Object, error) {
+	list := &v1.List{}
+
+	// convert objects to versioned and add them to list
+	for _, object := range objects {
+		versionedObject, err := convertToVersion(object, schema.GroupVersion{})
+		if err != nil {
+			return nil, err
+		}
+
+		list.Items = append(list.Items, runtime.RawExtension{Object: versionedObject})
+	}
+
+	// if additional manifests files are specified, add them to the generated objects list
+	for _, extraManifest := range additionalManifests {
+		ro, err := fileToRuntimeObject(extraManifest)
+		if err != nil {
+			return nil, err
+		}
+
+		list.Items = append(list.Items, runtime.RawExtension{Object: ro})
+	}
+
+	// version list itself
+	listVersion := schema.GroupVersion{Group: "", Version: "v1"}
+	list.Kind = "List"
+	list.APIVersion = "v1"
+	return convertToVersion(list, listVersion)
+}
+
 // fileToRuntimeObject reads a file and converts its contents to a runtime.Object
 func fileToRuntimeObject(file string) (runtime.Object, error) {
 	// @step: create a new decoder
@@ -284,9 +323,9 @@ func fileToRuntimeObject(file string) (runtime.Object, error) {
 	return runtimeObject, nil
 }
 
-// print either renders to stdout or to file/s
+// print either renders to a single file or to file/s
 // @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/utils.go#L176
-func print(path, name, kind string, data []byte, toStdout, generateJSON bool, f *os.File) (string, error) {
+func print(path, name, kind string, data []byte, generateJSON bool, f *os.File) (string, error) {
 	file := ""
 
 	if generateJSON {
@@ -295,17 +334,14 @@ func print(path, name, kind string, data []byte, toStdout, generateJSON bool, f
 		file = fmt.Sprintf("%s-%s.yaml", name, kind)
 	}
 
-	if toStdout {
-		_, _ = fmt.Fprintf(os.Stdout, "%s\n", string(data))
-		return "", nil
-
-	} else if f != nil {
+	if f != nil {
 		// Write all content to a single file f
 		if _, err := f.WriteString(fmt.Sprintf("%s\n", string(data))); err != nil {
 			log.Error("Couldn't write manifests content to a single file")
 			return "", err
 		}
 		_ = f.Sync()
+		file = f.Name()
 
 	} else {
 		// Write content separately to each file
@@ -322,69 +358,6 @@ func print(path, name, kind string, data []byte, toStdout, generateJSON bool, f
 	return file, nil
 }
 
-// Generate Helm Chart configuration
-// @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/kubernetes/k8sutils.go#L54
-func generateHelm(dirName string) error {
-	type ChartDetails struct {
-		Name string
-	}
-
-	details := ChartDetails{dirName}
-	manifestDir := dirName + string(os.PathSeparator) + "templates"
-	dir, err := os.Open(dirName)
-
-	// @step Setup the initial directories/files
-	if err == nil {
-		_ = dir.Close()
-	}
-
-	if err != nil {
-		err = os.Mkdir(dirName, 0755)
-		if err != nil {
-			return err
-		}
-
-		err = os.Mkdir(manifestDir, 0755)
-		if err != nil {
-			return err
-		}
-	}
-
-	// @step Create the readme file
-	readme := "This chart was created by Kompose\n"
-	err = os.WriteFile(dirName+string(os.PathSeparator)+"README.md", []byte(readme), 0644)
-	if err != nil {
-		return err
-	}
-
-	// @step Create the Chart.yaml file
-	chart := `name: {{.Name}}
-description: A generated Helm Chart for {{.Name}} from Skippbox Kompose
-version: 0.0.1
-apiVersion: v1
-keywords:
-  - {{.Name}}
-sources:
-home:
-`
-
-	t, err := template.New("ChartTmpl").Parse(chart)
-	if err != nil {
-		log.Error("Failed to generate Chart template")
-		return err
-	}
-	var chartData bytes.Buffer
-	_ = t.Execute(&chartData, details)
-
-	err = os.WriteFile(dirName+string(os.PathSeparator)+"Chart.yaml", chartData.Bytes(), 0644)
-	if err != nil {
-		return err
-	}
-
-	log.Debugf("chart created in %q", dirName+string(os.PathSeparator))
-	return nil
-}
-
 // Check if given path is a directory
 // @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/kubernetes/k8sutils.go#L115
 func isDir(name string) (bool, error) {
@@ -422,14 +395,7 @@ func isDir(name string) (bool, error) {
 func getDirName(opt ConvertOptions) string {
 	dirName := opt.OutFile
 	if dirName == "" {
-		// This assumes all the docker-compose files are in the same directory
-		if opt.CreateChart {
-			filename := opt.InputFiles[0]
-			extension := filepath.Ext(filename)
-			dirName = filename[0 : len(filename)-len(extension)]
-		} else {
-			dirName = "."
-		}
+		dirName = "."
 	}
 	return dirName
 }
@@ -462,6 +428,44 @@ func sanitizeObject(obj runtime.Object) (map[string]interface{}, error) {
 	return jsonObj, nil
 }
 
+// objectMap returns a map representation of a versioned and sanitized object,
+// as written to the rendered manifests
+func objectMap(obj runtime.Object) (map[string]interface{}, error) {
+	versionedObject, err := convertToVersion(obj, schema.GroupVersion{})
+	if err != nil {
+		return nil, err
+	}
+	return sanitizeObject(versionedObject)
+}
+
+// ObjectMaps converts compose project to set of k8s objects, followed by the additional
+// manifests objects, and returns their map representations
+func (k *Kubernetes) ObjectMaps(additionalManifests []string) ([]map[string]interface{}, error) {
+	objects, err := k.Transform()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range additionalManifests {
+		o, err := fileToRuntimeObject(f)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, o)
+	}
+
+	var out []map[string]interface{}
+	for _, o := range objects {
+		m, err := objectMap(o)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+
+	return out, nil
+}
+
 // marshal marshals a runtime.Object and return byte array
 // @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/kubernetes/k8sutils.go#L269
 func marshal(obj runtime.Object, jsonFormat bool, indent int) ([]byte, error) {
@@ -627,6 +631,15 @@ func configLabels(name string) map[string]string {
 	return map[string]string{Selector: name}
 }
 
+// unstructuredMap converts a string map, e.g. labels or annotations, to a map usable in unstructured objects
+func unstructuredMap(m map[string]string) map[string]interface{} {
+	out := map[string]interface{}{}
+	for key, val := range m {
+		out[key] = val
+	}
+	return out
+}
+
 // configAllLabels creates labels with service name and deploy labels
 // @orig: https://github.com/kubernetes/kompose/blob/master/pkg/transformer/utils.go#L140
 func configAllLabels(projectService ProjectService) map[string]string {
@@ -708,16 +721,87 @@ func configLabelsWithNetwork(projectService ProjectService) map[string]string {
 	return labels
 }
 
-// findByName selects compose project service by name
+// findByName selects compose project service by its K8s name
 func findByName(projectServices composego.Services, name string) *composego.ServiceConfig {
 	for _, ps := range projectServices {
-		if rfc1123dns(ps.Name) == name {
+		if k8sServiceName(ps) == name {
 			return &ps
 		}
 	}
 	return nil
 }
 
+// k8sServiceName returns K8s name of compose project service. It's the name explicitly set via `x-k8s.name`,
+// or the compose service name normalised to a valid DNS name otherwise.
+func k8sServiceName(svc composego.ServiceConfig) string {
+	if _, ok := svc.Extensions[config.K8SExtensionKey]; ok {
+		cfg, err := config.ParseSvcK8sConfigFromMap(svc.Extensions, config.SkipValidation())
+		if err == nil && cfg.Name != "" {
+			return cfg.Name
+		}
+	}
+	return rfc1123dns(svc.Name)
+}
+
+// k8sServiceNameByRef returns K8s name of compose project service referenced by its compose name.
+// Names not referencing any of the project services are normalised to a valid DNS name.
+func k8sServiceNameByRef(projectServices composego.Services, name string) string {
+	for _, ps := range projectServices {
+		if ps.Name == name {
+			return k8sServiceName(ps)
+		}
+	}
+	return rfc1123dns(name)
+}
+
+// k8sVolumeName returns K8s name of compose project volume. It's the name explicitly set via `x-k8s.name`,
+// the name of an external volume, or the compose volume name normalised to a valid DNS name otherwise.
+func k8sVolumeName(name string, vol composego.VolumeConfig) string {
+	if _, ok := vol.Extensions[config.K8SExtensionKey]; ok {
+		cfg, err := config.ParseVolK8sConfigFromMap(vol.Extensions, config.SkipValidation())
+		if err == nil && cfg.Name != "" {
+			return cfg.Name
+		}
+	}
+	if vol.External.External && vol.Name != "" {
+		return rfc1123(vol.Name)
+	}
+	return rfc1123(name)
+}
+
+// k8sFileObjectName returns K8s name of compose project secret or config. It's the name explicitly set
+// via `x-k8s.name`, or the compose secret / config name normalised to a valid DNS name otherwise.
+func k8sFileObjectName(name string, obj composego.FileObjectConfig) string {
+	cfg, err := config.FileObjK8sConfigFromCompose(&obj)
+	if err == nil && cfg.Name != "" {
+		return cfg.Name
+	}
+	return rfc1123dns(name)
+}
+
+// fileObjectContent returns content of a secret or config defined inline, or sourced from an env var,
+// via its `x-k8s` extension. Returns false when content isn't defined via the extension.
+func fileObjectContent(kind, name string, obj composego.FileObjectConfig) (string, bool, error) {
+	cfg, err := config.FileObjK8sConfigFromCompose(&obj)
+	if err != nil {
+		return "", false, errors.Wrapf(err, "%s %s has invalid configuration", kind, name)
+	}
+
+	if cfg.Content != "" {
+		return cfg.Content, true, nil
+	}
+
+	if cfg.Environment != "" {
+		value, ok := os.LookupEnv(cfg.Environment)
+		if !ok {
+			return "", false, fmt.Errorf("%s %s environment variable %s isn't set", kind, name, cfg.Environment)
+		}
+		return value, true, nil
+	}
+
+	return "", false, nil
+}
+
 // retrieveVolume returns all volumes associated with service.
 // If `volumes_from` key is used, we also retrieve volumes used by those services. Hence, recursive function call.
 // @orig: https://github.com/kubernetes/kompose/blob/e7f05588bf8bd645000612faa136b1b6aa0d5bb6/pkg/loader/compose/v1v2.go#L341
@@ -740,7 +824,7 @@ func retrieveVolume(projectServiceName string, project *composego.Project) (volu
 		for _, depSvc := range projectService.VolumesFrom {
 
 			// recursive call for retrieving volumes from `volumes-from` services
-			dVols, err := retrieveVolume(depSvc, project)
+			dVols, err := retrieveVolume(k8sServiceNameByRef(project.Services, depSvc), project)
 			if err != nil {
 				log.Error("Could not retrieve the volume")
 				return nil, errors.New("Could not retrieve the volume")
@@ -813,6 +897,7 @@ func parseVols(volNames []string, svcName string) ([]Volumes, error) {
 			return nil, err
 		}
 
+		v.ComposeName = v.VolumeName
 		v.VolumeName = rfc1123(v.VolumeName)
 		v.SvcName = svcName
 		v.MountPath = fmt.Sprintf("%s:%s", v.Host, v.Container)
@@ -903,6 +988,11 @@ func isPath(substring string) bool {
 func loadVolumes(volumes []composego.ServiceVolumeConfig) []string {
 	var volArray []string
 	for _, vol := range volumes {
+		// tmpfs volumes are converted separately, see configTmpfs
+		if vol.Type == "tmpfs" {
+			continue
+		}
+
 		// There will *always* be Source when parsing
 		v := vol.Source
 
@@ -999,6 +1089,35 @@ func contains(strs []string, s string) bool {
 	return i < len(strs) && strs[i] == s
 }
 
+// parsePortRange parses a single port or a range of ports, e.g. `8080` or `8000-8010`
+func parsePortRange(s string) (uint32, uint32, error) {
+	parts := strings.SplitN(strings.TrimSpace(s), "-", 2)
+
+	start, err := strconv.ParseUint(parts[0], 10, 16)
+	if err != nil || start == 0 {
+		return 0, 0, fmt.Errorf("invalid port %q", s)
+	}
+
+	end := start
+	if len(parts) == 2 {
+		end, err = strconv.ParseUint(parts[1], 10, 16)
+		if err != nil || end < start {
+			return 0, 0, fmt.Errorf("invalid port range %q", s)
+		}
+	}
+
+	return uint32(start), uint32(end), nil
+}
+
+// parseLink parses compose link in the `SERVICE:ALIAS` or `SERVICE` format
+func parseLink(link string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(link), ":", 2)
+	if len(parts) == 2 {
+		return parts[0], parts[1]
+	}
+	return parts[0], ""
+}
+
 // ToUnstructured converts runtime.Object to unstructured map[string]interface{}
 func ToUnstructured(o runtime.Object) (map[string]interface{}, error) {
 	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
@@ -17,8 +17,14 @@
 package kubernetes
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
+	"github.com/appvia/tako/pkg/tako/config"
 	composego "github.com/compose-spec/compose-go/types"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -27,6 +33,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -231,6 +238,67 @@ var _ = Describe("Utils", func() {
 		})
 	})
 
+	Describe("k8sServiceName", func() {
+		It("returns normalised compose service name", func() {
+			Expect(k8sServiceName(composego.ServiceConfig{Name: "My_API"})).To(Equal("my-api"))
+		})
+
+		It("returns explicit name when set in k8s extension", func() {
+			svc := composego.ServiceConfig{
+				Name: "my_api",
+				Extensions: map[string]interface{}{
+					config.K8SExtensionKey: map[string]interface{}{"name": "api-v2"},
+				},
+			}
+			Expect(k8sServiceName(svc)).To(Equal("api-v2"))
+		})
+	})
+
+	Describe("k8sVolumeName", func() {
+		It("returns normalised compose volume name", func() {
+			Expect(k8sVolumeName("db_data", composego.VolumeConfig{})).To(Equal("db-data"))
+		})
+
+		It("returns name of an external volume", func() {
+			vol := composego.VolumeConfig{
+				Name:     "shared_data",
+				External: composego.External{External: true},
+			}
+			Expect(k8sVolumeName("data", vol)).To(Equal("shared-data"))
+		})
+
+		It("returns explicit name when set in k8s extension", func() {
+			vol := composego.VolumeConfig{
+				Name:     "shared_data",
+				External: composego.External{External: true},
+				Extensions: map[string]interface{}{
+					config.K8SExtensionKey: map[string]interface{}{"name": "existing-claim"},
+				},
+			}
+			Expect(k8sVolumeName("data", vol)).To(Equal("existing-claim"))
+		})
+	})
+
+	Describe("k8sServiceNameByRef", func() {
+		services := composego.Services{
+			{
+				Name: "my_api",
+				Extensions: map[string]interface{}{
+					config.K8SExtensionKey: map[string]interface{}{"name": "api-v2"},
+				},
+			},
+		}
+
+		It("resolves K8s name of referenced project service", func() {
+			Expect(k8sServiceNameByRef(services, "my_api")).To(Equal("api-v2"))
+			Expect(findByName(services, "api-v2")).ToNot(BeNil())
+		})
+
+		It("normalises names not referencing project services", func() {
+			Expect(k8sServiceNameByRef(services, "other_svc")).To(Equal("other-svc"))
+		})
+	})
+
 	Describe("retrieveVolume", func() {
 		var project composego.Project
 
@@ -350,6 +418,7 @@ var _ = Describe("Utils", func() {
 						MountPath:     ":/some/path",
 						VFrom:         "",
 						VolumeName:    "vol1",
+						ComposeName:   "vol1",
 						Host:          "",
 						Container:     "/some/path",
 						Mode:          "",
@@ -363,6 +432,7 @@ var _ = Describe("Utils", func() {
 						MountPath:     ":/another/path/",
 						VFrom:         "",
 						VolumeName:    "vol2",
+						ComposeName:   "vol2",
 						Host:          "",
 						Container:     "/another/path/",
 						Mode:          "ro",
@@ -539,6 +609,29 @@ var _ = Describe("Utils", func() {
 		})
 	})
 
+	Describe("parsePortRange", func() {
+		It("parses a single port", func() {
+			start, end, err := parsePortRange("8080")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(start).To(Equal(uint32(8080)))
+			Expect(end).To(Equal(uint32(8080)))
+		})
+
+		It("parses a range of ports", func() {
+			start, end, err := parsePortRange("8000-8010")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(start).To(Equal(uint32(8000)))
+			Expect(end).To(Equal(uint32(8010)))
+		})
+
+		It("returns an error for invalid values", func() {
+			for _, v := range []string{"", "0", "http", "8010-8000", "70000"} {
+				_, _, err := parsePortRange(v)
+				Expect(err).To(HaveOccurred())
+			}
+		})
+	})
+
 	Describe("configAnnotations", func() {
 		var (
 			projectService ProjectService
@@ -573,4 +666,87 @@ var _ = Describe("Utils", func() {
 		})
 
 	})
+
+	Describe("PrintList", func() {
+		var (
+			objects  []runtime.Object
+			opt      ConvertOptions
+			rendered map[string][]byte
+			tmpDir   string
+			err      error
+		)
+
+		BeforeEach(func() {
+			tmpDir, err = ioutil.TempDir("", "print-list")
+			Expect(err).NotTo(HaveOccurred())
+
+			objects = []runtime.Object{
+				&v1.Service{
+					TypeMeta:   meta.TypeMeta{Kind: "Service", APIVersion: "v1"},
+					ObjectMeta: meta.ObjectMeta{Name: "web"},
+				},
+				&v1.ConfigMap{
+					TypeMeta:   meta.TypeMeta{Kind: "ConfigMap", APIVersion: "v1"},
+					ObjectMeta: meta.ObjectMeta{Name: "web-config"},
+					Data:       map[string]string{"LOG_LEVEL": "debug"},
+				},
+			}
+			rendered = map[string][]byte{}
+			opt = ConvertOptions{
+				OutFile:  filepath.Join(tmpDir, "dev"),
+				ToStdout: true,
+			}
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(tmpDir)
+		})
+
+		Context("when printing to stdout", func() {
+
+			It("renders a multi-document YAML stream", func() {
+				Expect(PrintList(objects, opt, nil, rendered)).To(Succeed())
+
+				docs := bytes.Split(rendered[StdoutOutput], []byte("---\n"))
+				Expect(docs).To(HaveLen(2))
+				Expect(string(docs[0])).To(HavePrefix("apiVersion: v1\nkind: Service\n"))
+				Expect(string(docs[1])).To(HavePrefix("apiVersion: v1\ndata:\n  LOG_LEVEL: debug\nkind: ConfigMap\n"))
+			})
+
+			It("renders a list object in JSON format", func() {
+				opt.GenerateJSON = true
+				Expect(PrintList(objects, opt, nil, rendered)).To(Succeed())
+
+				var list map[string]interface{}
+				Expect(json.Unmarshal(rendered[StdoutOutput], &list)).To(Succeed())
+				Expect(list).To(HaveKeyWithValue("kind", "List"))
+				Expect(list["items"]).To(HaveLen(2))
+			})
+
+			It("only records manifests as rendered, leaving printing to the caller", func() {
+				Expect(PrintList(objects, opt, nil, rendered)).To(Succeed())
+				Expect(rendered).To(HaveLen(1))
+				Expect(rendered).To(HaveKey(StdoutOutput))
+			})
+
+			It("doesn't write anything to disk", func() {
+				Expect(PrintList(objects, opt, nil, rendered)).To(Succeed())
+				Expect(opt.OutFile).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("when writing JSON files", func() {
+
+			It("writes each object to a separate JSON file", func() {
+				opt.ToStdout = false
+				opt.GenerateJSON = true
+				Expect(os.MkdirAll(opt.OutFile, 0755)).To(Succeed())
+				Expect(PrintList(objects, opt, nil, rendered)).To(Succeed())
+
+				Expect(filepath.Join(opt.OutFile, "web-service.json")).To(BeAnExistingFile())
+				Expect(filepath.Join(opt.OutFile, "web-config-configmap.json")).To(BeAnExistingFile())
+				Expect(rendered).NotTo(HaveKey(StdoutOutput))
+			})
+		})
+	})
 })
@@ -0,0 +1,288 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"reflect"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	"github.com/appvia/tako/pkg/tako/log"
+	v1apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// isBindMount tells whether the volume is a bind mount of a host path, e.g. `./src:/app`
+func isBindMount(volume Volumes) bool {
+	return volume.Host != "" && volume.ComposeName == ""
+}
+
+// bindMountStrategy returns the strategy for converting a bind mount. Project service mount specific strategy
+// takes precedence over the project wide one. Returns a blank strategy when none has been configured.
+func (k *Kubernetes) bindMountStrategy(projectService ProjectService, volume Volumes) config.BindMountStrategy {
+	if strategy, ok := projectService.SvcK8sConfig.Workload.BindMounts[volume.Container]; ok {
+		s, _ := config.BindMountStrategyFromValue(strategy.String())
+		return s
+	}
+
+	cfg, _ := k.projectK8sConfig()
+	return cfg.BindMounts
+}
+
+// configNFSVolumeSource returns an inline NFS volume source of the volume
+func (k *Kubernetes) configNFSVolumeSource(volume Volumes, readonly bool) *v1.VolumeSource {
+	return &v1.VolumeSource{
+		NFS: &v1.NFSVolumeSource{
+			Server:   volume.NFS.Server,
+			Path:     volume.NFS.Path,
+			ReadOnly: readonly,
+		},
+	}
+}
+
+// configCSIVolumeSource returns a CSI inline volume source, or a generic ephemeral volume source
+// with a claim template provisioned via the volume storage class when the volume is ephemeral.
+func (k *Kubernetes) configCSIVolumeSource(volume Volumes, readonly bool) (*v1.VolumeSource, error) {
+	if !volume.CSI.Ephemeral {
+		csi := &v1.CSIVolumeSource{
+			Driver:           volume.CSI.Driver,
+			VolumeAttributes: volume.CSI.VolumeAttributes,
+		}
+		if ro := readonly || volume.CSI.ReadOnly; ro {
+			csi.ReadOnly = &ro
+		}
+		if len(volume.CSI.FSType) > 0 {
+			csi.FSType = &volume.CSI.FSType
+		}
+		if len(volume.CSI.NodePublishSecret) > 0 {
+			csi.NodePublishSecretRef = &v1.LocalObjectReference{Name: volume.CSI.NodePublishSecret}
+		}
+
+		return &v1.VolumeSource{CSI: csi}, nil
+	}
+
+	// @step reuse the PVC spec for the ephemeral volume claim template
+	pvc, err := k.createPVC(volume)
+	if err != nil {
+		return nil, err
+	}
+
+	return &v1.VolumeSource{
+		Ephemeral: &v1.EphemeralVolumeSource{
+			VolumeClaimTemplate: &v1.PersistentVolumeClaimTemplate{
+				ObjectMeta: meta.ObjectMeta{
+					Labels:      volume.Labels,
+					Annotations: volume.Annotations,
+				},
+				Spec: pvc.Spec,
+			},
+		},
+	}, nil
+}
+
+// createPersistentVolumes returns static NFS PersistentVolumes for the given project service claims
+// of volumes configured with `nfs.persistentVolume`. Each PersistentVolume is named after its claim.
+func (k *Kubernetes) createPersistentVolumes(projectService ProjectService, pvcs []*v1.PersistentVolumeClaim) ([]*v1.PersistentVolume, error) {
+	projectServiceVolumes, err := projectService.volumes(k.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	var pvs []*v1.PersistentVolume
+	for _, pvc := range pvcs {
+		for _, vol := range projectServiceVolumes {
+			if vol.VolumeName != pvc.Name || !vol.NFS.Enabled() || !vol.NFS.PersistentVolume {
+				continue
+			}
+
+			size, err := resource.ParseQuantity(vol.PVCSize)
+			if err != nil {
+				return nil, err
+			}
+
+			storageClass := ""
+			if pvc.Spec.StorageClassName != nil {
+				storageClass = *pvc.Spec.StorageClassName
+			}
+
+			pvs = append(pvs, &v1.PersistentVolume{
+				TypeMeta: meta.TypeMeta{
+					Kind:       "PersistentVolume",
+					APIVersion: "v1",
+				},
+				ObjectMeta: meta.ObjectMeta{
+					Name:   pvc.Name,
+					Labels: configLabels(pvc.Name),
+				},
+				Spec: v1.PersistentVolumeSpec{
+					Capacity: v1.ResourceList{
+						v1.ResourceStorage: size,
+					},
+					AccessModes:                   pvc.Spec.AccessModes,
+					PersistentVolumeReclaimPolicy: v1.PersistentVolumeReclaimRetain,
+					StorageClassName:              storageClass,
+					VolumeMode:                    pvc.Spec.VolumeMode,
+					PersistentVolumeSource: v1.PersistentVolumeSource{
+						NFS: &v1.NFSVolumeSource{
+							Server: vol.NFS.Server,
+							Path:   vol.NFS.Path,
+						},
+					},
+				},
+			})
+			break
+		}
+	}
+
+	return pvs, nil
+}
+
+// configVolumeDevices moves container mounts of PVC volumes in `Block` mode to container volume devices,
+// as raw block volumes are exposed to the container as a device at the mount path.
+func (k *Kubernetes) configVolumeDevices(projectService ProjectService, volumeMounts []v1.VolumeMount, volumes []v1.Volume) ([]v1.VolumeMount, []v1.VolumeDevice, error) {
+	projectServiceVolumes, err := projectService.volumes(k.Project)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blockClaims := map[string]bool{}
+	for _, vol := range projectServiceVolumes {
+		if vol.VolumeMode == string(v1.PersistentVolumeBlock) {
+			blockClaims[vol.VolumeName] = true
+		}
+	}
+
+	if len(blockClaims) == 0 {
+		return volumeMounts, nil, nil
+	}
+
+	blockVolumes := map[string]bool{}
+	for _, vol := range volumes {
+		if vol.PersistentVolumeClaim != nil && blockClaims[vol.PersistentVolumeClaim.ClaimName] {
+			blockVolumes[vol.Name] = true
+		}
+	}
+
+	var mounts []v1.VolumeMount
+	var devices []v1.VolumeDevice
+	for _, mount := range volumeMounts {
+		if !blockVolumes[mount.Name] {
+			mounts = append(mounts, mount)
+			continue
+		}
+
+		devices = append(devices, v1.VolumeDevice{
+			Name:       mount.Name,
+			DevicePath: mount.MountPath,
+		})
+	}
+
+	return mounts, devices, nil
+}
+
+// configVolumeClaimTemplates converts StatefulSet PVCs of volumes with a retention policy to volume claim templates,
+// as K8s only applies the StatefulSet PVC retention policy to claims created from templates.
+func (k *Kubernetes) configVolumeClaimTemplates(projectService ProjectService, objects *[]runtime.Object) error {
+	projectServiceVolumes, err := projectService.volumes(k.Project)
+	if err != nil {
+		return err
+	}
+
+	retained := map[string]Volumes{}
+	for _, vol := range projectServiceVolumes {
+		if vol.Retention.Enabled() && vol.VFrom == "" {
+			retained[vol.VolumeName] = vol
+		}
+	}
+
+	if len(retained) == 0 {
+		return nil
+	}
+
+	var sts *v1apps.StatefulSet
+	for _, obj := range *objects {
+		if s, ok := obj.(*v1apps.StatefulSet); ok {
+			sts = s
+		}
+	}
+
+	if sts == nil {
+		log.WarnfWithFields(log.Fields{
+			"project-service": projectService.Name,
+		}, "Ignoring volume retention policy on compose project service %s as it's only supported by StatefulSet workloads", projectService.Name)
+		return nil
+	}
+
+	var out []runtime.Object
+	for _, obj := range *objects {
+		pvc, ok := obj.(*v1.PersistentVolumeClaim)
+		if !ok {
+			out = append(out, obj)
+			continue
+		}
+
+		vol, ok := retained[pvc.Name]
+		if !ok {
+			out = append(out, obj)
+			continue
+		}
+
+		// @step claims created from the template are mounted via the pod volume name
+		template := *pvc
+		template.TypeMeta = meta.TypeMeta{}
+		template.Name = removePodVolume(&sts.Spec.Template.Spec, pvc.Name)
+		sts.Spec.VolumeClaimTemplates = append(sts.Spec.VolumeClaimTemplates, template)
+
+		policy := &v1apps.StatefulSetPersistentVolumeClaimRetentionPolicy{
+			WhenDeleted: v1apps.PersistentVolumeClaimRetentionPolicyType(vol.Retention.WhenDeleted),
+			WhenScaled:  v1apps.PersistentVolumeClaimRetentionPolicyType(vol.Retention.WhenScaled),
+		}
+
+		if sts.Spec.PersistentVolumeClaimRetentionPolicy == nil {
+			sts.Spec.PersistentVolumeClaimRetentionPolicy = policy
+		} else if !reflect.DeepEqual(sts.Spec.PersistentVolumeClaimRetentionPolicy, policy) {
+			log.WarnfWithFields(log.Fields{
+				"project-service": projectService.Name,
+				"volume":          vol.VolumeName,
+			}, "Ignoring volume %s retention policy as it differs from other volumes of the StatefulSet", vol.VolumeName)
+		}
+	}
+
+	*objects = out
+
+	return nil
+}
+
+// removePodVolume removes the pod volume referencing the claim and returns its name,
+// or the claim name when the claim isn't referenced
+func removePodVolume(podSpec *v1.PodSpec, claimName string) string {
+	name := claimName
+
+	var volumes []v1.Volume
+	for _, vol := range podSpec.Volumes {
+		if vol.PersistentVolumeClaim != nil && vol.PersistentVolumeClaim.ClaimName == claimName {
+			name = vol.Name
+			continue
+		}
+		volumes = append(volumes, vol)
+	}
+	podSpec.Volumes = volumes
+
+	return name
+}
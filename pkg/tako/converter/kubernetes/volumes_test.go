@@ -0,0 +1,374 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kubernetes
+
+import (
+	"path/filepath"
+
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1apps "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+var _ = Describe("Volumes", func() {
+
+	var (
+		k            Kubernetes
+		project      composego.Project
+		workloadType config.WorkloadType
+		volumeExt    map[string]interface{}
+		driverOpts   map[string]string
+		external     bool
+		objs         []runtime.Object
+		err          error
+	)
+
+	findObject := func(kind string) runtime.Object {
+		for _, o := range objs {
+			if o.GetObjectKind().GroupVersionKind().Kind == kind {
+				return o
+			}
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		workloadType = config.StatefulSetWorkload
+		volumeExt = map[string]interface{}{"size": "1Gi"}
+		driverOpts = nil
+		external = false
+	})
+
+	JustBeforeEach(func() {
+		project = composego.Project{
+			Services: composego.Services{
+				{
+					Name:  "db",
+					Image: "postgres",
+					Volumes: []composego.ServiceVolumeConfig{
+						{Type: "volume", Source: "data", Target: "/var/lib/data"},
+					},
+					Extensions: map[string]interface{}{
+						config.K8SExtensionKey: map[string]interface{}{
+							"workload": map[string]interface{}{"type": workloadType.String()},
+						},
+					},
+				},
+			},
+			Volumes: composego.Volumes{
+				"data": composego.VolumeConfig{
+					DriverOpts: driverOpts,
+					External:   composego.External{External: external},
+					Extensions: map[string]interface{}{config.K8SExtensionKey: volumeExt},
+				},
+			},
+		}
+
+		k = Kubernetes{
+			Opt:     ConvertOptions{},
+			Project: &project,
+			UI:      kmd.NoOpUI(),
+		}
+
+		objs, err = k.Transform()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Context("with default volume mode", func() {
+		It("mounts the volume in the container", func() {
+			sts := findObject("StatefulSet").(*v1apps.StatefulSet)
+			Expect(sts.Spec.Template.Spec.Containers[0].VolumeMounts).To(HaveLen(1))
+			Expect(sts.Spec.Template.Spec.Containers[0].VolumeDevices).To(BeEmpty())
+		})
+	})
+
+	Context("with Block volume mode", func() {
+		BeforeEach(func() {
+			volumeExt["volumeMode"] = "Block"
+		})
+
+		It("exposes the volume as a container device", func() {
+			sts := findObject("StatefulSet").(*v1apps.StatefulSet)
+			Expect(sts.Spec.Template.Spec.Containers[0].VolumeMounts).To(BeEmpty())
+			Expect(sts.Spec.Template.Spec.Containers[0].VolumeDevices).To(Equal([]v1.VolumeDevice{
+				{Name: "data", DevicePath: "/var/lib/data"},
+			}))
+		})
+	})
+
+	Context("with retention policy", func() {
+		BeforeEach(func() {
+			volumeExt["retention"] = map[string]interface{}{"whenDeleted": "Delete", "whenScaled": "Retain"}
+		})
+
+		It("creates the claim from a StatefulSet volume claim template", func() {
+			Expect(findObject("PersistentVolumeClaim")).To(BeNil())
+
+			sts := findObject("StatefulSet").(*v1apps.StatefulSet)
+			Expect(sts.Spec.VolumeClaimTemplates).To(HaveLen(1))
+			Expect(sts.Spec.VolumeClaimTemplates[0].Name).To(Equal("data"))
+			Expect(sts.Spec.VolumeClaimTemplates[0].Kind).To(BeEmpty())
+			Expect(sts.Spec.Template.Spec.Volumes).To(BeEmpty())
+			Expect(sts.Spec.Template.Spec.Containers[0].VolumeMounts[0].Name).To(Equal("data"))
+		})
+
+		It("sets the StatefulSet PVC retention policy", func() {
+			sts := findObject("StatefulSet").(*v1apps.StatefulSet)
+			Expect(sts.Spec.PersistentVolumeClaimRetentionPolicy).To(Equal(&v1apps.StatefulSetPersistentVolumeClaimRetentionPolicy{
+				WhenDeleted: v1apps.DeletePersistentVolumeClaimRetentionPolicyType,
+				WhenScaled:  v1apps.RetainPersistentVolumeClaimRetentionPolicyType,
+			}))
+		})
+
+		Context("for workloads other than StatefulSet", func() {
+			BeforeEach(func() {
+				workloadType = config.DeploymentWorkload
+			})
+
+			It("keeps a standalone PVC", func() {
+				Expect(findObject("PersistentVolumeClaim")).NotTo(BeNil())
+			})
+		})
+	})
+
+	Context("with NFS local driver options", func() {
+		BeforeEach(func() {
+			driverOpts = map[string]string{"type": "nfs", "o": "addr=10.0.0.1,rw,nfsvers=4", "device": ":/exports/data"}
+		})
+
+		It("mounts the NFS share inline", func() {
+			Expect(findObject("PersistentVolumeClaim")).To(BeNil())
+
+			sts := findObject("StatefulSet").(*v1apps.StatefulSet)
+			Expect(sts.Spec.Template.Spec.Volumes).To(HaveLen(1))
+			Expect(sts.Spec.Template.Spec.Volumes[0].NFS).To(Equal(&v1.NFSVolumeSource{
+				Server: "10.0.0.1",
+				Path:   "/exports/data",
+			}))
+		})
+
+		Context("and a static persistent volume", func() {
+			BeforeEach(func() {
+				volumeExt["nfs"] = map[string]interface{}{"persistentVolume": true}
+			})
+
+			It("creates a PersistentVolume with the NFS share", func() {
+				pv := findObject("PersistentVolume").(*v1.PersistentVolume)
+				Expect(pv.Name).To(Equal("data"))
+				Expect(pv.Spec.NFS).To(Equal(&v1.NFSVolumeSource{Server: "10.0.0.1", Path: "/exports/data"}))
+				Expect(pv.Spec.AccessModes).To(Equal([]v1.PersistentVolumeAccessMode{v1.ReadWriteMany}))
+				Expect(pv.Spec.StorageClassName).To(BeEmpty())
+				Expect(pv.Spec.Capacity.Storage().String()).To(Equal("1Gi"))
+			})
+
+			It("binds the claim to the PersistentVolume", func() {
+				pvc := findObject("PersistentVolumeClaim").(*v1.PersistentVolumeClaim)
+				Expect(pvc.Spec.VolumeName).To(Equal("data"))
+				Expect(*pvc.Spec.StorageClassName).To(BeEmpty())
+				Expect(pvc.Spec.AccessModes).To(Equal([]v1.PersistentVolumeAccessMode{v1.ReadWriteMany}))
+			})
+		})
+	})
+
+	Context("with CSI inline volume", func() {
+		BeforeEach(func() {
+			volumeExt["csi"] = map[string]interface{}{
+				"driver":            "secrets-store.csi.k8s.io",
+				"readOnly":          true,
+				"volumeAttributes":  map[string]interface{}{"secretProviderClass": "vault"},
+				"nodePublishSecret": "creds",
+			}
+		})
+
+		It("mounts the CSI volume inline", func() {
+			Expect(findObject("PersistentVolumeClaim")).To(BeNil())
+
+			ro := true
+			sts := findObject("StatefulSet").(*v1apps.StatefulSet)
+			Expect(sts.Spec.Template.Spec.Volumes[0].CSI).To(Equal(&v1.CSIVolumeSource{
+				Driver:               "secrets-store.csi.k8s.io",
+				ReadOnly:             &ro,
+				VolumeAttributes:     map[string]string{"secretProviderClass": "vault"},
+				NodePublishSecretRef: &v1.LocalObjectReference{Name: "creds"},
+			}))
+		})
+	})
+
+	Context("with CSI ephemeral volume", func() {
+		BeforeEach(func() {
+			volumeExt["storageClass"] = "fast"
+			volumeExt["csi"] = map[string]interface{}{"ephemeral": true}
+		})
+
+		It("provisions a generic ephemeral volume via the storage class", func() {
+			Expect(findObject("PersistentVolumeClaim")).To(BeNil())
+
+			sts := findObject("StatefulSet").(*v1apps.StatefulSet)
+			ephemeral := sts.Spec.Template.Spec.Volumes[0].Ephemeral
+			Expect(ephemeral).NotTo(BeNil())
+			Expect(*ephemeral.VolumeClaimTemplate.Spec.StorageClassName).To(Equal("fast"))
+			Expect(ephemeral.VolumeClaimTemplate.Spec.Resources.Requests.Storage().String()).To(Equal("1Gi"))
+		})
+	})
+
+	Context("with external volume", func() {
+		BeforeEach(func() {
+			external = true
+		})
+
+		It("references the existing claim without creating one", func() {
+			Expect(findObject("PersistentVolumeClaim")).To(BeNil())
+
+			sts := findObject("StatefulSet").(*v1apps.StatefulSet)
+			Expect(sts.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal("data"))
+		})
+	})
+
+	Describe("bind mounts", func() {
+		var (
+			projectExt  map[string]interface{}
+			bindMounts  map[string]interface{}
+			bindObjects []runtime.Object
+		)
+
+		BeforeEach(func() {
+			projectExt = nil
+			bindMounts = nil
+		})
+
+		JustBeforeEach(func() {
+			configDir, err := filepath.Abs("../../testdata/converter/kubernetes/configmaps")
+			Expect(err).NotTo(HaveOccurred())
+
+			workload := map[string]interface{}{"type": config.DeploymentWorkload.String()}
+			if bindMounts != nil {
+				workload["bindMounts"] = bindMounts
+			}
+
+			bindProject := composego.Project{
+				Services: composego.Services{
+					{
+						Name:  "web",
+						Image: "nginx",
+						Volumes: []composego.ServiceVolumeConfig{
+							{Type: "bind", Source: configDir, Target: "/usr/share/nginx/html"},
+						},
+						Extensions: map[string]interface{}{
+							config.K8SExtensionKey: map[string]interface{}{"workload": workload},
+						},
+					},
+				},
+			}
+			if projectExt != nil {
+				bindProject.Extensions = map[string]interface{}{config.K8SExtensionKey: projectExt}
+			}
+
+			bk := Kubernetes{
+				Opt:     ConvertOptions{},
+				Project: &bindProject,
+				UI:      kmd.NoOpUI(),
+			}
+
+			bindObjects, err = bk.Transform()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		podVolumes := func() []v1.Volume {
+			for _, o := range bindObjects {
+				if d, ok := o.(*v1apps.Deployment); ok {
+					return d.Spec.Template.Spec.Volumes
+				}
+			}
+			return nil
+		}
+
+		kinds := func() []string {
+			var out []string
+			for _, o := range bindObjects {
+				out = append(out, o.GetObjectKind().GroupVersionKind().Kind)
+			}
+			return out
+		}
+
+		Context("without a strategy", func() {
+			It("claims the bind mount as a PVC named after the mount", func() {
+				Expect(kinds()).To(ContainElement("PersistentVolumeClaim"))
+				Expect(podVolumes()).To(HaveLen(1))
+				Expect(podVolumes()[0].PersistentVolumeClaim.ClaimName).To(Equal("web-claim0"))
+
+				for _, o := range bindObjects {
+					if pvc, ok := o.(*v1.PersistentVolumeClaim); ok {
+						Expect(pvc.Name).To(Equal("web-claim0"))
+					}
+				}
+			})
+		})
+
+		Context("with project wide hostPath strategy", func() {
+			BeforeEach(func() {
+				projectExt = map[string]interface{}{"bindMounts": "hostPath"}
+			})
+
+			It("mounts the host path", func() {
+				Expect(kinds()).NotTo(ContainElement("PersistentVolumeClaim"))
+				Expect(podVolumes()).To(HaveLen(1))
+				Expect(podVolumes()[0].Name).To(Equal("web-hostpath0"))
+				Expect(podVolumes()[0].HostPath).NotTo(BeNil())
+			})
+		})
+
+		Context("with project wide emptyDir strategy", func() {
+			BeforeEach(func() {
+				projectExt = map[string]interface{}{"bindMounts": "emptyDir"}
+			})
+
+			It("mounts an empty dir", func() {
+				Expect(podVolumes()).To(HaveLen(1))
+				Expect(podVolumes()[0].EmptyDir).NotTo(BeNil())
+			})
+		})
+
+		Context("with configMap strategy set for the mount", func() {
+			BeforeEach(func() {
+				projectExt = map[string]interface{}{"bindMounts": "hostPath"}
+				bindMounts = map[string]interface{}{"/usr/share/nginx/html": "configMap"}
+			})
+
+			It("mounts the host directory content from a ConfigMap", func() {
+				Expect(kinds()).To(ContainElement("ConfigMap"))
+				Expect(podVolumes()).To(HaveLen(1))
+				Expect(podVolumes()[0].ConfigMap).NotTo(BeNil())
+			})
+		})
+
+		Context("with none strategy set for the mount", func() {
+			BeforeEach(func() {
+				bindMounts = map[string]interface{}{"/usr/share/nginx/html": "none"}
+			})
+
+			It("drops the bind mount", func() {
+				Expect(kinds()).NotTo(ContainElement("PersistentVolumeClaim"))
+				Expect(podVolumes()).To(BeEmpty())
+			})
+		})
+	})
+})
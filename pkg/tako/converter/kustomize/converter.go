@@ -0,0 +1,195 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kustomize
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/converter/kubernetes"
+	"github.com/appvia/tako/pkg/tako/log"
+	composego "github.com/compose-spec/compose-go/types"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Name of the converter
+	Name = "kustomize"
+
+	// MultiFileSubDir is default output directory name for kustomize base and overlays
+	MultiFileSubDir = "kustomize"
+
+	baseDir     = "base"
+	overlaysDir = "overlays"
+)
+
+// Kustomize is a kustomize converter. It renders a base from the compose sources
+// and an overlay per environment holding the environment differences only.
+type Kustomize struct {
+	UI kmd.UI
+
+	// SecretMatchers are used to classify env vars and env_file contents as secret
+	SecretMatchers []map[string]string
+	// SecretAllowlist holds env var names of compose services which aren't secrets
+	SecretAllowlist map[string][]string
+	// Sources is the compose sources project, before any environment overrides are merged into it
+	Sources *composego.Project
+}
+
+// New return a kustomize converter
+func New() *Kustomize {
+	return &Kustomize{}
+}
+
+// NewWithUI returns a kustomize converter using the given UI
+func NewWithUI(ui kmd.UI) *Kustomize {
+	return &Kustomize{UI: ui}
+}
+
+// Render generates outcome
+func (c *Kustomize) Render(singleFile bool,
+	dir, workDir string,
+	projects map[string]*composego.Project,
+	files map[string][]string,
+	additionalFiles []string,
+	rendered map[string][]byte,
+	excluded map[string][]string) (map[string]string, error) {
+
+	if singleFile {
+		log.Warnf("Single file output isn't supported by %s format, rendering base and overlays directories instead", Name)
+	}
+
+	envs := kubernetes.SortedEnvs(projects)
+	if len(envs) == 0 {
+		return nil, nil
+	}
+
+	// @step override output directory if specified
+	outDirPath := filepath.Join(workDir, MultiFileSubDir)
+	if dir != "" {
+		outDirPath = dir
+	}
+
+	// @step render base from compose sources, i.e. environment files excluded
+	if c.Sources == nil {
+		return nil, errors.New("Could not render base, compose sources project isn't set")
+	}
+	sources := files[envs[0]][:len(files[envs[0]])-1]
+	base, err := c.objects(c.Sources, sources, nil, additionalFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	baseFiles, err := baseKustomization(base)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := writeFiles(filepath.Join(outDirPath, baseDir), baseFiles); err != nil {
+		return nil, err
+	}
+
+	// @step render overlay of each environment
+	renderOutputPaths := map[string]string{}
+	for _, env := range envs {
+		log.Debugf("Rendering environment [%s]", env)
+
+		envFile := files[env][len(files[env])-1]
+		c.UI.Output(fmt.Sprintf("%s: %s", env, envFile))
+
+		objects, err := c.objects(projects[env], files[env], excluded[env], additionalFiles)
+		if err != nil {
+			return nil, err
+		}
+
+		overlayFiles, err := newOverlay(base, objects).files()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Could not generate %s overlay for environment %s, details:\n", Name, env)
+		}
+
+		overlayDir := filepath.Join(outDirPath, overlaysDir, env)
+		if err := writeFiles(overlayDir, overlayFiles); err != nil {
+			return nil, err
+		}
+		renderOutputPaths[env] = overlayDir
+
+		// overlay patches aren't valid manifests, so full objects of the environment are recorded against its kustomization
+		if rendered[filepath.Join(overlayDir, kustomizationFile)], err = manifestStream(objects); err != nil {
+			return nil, err
+		}
+	}
+
+	return renderOutputPaths, nil
+}
+
+// objects returns the kubernetes objects of a project, as maps.
+func (c *Kustomize) objects(project *composego.Project, files, excluded, additionalFiles []string) ([]map[string]interface{}, error) {
+	// @step Get Kubernetes transformer that maps compose project to Kubernetes primitives
+	k := &kubernetes.Kubernetes{
+		Opt:             kubernetes.ConvertOptions{InputFiles: files},
+		Project:         project,
+		Excluded:        excluded,
+		UI:              c.UI,
+		SecretMatchers:  c.SecretMatchers,
+		SecretAllowlist: c.SecretAllowlist,
+	}
+
+	// @step Do the transformation
+	return k.ObjectMaps(additionalFiles)
+}
+
+// writeFiles replaces contents of the directory with the given files
+func writeFiles(dir string, files map[string][]byte) error {
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	var names []string
+	for f := range files {
+		names = append(names, f)
+	}
+	sort.Strings(names)
+
+	for _, f := range names {
+		file := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(file, files[f], 0644); err != nil {
+			return errors.Wrapf(err, "Could not render %s files to disk, details:\n", Name)
+		}
+	}
+
+	return nil
+}
+
+// manifestStream returns objects as a multi document YAML manifest
+func manifestStream(objects []map[string]interface{}) ([]byte, error) {
+	var docs [][]byte
+	for _, o := range objects {
+		data, err := encode(o)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, data)
+	}
+	return bytes.Join(docs, []byte("---\n")), nil
+}
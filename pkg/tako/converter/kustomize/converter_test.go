@@ -0,0 +1,203 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kustomize
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	composego "github.com/compose-spec/compose-go/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/kustomize/api/filesys"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+var _ = Describe("Kustomize", func() {
+
+	var (
+		c        *Kustomize
+		workDir  string
+		outDir   string
+		projects map[string]*composego.Project
+		files    map[string][]string
+		excluded map[string][]string
+		rendered map[string][]byte
+	)
+
+	compose := `version: "3.7"
+services:
+  web:
+    image: nginx:1.21
+    ports:
+      - 8080:80
+    environment:
+      LOG_LEVEL: info
+  db:
+    image: postgres:13
+`
+
+	// envProject returns the compose sources project with environment overrides
+	envProject := func(services map[string]map[string]interface{}, images map[string]string) *composego.Project {
+		p, err := loadProject(filepath.Join(workDir, "docker-compose.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		var overridden composego.Services
+		for _, svc := range p.Services {
+			ext, ok := services[svc.Name]
+			if !ok {
+				continue
+			}
+			if img, ok := images[svc.Name]; ok {
+				svc.Image = img
+			}
+			svc.Extensions = map[string]interface{}{config.K8SExtensionKey: ext}
+			overridden = append(overridden, svc)
+		}
+		p.Services = overridden
+		return p
+	}
+
+	BeforeEach(func() {
+		c = NewWithUI(kmd.NoOpUI())
+
+		var err error
+		workDir, err = os.MkdirTemp("", "kustomize")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(os.WriteFile(filepath.Join(workDir, "docker-compose.yaml"), []byte(compose), 0644)).To(Succeed())
+		outDir = ""
+
+		c.Sources, err = loadProject(filepath.Join(workDir, "docker-compose.yaml"))
+		Expect(err).NotTo(HaveOccurred())
+
+		projects = map[string]*composego.Project{
+			"dev": envProject(map[string]map[string]interface{}{
+				"web": {},
+				"db":  {},
+			}, nil),
+			"prod": envProject(map[string]map[string]interface{}{
+				"web": {
+					"workload": map[string]interface{}{
+						"replicas": 3,
+						"resource": map[string]interface{}{"memory": "512Mi"},
+					},
+					"service": map[string]interface{}{
+						"expose": map[string]interface{}{"domain": "www.example.com"},
+					},
+				},
+			}, map[string]string{"web": "nginx:1.23"}),
+		}
+
+		files = map[string][]string{
+			"dev":  {filepath.Join(workDir, "docker-compose.yaml"), filepath.Join(workDir, "docker-compose.env.dev.yaml")},
+			"prod": {filepath.Join(workDir, "docker-compose.yaml"), filepath.Join(workDir, "docker-compose.env.prod.yaml")},
+		}
+		excluded = map[string][]string{}
+		rendered = map[string][]byte{}
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(workDir)).To(Succeed())
+	})
+
+	Describe("Render", func() {
+
+		var outputPaths map[string]string
+		var renderErr error
+
+		JustBeforeEach(func() {
+			outputPaths, renderErr = c.Render(false, outDir, workDir, projects, files, nil, rendered, excluded)
+		})
+
+		It("writes base and an overlay for each environment", func() {
+			Expect(renderErr).NotTo(HaveOccurred())
+
+			for _, f := range []string{"base/kustomization.yaml", "base/web-deployment.yaml", "overlays/dev/kustomization.yaml", "overlays/prod/kustomization.yaml"} {
+				Expect(filepath.Join(workDir, MultiFileSubDir, f)).To(BeAnExistingFile())
+			}
+
+			Expect(outputPaths).To(Equal(map[string]string{
+				"dev":  filepath.Join(workDir, MultiFileSubDir, "overlays", "dev"),
+				"prod": filepath.Join(workDir, MultiFileSubDir, "overlays", "prod"),
+			}))
+		})
+
+		It("records full objects of each environment against its overlay kustomization", func() {
+			Expect(renderErr).NotTo(HaveOccurred())
+			Expect(rendered).To(HaveLen(2))
+
+			for _, env := range []string{"dev", "prod"} {
+				objects, err := c.objects(projects[env], files[env], excluded[env], nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				manifest := rendered[filepath.Join(outputPaths[env], "kustomization.yaml")]
+				var recorded []map[string]interface{}
+				for _, doc := range strings.Split(string(manifest), "---\n") {
+					var obj map[string]interface{}
+					Expect(sigsyaml.Unmarshal([]byte(doc), &obj)).To(Succeed())
+					recorded = append(recorded, obj)
+				}
+				Expect(expectedObjects(recorded)).To(Equal(expectedObjects(objects)))
+			}
+		})
+
+		It("builds overlays to the same objects as kubernetes manifests for each environment", func() {
+			Expect(renderErr).NotTo(HaveOccurred())
+
+			for _, env := range []string{"dev", "prod"} {
+				objects, err := c.objects(projects[env], files[env], excluded[env], nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				out, err := build(filesys.MakeFsOnDisk(), outputPaths[env])
+				Expect(err).NotTo(HaveOccurred())
+				Expect(out).To(Equal(expectedObjects(objects)), env)
+			}
+		})
+
+		It("only keeps environment differences in overlays", func() {
+			Expect(renderErr).NotTo(HaveOccurred())
+
+			k, err := os.ReadFile(filepath.Join(outputPaths["dev"], kustomizationFile))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(k)).To(Equal("apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n  - ../../base\n"))
+
+			k, err = os.ReadFile(filepath.Join(outputPaths["prod"], kustomizationFile))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(k)).To(ContainSubstring("images:\n  - name: nginx\n    newTag: \"1.23\"\n"))
+			Expect(string(k)).To(ContainSubstring("replicas:\n  - name: web\n    count: 3\n"))
+			Expect(string(k)).To(ContainSubstring("  - web-ingress.yaml\n"))
+			Expect(string(k)).To(ContainSubstring("$patch: delete"))
+			Expect(filepath.Join(outputPaths["prod"], patchesDir, "web-deployment.yaml")).To(BeAnExistingFile())
+		})
+
+		When("output directory is specified", func() {
+
+			BeforeEach(func() {
+				outDir = filepath.Join(workDir, "deploy")
+			})
+
+			It("writes base and overlays to that directory", func() {
+				Expect(renderErr).NotTo(HaveOccurred())
+				Expect(filepath.Join(outDir, "base", kustomizationFile)).To(BeAnExistingFile())
+				Expect(outputPaths["dev"]).To(Equal(filepath.Join(outDir, "overlays", "dev")))
+			})
+		})
+	})
+})
@@ -0,0 +1,93 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kustomize
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/compose-spec/compose-go/cli"
+	composego "github.com/compose-spec/compose-go/types"
+	"sigs.k8s.io/kustomize/api/filesys"
+	"sigs.k8s.io/kustomize/api/krusty"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// loadProject loads a compose project from the compose file
+func loadProject(path string) (*composego.Project, error) {
+	projectOptions, err := cli.NewProjectOptions([]string{path}, cli.WithOsEnv, cli.WithDotEnv, cli.WithDiscardEnvFile)
+	if err != nil {
+		return nil, err
+	}
+	return cli.ProjectFromOptions(projectOptions)
+}
+
+// build runs kustomize build of the directory and returns built objects keyed by their file name
+func build(fs filesys.FileSystem, dir string) (map[string]interface{}, error) {
+	resources, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := resources.AsYaml()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []map[string]interface{}
+	for _, doc := range strings.Split(string(data), "\n---\n") {
+		var obj map[string]interface{}
+		if err := sigsyaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+
+	return expectedObjects(objects), nil
+}
+
+// buildFiles writes files to an in memory file system and runs kustomize build of the overlay
+func buildFiles(base, overlay map[string][]byte) (map[string]interface{}, error) {
+	fs := filesys.MakeFsInMemory()
+	for dir, files := range map[string]map[string][]byte{"/app/base": base, "/app/overlays/env": overlay} {
+		for name, data := range files {
+			if err := fs.WriteFile(filepath.Join(dir, name), data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return build(fs, "/app/overlays/env")
+}
+
+// expectedObjects returns objects keyed by their file name
+func expectedObjects(objects []map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for _, o := range objects {
+		file, _ := objectFile(o)
+		out[file] = normalise(o)
+	}
+	return out
+}
+
+// normalise returns a JSON representation of an object, making numbers comparable
+func normalise(o interface{}) interface{} {
+	data, _ := json.Marshal(o)
+	var out interface{}
+	_ = json.Unmarshal(data, &out)
+	return out
+}
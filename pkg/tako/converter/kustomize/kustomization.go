@@ -0,0 +1,169 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kustomize
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	kustomizationAPIVersion = "kustomize.config.k8s.io/v1beta1"
+	kustomizationKind       = "Kustomization"
+	kustomizationFile       = "kustomization.yaml"
+	patchesDir              = "patches"
+)
+
+// kustomization is a subset of kustomization file fields used by base and overlays
+type kustomization struct {
+	APIVersion         string            `yaml:"apiVersion"`
+	Kind               string            `yaml:"kind"`
+	Resources          []string          `yaml:"resources,omitempty"`
+	Images             []image           `yaml:"images,omitempty"`
+	Replicas           []replicas        `yaml:"replicas,omitempty"`
+	GeneratorOptions   *generatorOptions `yaml:"generatorOptions,omitempty"`
+	ConfigMapGenerator []configMapArgs   `yaml:"configMapGenerator,omitempty"`
+	Patches            []patch           `yaml:"patches,omitempty"`
+}
+
+// image overrides name, tag or digest of matching container images
+type image struct {
+	Name    string `yaml:"name"`
+	NewName string `yaml:"newName,omitempty"`
+	NewTag  string `yaml:"newTag,omitempty"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+// replicas overrides replicas of a workload
+type replicas struct {
+	Name  string `yaml:"name"`
+	Count int64  `yaml:"count"`
+}
+
+// generatorOptions control generated resources
+type generatorOptions struct {
+	DisableNameSuffixHash bool `yaml:"disableNameSuffixHash"`
+}
+
+// configMapArgs generates a config map merged into or replacing a base config map
+type configMapArgs struct {
+	Name      string   `yaml:"name"`
+	Namespace string   `yaml:"namespace,omitempty"`
+	Behavior  string   `yaml:"behavior"`
+	Literals  []string `yaml:"literals,omitempty"`
+}
+
+// patch is a JSON6902 patch file or an inline strategic merge patch
+type patch struct {
+	Path   string  `yaml:"path,omitempty"`
+	Patch  string  `yaml:"patch,omitempty"`
+	Target *target `yaml:"target,omitempty"`
+}
+
+// target selects a patched object
+type target struct {
+	Group     string `yaml:"group,omitempty"`
+	Version   string `yaml:"version"`
+	Kind      string `yaml:"kind"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// newKustomization returns a kustomization with the given resources
+func newKustomization(resources ...string) *kustomization {
+	return &kustomization{
+		APIVersion: kustomizationAPIVersion,
+		Kind:       kustomizationKind,
+		Resources:  resources,
+	}
+}
+
+// baseKustomization returns base files, i.e. objects and the kustomization listing them
+func baseKustomization(objects []map[string]interface{}) (map[string][]byte, error) {
+	out := map[string][]byte{}
+	k := newKustomization()
+
+	for _, o := range objects {
+		file, err := objectFile(o)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := encode(o)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := out[file]; !ok {
+			k.Resources = append(k.Resources, file)
+		}
+		out[file] = data
+	}
+
+	data, err := encode(k)
+	if err != nil {
+		return nil, err
+	}
+	out[kustomizationFile] = data
+
+	return out, nil
+}
+
+// objectFile returns the object file name, as used by kubernetes manifests
+func objectFile(o map[string]interface{}) (string, error) {
+	kind, name, _ := objectID(o)
+	if kind == "" || name == "" {
+		return "", errors.New("kubernetes object is missing kind or metadata name")
+	}
+	return fmt.Sprintf("%s-%s.yaml", name, strings.ToLower(kind)), nil
+}
+
+// objectID returns the object kind, name and namespace
+func objectID(o map[string]interface{}) (string, string, string) {
+	kind, _ := o["kind"].(string)
+	metadata, _ := o["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	return kind, name, namespace
+}
+
+// objectTarget returns the patch target selecting the object
+func objectTarget(o map[string]interface{}) *target {
+	kind, name, namespace := objectID(o)
+	apiVersion, _ := o["apiVersion"].(string)
+
+	t := &target{Version: apiVersion, Kind: kind, Name: name, Namespace: namespace}
+	if i := strings.LastIndex(apiVersion, "/"); i >= 0 {
+		t.Group, t.Version = apiVersion[:i], apiVersion[i+1:]
+	}
+	return t
+}
+
+// encode returns YAML encoded data
+func encode(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := yaml.NewEncoder(&b)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,577 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kustomize
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// containerPaths are object paths of containers which images are updated by kustomize images
+	containerPaths = [][]string{
+		{"spec", "containers"},
+		{"spec", "initContainers"},
+		{"spec", "template", "spec", "containers"},
+		{"spec", "template", "spec", "initContainers"},
+	}
+
+	// scalableKinds are kinds which replicas are updated by kustomize replicas
+	scalableKinds = map[string]bool{
+		"Deployment":            true,
+		"ReplicationController": true,
+		"ReplicaSet":            true,
+		"StatefulSet":           true,
+	}
+)
+
+// overlay holds base and environment objects keyed by their file name
+type overlay struct {
+	base      map[string]map[string]interface{}
+	baseOrder []string
+	env       map[string]map[string]interface{}
+	envOrder  []string
+}
+
+// newOverlay returns an overlay turning base objects into environment objects
+func newOverlay(base, env []map[string]interface{}) *overlay {
+	o := &overlay{
+		base: map[string]map[string]interface{}{},
+		env:  map[string]map[string]interface{}{},
+	}
+	o.baseOrder = indexObjects(base, o.base)
+	o.envOrder = indexObjects(env, o.env)
+	return o
+}
+
+// files returns overlay files. Environment objects missing in base are added as resources, base objects
+// missing in the environment are deleted, and other differences are expressed as images, replicas,
+// config map generators and JSON6902 patches, in that order of preference.
+func (o *overlay) files() (map[string][]byte, error) {
+	out := map[string][]byte{}
+	k := newKustomization(path.Join("..", "..", baseDir))
+
+	// @step add environment only objects
+	for _, file := range o.envOrder {
+		if _, ok := o.base[file]; ok {
+			continue
+		}
+
+		data, err := encode(o.env[file])
+		if err != nil {
+			return nil, err
+		}
+		out[file] = data
+		k.Resources = append(k.Resources, file)
+	}
+
+	// @step delete base only objects, work on copies of the others
+	kept := map[string]map[string]interface{}{}
+	var keptOrder []string
+	for _, file := range o.baseOrder {
+		if _, ok := o.env[file]; !ok {
+			p, err := deletePatch(o.base[file])
+			if err != nil {
+				return nil, err
+			}
+			k.Patches = append(k.Patches, patch{Patch: p})
+			continue
+		}
+		kept[file] = deepCopy(o.base[file]).(map[string]interface{})
+		keptOrder = append(keptOrder, file)
+	}
+
+	k.Images = o.images(kept, keptOrder)
+	k.Replicas = o.replicas(kept, keptOrder)
+	k.ConfigMapGenerator = o.configMaps(kept, keptOrder)
+	if len(k.ConfigMapGenerator) > 0 {
+		// generated config maps replace base ones, so their names must be kept
+		k.GeneratorOptions = &generatorOptions{DisableNameSuffixHash: true}
+	}
+
+	// @step patch remaining differences
+	for _, file := range keptOrder {
+		ops := jsonPatch("", kept[file], o.env[file])
+		if len(ops) == 0 {
+			continue
+		}
+
+		data, err := encode(ops)
+		if err != nil {
+			return nil, err
+		}
+		patchFile := path.Join(patchesDir, file)
+		out[patchFile] = data
+		k.Patches = append(k.Patches, patch{Path: patchFile, Target: objectTarget(o.base[file])})
+	}
+
+	data, err := encode(k)
+	if err != nil {
+		return nil, err
+	}
+	out[kustomizationFile] = data
+
+	return out, nil
+}
+
+// images returns images overrides and applies them to kept objects. An image is only overridden when
+// all changed containers referencing it are changed the same way in the environment.
+func (o *overlay) images(kept map[string]map[string]interface{}, keptOrder []string) []image {
+	candidates := map[string]*image{}
+	invalid := map[string]bool{}
+	var names []string
+
+	// @step collect image changes of the corresponding containers
+	for _, file := range keptOrder {
+		baseContainers := containers(kept[file])
+		envContainers := containers(o.env[file])
+		for id, c := range baseContainers {
+			from, _ := c["image"].(string)
+			to, _ := envContainers[id]["image"].(string)
+			if from == "" || to == "" || from == to {
+				continue
+			}
+
+			i, ok := imageOverride(from, to)
+			if !ok {
+				invalid[imageName(from)] = true
+				continue
+			}
+			if existing, ok := candidates[i.Name]; ok && !reflect.DeepEqual(*existing, i) {
+				invalid[i.Name] = true
+				continue
+			}
+			if _, ok := candidates[i.Name]; !ok {
+				candidates[i.Name] = &i
+				names = append(names, i.Name)
+			}
+		}
+	}
+
+	// @step verify overrides have the expected effect on all objects
+	var out []image
+	for _, name := range names {
+		i := *candidates[name]
+		if invalid[name] || !o.imageOverrideValid(i, kept, keptOrder) {
+			continue
+		}
+
+		for _, file := range keptOrder {
+			for _, c := range containers(kept[file]) {
+				if from, ok := c["image"].(string); ok && imageMatched(from, i.Name) {
+					c["image"] = i.apply(from)
+				}
+			}
+		}
+		out = append(out, i)
+	}
+
+	return out
+}
+
+// imageOverrideValid tells whether the image override is safe to use. Kustomize updates images after patches
+// are applied, so the override must leave matching environment images unchanged. Matching images must also be
+// found on kustomize images paths only, as other paths aren't updated consistently across kustomize versions.
+func (o *overlay) imageOverrideValid(i image, kept map[string]map[string]interface{}, keptOrder []string) bool {
+	for _, obj := range append(objectList(o.env, o.envOrder), objectList(kept, keptOrder)...) {
+		matched := 0
+		for _, c := range containers(obj) {
+			if img, ok := c["image"].(string); ok && imageMatched(img, i.Name) {
+				matched++
+			}
+		}
+		if matched != countImages(obj, i.Name) {
+			return false
+		}
+	}
+
+	for _, obj := range objectList(o.env, o.envOrder) {
+		for _, c := range containers(obj) {
+			if img, ok := c["image"].(string); ok && i.apply(img) != img {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// replicas returns replicas overrides of scalable workloads and applies them to kept objects
+func (o *overlay) replicas(kept map[string]map[string]interface{}, keptOrder []string) []replicas {
+	// replicas are matched by name, so names shared by several scalable objects can't be used
+	names := map[string]int{}
+	for _, obj := range objectList(o.env, o.envOrder) {
+		if kind, name, _ := objectID(obj); scalableKinds[kind] {
+			names[name]++
+		}
+	}
+
+	var out []replicas
+	for _, file := range keptOrder {
+		kind, name, _ := objectID(kept[file])
+		if !scalableKinds[kind] || names[name] > 1 {
+			continue
+		}
+
+		spec, _ := kept[file]["spec"].(map[string]interface{})
+		envSpec, _ := o.env[file]["spec"].(map[string]interface{})
+		from, fromOk := toInt64(spec["replicas"])
+		to, toOk := toInt64(envSpec["replicas"])
+		if !fromOk || !toOk || from == to {
+			continue
+		}
+
+		spec["replicas"] = envSpec["replicas"]
+		out = append(out, replicas{Name: name, Count: to})
+	}
+
+	return out
+}
+
+// configMaps returns generators of config maps which only differ in data and applies them to kept objects.
+// Generators merge changed data into base config maps, or replace them when keys are removed.
+func (o *overlay) configMaps(kept map[string]map[string]interface{}, keptOrder []string) []configMapArgs {
+	var out []configMapArgs
+	for _, file := range keptOrder {
+		base, env := kept[file], o.env[file]
+		kind, name, namespace := objectID(base)
+		if kind != "ConfigMap" || base["apiVersion"] != "v1" || reflect.DeepEqual(base, env) {
+			continue
+		}
+		if _, ok := base["binaryData"]; ok {
+			continue
+		}
+		if !reflect.DeepEqual(withoutKey(base, "data"), withoutKey(env, "data")) {
+			continue
+		}
+
+		baseData, _ := base["data"].(map[string]interface{})
+		envData, ok := env["data"].(map[string]interface{})
+		if !ok || len(envData) == 0 {
+			continue
+		}
+
+		behavior := "merge"
+		for key := range baseData {
+			if _, ok := envData[key]; !ok {
+				behavior = "replace"
+			}
+		}
+
+		var keys []string
+		for key, value := range envData {
+			if behavior == "merge" && reflect.DeepEqual(baseData[key], value) {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		literals, ok := configMapLiterals(keys, envData)
+		if !ok {
+			continue
+		}
+
+		base["data"] = deepCopy(envData)
+		out = append(out, configMapArgs{Name: name, Namespace: namespace, Behavior: behavior, Literals: literals})
+	}
+
+	return out
+}
+
+// configMapLiterals returns key=value literals, as long as kustomize reads them back unchanged
+func configMapLiterals(keys []string, data map[string]interface{}) ([]string, bool) {
+	var out []string
+	for _, key := range keys {
+		value, ok := data[key].(string)
+		if !ok || key == "" || strings.Contains(key, "=") || strings.Trim(value, `"'`) != value {
+			return nil, false
+		}
+		out = append(out, fmt.Sprintf("%s=%s", key, value))
+	}
+	return out, true
+}
+
+// deletePatch returns a strategic merge patch deleting the object
+func deletePatch(o map[string]interface{}) (string, error) {
+	kind, name, namespace := objectID(o)
+	metadata := map[string]interface{}{"name": name}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+
+	data, err := encode(map[string]interface{}{
+		"apiVersion": o["apiVersion"],
+		"kind":       kind,
+		"metadata":   metadata,
+		"$patch":     "delete",
+	})
+	return string(data), err
+}
+
+// jsonPatch returns JSON6902 operations turning from into to
+func jsonPatch(pointer string, from, to interface{}) []map[string]interface{} {
+	fromMap, fromIsMap := from.(map[string]interface{})
+	toMap, toIsMap := to.(map[string]interface{})
+	if fromIsMap && toIsMap {
+		var ops []map[string]interface{}
+		for _, key := range sortedKeys(fromMap, toMap) {
+			keyPointer := pointer + "/" + escapePointer(key)
+			fromValue, inFrom := fromMap[key]
+			toValue, inTo := toMap[key]
+			switch {
+			case !inTo:
+				ops = append(ops, map[string]interface{}{"op": "remove", "path": keyPointer})
+			case !inFrom:
+				ops = append(ops, map[string]interface{}{"op": "add", "path": keyPointer, "value": toValue})
+			default:
+				ops = append(ops, jsonPatch(keyPointer, fromValue, toValue)...)
+			}
+		}
+		return ops
+	}
+
+	fromList, fromIsList := from.([]interface{})
+	toList, toIsList := to.([]interface{})
+	if fromIsList && toIsList && len(fromList) == len(toList) {
+		var ops []map[string]interface{}
+		for i := range fromList {
+			ops = append(ops, jsonPatch(fmt.Sprintf("%s/%d", pointer, i), fromList[i], toList[i])...)
+		}
+		return ops
+	}
+
+	if reflect.DeepEqual(from, to) {
+		return nil
+	}
+	return []map[string]interface{}{{"op": "replace", "path": pointer, "value": to}}
+}
+
+// escapePointer escapes a JSON pointer reference token
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// containers returns containers found on kustomize images paths keyed by their path and position
+func containers(o map[string]interface{}) map[string]map[string]interface{} {
+	out := map[string]map[string]interface{}{}
+	for _, p := range containerPaths {
+		var node interface{} = o
+		for _, key := range p {
+			m, _ := node.(map[string]interface{})
+			node = m[key]
+		}
+
+		list, _ := node.([]interface{})
+		for i, item := range list {
+			if c, ok := item.(map[string]interface{}); ok {
+				out[fmt.Sprintf("%s/%d", strings.Join(p, "/"), i)] = c
+			}
+		}
+	}
+	return out
+}
+
+// countImages returns number of container images matching the image name, found anywhere in the object
+func countImages(node interface{}, name string) int {
+	count := 0
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, v := range n {
+			if list, ok := v.([]interface{}); ok && (key == "containers" || key == "initContainers") {
+				for _, item := range list {
+					c, _ := item.(map[string]interface{})
+					if img, ok := c["image"].(string); ok && imageMatched(img, name) {
+						count++
+					}
+				}
+			}
+			count += countImages(v, name)
+		}
+	case []interface{}:
+		for _, v := range n {
+			count += countImages(v, name)
+		}
+	}
+	return count
+}
+
+// imageOverride returns an override turning one image into the other
+func imageOverride(from, to string) (image, bool) {
+	fromName, fromTag := splitImage(from)
+	toName, toTag := splitImage(to)
+
+	i := image{Name: fromName}
+	if toName != fromName {
+		i.NewName = toName
+	}
+	switch {
+	case toTag == fromTag:
+	case strings.HasPrefix(toTag, ":"):
+		i.NewTag = toTag[1:]
+	case strings.HasPrefix(toTag, "@"):
+		i.Digest = toTag[1:]
+	default:
+		// a tag can't be removed by an override
+		return image{}, false
+	}
+
+	return i, i.apply(from) == to
+}
+
+// apply returns the image updated by the override, the way kustomize does
+func (i image) apply(img string) string {
+	if !imageMatched(img, i.Name) {
+		return img
+	}
+
+	name, tag := splitImage(img)
+	if i.NewName != "" {
+		name = i.NewName
+	}
+	if i.NewTag != "" {
+		tag = ":" + i.NewTag
+	}
+	if i.Digest != "" {
+		tag = "@" + i.Digest
+	}
+	return name + tag
+}
+
+// imageName returns the image name without tag or digest
+func imageName(img string) string {
+	name, _ := splitImage(img)
+	return name
+}
+
+// imageMatched tells whether the image has the given name, the way kustomize does
+func imageMatched(img, name string) bool {
+	pattern, err := regexp.Compile("^" + name + "(@sha256)?(:[a-zA-Z0-9_.{}-]*)?$")
+	return err == nil && pattern.MatchString(img)
+}
+
+// splitImage separates the image name and tag, or digest, the way kustomize does.
+// The returned tag keeps its separator.
+func splitImage(img string) (string, string) {
+	ic := -1
+	if slashIndex := strings.Index(img, "/"); slashIndex < 0 {
+		ic = strings.LastIndex(img, ":")
+	} else if lastIc := strings.LastIndex(img[slashIndex:], ":"); lastIc > 0 {
+		ic = slashIndex + lastIc
+	}
+
+	ia := strings.LastIndex(img, "@")
+	if ic < 0 && ia < 0 {
+		return img, ""
+	}
+
+	i := ic
+	if ia > 0 {
+		i = ia
+	}
+	return img[:i], img[i:]
+}
+
+// indexObjects indexes objects by their file name and returns the file names in order
+func indexObjects(objects []map[string]interface{}, index map[string]map[string]interface{}) []string {
+	var order []string
+	for _, obj := range objects {
+		file, err := objectFile(obj)
+		if err != nil {
+			continue
+		}
+		if _, ok := index[file]; !ok {
+			order = append(order, file)
+		}
+		index[file] = obj
+	}
+	return order
+}
+
+// objectList returns indexed objects in order
+func objectList(index map[string]map[string]interface{}, order []string) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, file := range order {
+		out = append(out, index[file])
+	}
+	return out
+}
+
+// withoutKey returns a shallow copy of the map without the key
+func withoutKey(m map[string]interface{}, key string) map[string]interface{} {
+	out := map[string]interface{}{}
+	for k, v := range m {
+		if k != key {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// sortedKeys returns sorted keys of both maps
+func sortedKeys(a, b map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, m := range []map[string]interface{}{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				out = append(out, k)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// deepCopy returns a copy of maps and lists nested in the node
+func deepCopy(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(n))
+		for k, v := range n {
+			out[k] = deepCopy(v)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(n))
+		for i, v := range n {
+			out[i] = deepCopy(v)
+		}
+		return out
+	}
+	return node
+}
+
+// toInt64 returns the number as int64
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint64:
+		return int64(n), true
+	case float64:
+		return int64(n), float64(int64(n)) == n
+	}
+	return 0, false
+}
@@ -0,0 +1,251 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kustomize
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = Describe("Overlay", func() {
+
+	deployment := func(name string, replicas int, images ...string) map[string]interface{} {
+		var containers []interface{}
+		for i, img := range images {
+			containers = append(containers, map[string]interface{}{
+				"name":  []string{"app", "sidecar"}[i],
+				"image": img,
+			})
+		}
+		return map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":   name,
+				"labels": map[string]interface{}{"network/default": "true"},
+			},
+			"spec": map[string]interface{}{
+				"replicas": replicas,
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{"containers": containers},
+				},
+			},
+		}
+	}
+
+	configMap := func(data map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata": map[string]interface{}{
+				"name":   "config",
+				"labels": map[string]interface{}{"service": "web"},
+			},
+			"data": data,
+		}
+	}
+
+	var base, env []map[string]interface{}
+	var k kustomization
+	var files map[string][]byte
+
+	JustBeforeEach(func() {
+		baseFiles, err := baseKustomization(base)
+		Expect(err).NotTo(HaveOccurred())
+
+		files, err = newOverlay(base, env).files()
+		Expect(err).NotTo(HaveOccurred())
+
+		k = kustomization{}
+		Expect(yaml.Unmarshal(files[kustomizationFile], &k)).To(Succeed())
+
+		out, err := buildFiles(baseFiles, files)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(out).To(Equal(expectedObjects(env)))
+	})
+
+	When("environment objects equal base objects", func() {
+
+		BeforeEach(func() {
+			base = []map[string]interface{}{deployment("web", 1, "nginx:1.21")}
+			env = []map[string]interface{}{deployment("web", 1, "nginx:1.21")}
+		})
+
+		It("only references base", func() {
+			Expect(k.Resources).To(Equal([]string{"../../base"}))
+			Expect(k.Images).To(BeEmpty())
+			Expect(k.Replicas).To(BeEmpty())
+			Expect(k.Patches).To(BeEmpty())
+		})
+	})
+
+	When("environment changes images and replicas", func() {
+
+		BeforeEach(func() {
+			base = []map[string]interface{}{deployment("web", 1, "nginx:1.21", "registry:5000/proxy:v1")}
+			env = []map[string]interface{}{deployment("web", 3, "nginx@sha256:abc", "registry:5000/envoy:v2")}
+		})
+
+		It("overrides images and replicas", func() {
+			Expect(k.Images).To(ConsistOf(
+				image{Name: "nginx", Digest: "sha256:abc"},
+				image{Name: "registry:5000/proxy", NewName: "registry:5000/envoy", NewTag: "v2"},
+			))
+			Expect(k.Replicas).To(Equal([]replicas{{Name: "web", Count: 3}}))
+			Expect(k.Patches).To(BeEmpty())
+		})
+	})
+
+	When("containers sharing an image are changed differently", func() {
+
+		BeforeEach(func() {
+			base = []map[string]interface{}{deployment("web", 1, "nginx:1.21", "nginx:1.21")}
+			env = []map[string]interface{}{deployment("web", 1, "nginx:1.23", "nginx:1.21")}
+		})
+
+		It("patches the images", func() {
+			Expect(k.Images).To(BeEmpty())
+			Expect(k.Patches).To(HaveLen(1))
+			Expect(string(files["patches/web-deployment.yaml"])).To(ContainSubstring("path: /spec/template/spec/containers/0/image"))
+		})
+	})
+
+	When("environment image tag is removed", func() {
+
+		BeforeEach(func() {
+			base = []map[string]interface{}{deployment("web", 1, "nginx:1.21")}
+			env = []map[string]interface{}{deployment("web", 1, "nginx")}
+		})
+
+		It("patches the image", func() {
+			Expect(k.Images).To(BeEmpty())
+			Expect(k.Patches).To(HaveLen(1))
+		})
+	})
+
+	When("scalable objects share a name", func() {
+
+		BeforeEach(func() {
+			sts := deployment("web", 1, "nginx:1.21")
+			sts["kind"] = "StatefulSet"
+			base = []map[string]interface{}{deployment("web", 1, "nginx:1.21"), sts}
+
+			envSts := deployment("web", 1, "nginx:1.21")
+			envSts["kind"] = "StatefulSet"
+			env = []map[string]interface{}{deployment("web", 2, "nginx:1.21"), envSts}
+		})
+
+		It("patches the replicas", func() {
+			Expect(k.Replicas).To(BeEmpty())
+			Expect(string(files["patches/web-deployment.yaml"])).To(ContainSubstring("path: /spec/replicas"))
+		})
+	})
+
+	When("environment adds, changes and removes fields", func() {
+
+		BeforeEach(func() {
+			b := deployment("web", 1, "nginx:1.21")
+			b["metadata"].(map[string]interface{})["annotations"] = map[string]interface{}{"a/b~c": "x"}
+
+			e := deployment("web", 1, "nginx:1.21")
+			e["metadata"].(map[string]interface{})["labels"] = map[string]interface{}{"network/default": "false", "tier": "web"}
+
+			base = []map[string]interface{}{b}
+			env = []map[string]interface{}{e}
+		})
+
+		It("patches the object", func() {
+			Expect(k.Patches).To(Equal([]patch{{
+				Path:   "patches/web-deployment.yaml",
+				Target: &target{Group: "apps", Version: "v1", Kind: "Deployment", Name: "web"},
+			}}))
+			Expect(string(files["patches/web-deployment.yaml"])).To(ContainSubstring("path: /metadata/labels/network~1default"))
+		})
+	})
+
+	When("objects are added or removed in the environment", func() {
+
+		BeforeEach(func() {
+			base = []map[string]interface{}{deployment("web", 1, "nginx:1.21"), deployment("worker", 1, "nginx:1.21")}
+			env = []map[string]interface{}{deployment("web", 1, "nginx:1.21"), deployment("api", 1, "nginx:1.21")}
+		})
+
+		It("adds and deletes them", func() {
+			Expect(k.Resources).To(Equal([]string{"../../base", "api-deployment.yaml"}))
+			Expect(files).To(HaveKey("api-deployment.yaml"))
+			Expect(k.Patches).To(HaveLen(1))
+			Expect(k.Patches[0].Patch).To(ContainSubstring("$patch: delete"))
+		})
+	})
+
+	When("environment changes config map data", func() {
+
+		BeforeEach(func() {
+			base = []map[string]interface{}{configMap(map[string]interface{}{"a": "1", "b": "2"})}
+			env = []map[string]interface{}{configMap(map[string]interface{}{"a": "1", "b": "3", "c": "x=y"})}
+		})
+
+		It("merges changed data", func() {
+			Expect(k.GeneratorOptions).To(Equal(&generatorOptions{DisableNameSuffixHash: true}))
+			Expect(k.ConfigMapGenerator).To(Equal([]configMapArgs{{Name: "config", Behavior: "merge", Literals: []string{"b=3", "c=x=y"}}}))
+			Expect(k.Patches).To(BeEmpty())
+		})
+	})
+
+	When("environment removes config map data", func() {
+
+		BeforeEach(func() {
+			base = []map[string]interface{}{configMap(map[string]interface{}{"a": "1", "b": "2"})}
+			env = []map[string]interface{}{configMap(map[string]interface{}{"a": "1"})}
+		})
+
+		It("replaces the data", func() {
+			Expect(k.ConfigMapGenerator).To(Equal([]configMapArgs{{Name: "config", Behavior: "replace", Literals: []string{"a=1"}}}))
+		})
+	})
+
+	When("config map data can't be expressed as literals", func() {
+
+		BeforeEach(func() {
+			base = []map[string]interface{}{configMap(map[string]interface{}{"a": "1"})}
+			env = []map[string]interface{}{configMap(map[string]interface{}{"a": `"quoted"`})}
+		})
+
+		It("patches the config map", func() {
+			Expect(k.ConfigMapGenerator).To(BeEmpty())
+			Expect(k.GeneratorOptions).To(BeNil())
+			Expect(k.Patches).To(HaveLen(1))
+		})
+	})
+
+	Describe("splitImage", func() {
+
+		It("splits the image name and tag the way kustomize does", func() {
+			for img, expected := range map[string][]string{
+				"nginx":                   {"nginx", ""},
+				"nginx:1.21":              {"nginx", ":1.21"},
+				"nginx@sha256:abc":        {"nginx", "@sha256:abc"},
+				"registry:5000/app":       {"registry:5000/app", ""},
+				"registry:5000/app:1.0.0": {"registry:5000/app", ":1.0.0"},
+			} {
+				name, tag := splitImage(img)
+				Expect([]string{name, tag}).To(Equal(expected), img)
+			}
+		})
+	})
+})
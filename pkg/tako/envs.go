@@ -211,9 +211,10 @@ func (e *Environment) loadOverride() (*Environment, error) {
 		volumes[volName] = volumeConfig
 	}
 	e.override = &composeOverride{
-		Version:  p.GetVersion(),
-		Services: services,
-		Volumes:  volumes,
+		Version:    p.GetVersion(),
+		Services:   services,
+		Volumes:    volumes,
+		Extensions: p.Extensions,
 	}
 	return e, nil
 }
@@ -76,6 +76,10 @@ func (e RunnerEvent) String() string {
 		return "PrePatchManifest"
 	case PostPatchManifest:
 		return "PostPatchManifest"
+	case PreValidateRenderedManifests:
+		return "PreValidateRenderedManifests"
+	case PostValidateRenderedManifests:
+		return "PostValidateRenderedManifests"
 	default:
 		return ""
 	}
@@ -110,6 +114,8 @@ const (
 	DevLoopIterated
 	PrePatchManifest
 	PostPatchManifest
+	PreValidateRenderedManifests
+	PostValidateRenderedManifests
 )
 
 // newEventError returns an event error wrapping the original error
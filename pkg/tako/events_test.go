@@ -82,6 +82,8 @@ var _ = Describe("Events", func() {
 				tako.PostReconcileEnvs,
 				tako.PreRenderFromComposeToK8sManifests,
 				tako.PostRenderFromComposeToK8sManifests,
+				tako.PreValidateRenderedManifests,
+				tako.PostValidateRenderedManifests,
 				tako.PrePrintSummary,
 				tako.PostPrintSummary,
 			}
@@ -65,6 +65,13 @@ func (r *InitRunner) Run() (WritableResults, error) {
 		return nil, err
 	}
 
+	if err := r.ReportSecrets(); err != nil {
+		sg := r.UI.StepGroup()
+		defer sg.Done()
+		initStepError(r.UI, sg.Add(""), initStepValidatingSources, err)
+		return nil, err
+	}
+
 	if err := r.CreateManifestAndEnvironmentOverrides(sources); err != nil {
 		return nil, err
 	}
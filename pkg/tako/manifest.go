@@ -27,6 +27,8 @@ import (
 	kmd "github.com/appvia/komando"
 	"github.com/appvia/tako/pkg/tako/config"
 	"github.com/appvia/tako/pkg/tako/converter"
+	"github.com/appvia/tako/pkg/tako/converter/helm"
+	"github.com/appvia/tako/pkg/tako/converter/kustomize"
 	"github.com/appvia/tako/pkg/tako/log"
 	composego "github.com/compose-spec/compose-go/types"
 	"github.com/google/uuid"
@@ -58,6 +60,10 @@ func LoadManifest(workingDir string) (*Manifest, error) {
 		return nil, err
 	}
 
+	if err := m.Secrets.validate(); err != nil {
+		return nil, err
+	}
+
 	m.UI = kmd.NoOpUI()
 
 	return m, nil
@@ -265,24 +271,48 @@ func validateEnvExtensions(e *Environment, base *composeOverride) error {
 			return err
 		}
 	}
+
+	if _, ok := e.override.Extensions[config.K8SExtensionKey]; ok {
+		if _, err := config.ParseProjK8sConfigFromMap(e.override.Extensions); err != nil {
+			return errors.Wrapf(err, "when parsing environment %s extensions", e.Name)
+		}
+	}
 	return nil
 }
 
 // MergeEnvIntoSources merges an environment into a parsed instance of the tracked docker-compose sources.
 // It returns the merged ComposeProject.
 func (m *Manifest) MergeEnvIntoSources(e *Environment) (*ComposeProject, error) {
-	p, err := m.SourcesToComposeProject()
+	p, err := m.Sources.toComposeProjectWithEnvFiles()
 	if err != nil {
 		return nil, err
 	}
 	if err := e.mergeInto(p); err != nil {
 		return nil, err
 	}
+	// @step env_file references are only kept for services loading env vars via envFrom
+	if err := p.discardEnvFiles(); err != nil {
+		return nil, err
+	}
 	return p, nil
 }
 
-// RenderWithConvertor renders K8s manifests with specific converter
-func (m *Manifest) RenderWithConvertor(c converter.Converter, runc *runConfig) (map[string]string, error) {
+// sourcesProject returns the sources as a ComposeProject loaded the same way environments are merged into them,
+// i.e. env_file references are only kept for services loading env vars via envFrom
+func (m *Manifest) sourcesProject() (*ComposeProject, error) {
+	p, err := m.Sources.toComposeProjectWithEnvFiles()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.discardEnvFiles(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// RenderWithConvertor renders K8s manifests with specific converter.
+// Contents of rendered manifests are collected in the rendered map, keyed by file path.
+func (m *Manifest) RenderWithConvertor(c converter.Converter, runc *runConfig, rendered map[string][]byte) (map[string]string, error) {
 	errSg := m.UI.StepGroup()
 	defer errSg.Done()
 
@@ -297,7 +327,11 @@ func (m *Manifest) RenderWithConvertor(c converter.Converter, runc *runConfig) (
 		return nil, err
 	}
 
-	rendered := map[string][]byte{}
+	if err := validateRenderOutput(runc, filteredEnvs); err != nil {
+		renderStepError(m.UI, errSg.Add(""), renderStepRenderGeneral, err)
+		return nil, err
+	}
+
 	projects := map[string]*composego.Project{}
 	files := map[string][]string{}
 	sourcesFiles := m.GetSourcesFiles()
@@ -313,6 +347,16 @@ func (m *Manifest) RenderWithConvertor(c converter.Converter, runc *runConfig) (
 		files[env.Name] = append(sourcesFiles, env.File)
 	}
 
+	// @step kustomize base is rendered from the compose sources, loaded the same way as environment projects
+	if k, ok := c.(*kustomize.Kustomize); ok {
+		p, err := m.sourcesProject()
+		if err != nil {
+			renderStepError(m.UI, errSg.Add(""), renderStepRenderGeneral, err)
+			return nil, err
+		}
+		k.Sources = p.Project
+	}
+
 	outputPaths, err := c.Render(runc.ManifestsAsSingleFile, runc.OutputDir, m.getWorkingDir(),
 		projects, files, runc.AdditionalManifests, rendered, runc.ExcludeServicesByEnv)
 	if err != nil {
@@ -321,11 +365,15 @@ func (m *Manifest) RenderWithConvertor(c converter.Converter, runc *runConfig) (
 	}
 
 	if len(m.Skaffold) > 0 {
-		// Update skaffold profiles upon render - this ensures profiles stay up to date
-		if err := UpdateSkaffoldProfiles(m.Skaffold, outputPaths); err != nil {
-			decoratedErr := errors.Errorf("Couldn't update skaffold.yaml profiles, details:\n%s", err)
-			renderStepError(m.UI, errSg.Add(""), renderStepRenderGeneral, decoratedErr)
-			return nil, err
+		// Update skaffold profiles upon render - this ensures profiles stay up to date.
+		// Profiles deploy raw kubernetes manifests, so helm charts, kustomize overlays
+		// and manifests printed to stdout leave them untouched.
+		if runc.ManifestFormat != helm.Name && runc.ManifestFormat != kustomize.Name && !runc.ManifestsToStdout {
+			if err := UpdateSkaffoldProfiles(m.Skaffold, outputPaths); err != nil {
+				decoratedErr := errors.Errorf("Couldn't update skaffold.yaml profiles, details:\n%s", err)
+				renderStepError(m.UI, errSg.Add(""), renderStepRenderGeneral, decoratedErr)
+				return nil, err
+			}
 		}
 
 		// Update skaffold build artifacts - these may change over time, usually by manual update in base docker compose
@@ -346,6 +394,31 @@ func (m *Manifest) RenderWithConvertor(c converter.Converter, runc *runConfig) (
 	return outputPaths, nil
 }
 
+// validateRenderOutput ensures the output format and stdout printing are supported by the manifest format
+// and that a single environment is selected when printing manifests to stdout.
+func validateRenderOutput(runc *runConfig, envs Environments) error {
+	switch runc.OutputFormat {
+	case "", OutputFormatYAML, OutputFormatJSON:
+	default:
+		return errors.Errorf("Unsupported output format %q, use %s or %s", runc.OutputFormat, OutputFormatYAML, OutputFormatJSON)
+	}
+
+	if runc.ManifestFormat == helm.Name || runc.ManifestFormat == kustomize.Name {
+		if runc.ManifestsToStdout {
+			return errors.Errorf("The %s format can't be printed to stdout, use the kubernetes format instead", runc.ManifestFormat)
+		}
+		if runc.OutputFormat == OutputFormatJSON {
+			return errors.Errorf("The %s format only supports %s output", runc.ManifestFormat, OutputFormatYAML)
+		}
+	}
+
+	if runc.ManifestsToStdout && len(envs) != 1 {
+		return errors.Errorf("Printing manifests to stdout requires a single environment, select one with --environment")
+	}
+
+	return nil
+}
+
 // GetSourcesFiles gets the sources tracked docker-compose files.
 func (m *Manifest) GetSourcesFiles() []string {
 	return m.Sources.Files
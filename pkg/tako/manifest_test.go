@@ -113,12 +113,67 @@ var _ = Describe("Manifest", func() {
 				Expect(mergedVol.Extensions).To(Equal(envVol.Extensions))
 			})
 
+			It("merged the environment top level extensions into sources", func() {
+				projK8sCfg, err := config.ProjK8sConfigFromCompose(merged.Project)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(projK8sCfg.Mesh).To(Equal(config.LinkerdMesh))
+			})
+
 			It("should not error", func() {
 				Expect(mergeErr).NotTo(HaveOccurred())
 			})
 		})
 	})
 
+	Describe("MergeEnvIntoSources with env files", func() {
+		var (
+			workingDir = "testdata/merge-envfrom"
+			merged     *tako.ComposeProject
+		)
+
+		BeforeEach(func() {
+			manifest, err := tako.LoadManifest(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = manifest.CalculateSourcesBaseOverride()
+			Expect(err).NotTo(HaveOccurred())
+
+			env, err := manifest.GetEnvironment("dev")
+			Expect(err).NotTo(HaveOccurred())
+
+			merged, err = manifest.MergeEnvIntoSources(env)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("keeps env_file references of services loading env vars via envFrom", func() {
+			svc, err := merged.GetService("api")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(svc.EnvFile).To(HaveLen(1))
+		})
+
+		It("discards env_file references of other services", func() {
+			svc, err := merged.GetService("worker")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(svc.EnvFile).To(BeEmpty())
+		})
+
+		It("resolves env_file contents into the service environment", func() {
+			for _, name := range []string{"api", "worker"} {
+				svc, err := merged.GetService(name)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(svc.Environment).To(HaveKey("LOG_LEVEL"))
+			}
+		})
+
+		It("discards env_file references when loading sources", func() {
+			sources, err := tako.NewComposeProject([]string{workingDir + "/docker-compose.yaml"})
+			Expect(err).NotTo(HaveOccurred())
+			for _, svc := range sources.Services {
+				Expect(svc.EnvFile).To(BeEmpty())
+			}
+		})
+	})
+
 	Describe("GetEnvironmentFileNameTemplate", func() {
 
 		var (
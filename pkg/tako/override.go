@@ -304,9 +304,24 @@ func (o *composeOverride) mergeInto(p *ComposeProject) error {
 	if err := o.mergeVolumesInto(p); err != nil {
 		return errors.Wrap(err, "cannot merge volumes into project")
 	}
+	if err := o.mergeExtensionsInto(p); err != nil {
+		return errors.Wrap(err, "cannot merge extensions into project")
+	}
 	return nil
 }
 
+func (o *composeOverride) mergeExtensionsInto(p *ComposeProject) error {
+	if len(o.Extensions) == 0 {
+		return nil
+	}
+
+	if p.Extensions == nil {
+		p.Extensions = map[string]interface{}{}
+	}
+
+	return mergo.Merge(&p.Extensions, o.Extensions, mergo.WithOverride)
+}
+
 func (o *composeOverride) mergeServicesInto(p *ComposeProject) error {
 	var overridden composego.Services
 	for _, override := range o.Services {
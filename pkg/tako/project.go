@@ -20,12 +20,15 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 
 	kmd "github.com/appvia/komando"
 	"github.com/appvia/tako/pkg/tako/config"
 	"github.com/appvia/tako/pkg/tako/log"
+	"github.com/mattn/go-isatty"
 	"github.com/pkg/errors"
+	"github.com/pterm/pterm"
 	"github.com/sirupsen/logrus"
 )
 
@@ -38,6 +41,14 @@ func (p *Project) Init(opts ...Options) {
 		p.AppName = config.AppName
 	}
 
+	// Manifests printed to stdout must not be mixed with UI output and logs
+	if p.config.ManifestsToStdout {
+		log.SetOutput(os.Stderr)
+		if p.UI == nil {
+			p.UI = stderrUI()
+		}
+	}
+
 	if p.UI == nil {
 		p.UI = kmd.ConsoleUI()
 	}
@@ -83,6 +94,11 @@ func (p *Project) detectSecretsInSources(sources *Sources, matchers []map[string
 
 	sg := p.UI.StepGroup()
 	defer sg.Done()
+
+	detectors, err := config.NewSecretDetectors(matchers)
+	if err != nil {
+		return false, err
+	}
 	for _, composeFile := range sources.Files {
 		p.UI.Output(fmt.Sprintf("Detecting secrets in: %s", composeFile))
 		composeProject, err := NewComposeProject([]string{composeFile})
@@ -92,11 +108,18 @@ func (p *Project) detectSecretsInSources(sources *Sources, matchers []map[string
 			return false, decoratedErr
 		}
 
+		lines, err := envVarLines(composeFile)
+		if err != nil {
+			log.DebugfWithFields(log.Fields{
+				"file": composeFile,
+			}, "Unable to locate env vars in compose file: %s", err)
+		}
+
 		for _, s := range composeProject.Services {
 			step := sg.Add(fmt.Sprintf("Analysing service: %s", s.Name))
 			serviceConfig := ServiceConfig{Name: s.Name, Environment: s.Environment}
 
-			hits := serviceConfig.detectSecretsInEnvVars(matchers)
+			hits := p.filterAllowedSecrets(serviceConfig.detectSecretsInEnvVars(detectors))
 			if len(hits) == 0 {
 				step.Success("None detected in service: ", s.Name)
 				continue
@@ -106,6 +129,10 @@ func (p *Project) detectSecretsInSources(sources *Sources, matchers []map[string
 			step.Warning("Detected in service: ", s.Name)
 
 			for _, hit := range hits {
+				hit.file = composeFile
+				hit.line = lines[s.Name][hit.envVar]
+				p.secretHits = append(p.secretHits, hit)
+
 				p.UI.Output(
 					fmt.Sprintf("env var [%s] - %s", hit.envVar, hit.description),
 					kmd.WithStyle(kmd.LogStyle),
@@ -118,6 +145,26 @@ func (p *Project) detectSecretsInSources(sources *Sources, matchers []map[string
 	return detected, nil
 }
 
+// filterAllowedSecrets removes secret hits suppressed by the manifest secrets allowlist
+func (p *Project) filterAllowedSecrets(hits []secretHit) []secretHit {
+	if p.manifest == nil {
+		return hits
+	}
+
+	var out []secretHit
+	for _, hit := range hits {
+		if a, ok := p.manifest.Secrets.allowed(hit.svcName, hit.envVar); ok {
+			log.DebugfWithFields(log.Fields{
+				"project-service": hit.svcName,
+				"env-var":         hit.envVar,
+			}, "Secret finding suppressed by allowlist: %s", a.Justification)
+			continue
+		}
+		out = append(out, hit)
+	}
+	return out
+}
+
 // Manifest returns the project's manifest
 func (p *Project) Manifest() *Manifest {
 	return p.manifest
@@ -150,6 +197,15 @@ func (p *Project) LogVerbose() bool {
 	return p.config.LogVerbose
 }
 
+// stderrUI returns a UI writing to stderr, interactive only when stderr is a terminal
+func stderrUI() kmd.UI {
+	if !isatty.IsTerminal(os.Stderr.Fd()) {
+		return kmd.NoOpUI()
+	}
+	pterm.SetDefaultOutput(os.Stderr)
+	return kmd.PTermUI()
+}
+
 // pipeLogsToUI pipes all logs to configured UI
 func (p *Project) pipeLogsToUI() (context.CancelFunc, *io.PipeReader, *io.PipeWriter) {
 	pr, pw := io.Pipe()
@@ -241,6 +297,29 @@ func WithManifestsAsSingleFile(c bool) Options {
 	}
 }
 
+// WithManifestsToStdout configures a project's run config with whether rendered K8s manifests
+// should be printed to stdout instead of being written to disk.
+func WithManifestsToStdout(c bool) Options {
+	return func(project *Project, cfg *runConfig) {
+		cfg.ManifestsToStdout = c
+	}
+}
+
+// WithManifestsOut configures a project's run config with a writer rendered K8s manifests are printed to,
+// when they're printed to stdout.
+func WithManifestsOut(w io.Writer) Options {
+	return func(project *Project, cfg *runConfig) {
+		cfg.ManifestsOut = w
+	}
+}
+
+// WithOutputFormat configures a project's run config with a serialisation format of rendered K8s manifests.
+func WithOutputFormat(c string) Options {
+	return func(project *Project, cfg *runConfig) {
+		cfg.OutputFormat = c
+	}
+}
+
 // WithAdditionalManifests configures a project's run config with additional manifests that should be added
 // in the output directory.
 func WithAdditionalManifests(c []string) Options {
@@ -335,3 +414,18 @@ func WithPatchOutputDir(c string) Options {
 		cfg.PatchOutputDir = c
 	}
 }
+
+// WithFailOnSecrets configures a project's run config to fail when secrets are detected in compose sources.
+func WithFailOnSecrets(c bool) Options {
+	return func(project *Project, cfg *runConfig) {
+		cfg.FailOnSecrets = c
+	}
+}
+
+// WithSecretsReport configures a project's run config with a file where detected secrets report
+// should be written. SARIF 2.1.0 format is used for files with `.sarif` extension, JSON otherwise.
+func WithSecretsReport(c string) Options {
+	return func(project *Project, cfg *runConfig) {
+		cfg.SecretsReport = c
+	}
+}
@@ -18,11 +18,12 @@ package tako
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 
 	kmd "github.com/appvia/komando"
-	"github.com/appvia/tako/pkg/tako/config"
 	"github.com/appvia/tako/pkg/tako/converter"
+	"github.com/appvia/tako/pkg/tako/converter/kubernetes"
 	"github.com/pkg/errors"
 )
 
@@ -51,7 +52,15 @@ func (r *RenderRunner) Run() (map[string]string, error) {
 		return nil, err
 	}
 
-	if err := r.ValidateSources(r.manifest.Sources, config.SecretMatchers); err != nil {
+	matchers, err := r.manifest.SecretMatchers(r.WorkingDir)
+	if err != nil {
+		sg := r.UI.StepGroup()
+		defer sg.Done()
+		renderStepError(r.UI, sg.Add(""), renderStepValidatingSources, err)
+		return nil, err
+	}
+
+	if err := r.ValidateSources(r.manifest.Sources, matchers); err != nil {
 		sg := r.UI.StepGroup()
 		defer sg.Done()
 		renderStepError(r.UI, sg.Add(""), renderStepValidatingSources, err)
@@ -62,7 +71,14 @@ func (r *RenderRunner) Run() (map[string]string, error) {
 		return nil, err
 	}
 
-	if err := r.ValidateEnvSources(config.SecretMatchers); err != nil {
+	if err := r.ValidateEnvSources(matchers); err != nil {
+		return nil, err
+	}
+
+	if err := r.ReportSecrets(); err != nil {
+		sg := r.UI.StepGroup()
+		defer sg.Done()
+		renderStepError(r.UI, sg.Add(""), renderStepValidatingSources, err)
 		return nil, err
 	}
 
@@ -71,8 +87,45 @@ func (r *RenderRunner) Run() (map[string]string, error) {
 	}
 
 	results, err := r.RenderFromComposeToK8sManifests()
+	if err != nil {
+		return nil, err
+	}
 
-	return results, err
+	if err := r.ValidateRenderedManifests(matchers); err != nil {
+		return nil, err
+	}
+
+	if err := r.ReportSecrets(); err != nil {
+		sg := r.UI.StepGroup()
+		defer sg.Done()
+		renderStepError(r.UI, sg.Add(""), renderStepValidatingSources, err)
+		return nil, err
+	}
+
+	// @step manifests only reach stdout once they passed the rendered manifests validation
+	if err := r.printManifestsToStdout(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// printManifestsToStdout prints manifests rendered for stdout. It's called once rendered manifests are validated,
+// so nothing gets printed, e.g. piped to `kubectl apply`, when secrets detection fails the render.
+func (r *RenderRunner) printManifestsToStdout() error {
+	if !r.config.ManifestsToStdout {
+		return nil
+	}
+
+	out := r.config.ManifestsOut
+	if out == nil {
+		out = os.Stdout
+	}
+
+	if _, err := out.Write(r.rendered[kubernetes.StdoutOutput]); err != nil {
+		return errors.Wrap(err, "Printing manifests failed")
+	}
+	return nil
 }
 
 // LoadProject loads the project into memory including the tako manifest and related deployment environments.
@@ -212,7 +265,17 @@ func (r *RenderRunner) RenderFromComposeToK8sManifests() (map[string]string, err
 	manifestFormat := r.config.ManifestFormat
 	r.UI.Header(fmt.Sprintf("Rendering manifests, format: %s...", manifestFormat))
 
-	results, err := r.manifest.RenderWithConvertor(converter.Factory(manifestFormat, r.UI), r.config)
+	matchers, err := r.manifest.SecretMatchers(r.WorkingDir)
+	if err != nil {
+		return nil, err
+	}
+
+	r.rendered = map[string][]byte{}
+	output := converter.Output{
+		ToStdout: r.config.ManifestsToStdout,
+		JSON:     r.config.OutputFormat == OutputFormatJSON,
+	}
+	results, err := r.manifest.RenderWithConvertor(converter.Factory(manifestFormat, r.UI, matchers, r.manifest.Secrets.allowlist(), output), r.config, r.rendered)
 	if err != nil {
 		return nil, err
 	}
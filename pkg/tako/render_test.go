@@ -0,0 +1,108 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tako_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RenderRunner", func() {
+	var (
+		workingDir string
+		outDir     string
+		format     string
+		opts       []tako.Options
+		results    map[string]string
+		rErr       error
+	)
+
+	BeforeEach(func() {
+		workingDir = "./testdata/render-helm"
+		opts = nil
+
+		var err error
+		outDir, err = ioutil.TempDir("", "render")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	JustBeforeEach(func() {
+		ui, _ := kmd.FakeUIAndLog()
+		runner := tako.NewRenderRunner(workingDir, append([]tako.Options{
+			tako.WithUI(ui),
+			tako.WithManifestFormat(format),
+			tako.WithOutputDir(outDir),
+		}, opts...)...)
+		results, rErr = runner.Run()
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(outDir)
+	})
+
+	Context("with helm format", func() {
+		BeforeEach(func() {
+			format = "helm"
+		})
+
+		It("renders a chart with values file for each environment", func() {
+			Expect(rErr).NotTo(HaveOccurred())
+
+			chartDir := filepath.Join(outDir, "render-helm")
+			Expect(results).To(Equal(map[string]string{
+				"dev":  filepath.Join(chartDir, "values-dev.yaml"),
+				"prod": filepath.Join(chartDir, "values-prod.yaml"),
+			}))
+			Expect(filepath.Join(chartDir, "templates", "web-deployment.yaml")).To(BeAnExistingFile())
+		})
+	})
+
+	Context("with manifests printed to stdout", func() {
+		var out *bytes.Buffer
+
+		BeforeEach(func() {
+			workingDir = "./testdata/render-stdout"
+			format = "kubernetes"
+			out = &bytes.Buffer{}
+			opts = []tako.Options{tako.WithManifestsToStdout(true), tako.WithManifestsOut(out)}
+		})
+
+		It("prints manifests once they're validated", func() {
+			Expect(rErr).NotTo(HaveOccurred())
+			Expect(out.String()).To(ContainSubstring("kind: Deployment"))
+		})
+
+		Context("when secrets detected in rendered manifests fail the render", func() {
+			BeforeEach(func() {
+				opts = append(opts, tako.WithFailOnSecrets(true))
+			})
+
+			It("doesn't print anything", func() {
+				Expect(errors.Is(rErr, tako.ErrSecretsDetected)).To(BeTrue())
+				Expect(out.Len()).To(BeZero())
+			})
+		})
+	})
+})
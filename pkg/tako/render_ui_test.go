@@ -31,16 +31,18 @@ var _ = Describe("RenderRunner UI", func() {
 		ui         kmd.UI
 		log        kmd.UILog
 		envs       []string
+		opts       []tako.Options
 	)
 
 	JustBeforeEach(func() {
 		workingDir = "./testdata/detect-secrets"
 		ui, log = kmd.FakeUIAndLog()
-		runner = tako.NewRenderRunner(workingDir, tako.WithEnvs(envs), tako.WithUI(ui))
+		runner = tako.NewRenderRunner(workingDir, append([]tako.Options{tako.WithEnvs(envs), tako.WithUI(ui)}, opts...)...)
 	})
 
 	AfterEach(func() {
 		log.Reset()
+		opts = nil
 	})
 
 	Context("Validating sources", func() {
@@ -96,4 +98,46 @@ var _ = Describe("RenderRunner UI", func() {
 			Expect(detectedSecrets).ToNot(ContainElement(HaveKey(MatchRegexp(`CACHE_SWITCH`))))
 		})
 	})
+
+	Context("Rendering manifests with unsupported output options", func() {
+		JustBeforeEach(func() {
+			Expect(runner.LoadProject()).To(Succeed())
+			log.Reset()
+		})
+
+		When("output format is unknown", func() {
+			BeforeEach(func() {
+				opts = []tako.Options{tako.WithOutputFormat("xml")}
+			})
+
+			It("displays an error", func() {
+				_, err := runner.RenderFromComposeToK8sManifests()
+				Expect(err).To(MatchError(`Unsupported output format "xml", use yaml or json`))
+
+				Expect(log.NextHeader()).To(HaveKeyWithValue("Rendering manifests, format: ...", []string{}))
+			})
+		})
+
+		When("helm chart is printed to stdout", func() {
+			BeforeEach(func() {
+				opts = []tako.Options{tako.WithManifestFormat("helm"), tako.WithManifestsToStdout(true)}
+			})
+
+			It("displays an error", func() {
+				_, err := runner.RenderFromComposeToK8sManifests()
+				Expect(err).To(MatchError("The helm format can't be printed to stdout, use the kubernetes format instead"))
+			})
+		})
+
+		When("kustomize overlays are rendered as JSON", func() {
+			BeforeEach(func() {
+				opts = []tako.Options{tako.WithManifestFormat("kustomize"), tako.WithOutputFormat(tako.OutputFormatJSON)}
+			})
+
+			It("displays an error", func() {
+				_, err := runner.RenderFromComposeToK8sManifests()
+				Expect(err).To(MatchError("The kustomize format only supports yaml output"))
+			})
+		})
+	})
 })
@@ -0,0 +1,113 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tako
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// loadSecretMatchers loads secret matcher definitions from a YAML file
+func loadSecretMatchers(path string) ([]map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read secret matchers file %s", path)
+	}
+
+	var matchers []map[string]string
+	if err := yaml.Unmarshal(data, &matchers); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse secret matchers file %s", path)
+	}
+
+	if _, err := config.NewSecretDetectors(matchers); err != nil {
+		return nil, errors.Wrapf(err, "invalid secret matchers file %s", path)
+	}
+
+	return matchers, nil
+}
+
+// SecretMatchers returns secret matchers used for secrets detection. These are the default matchers
+// followed by any extra matchers loaded from the file referenced in the manifest.
+func (m *Manifest) SecretMatchers(workingDir string) ([]map[string]string, error) {
+	matchers := config.SecretMatchers
+
+	if m.Secrets == nil || m.Secrets.Matchers == "" {
+		return matchers, nil
+	}
+
+	path := m.Secrets.Matchers
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+
+	extra, err := loadSecretMatchers(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(append([]map[string]string{}, matchers...), extra...), nil
+}
+
+// validate validates secrets detection configuration
+func (sd *SecretsDetection) validate() error {
+	if sd == nil {
+		return nil
+	}
+
+	for _, a := range sd.Allowlist {
+		if a.Service == "" || a.EnvVar == "" {
+			return errors.New("secrets allowlist entry requires both service and env var name")
+		}
+		if a.Justification == "" {
+			return errors.Errorf("secrets allowlist entry for service %s env var %s requires a justification", a.Service, a.EnvVar)
+		}
+	}
+
+	return nil
+}
+
+// allowed returns the allowlist entry suppressing secret findings for a service env var if any
+func (sd *SecretsDetection) allowed(service, envVar string) (SecretAllowance, bool) {
+	if sd == nil {
+		return SecretAllowance{}, false
+	}
+
+	for _, a := range sd.Allowlist {
+		if a.Service == service && a.EnvVar == envVar {
+			return a, true
+		}
+	}
+
+	return SecretAllowance{}, false
+}
+
+// allowlist returns allowlisted env var names keyed by service name
+func (sd *SecretsDetection) allowlist() map[string][]string {
+	if sd == nil {
+		return nil
+	}
+
+	out := map[string][]string{}
+	for _, a := range sd.Allowlist {
+		out[a.Service] = append(out[a.Service], a.EnvVar)
+	}
+	return out
+}
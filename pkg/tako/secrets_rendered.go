@@ -0,0 +1,426 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tako
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako/config"
+	"github.com/appvia/tako/pkg/tako/converter/kubernetes"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// renderedFieldEnv identifies secrets found in container env var values
+	renderedFieldEnv = "env"
+	// renderedFieldArgs identifies secrets found in container args
+	renderedFieldArgs = "args"
+	// renderedFieldCommand identifies secrets found in container command
+	renderedFieldCommand = "command"
+	// renderedFieldData identifies secrets found in ConfigMap data
+	renderedFieldData = "data"
+	// renderedFieldAnnotations identifies secrets found in object annotations
+	renderedFieldAnnotations = "annotations"
+)
+
+// containerKeys are pod spec keys holding lists of containers
+var containerKeys = []string{"containers", "initContainers", "ephemeralContainers"}
+
+// ValidateRenderedManifests includes validation checks to ensure the rendered K8s manifests are valid.
+// For now it detects plaintext secrets leaked into rendered objects, including additional manifests.
+func (r *RenderRunner) ValidateRenderedManifests(matchers []map[string]string) error {
+	if err := r.eventHandler(PreValidateRenderedManifests, r); err != nil {
+		return newEventError(err, PreValidateRenderedManifests)
+	}
+
+	r.UI.Header("Validating rendered manifests...")
+
+	secretsDetected, err := r.detectSecretsInRendered(matchers)
+	if err != nil {
+		return err
+	}
+
+	r.UI.Output("")
+
+	if secretsDetected {
+		if err := r.eventHandler(SecretsDetected, r); err != nil {
+			return newEventError(err, SecretsDetected)
+		}
+		r.UI.Output(fmt.Sprintf(`To prevent secrets leaking, see help page:
+%s`, SecretsReferenceUrl))
+	}
+
+	if err := r.eventHandler(PostValidateRenderedManifests, r); err != nil {
+		return newEventError(err, PostValidateRenderedManifests)
+	}
+	return nil
+}
+
+func (r *RenderRunner) detectSecretsInRendered(matchers []map[string]string) (bool, error) {
+	var detected bool
+
+	sg := r.UI.StepGroup()
+	defer sg.Done()
+
+	detectors, err := config.NewSecretDetectors(matchers)
+	if err != nil {
+		return false, err
+	}
+
+	// service env vars and ConfigMap data already reported by compose sources validation aren't reported again
+	reported := map[secretHitKey]bool{}
+	for _, hit := range r.secretHits {
+		reported[secretHitKey{service: hit.svcName, envVar: hit.envVar}] = true
+	}
+
+	var files []string
+	for file := range r.rendered {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	// ConfigMap data is also attributed to services referencing the ConfigMap, e.g. env file ConfigMaps shared by services.
+	// Manifests which can't be parsed are reported below.
+	configMapRefs := map[string]map[string]bool{}
+	for _, file := range files {
+		docs, err := manifestDocs(r.rendered[file])
+		if err != nil {
+			continue
+		}
+		for _, doc := range docs {
+			collectConfigMapRefs(doc, configMapRefs)
+		}
+	}
+
+	for _, file := range files {
+		step := sg.Add(fmt.Sprintf("Analysing manifest: %s", file))
+
+		hits, err := detectSecretsInManifest(r.rendered[file], detectors)
+		if err != nil {
+			decoratedErr := errors.Wrapf(err, "cannot parse rendered manifest %s", file)
+			step.Error(decoratedErr.Error())
+			return false, decoratedErr
+		}
+
+		var found []secretHit
+		for _, hit := range r.filterAllowedSecrets(hits) {
+			if reportedFromSources(hit, reported, configMapRefs) {
+				continue
+			}
+			hit.file = file
+
+			key := secretHitKey{file: hit.file, object: hit.object, service: hit.svcName, envVar: hit.envVar}
+			if reported[key] {
+				continue
+			}
+			reported[key] = true
+
+			found = append(found, hit)
+		}
+
+		if len(found) == 0 {
+			step.Success("None detected in manifest: ", file)
+			continue
+		}
+
+		detected = true
+		step.Warning("Detected in manifest: ", file)
+
+		for _, hit := range found {
+			r.secretHits = append(r.secretHits, hit)
+
+			r.UI.Output(
+				fmt.Sprintf("%s %s [%s] - %s", hit.object, hit.field, hit.envVar, hit.description),
+				kmd.WithStyle(kmd.LogStyle),
+				kmd.WithIndentChar(kmd.LogIndentChar),
+				kmd.WithIndent(3),
+			)
+		}
+	}
+
+	return detected, nil
+}
+
+// secretHitKey identifies a detected secret by the manifest file, K8s object, service and env var it was found in.
+// Secrets detected in compose sources are only identified by service and env var.
+type secretHitKey struct {
+	file    string
+	object  string
+	service string
+	envVar  string
+}
+
+// reportedFromSources tells whether a secret detected in a rendered env var or ConfigMap data has already been reported
+// from compose sources for the same service. ConfigMap data belongs to the ConfigMap service and to services referencing it.
+func reportedFromSources(hit secretHit, reported map[secretHitKey]bool, configMapRefs map[string]map[string]bool) bool {
+	switch hit.field {
+	case renderedFieldEnv:
+		return reported[secretHitKey{service: hit.svcName, envVar: hit.envVar}]
+	case renderedFieldData:
+		if reported[secretHitKey{service: hit.svcName, envVar: hit.envVar}] {
+			return true
+		}
+		for service := range configMapRefs[strings.TrimPrefix(hit.object, "ConfigMap/")] {
+			if reported[secretHitKey{service: service, envVar: hit.envVar}] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// manifestDocs returns root nodes of all documents of a rendered manifest
+func manifestDocs(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		docs = append(docs, doc.Content[0])
+	}
+
+	return docs, nil
+}
+
+// detectSecretsInManifest detects secrets in all K8s objects of a rendered manifest.
+// Secret objects are skipped as they are expected to hold sensitive data.
+func detectSecretsInManifest(data []byte, detectors []config.SecretDetector) ([]secretHit, error) {
+	docs, err := manifestDocs(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var hits []secretHit
+	for _, doc := range docs {
+		hits = append(hits, detectSecretsInObject(doc, detectors)...)
+	}
+
+	return hits, nil
+}
+
+// collectConfigMapRefs records services of a K8s object referencing ConfigMaps from container env vars, keyed by ConfigMap name
+func collectConfigMapRefs(obj *yaml.Node, refs map[string]map[string]bool) {
+	if scalarValue(mappingValue(obj, "kind")) == "List" {
+		if items := mappingValue(obj, "items"); items != nil && items.Kind == yaml.SequenceNode {
+			for _, item := range items.Content {
+				collectConfigMapRefs(item, refs)
+			}
+		}
+		return
+	}
+
+	service := objectService(mappingValue(obj, "metadata"))
+
+	add := func(name string) {
+		if name == "" {
+			return
+		}
+		if refs[name] == nil {
+			refs[name] = map[string]bool{}
+		}
+		refs[name][service] = true
+	}
+
+	for _, container := range findContainers(obj) {
+		if envFrom := mappingValue(container, "envFrom"); envFrom != nil && envFrom.Kind == yaml.SequenceNode {
+			for _, e := range envFrom.Content {
+				add(scalarValue(mappingValue(mappingValue(e, "configMapRef"), "name")))
+			}
+		}
+		if env := mappingValue(container, "env"); env != nil && env.Kind == yaml.SequenceNode {
+			for _, e := range env.Content {
+				add(scalarValue(mappingValue(mappingValue(mappingValue(e, "valueFrom"), "configMapKeyRef"), "name")))
+			}
+		}
+	}
+}
+
+// objectService returns the service a K8s object belongs to, i.e. its service label, or its name when the label is missing
+func objectService(metadata *yaml.Node) string {
+	if service := scalarValue(mappingValue(mappingValue(metadata, "labels"), kubernetes.Selector)); service != "" {
+		return service
+	}
+	return scalarValue(mappingValue(metadata, "name"))
+}
+
+// detectSecretsInObject detects secrets in ConfigMap data, annotations and container env vars,
+// args and command of a K8s object
+func detectSecretsInObject(obj *yaml.Node, detectors []config.SecretDetector) []secretHit {
+	kind := scalarValue(mappingValue(obj, "kind"))
+
+	switch kind {
+	case "List":
+		var hits []secretHit
+		if items := mappingValue(obj, "items"); items != nil && items.Kind == yaml.SequenceNode {
+			for _, item := range items.Content {
+				hits = append(hits, detectSecretsInObject(item, detectors)...)
+			}
+		}
+		return hits
+	case "Secret":
+		return nil
+	}
+
+	metadata := mappingValue(obj, "metadata")
+	d := &objectSecretDetection{
+		detectors: detectors,
+		object:    kind + "/" + scalarValue(mappingValue(metadata, "name")),
+		service:   objectService(metadata),
+	}
+
+	d.detectInMapping(mappingValue(metadata, "annotations"), renderedFieldAnnotations)
+
+	if kind == "ConfigMap" {
+		d.detectInMapping(mappingValue(obj, "data"), renderedFieldData)
+	}
+
+	for _, container := range findContainers(obj) {
+		if env := mappingValue(container, "env"); env != nil && env.Kind == yaml.SequenceNode {
+			for _, e := range env.Content {
+				value := mappingValue(e, "value")
+				if value == nil {
+					continue
+				}
+				d.detect(scalarValue(mappingValue(e, "name")), value, renderedFieldEnv)
+			}
+		}
+		d.detectInArgs(mappingValue(container, "command"), renderedFieldCommand)
+		d.detectInArgs(mappingValue(container, "args"), renderedFieldArgs)
+	}
+
+	return d.hits
+}
+
+// objectSecretDetection collects secrets detected in a single K8s object
+type objectSecretDetection struct {
+	detectors []config.SecretDetector
+	object    string
+	service   string
+	hits      []secretHit
+}
+
+// detect runs detectors against a key and its value node
+func (d *objectSecretDetection) detect(key string, value *yaml.Node, field string) {
+	for _, detector := range d.detectors {
+		if detector.Detect(key, value.Value) {
+			d.hits = append(d.hits, secretHit{
+				svcName:     d.service,
+				envVar:      key,
+				description: detector.Describe(),
+				line:        value.Line,
+				object:      d.object,
+				field:       field,
+			})
+			return
+		}
+	}
+}
+
+// detectInMapping runs detectors against each key value pair of a mapping node
+func (d *objectSecretDetection) detectInMapping(node *yaml.Node, field string) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		d.detect(node.Content[i].Value, node.Content[i+1], field)
+	}
+}
+
+// detectInArgs runs detectors against each word of container args or command.
+// Words in `--flag=value` form and values following a `--flag` word are keyed by the flag.
+func (d *objectSecretDetection) detectInArgs(node *yaml.Node, field string) {
+	if node == nil || node.Kind != yaml.SequenceNode {
+		return
+	}
+
+	var flag string
+	for _, arg := range node.Content {
+		for _, word := range strings.Fields(arg.Value) {
+			key, value := flag, word
+			flag = ""
+
+			if parts := strings.SplitN(word, "=", 2); len(parts) == 2 {
+				key, value = strings.TrimLeft(parts[0], "-"), parts[1]
+			} else if strings.HasPrefix(word, "-") {
+				flag = strings.TrimLeft(word, "-")
+				continue
+			}
+
+			d.detect(key, &yaml.Node{Kind: yaml.ScalarNode, Value: value, Line: arg.Line}, field)
+		}
+	}
+}
+
+// findContainers returns container nodes found anywhere in a K8s object,
+// e.g. in pod template spec of a workload or job template of a CronJob
+func findContainers(node *yaml.Node) []*yaml.Node {
+	if node == nil {
+		return nil
+	}
+
+	var out []*yaml.Node
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			if isContainerKey(key) && value.Kind == yaml.SequenceNode {
+				out = append(out, value.Content...)
+				continue
+			}
+			out = append(out, findContainers(value)...)
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			out = append(out, findContainers(item)...)
+		}
+	}
+
+	return out
+}
+
+func isContainerKey(key string) bool {
+	for _, k := range containerKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// scalarValue returns a value of a scalar yaml node
+func scalarValue(node *yaml.Node) string {
+	if node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
@@ -0,0 +1,299 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tako
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/appvia/tako/pkg/tako/config"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SARIFVersion is the SARIF specification version of secrets reports
+	SARIFVersion = "2.1.0"
+	// SARIFSchema is the SARIF specification schema of secrets reports
+	SARIFSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// ErrSecretsDetected is returned when secrets are detected in compose sources and the run is configured
+// to fail on secrets
+var ErrSecretsDetected = errors.New("secrets detected in compose sources")
+
+var nonAlphanumericRegex = regexp.MustCompile("[^a-z0-9]+")
+
+// secretsReport is a JSON secrets report
+type secretsReport struct {
+	Findings []secretFinding `json:"findings"`
+}
+
+// secretFinding is a single secret finding in a JSON secrets report
+type secretFinding struct {
+	File        string `json:"file"`
+	Line        int    `json:"line,omitempty"`
+	Service     string `json:"service"`
+	EnvVar      string `json:"envVar"`
+	Description string `json:"description"`
+	Object      string `json:"object,omitempty"`
+	Field       string `json:"field,omitempty"`
+}
+
+// sarifReport is a SARIF 2.1.0 secrets report
+// See: https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type sarifReport struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// ReportSecrets writes a report of secrets detected in compose sources, if configured.
+// It returns ErrSecretsDetected when any secrets were detected and the run is configured to fail on secrets.
+func (p *Project) ReportSecrets() error {
+	hits := sortedSecretHits(p.secretHits)
+
+	if path := p.config.SecretsReport; path != "" {
+		data, err := secretsReportData(path, hits)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			return errors.Wrapf(err, "cannot write secrets report %s", path)
+		}
+	}
+
+	if p.config.FailOnSecrets && len(hits) > 0 {
+		return errors.WithMessagef(ErrSecretsDetected, "%d potential secret(s) found", len(hits))
+	}
+
+	return nil
+}
+
+// secretsReportData returns secrets report content. SARIF format is used for `.sarif` files, JSON otherwise.
+func secretsReportData(path string, hits []secretHit) ([]byte, error) {
+	if strings.HasSuffix(path, ".sarif") || strings.HasSuffix(path, ".sarif.json") {
+		return json.MarshalIndent(newSARIFReport(hits), "", "  ")
+	}
+	return json.MarshalIndent(newSecretsReport(hits), "", "  ")
+}
+
+// newSecretsReport creates JSON secrets report from secret hits
+func newSecretsReport(hits []secretHit) secretsReport {
+	report := secretsReport{Findings: []secretFinding{}}
+	for _, hit := range hits {
+		report.Findings = append(report.Findings, secretFinding{
+			File:        hit.file,
+			Line:        hit.line,
+			Service:     hit.svcName,
+			EnvVar:      hit.envVar,
+			Description: hit.description,
+			Object:      hit.object,
+			Field:       hit.field,
+		})
+	}
+	return report
+}
+
+// newSARIFReport creates SARIF secrets report from secret hits. Each matcher description becomes a rule.
+func newSARIFReport(hits []secretHit) sarifReport {
+	rules := []sarifRule{}
+	results := []sarifResult{}
+	known := map[string]bool{}
+
+	for _, hit := range hits {
+		ruleID := secretRuleID(hit.description)
+		if !known[ruleID] {
+			known[ruleID] = true
+			rules = append(rules, sarifRule{
+				ID:               ruleID,
+				ShortDescription: sarifMessage{Text: hit.description},
+			})
+		}
+
+		location := sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(hit.file)},
+		}
+		if hit.line > 0 {
+			location.Region = &sarifRegion{StartLine: hit.line}
+		}
+
+		message := "Service " + hit.svcName + " env var " + hit.envVar + " contains " + hit.description
+		if hit.object != "" {
+			message = hit.object + " " + hit.field + " " + hit.envVar + " contains " + hit.description
+		}
+
+		results = append(results, sarifResult{
+			RuleID:    ruleID,
+			Level:     "error",
+			Message:   sarifMessage{Text: message},
+			Locations: []sarifLocation{{PhysicalLocation: location}},
+		})
+	}
+
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+
+	return sarifReport{
+		Schema:  SARIFSchema,
+		Version: SARIFVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           config.AppName,
+				InformationURI: SecretsReferenceUrl,
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
+
+// secretRuleID returns a rule identifier derived from secret matcher description
+func secretRuleID(description string) string {
+	id := strings.Trim(nonAlphanumericRegex.ReplaceAllString(strings.ToLower(description), "-"), "-")
+	return "secret/" + id
+}
+
+// sortedSecretHits returns secret hits sorted by file, line, service and env var
+func sortedSecretHits(hits []secretHit) []secretHit {
+	out := append([]secretHit{}, hits...)
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.file != b.file {
+			return a.file < b.file
+		}
+		if a.line != b.line {
+			return a.line < b.line
+		}
+		if a.svcName != b.svcName {
+			return a.svcName < b.svcName
+		}
+		return a.envVar < b.envVar
+	})
+	return out
+}
+
+// envVarLines returns line numbers of env vars defined by each service in a compose file,
+// keyed by service name and env var name.
+func envVarLines(file string) (map[string]map[string]int, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	out := map[string]map[string]int{}
+	if len(doc.Content) == 0 {
+		return out, nil
+	}
+
+	services := mappingValue(doc.Content[0], "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return out, nil
+	}
+
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		name, svc := services.Content[i].Value, services.Content[i+1]
+		lines := map[string]int{}
+
+		env := mappingValue(svc, "environment")
+		switch {
+		case env == nil:
+		case env.Kind == yaml.MappingNode:
+			for j := 0; j+1 < len(env.Content); j += 2 {
+				lines[env.Content[j].Value] = env.Content[j].Line
+			}
+		case env.Kind == yaml.SequenceNode:
+			for _, item := range env.Content {
+				key := strings.SplitN(item.Value, "=", 2)[0]
+				lines[key] = item.Line
+			}
+		}
+
+		out[name] = lines
+	}
+
+	return out, nil
+}
+
+// mappingValue returns a value node of a key in yaml mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,474 @@
+/**
+ * Copyright 2021 Appvia Ltd <info@appvia.io>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tako_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	kmd "github.com/appvia/komando"
+	"github.com/appvia/tako/pkg/tako"
+	"github.com/appvia/tako/pkg/tako/config"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Secrets detection", func() {
+	var workingDir = "./testdata/detect-secrets-allowlist"
+
+	Describe("SecretMatchers", func() {
+		It("returns default matchers followed by extra matchers from the referenced file", func() {
+			manifest, err := tako.LoadManifest(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			matchers, err := manifest.SecretMatchers(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matchers).To(HaveLen(len(config.SecretMatchers) + 1))
+			Expect(matchers[len(matchers)-1]).To(HaveKeyWithValue("description", "an ACME internal reference"))
+		})
+
+		It("returns default matchers when no matchers file is referenced", func() {
+			matchers, err := (&tako.Manifest{}).SecretMatchers(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(matchers).To(Equal(config.SecretMatchers))
+		})
+
+		Context("with invalid matchers file", func() {
+			var dir string
+
+			BeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "tako-matchers")
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				_ = os.RemoveAll(dir)
+			})
+
+			It("returns an error for unsupported matcher type", func() {
+				content := "- type: magic\n  description: a Magic secret\n"
+				Expect(ioutil.WriteFile(filepath.Join(dir, "matchers.yaml"), []byte(content), 0600)).To(Succeed())
+
+				manifest := &tako.Manifest{Secrets: &tako.SecretsDetection{Matchers: "matchers.yaml"}}
+				_, err := manifest.SecretMatchers(dir)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(`secret matcher "a Magic secret" has unsupported type "magic"`))
+			})
+
+			It("returns an error for invalid match expression", func() {
+				content := "- part: value\n  match: '(unclosed'\n  description: a Broken secret\n"
+				Expect(ioutil.WriteFile(filepath.Join(dir, "matchers.yaml"), []byte(content), 0600)).To(Succeed())
+
+				manifest := &tako.Manifest{Secrets: &tako.SecretsDetection{Matchers: "matchers.yaml"}}
+				_, err := manifest.SecretMatchers(dir)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring(`secret matcher "a Broken secret" has invalid match expression`))
+			})
+
+			It("returns an error when the file doesn't exist", func() {
+				manifest := &tako.Manifest{Secrets: &tako.SecretsDetection{Matchers: "missing.yaml"}}
+				_, err := manifest.SecretMatchers(dir)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("cannot read secret matchers file"))
+			})
+		})
+	})
+
+	Describe("allowlist", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "tako-allowlist")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(dir)
+		})
+
+		It("requires a justification for each entry", func() {
+			content := "secrets:\n  allowlist:\n    - service: db\n      env: MYSQL_USER\n"
+			Expect(ioutil.WriteFile(filepath.Join(dir, tako.ManifestFilename), []byte(content), 0600)).To(Succeed())
+
+			_, err := tako.LoadManifest(dir)
+			Expect(err).To(MatchError("secrets allowlist entry for service db env var MYSQL_USER requires a justification"))
+		})
+	})
+
+	Describe("ValidateSources", func() {
+		var (
+			runner *tako.RenderRunner
+			log    kmd.UILog
+		)
+
+		BeforeEach(func() {
+			var ui kmd.UI
+			ui, log = kmd.FakeUIAndLog()
+			runner = tako.NewRenderRunner(workingDir, tako.WithUI(ui))
+			Expect(runner.LoadProject()).To(Succeed())
+			log.Reset()
+		})
+
+		It("reports token, entropy and custom matcher findings and skips allowed env vars", func() {
+			matchers, err := runner.Manifest().SecretMatchers(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(runner.ValidateSources(runner.Manifest().Sources, matchers)).To(Succeed())
+
+			Expect(log.NextHeader()).To(HaveKeyWithValue("Validating compose sources...", []string{}))
+			Expect(log.NextOutput()).To(HaveKeyWithValue("Detecting secrets in: testdata/detect-secrets-allowlist/docker-compose.yaml", []string{}))
+			Expect(log.NextStep()).To(HaveKeyWithValue(`Analysing service: db`, []string{}))
+			Expect(log.NextStep()).To(HaveKeyWithValue("Warning", []string{`[Detected in service:  db]`}))
+
+			var detectedSecrets []map[string][]string
+			for i := 0; i < 3; i++ {
+				detectedSecrets = append(detectedSecrets, log.NextOutput())
+			}
+			Expect(detectedSecrets).To(ConsistOf(
+				HaveKey("env var [API_TOKEN] - a JSON Web Token"),
+				HaveKey("env var [SIGNING_SALT] - a High entropy string"),
+				HaveKey("env var [ACME_REF] - an ACME internal reference"),
+			))
+		})
+	})
+
+	Describe("ReportSecrets", func() {
+		var (
+			runner    *tako.RenderRunner
+			reportDir string
+			opts      []tako.Options
+		)
+
+		JustBeforeEach(func() {
+			ui, _ := kmd.FakeUIAndLog()
+			runner = tako.NewRenderRunner(workingDir, append(opts, tako.WithUI(ui))...)
+			Expect(runner.LoadProject()).To(Succeed())
+
+			matchers, err := runner.Manifest().SecretMatchers(workingDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runner.ValidateSources(runner.Manifest().Sources, matchers)).To(Succeed())
+		})
+
+		BeforeEach(func() {
+			var err error
+			reportDir, err = ioutil.TempDir("", "secrets-report")
+			Expect(err).NotTo(HaveOccurred())
+			opts = []tako.Options{}
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(reportDir)
+		})
+
+		Context("with JSON report", func() {
+			var reportFile string
+
+			BeforeEach(func() {
+				reportFile = filepath.Join(reportDir, "secrets.json")
+				opts = append(opts, tako.WithSecretsReport(reportFile))
+			})
+
+			It("writes findings with compose file line numbers", func() {
+				Expect(runner.ReportSecrets()).To(Succeed())
+
+				content, err := ioutil.ReadFile(reportFile)
+				Expect(err).NotTo(HaveOccurred())
+
+				var report map[string][]map[string]interface{}
+				Expect(json.Unmarshal(content, &report)).To(Succeed())
+
+				file := "testdata/detect-secrets-allowlist/docker-compose.yaml"
+				Expect(report["findings"]).To(Equal([]map[string]interface{}{
+					{"file": file, "line": float64(7), "service": "db", "envVar": "API_TOKEN", "description": "a JSON Web Token"},
+					{"file": file, "line": float64(8), "service": "db", "envVar": "ACME_REF", "description": "an ACME internal reference"},
+					{"file": file, "line": float64(9), "service": "db", "envVar": "SIGNING_SALT", "description": "a High entropy string"},
+				}))
+			})
+		})
+
+		Context("with SARIF report", func() {
+			var reportFile string
+
+			BeforeEach(func() {
+				reportFile = filepath.Join(reportDir, "secrets.sarif")
+				opts = append(opts, tako.WithSecretsReport(reportFile))
+			})
+
+			It("writes SARIF 2.1.0 results with rules derived from matcher descriptions", func() {
+				Expect(runner.ReportSecrets()).To(Succeed())
+
+				content, err := ioutil.ReadFile(reportFile)
+				Expect(err).NotTo(HaveOccurred())
+
+				var report struct {
+					Version string `json:"version"`
+					Runs    []struct {
+						Tool struct {
+							Driver struct {
+								Name  string `json:"name"`
+								Rules []struct {
+									ID string `json:"id"`
+								} `json:"rules"`
+							} `json:"driver"`
+						} `json:"tool"`
+						Results []struct {
+							RuleID    string `json:"ruleId"`
+							Locations []struct {
+								PhysicalLocation struct {
+									ArtifactLocation struct {
+										URI string `json:"uri"`
+									} `json:"artifactLocation"`
+									Region struct {
+										StartLine int `json:"startLine"`
+									} `json:"region"`
+								} `json:"physicalLocation"`
+							} `json:"locations"`
+						} `json:"results"`
+					} `json:"runs"`
+				}
+				Expect(json.Unmarshal(content, &report)).To(Succeed())
+
+				Expect(report.Version).To(Equal("2.1.0"))
+				Expect(report.Runs).To(HaveLen(1))
+				Expect(report.Runs[0].Tool.Driver.Name).To(Equal(config.AppName))
+				Expect(report.Runs[0].Tool.Driver.Rules).To(HaveLen(3))
+				Expect(report.Runs[0].Results).To(HaveLen(3))
+
+				result := report.Runs[0].Results[0]
+				Expect(result.RuleID).To(Equal("secret/a-json-web-token"))
+				Expect(result.Locations[0].PhysicalLocation.ArtifactLocation.URI).To(Equal("testdata/detect-secrets-allowlist/docker-compose.yaml"))
+				Expect(result.Locations[0].PhysicalLocation.Region.StartLine).To(Equal(7))
+			})
+		})
+
+		Context("with fail on secrets enabled", func() {
+			BeforeEach(func() {
+				opts = append(opts, tako.WithFailOnSecrets(true))
+			})
+
+			It("returns secrets detected error", func() {
+				err := runner.ReportSecrets()
+				Expect(errors.Is(err, tako.ErrSecretsDetected)).To(BeTrue())
+				Expect(err.Error()).To(ContainSubstring("3 potential secret(s) found"))
+			})
+		})
+
+		Context("with fail on secrets disabled", func() {
+			It("succeeds", func() {
+				Expect(runner.ReportSecrets()).To(Succeed())
+			})
+		})
+	})
+
+	Describe("ValidateRenderedManifests", func() {
+		var (
+			renderedDir string
+			reportFile  string
+			opts        []tako.Options
+			runner      *tako.RenderRunner
+			log         kmd.UILog
+		)
+
+		BeforeEach(func() {
+			var err error
+			renderedDir, err = ioutil.TempDir("", "rendered")
+			Expect(err).NotTo(HaveOccurred())
+			reportFile = filepath.Join(renderedDir, "secrets.json")
+			opts = []tako.Options{tako.WithSecretsReport(reportFile)}
+		})
+
+		JustBeforeEach(func() {
+			var ui kmd.UI
+			ui, log = kmd.FakeUIAndLog()
+			runner = tako.NewRenderRunner("./testdata/detect-secrets-rendered", append(opts,
+				tako.WithUI(ui),
+				tako.WithOutputDir(renderedDir),
+				tako.WithAdditionalManifests([]string{
+					"testdata/detect-secrets-rendered/additional/config.yaml",
+					"testdata/detect-secrets-rendered/additional/secret.yaml",
+				}),
+			)...)
+			Expect(runner.LoadProject()).To(Succeed())
+			_, err := runner.RenderFromComposeToK8sManifests()
+			Expect(err).NotTo(HaveOccurred())
+			log.Reset()
+
+			Expect(runner.ValidateRenderedManifests(config.SecretMatchers)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(renderedDir)
+		})
+
+		It("reports secrets found in ConfigMap data, annotations and container args and skips allowed keys and Secrets", func() {
+			Expect(log.NextHeader()).To(HaveKeyWithValue("Validating rendered manifests...", []string{}))
+
+			Expect(runner.ReportSecrets()).To(Succeed())
+			content, err := ioutil.ReadFile(reportFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			var report map[string][]map[string]interface{}
+			Expect(json.Unmarshal(content, &report)).To(Succeed())
+
+			var findings []string
+			for _, f := range report["findings"] {
+				findings = append(findings, fmt.Sprintf("%s %s %s %s: %s", f["service"], f["object"], f["field"], f["envVar"], f["description"]))
+			}
+			Expect(findings).To(ConsistOf(
+				"api Deployment/api args db-url: a URL with embedded credentials",
+				"shared-config ConfigMap/shared-config annotations example.com/signature: a High entropy string",
+				"shared-config ConfigMap/shared-config data MAPS_API_KEY: a Google API key",
+			))
+		})
+
+		Context("with fail on secrets enabled", func() {
+			BeforeEach(func() {
+				opts = append(opts, tako.WithFailOnSecrets(true))
+			})
+
+			It("returns secrets detected error", func() {
+				err := runner.ReportSecrets()
+				Expect(errors.Is(err, tako.ErrSecretsDetected)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("ValidateRenderedManifests after compose sources validation", func() {
+		var (
+			renderedDir string
+			reportFile  string
+			runner      *tako.RenderRunner
+		)
+
+		BeforeEach(func() {
+			var err error
+			renderedDir, err = ioutil.TempDir("", "rendered")
+			Expect(err).NotTo(HaveOccurred())
+			reportFile = filepath.Join(renderedDir, "secrets.json")
+
+			ui, _ := kmd.FakeUIAndLog()
+			runner = tako.NewRenderRunner("./testdata/detect-secrets-rendered-services",
+				tako.WithUI(ui),
+				tako.WithOutputDir(renderedDir),
+				tako.WithSecretsReport(reportFile),
+				tako.WithAdditionalManifests([]string{
+					"testdata/detect-secrets-rendered-services/additional/api-env.yaml",
+					"testdata/detect-secrets-rendered-services/additional/shared-env.yaml",
+					"testdata/detect-secrets-rendered-services/additional/web-env.yaml",
+				}),
+			)
+			Expect(runner.LoadProject()).To(Succeed())
+			Expect(runner.ValidateEnvSources(config.SecretMatchers)).To(Succeed())
+			_, err = runner.RenderFromComposeToK8sManifests()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runner.ValidateRenderedManifests(config.SecretMatchers)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(renderedDir)
+		})
+
+		It("only skips env vars already reported for the same service", func() {
+			Expect(runner.ReportSecrets()).To(Succeed())
+			content, err := ioutil.ReadFile(reportFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			var report map[string][]map[string]interface{}
+			Expect(json.Unmarshal(content, &report)).To(Succeed())
+
+			var findings []string
+			for _, f := range report["findings"] {
+				findings = append(findings, fmt.Sprintf("%s %v %s", f["service"], f["object"], f["envVar"]))
+			}
+			Expect(findings).To(ConsistOf(
+				"api <nil> DB_PASSWORD",
+				"web Deployment/web DB_PASSWORD",
+				"web ConfigMap/web-env DB_PASSWORD",
+			))
+		})
+
+		It("skips ConfigMap data already reported for the service labelling or referencing the ConfigMap", func() {
+			Expect(runner.ReportSecrets()).To(Succeed())
+			content, err := ioutil.ReadFile(reportFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			var report map[string][]map[string]interface{}
+			Expect(json.Unmarshal(content, &report)).To(Succeed())
+
+			var objects []interface{}
+			for _, f := range report["findings"] {
+				objects = append(objects, f["object"])
+			}
+			Expect(objects).NotTo(ContainElement("ConfigMap/api-env"))
+			Expect(objects).NotTo(ContainElement("ConfigMap/shared-env"))
+		})
+	})
+
+	Describe("ValidateRenderedManifests of kustomize overlays", func() {
+		var (
+			renderedDir string
+			reportFile  string
+			runner      *tako.RenderRunner
+		)
+
+		BeforeEach(func() {
+			var err error
+			renderedDir, err = ioutil.TempDir("", "rendered")
+			Expect(err).NotTo(HaveOccurred())
+			reportFile = filepath.Join(renderedDir, "secrets.json")
+
+			ui, _ := kmd.FakeUIAndLog()
+			runner = tako.NewRenderRunner("./testdata/detect-secrets-rendered-kustomize",
+				tako.WithUI(ui),
+				tako.WithManifestFormat("kustomize"),
+				tako.WithOutputDir(renderedDir),
+				tako.WithSecretsReport(reportFile),
+			)
+			Expect(runner.LoadProject()).To(Succeed())
+			_, err = runner.RenderFromComposeToK8sManifests()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(runner.ValidateRenderedManifests(config.SecretMatchers)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(renderedDir)
+		})
+
+		It("reports secrets present in a single overlay against its kustomization", func() {
+			Expect(runner.ReportSecrets()).To(Succeed())
+			content, err := ioutil.ReadFile(reportFile)
+			Expect(err).NotTo(HaveOccurred())
+
+			var report map[string][]map[string]interface{}
+			Expect(json.Unmarshal(content, &report)).To(Succeed())
+
+			var findings []string
+			for _, f := range report["findings"] {
+				findings = append(findings, fmt.Sprintf("%s %s %s %s", f["file"], f["object"], f["field"], f["envVar"]))
+			}
+			Expect(findings).To(ConsistOf(
+				filepath.Join(renderedDir, "overlays", "prod", "kustomization.yaml") + " Service/api annotations example.com/db-url",
+			))
+		})
+	})
+})
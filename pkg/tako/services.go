@@ -18,7 +18,6 @@ package tako
 
 import (
 	"encoding/json"
-	"regexp"
 
 	"github.com/appvia/tako/pkg/tako/config"
 	composego "github.com/compose-spec/compose-go/types"
@@ -70,23 +69,20 @@ func (s Services) Set() map[string]bool {
 	return out
 }
 
-func (sc ServiceConfig) detectSecretsInEnvVars(matchers []map[string]string) []secretHit {
+// detectSecretsInEnvVars detects secrets in service env vars. Each env var is reported once,
+// by the first detector in order that matches it.
+func (sc ServiceConfig) detectSecretsInEnvVars(detectors []config.SecretDetector) []secretHit {
 	var matches []secretHit
 
 	for key, val := range sc.Environment {
-		for _, matcher := range matchers {
-			var candidate string
-
-			if matcher["part"] == config.PartIdentifier {
-				candidate = key
-			}
-
-			if matcher["part"] == matcher[config.PartIdentifier] {
-				candidate = *val
-			}
+		var value string
+		if val != nil {
+			value = *val
+		}
 
-			if found := regexp.MustCompile(matcher["match"]).MatchString(candidate); found {
-				matches = append(matches, secretHit{sc.Name, key, matcher["description"]})
+		for _, detector := range detectors {
+			if detector.Detect(key, value) {
+				matches = append(matches, secretHit{svcName: sc.Name, envVar: key, description: detector.Describe()})
 				break
 			}
 		}
@@ -165,3 +165,8 @@ func (s *Sources) getWorkingDir() string {
 func (s *Sources) toComposeProject() (*ComposeProject, error) {
 	return NewComposeProject(s.Files)
 }
+
+// toComposeProjectWithEnvFiles returns the sources as a ComposeProject keeping service env_file references
+func (s *Sources) toComposeProjectWithEnvFiles() (*ComposeProject, error) {
+	return newComposeProject(s.Files, true)
+}
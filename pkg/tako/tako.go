@@ -19,6 +19,11 @@ package tako
 const (
 	// SandboxEnv is a default environment name
 	SandboxEnv = "dev"
+
+	// OutputFormatYAML is a default serialisation format of rendered manifests
+	OutputFormatYAML = "yaml"
+	// OutputFormatJSON is a JSON serialisation format of rendered manifests
+	OutputFormatJSON = "json"
 )
 
 var (
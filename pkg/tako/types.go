@@ -34,6 +34,12 @@ type runConfig struct {
 	ManifestFormat string
 	// ManifestsAsSingleFile indicates whether to render all manifests into a single file
 	ManifestsAsSingleFile bool
+	// ManifestsToStdout indicates whether to print manifests to stdout instead of writing them to disk
+	ManifestsToStdout bool
+	// ManifestsOut is a writer manifests are printed to when ManifestsToStdout is set. By default os.Stdout.
+	ManifestsOut io.Writer
+	// OutputFormat is a serialisation format of the output manifests, yaml or json
+	OutputFormat string
 	// AdditionalManifests is a list of additional manifests that should be added to the generated manifests set
 	AdditionalManifests []string
 	// OutputDir is a directory where to store the generated manifests
@@ -63,6 +69,10 @@ type runConfig struct {
 	// Output directory structure will reflect that of the source directory tree.
 	// If patch output directory is not specified then manifests will be overriden in the source directory.
 	PatchOutputDir string
+	// FailOnSecrets indicates whether to fail when secrets are detected in compose sources.
+	FailOnSecrets bool
+	// SecretsReport is a file where detected secrets report should be written, in JSON or SARIF format.
+	SecretsReport string
 }
 
 // Options helps configure running project commands
@@ -103,6 +113,10 @@ type Project struct {
 	// eventHandler is the event handler.
 	eventHandler EventHandler
 	ctx          context.Context
+	// secretHits are secrets detected in compose sources during validation.
+	secretHits []secretHit
+	// rendered are K8s manifests contents produced during render, keyed by file path.
+	rendered map[string][]byte
 }
 
 // InitRunner runs the required sequences to initialise a project.
@@ -127,11 +141,27 @@ type PatchRunner struct {
 
 // Manifest contains the tracked project's docker-compose sources and deployment environments
 type Manifest struct {
-	Id           string       `yaml:"id,omitempty" json:"id,omitempty"`
-	Sources      *Sources     `yaml:"compose,omitempty" json:"compose,omitempty"`
-	Environments Environments `yaml:"environments,omitempty" json:"environments,omitempty"`
-	Skaffold     string       `yaml:"skaffold,omitempty" json:"skaffold,omitempty"`
-	UI           kmd.UI       `yaml:"-" json:"-"`
+	Id           string            `yaml:"id,omitempty" json:"id,omitempty"`
+	Sources      *Sources          `yaml:"compose,omitempty" json:"compose,omitempty"`
+	Environments Environments      `yaml:"environments,omitempty" json:"environments,omitempty"`
+	Skaffold     string            `yaml:"skaffold,omitempty" json:"skaffold,omitempty"`
+	Secrets      *SecretsDetection `yaml:"secrets,omitempty" json:"secrets,omitempty"`
+	UI           kmd.UI            `yaml:"-" json:"-"`
+}
+
+// SecretsDetection configures detection of secrets in compose sources
+type SecretsDetection struct {
+	// Matchers is a path to a YAML file with extra secret matchers, relative to the project directory
+	Matchers string `yaml:"matchers,omitempty" json:"matchers,omitempty"`
+	// Allowlist suppresses secret findings for specific service env vars
+	Allowlist []SecretAllowance `yaml:"allowlist,omitempty" json:"allowlist,omitempty"`
+}
+
+// SecretAllowance suppresses secret findings for a service env var
+type SecretAllowance struct {
+	Service       string `yaml:"service" json:"service"`
+	EnvVar        string `yaml:"env" json:"env"`
+	Justification string `yaml:"justification" json:"justification"`
 }
 
 // Sources tracks a project's docker-compose sources
@@ -153,10 +183,11 @@ type Environment struct {
 // composeOverride augments a compose project with an extension and env vars to produce
 // k8s deployment config
 type composeOverride struct {
-	Version  string   `yaml:"version,omitempty" json:"version,omitempty" diff:"version"`
-	Services Services `json:"services" diff:"services"`
-	Volumes  Volumes  `yaml:",omitempty" json:"volumes,omitempty" diff:"volumes"`
-	UI       kmd.UI   `yaml:"-" json:"-"`
+	Version    string                 `yaml:"version,omitempty" json:"version,omitempty" diff:"version"`
+	Services   Services               `json:"services" diff:"services"`
+	Volumes    Volumes                `yaml:",omitempty" json:"volumes,omitempty" diff:"volumes"`
+	Extensions map[string]interface{} `yaml:",inline" json:"-"`
+	UI         kmd.UI                 `yaml:"-" json:"-"`
 }
 
 // ComposeProject wrapper around a compose-go Project. It also provides the original
@@ -178,6 +209,10 @@ type secretHit struct {
 	svcName     string
 	envVar      string
 	description string
+	file        string
+	line        int
+	object      string
+	field       string
 }
 
 // Services is a list of ServiceConfig